@@ -2,7 +2,6 @@ package main
 
 import (
 	"context"
-	"encoding/json"
 	"fmt"
 	"os"
 	"time"
@@ -13,24 +12,26 @@ import (
 
 // BackupResult holds all identity events for export.
 type BackupResult struct {
-	Npub   string             `json:"npub"`
-	Pubkey string             `json:"pubkey"`
-	Events []BackupEvent      `json:"events"`
-	Meta   BackupMeta         `json:"meta"`
+	SchemaVersion int           `json:"schema_version"`
+	Npub          string        `json:"npub"`
+	Pubkey        string        `json:"pubkey"`
+	Events        []BackupEvent `json:"events"`
+	Meta          BackupMeta    `json:"meta"`
 }
 
 // BackupEvent wraps a nostr event with its kind label for readability.
 type BackupEvent struct {
-	Kind      int              `json:"kind"`
-	KindLabel string           `json:"kind_label"`
-	Event     *nostr.Event     `json:"event"`
+	Kind      int          `json:"kind"`
+	KindLabel string       `json:"kind_label"`
+	Event     *nostr.Event `json:"event"`
 }
 
 // BackupMeta holds metadata about the backup itself.
 type BackupMeta struct {
-	CreatedAt string `json:"created_at"`
-	Version   string `json:"version"`
-	Relays    []string `json:"relays_queried"`
+	CreatedAt  string         `json:"created_at"`
+	Version    string         `json:"version"`
+	Relays     []string       `json:"relays_queried"`
+	ExtraKinds []KindPresence `json:"extra_kinds,omitempty"` // with --extra-kinds
 }
 
 // kindLabels maps event kinds to human-readable labels.
@@ -47,7 +48,7 @@ var kindLabels = map[int]string{
 // backupKinds is the ordered list of kinds to back up.
 var backupKinds = []int{0, 3, 10002, 10050, 10019, 17375, 37375}
 
-func runBackup(target string, quiet bool, relays []string) {
+func runBackup(target string, quiet bool, relays []string, extraKinds []int) {
 	if target == "" {
 		fatal("usage: nihao backup <npub|nip05>")
 	}
@@ -82,9 +83,10 @@ func runBackup(target string, quiet bool, relays []string) {
 	}
 
 	result := BackupResult{
-		Npub:   npub,
-		Pubkey: pk.Hex(),
-		Events: []BackupEvent{}, // empty slice, not nil (ensures JSON "events": [] not null)
+		SchemaVersion: schemaVersion,
+		Npub:          npub,
+		Pubkey:        pk.Hex(),
+		Events:        []BackupEvent{}, // empty slice, not nil (ensures JSON "events": [] not null)
 		Meta: BackupMeta{
 			CreatedAt: time.Now().UTC().Format(time.RFC3339),
 			Version:   version,
@@ -120,11 +122,39 @@ func runBackup(target string, quiet bool, relays []string) {
 		}
 	}
 
+	// Extra kinds may be addressable or regular, so every event is kept,
+	// not just the latest
+	for _, kind := range extraKinds {
+		kindCtx, kindCancel := context.WithTimeout(ctx, 10*time.Second)
+		events := fetchKindEvents(kindCtx, checkRelays, pk, kind)
+		kindCancel()
+		result.Meta.ExtraKinds = append(result.Meta.ExtraKinds, kindPresence(kind, events))
+		label := kindLabels[kind]
+		if label == "" {
+			label = fmt.Sprintf("kind_%d", kind)
+		}
+		for i := range events {
+			result.Events = append(result.Events, BackupEvent{Kind: kind, KindLabel: label, Event: &events[i]})
+		}
+		found += len(events)
+		if quiet {
+			continue
+		}
+		if len(events) > 0 {
+			fmt.Fprintf(os.Stderr, "  ✓ kind %d (%s) — %d event(s)\n", kind, label, len(events))
+		} else {
+			fmt.Fprintf(os.Stderr, "  · kind %d (%s) — not found\n", kind, label)
+		}
+	}
+
 	if !quiet {
 		fmt.Fprintf(os.Stderr, "\n  📦 %d event(s) backed up\n", found)
 	}
 
 	// Always output JSON to stdout (this IS the backup)
-	out, _ := json.MarshalIndent(result, "", "  ")
+	out, err := marshalVersioned(result)
+	if err != nil {
+		fatal("%s", err)
+	}
 	fmt.Println(string(out))
 }
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+)
+
+// BaselineDiff is how a check compares to a stored previous result.
+type BaselineDiff struct {
+	PreviousScore int            `json:"previous_score"`
+	Regressions   []StatusChange `json:"regressions"`
+	Improvements  []StatusChange `json:"improvements"`
+}
+
+// StatusChange is one check whose status moved since the baseline.
+type StatusChange struct {
+	Check  string `json:"check"`
+	From   string `json:"from"`
+	To     string `json:"to"`
+	Detail string `json:"detail,omitempty"`
+}
+
+// loadBaseline reads a previous `nihao check --json` result.
+func loadBaseline(path string) (*CheckResult, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var r CheckResult
+	if err := json.Unmarshal(data, &r); err != nil {
+		return nil, fmt.Errorf("not a check result: %w", err)
+	}
+	return &r, nil
+}
+
+// writeBaseline stores a check result as the new baseline, without its own
+// comparison to the old one.
+func writeBaseline(path string, r CheckResult) error {
+	r.Baseline = nil
+	out, err := json.MarshalIndent(r, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, append(out, '\n'), 0644)
+}
+
+// compareBaseline finds the checks whose status got worse or better since
+// the baseline. Only checks present in both are compared: a warning that
+// was already there isn't a regression, and neither is a check that's new
+// (a newer nihao, or a conditional check like paid_relays).
+func compareBaseline(baseline, current CheckResult) BaselineDiff {
+	diff := BaselineDiff{PreviousScore: baseline.Score, Regressions: []StatusChange{}, Improvements: []StatusChange{}}
+	for _, c := range current.Checks {
+		before := baseline.status(c.Name)
+		if before == "" || before == c.Status {
+			continue
+		}
+		change := StatusChange{Check: c.Name, From: before, To: c.Status, Detail: c.Detail}
+		if statusRank[c.Status] < statusRank[before] {
+			diff.Regressions = append(diff.Regressions, change)
+		} else {
+			diff.Improvements = append(diff.Improvements, change)
+		}
+	}
+	return diff
+}
+
+func printBaselineDiff(d BaselineDiff, score int) {
+	fmt.Println()
+	if len(d.Regressions) == 0 {
+		fmt.Printf("  📏 no regressions since baseline (score %d → %d)\n", d.PreviousScore, score)
+	} else {
+		fmt.Printf("  📉 %d regression(s) since baseline (score %d → %d)\n", len(d.Regressions), d.PreviousScore, score)
+	}
+	for _, c := range d.Regressions {
+		line := fmt.Sprintf("  ❌ %s: %s → %s", c.Check, c.From, c.To)
+		if c.Detail != "" {
+			line += ": " + c.Detail
+		}
+		fmt.Println(line)
+	}
+	for _, c := range d.Improvements {
+		fmt.Printf("  📈 %s: %s → %s\n", c.Check, c.From, c.To)
+	}
+}
@@ -0,0 +1,176 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+)
+
+// BatchManifest is the machine-readable output of `nihao setup --batch`.
+type BatchManifest struct {
+	CreatedAt  string        `json:"created_at"`
+	Version    string        `json:"version"`
+	Identities []SetupResult `json:"identities"`
+}
+
+// batchColumns are the CSV header names understood by --batch. Every row
+// overrides the matching setup flag for that identity; empty cells fall
+// back to the flag (or default).
+var batchColumns = map[string]bool{
+	"name":      true,
+	"about":     true,
+	"picture":   true,
+	"banner":    true,
+	"nip05":     true,
+	"lud16":     true,
+	"relays":    true,
+	"dm_relays": true,
+	"hello_at":  true,
+}
+
+// parseBatchCSV reads a CSV with a header row and returns one map per row,
+// keyed by lowercased column name. Unknown columns are an error so typos
+// don't silently produce identities with missing fields.
+func parseBatchCSV(r io.Reader) ([]map[string]string, error) {
+	cr := csv.NewReader(r)
+	cr.TrimLeadingSpace = true
+	cr.Comment = '#'
+
+	header, err := cr.Read()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read header: %w", err)
+	}
+	for i, h := range header {
+		header[i] = strings.ToLower(strings.TrimSpace(h))
+		if !batchColumns[header[i]] {
+			return nil, fmt.Errorf("unknown column %q", h)
+		}
+	}
+
+	var rows []map[string]string
+	for {
+		rec, err := cr.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		row := make(map[string]string, len(header))
+		for i, v := range rec {
+			if i < len(header) {
+				row[header[i]] = strings.TrimSpace(v)
+			}
+		}
+		rows = append(rows, row)
+	}
+	return rows, nil
+}
+
+// splitRelayCell splits a relays cell. Relays may be separated by spaces,
+// semicolons, or commas (when the cell is quoted).
+func splitRelayCell(cell string) []string {
+	return strings.FieldsFunc(cell, func(r rune) bool {
+		return r == ' ' || r == ';' || r == ','
+	})
+}
+
+// applyBatchRow returns a copy of base with the row's cells applied.
+func applyBatchRow(base setupOpts, row map[string]string) setupOpts {
+	opts := base
+	set := func(dst *string, col string) {
+		if v := row[col]; v != "" {
+			*dst = v
+		}
+	}
+	set(&opts.name, "name")
+	set(&opts.about, "about")
+	set(&opts.picture, "picture")
+	set(&opts.banner, "banner")
+	set(&opts.nip05, "nip05")
+	set(&opts.lud16, "lud16")
+	if v := row["relays"]; v != "" {
+		opts.relays = splitRelayCell(v)
+	}
+	if v := row["dm_relays"]; v != "" {
+		opts.dmRelays = splitRelayCell(v)
+	}
+	if v := row["hello_at"]; v != "" {
+		opts.helloAt = parseHelloAt("hello_at", v, time.Now())
+	}
+	return opts
+}
+
+// runBatchSetup creates one identity per CSV row and prints a JSON
+// manifest to stdout. Progress goes to stderr.
+func runBatchSetup(opts setupOpts) {
+	if opts.keySource().isSet() {
+		fatal("--batch creates fresh keys; it can't be combined with --sec, --stdin, or --sec-cmd")
+	}
+	if opts.nwc != "" {
+		fatal("--nwc is one wallet connection; it can't be shared across a --batch")
+	}
+	if opts.nsecFile != "" && !strings.Contains(opts.nsecFile, "{name}") && !strings.Contains(opts.nsecFile, "{npub}") {
+		fatal("--nsec-file in --batch mode needs a {name} or {npub} placeholder (e.g. keys/{npub}.key)")
+	}
+
+	if opts.ncryptsec && opts.passphrase == "" {
+		pass, err := readPassphrase(opts.passphraseFD, true)
+		if err != nil {
+			fatal("ncryptsec encryption failed: %s", err)
+		}
+		opts.passphrase = pass
+	}
+
+	f, err := os.Open(opts.batch)
+	if err != nil {
+		fatal("%s", err)
+	}
+	rows, err := parseBatchCSV(f)
+	f.Close()
+	if err != nil {
+		fatal("invalid batch file %s: %s", opts.batch, err)
+	}
+	if len(rows) == 0 {
+		fatal("batch file %s has no rows", opts.batch)
+	}
+
+	if !opts.quiet {
+		fmt.Fprintf(os.Stderr, "nihao batch 👥 %d identities from %s\n\n", len(rows), opts.batch)
+	}
+
+	manifest := BatchManifest{
+		CreatedAt:  time.Now().UTC().Format(time.RFC3339),
+		Version:    version,
+		Identities: []SetupResult{},
+	}
+	var held []*heldNote
+	for i, row := range rows {
+		rowOpts := applyBatchRow(opts, row)
+		rowOpts.quiet = true
+		if row["hello_at"] == "" && !opts.helloAt.IsZero() {
+			rowOpts.helloAt = opts.helloAt.Add(time.Duration(i) * helloStagger)
+		}
+		result := setupIdentity(rowOpts)
+		manifest.Identities = append(manifest.Identities, result)
+		if result.held != nil {
+			held = append(held, result.held)
+		}
+		if !opts.quiet {
+			fmt.Fprintf(os.Stderr, "  ✓ [%d/%d] %s %s\n", i+1, len(rows), result.Profile.Name, result.Npub)
+		}
+	}
+
+	if !opts.quiet {
+		fmt.Fprintf(os.Stderr, "\n  👥 %d identities created\n", len(manifest.Identities))
+		printRequestBudget(os.Stderr)
+	}
+
+	out, _ := json.MarshalIndent(manifest, "", "  ")
+	fmt.Println(string(out))
+	publishHeld(held, opts.quiet)
+}
@@ -0,0 +1,282 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"fiatjaf.com/nostr"
+	"fiatjaf.com/nostr/nip19"
+	"github.com/btcsuite/btcd/btcutil/bech32"
+)
+
+// maxRelayHints caps the relay hints nihao puts in the nprofiles and
+// nevents it hands out: clients only try the first few, and every hint
+// makes the code longer.
+const maxRelayHints = 3
+
+// Entity is a decoded NIP-19 code: what it points at, in hex, with any
+// relay hints.
+type Entity struct {
+	Type       string   `json:"type"` // npub, nsec, note, nprofile, nevent, or naddr
+	Pubkey     string   `json:"pubkey,omitempty"`
+	Secret     string   `json:"secret,omitempty"` // nsec only
+	ID         string   `json:"id,omitempty"`
+	Kind       *int     `json:"kind,omitempty"`
+	Identifier *string  `json:"identifier,omitempty"` // naddr "d" tag, may be empty
+	Relays     []string `json:"relays,omitempty"`
+}
+
+// decodeEntity decodes a NIP-19 code, with or without a nostr: prefix.
+func decodeEntity(code string) (Entity, error) {
+	code = strings.TrimPrefix(strings.TrimSpace(code), "nostr:")
+	prefix, value, err := nip19.Decode(code)
+	if err != nil {
+		return Entity{}, fmt.Errorf("%s: %w", code, err)
+	}
+	e := Entity{Type: prefix}
+	switch v := value.(type) {
+	case nostr.PubKey:
+		e.Pubkey = v.Hex()
+	case nostr.SecretKey:
+		e.Secret = v.Hex()
+		e.Pubkey = v.Public().Hex()
+	case nostr.ProfilePointer:
+		e.Pubkey = v.PublicKey.Hex()
+		e.Relays = v.Relays
+	case nostr.EventPointer:
+		e.ID = v.ID.Hex()
+		if v.Author != nostr.ZeroPK {
+			e.Pubkey = v.Author.Hex()
+		}
+		if v.Kind != 0 {
+			kind := int(v.Kind)
+			e.Kind = &kind
+		}
+		e.Relays = v.Relays
+	case nostr.EntityPointer:
+		kind := int(v.Kind)
+		e.Pubkey, e.Kind, e.Identifier, e.Relays = v.PublicKey.Hex(), &kind, &v.Identifier, v.Relays
+	default:
+		return Entity{}, fmt.Errorf("%s: unsupported type %s", code, prefix)
+	}
+	return e, nil
+}
+
+// encodeOpts are the extra fields nprofile, nevent, and naddr carry.
+type encodeOpts struct {
+	relays     []string
+	author     string
+	kind       int
+	identifier string
+}
+
+// encodeEntity encodes value as a NIP-19 code of type typ. Pubkeys and
+// event IDs may be given in hex or as another NIP-19 code (npub, note,
+// nevent), so e.g. an npub can be turned into an nprofile with hints.
+func encodeEntity(typ, value string, o encodeOpts) (string, error) {
+	value = strings.TrimPrefix(strings.TrimSpace(value), "nostr:")
+	for _, r := range o.relays {
+		if normalizeRelayURL(r) == "" {
+			return "", fmt.Errorf("relay hint %q isn't a ws:// or wss:// URL", r)
+		}
+	}
+	switch typ {
+	case "npub", "nprofile", "naddr":
+		pk, err := entityPubkey(value)
+		if err != nil {
+			return "", err
+		}
+		switch typ {
+		case "npub":
+			return nip19.EncodeNpub(pk), nil
+		case "nprofile":
+			return nip19.EncodeNprofile(pk, o.relays), nil
+		}
+		if o.kind <= 0 {
+			return "", fmt.Errorf("naddr needs --kind")
+		}
+		return nip19.EncodeNaddr(pk, nostr.Kind(o.kind), o.identifier, o.relays), nil
+	case "nsec":
+		sk, err := nostr.SecretKeyFromHex(value)
+		if err != nil {
+			return "", fmt.Errorf("nsec needs a 64-character hex secret key")
+		}
+		return nip19.EncodeNsec(sk), nil
+	case "note", "nevent":
+		id, err := entityID(value)
+		if err != nil {
+			return "", err
+		}
+		if typ == "note" {
+			return encodeNote(id), nil
+		}
+		var author nostr.PubKey
+		if o.author != "" {
+			if author, err = entityPubkey(o.author); err != nil {
+				return "", fmt.Errorf("--author: %w", err)
+			}
+		}
+		return nip19.EncodeNevent(id, o.relays, author), nil
+	}
+	return "", fmt.Errorf("unknown type %q (use npub, nsec, note, nprofile, nevent, or naddr)", typ)
+}
+
+// encodeNote encodes a bare event ID as a note, which nip19 only decodes.
+func encodeNote(id nostr.ID) string {
+	bits5, _ := bech32.ConvertBits(id[:], 8, 5, true)
+	note, _ := bech32.Encode("note", bits5)
+	return note
+}
+
+// entityPubkey reads a pubkey from hex, an npub, or an nprofile.
+func entityPubkey(value string) (nostr.PubKey, error) {
+	if strings.HasPrefix(value, "nprofile1") {
+		e, err := decodeEntity(value)
+		if err != nil {
+			return nostr.PubKey{}, err
+		}
+		value = e.Pubkey
+	}
+	pk, err := parsePubkey(value)
+	if err != nil {
+		return nostr.PubKey{}, fmt.Errorf("%q isn't a hex pubkey, npub, or nprofile", value)
+	}
+	return pk, nil
+}
+
+// entityID reads an event ID from hex, a note, or an nevent.
+func entityID(value string) (nostr.ID, error) {
+	if strings.HasPrefix(value, "note1") || strings.HasPrefix(value, "nevent1") {
+		e, err := decodeEntity(value)
+		if err != nil {
+			return nostr.ID{}, err
+		}
+		value = e.ID
+	}
+	id, err := nostr.IDFromHex(value)
+	if err != nil {
+		return nostr.ID{}, fmt.Errorf("%q isn't a hex event ID, note, or nevent", value)
+	}
+	return id, nil
+}
+
+func runDecode(args []string) {
+	var codes []string
+	jsonOutput := false
+	for _, a := range args {
+		switch {
+		case a == "--json":
+			jsonOutput = true
+		case strings.HasPrefix(a, "-"):
+			fatal("unknown flag: %s (see nihao help)", a)
+		default:
+			codes = append(codes, a)
+		}
+	}
+	if len(codes) == 0 {
+		fatal("usage: nihao decode <npub|nsec|note|nprofile|nevent|naddr> ... [--json]")
+	}
+
+	var entities []Entity
+	for _, code := range codes {
+		e, err := decodeEntity(code)
+		if err != nil {
+			fatal("%s", err)
+		}
+		entities = append(entities, e)
+	}
+	if jsonOutput {
+		var out []byte
+		if len(entities) == 1 {
+			out, _ = json.MarshalIndent(entities[0], "", "  ")
+		} else {
+			out, _ = json.MarshalIndent(entities, "", "  ")
+		}
+		fmt.Println(string(out))
+		return
+	}
+	for i, e := range entities {
+		if len(entities) > 1 {
+			if i > 0 {
+				fmt.Println()
+			}
+			fmt.Println(codes[i])
+		}
+		printEntity(e)
+	}
+}
+
+// printEntity shows a decoded entity one field per line. A lone npub,
+// note, or nsec prints just its hex, so it can be used in $(...).
+func printEntity(e Entity) {
+	switch e.Type {
+	case "npub":
+		fmt.Println(e.Pubkey)
+		return
+	case "note":
+		fmt.Println(e.ID)
+		return
+	case "nsec":
+		fmt.Println(e.Secret)
+		return
+	}
+	fmt.Printf("type:       %s\n", e.Type)
+	if e.ID != "" {
+		fmt.Printf("id:         %s\n", e.ID)
+	}
+	if e.Pubkey != "" {
+		fmt.Printf("pubkey:     %s\n", e.Pubkey)
+	}
+	if e.Kind != nil {
+		fmt.Printf("kind:       %d (%s)\n", *e.Kind, kindName(*e.Kind))
+	}
+	if e.Identifier != nil {
+		fmt.Printf("identifier: %q\n", *e.Identifier)
+	}
+	for _, r := range e.Relays {
+		fmt.Printf("relay:      %s\n", r)
+	}
+}
+
+func runEncode(args []string) {
+	usage := "usage: nihao encode <npub|nsec|note|nprofile|nevent|naddr> <hex|npub|note|...> [--relay <url>] [--author <pubkey>] [--kind <n>] [--identifier <d>]"
+	var o encodeOpts
+	var pos []string
+	for i := 0; i < len(args); i++ {
+		switch a := args[i]; {
+		case a == "--relay" && i+1 < len(args):
+			i++
+			o.relays = append(o.relays, args[i])
+		case a == "--relays" && i+1 < len(args):
+			i++
+			o.relays = append(o.relays, strings.Split(args[i], ",")...)
+		case a == "--author" && i+1 < len(args):
+			i++
+			o.author = args[i]
+		case a == "--kind" && i+1 < len(args):
+			i++
+			kind, err := strconv.Atoi(args[i])
+			if err != nil || kind < 0 || kind > 65535 {
+				fatal("invalid --kind %q", args[i])
+			}
+			o.kind = kind
+		case (a == "--identifier" || a == "-d") && i+1 < len(args):
+			i++
+			o.identifier = args[i]
+		case strings.HasPrefix(a, "-"):
+			fatal("unknown flag: %s (see nihao help)", a)
+		default:
+			pos = append(pos, a)
+		}
+	}
+	if len(pos) != 2 {
+		fatal("%s", usage)
+	}
+	code, err := encodeEntity(pos[0], pos[1], o)
+	if err != nil {
+		fatal("%s", err)
+	}
+	fmt.Println(code)
+}
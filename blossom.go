@@ -0,0 +1,197 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"sync"
+
+	"fiatjaf.com/nostr"
+)
+
+// blossomServerInfo is the result of probing a Blossom server's BUD-01
+// (retrieval) and BUD-06 (upload requirements) endpoints.
+type blossomServerInfo struct {
+	URL          string `json:"url"`
+	BlobEndpoint bool   `json:"blob_endpoint"`
+	Upload       string `json:"upload"` // "open", "auth", "paid", "rejected", "unknown"
+	Reason       string `json:"reason,omitempty"`
+	Error        string `json:"error,omitempty"`
+}
+
+// functional reports whether the server answers blob requests and doesn't
+// refuse uploads outright.
+func (b blossomServerInfo) functional() bool {
+	return b.BlobEndpoint && b.Upload != "rejected"
+}
+
+// blossomProbeHash is the sha256 of the empty string. Servers that speak
+// BUD-01 answer HEAD /<hash> with 200 or 404; anything else means the path
+// isn't a blob endpoint.
+const blossomProbeHash = "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"
+
+var blossomHashRe = regexp.MustCompile(`^([0-9a-f]{64})(\.[A-Za-z0-9]+)?$`)
+
+// blossomServers extracts server URLs from a kind 10063 user server list.
+func blossomServers(evt *nostr.Event) []string {
+	var servers []string
+	for _, tag := range evt.Tags {
+		if len(tag) >= 2 && tag[0] == "server" {
+			servers = append(servers, strings.TrimRight(tag[1], "/"))
+		}
+	}
+	return servers
+}
+
+// blossomHash returns the sha256 in a BUD-01 style URL (…/<sha256>[.ext]),
+// or "" if the URL isn't content-addressed.
+func blossomHash(rawURL string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return ""
+	}
+	last := u.Path[strings.LastIndex(u.Path, "/")+1:]
+	if m := blossomHashRe.FindStringSubmatch(last); m != nil {
+		return m[1]
+	}
+	return ""
+}
+
+// probeBlossomServer checks that server serves blobs and asks BUD-06 what
+// it would take to upload.
+func probeBlossomServer(ctx context.Context, server string) blossomServerInfo {
+	info := blossomServerInfo{URL: server, Upload: "unknown"}
+
+	req, err := http.NewRequestWithContext(ctx, "HEAD", server+"/"+blossomProbeHash, nil)
+	if err != nil {
+		info.Error = "invalid URL"
+		return info
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		info.Error = "unreachable"
+		return info
+	}
+	resp.Body.Close()
+	if resp.StatusCode != 200 && resp.StatusCode != 404 {
+		info.Error = fmt.Sprintf("HTTP %d on blob lookup", resp.StatusCode)
+		return info
+	}
+	info.BlobEndpoint = true
+
+	req, err = http.NewRequestWithContext(ctx, "HEAD", server+"/upload", nil)
+	if err != nil {
+		return info
+	}
+	req.Header.Set("X-SHA-256", blossomProbeHash)
+	req.Header.Set("X-Content-Length", "1024")
+	req.Header.Set("X-Content-Type", "image/png")
+	resp, err = http.DefaultClient.Do(req)
+	if err != nil {
+		return info
+	}
+	resp.Body.Close()
+
+	info.Reason = resp.Header.Get("X-Reason")
+	switch {
+	case resp.StatusCode >= 200 && resp.StatusCode < 300:
+		info.Upload = "open"
+	case resp.StatusCode == 401:
+		info.Upload = "auth"
+	case resp.StatusCode == 402:
+		info.Upload = "paid"
+	case resp.StatusCode == 403 || resp.StatusCode == 413 || resp.StatusCode == 415:
+		info.Upload = "rejected"
+	}
+	return info
+}
+
+// probeBlossomBlob reports whether the host of a content-addressed URL
+// serves that hash from its root, as BUD-01 requires.
+func probeBlossomBlob(ctx context.Context, rawURL string) bool {
+	hash := blossomHash(rawURL)
+	if hash == "" {
+		return false
+	}
+	u, _ := url.Parse(rawURL)
+	req, err := http.NewRequestWithContext(ctx, "HEAD", u.Scheme+"://"+u.Host+"/"+hash, nil)
+	if err != nil {
+		return false
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return false
+	}
+	resp.Body.Close()
+	return resp.StatusCode == 200
+}
+
+// checkBlossomServers probes every server in a kind 10063 list and records
+// a "blossom_servers" check.
+func checkBlossomServers(ctx context.Context, result *CheckResult, evt *nostr.Event) {
+	servers := blossomServers(evt)
+	if len(servers) == 0 {
+		result.addCheck("blossom_servers", "warn", "kind 10063 found but no server tags")
+		return
+	}
+
+	infos := make([]blossomServerInfo, len(servers))
+	var wg sync.WaitGroup
+	for i, s := range servers {
+		wg.Add(1)
+		go func(i int, s string) {
+			defer wg.Done()
+			infos[i] = probeBlossomServer(ctx, s)
+		}(i, s)
+	}
+	wg.Wait()
+
+	status, detail := assessBlossomServers(infos)
+	result.addCheck("blossom_servers", status, detail)
+}
+
+// assessBlossomServers summarizes probe results into a check status and detail.
+func assessBlossomServers(infos []blossomServerInfo) (string, string) {
+	uploadLabel := map[string]string{
+		"open":     "open uploads",
+		"auth":     "auth required",
+		"paid":     "payment required",
+		"rejected": "uploads rejected",
+		"unknown":  "upload rules not advertised",
+	}
+
+	working := 0
+	var parts []string
+	for _, info := range infos {
+		host := info.URL
+		if u, err := url.Parse(info.URL); err == nil && u.Host != "" {
+			host = u.Host
+		}
+		switch {
+		case info.functional():
+			working++
+			parts = append(parts, fmt.Sprintf("%s ✓ (%s)", host, uploadLabel[info.Upload]))
+		case info.Error != "":
+			parts = append(parts, fmt.Sprintf("%s ✗ (%s)", host, info.Error))
+		default:
+			reason := uploadLabel[info.Upload]
+			if info.Reason != "" {
+				reason += ": " + info.Reason
+			}
+			parts = append(parts, fmt.Sprintf("%s ✗ (%s)", host, reason))
+		}
+	}
+
+	detail := fmt.Sprintf("%d/%d server(s) functional: %s", working, len(infos), strings.Join(parts, ", "))
+	switch {
+	case working == len(infos):
+		return "pass", detail
+	case working > 0:
+		return "warn", detail
+	default:
+		return "fail", detail
+	}
+}
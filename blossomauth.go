@@ -0,0 +1,129 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"fiatjaf.com/nostr"
+)
+
+// blossomAuthTTL is how long a Blossom authorization event stays valid.
+// Each request gets its own, so it only has to outlive one upload.
+const blossomAuthTTL = 5 * time.Minute
+
+// blobDescriptor is how a Blossom server describes a stored blob (BUD-02).
+type blobDescriptor struct {
+	URL      string `json:"url"`
+	SHA256   string `json:"sha256"`
+	Size     int64  `json:"size"`
+	Type     string `json:"type,omitempty"`
+	Uploaded int64  `json:"uploaded,omitempty"`
+}
+
+// blossomAuthEvent builds the kind 24242 authorization event for one
+// operation ("upload", "list", "delete"): a t tag for the verb, an
+// expiration, x tags for the blobs it covers, and a server tag scoping it
+// to the one server, so a leaked header can't be replayed elsewhere.
+func blossomAuthEvent(verb, server string, hashes []string, now nostr.Timestamp) nostr.Event {
+	content := map[string]string{"upload": "Upload blob", "list": "List blobs", "delete": "Delete blob"}[verb]
+	evt := nostr.Event{
+		Kind:      24242,
+		CreatedAt: now,
+		Content:   content,
+		Tags: nostr.Tags{
+			{"t", verb},
+			{"expiration", strconv.FormatInt(int64(now)+int64(blossomAuthTTL/time.Second), 10)},
+		},
+	}
+	for _, h := range hashes {
+		evt.Tags = append(evt.Tags, nostr.Tag{"x", h})
+	}
+	if u, err := url.Parse(server); err == nil && u.Hostname() != "" {
+		evt.Tags = append(evt.Tags, nostr.Tag{"server", strings.ToLower(u.Hostname())})
+	}
+	return evt
+}
+
+// blossomAuthHeader signs a blossomAuthEvent into an Authorization header.
+func blossomAuthHeader(ctx context.Context, signer nostr.Signer, verb, server string, hashes ...string) (string, error) {
+	evt := blossomAuthEvent(verb, server, hashes, nostr.Now())
+	if err := signer.SignEvent(ctx, &evt); err != nil {
+		return "", fmt.Errorf("signing %s authorization: %w", verb, err)
+	}
+	data, _ := json.Marshal(evt)
+	return "Nostr " + base64.StdEncoding.EncodeToString(data), nil
+}
+
+// blossomCall makes one Blossom request, authorized for verb when signer
+// is set, and decodes the JSON answer into out. Errors carry the server's
+// X-Reason, which is usually the only explanation a refusal comes with.
+func blossomCall(ctx context.Context, signer nostr.Signer, method, server, path, verb string, hashes []string, body []byte, contentType string, out any) error {
+	var reader io.Reader
+	if body != nil {
+		reader = bytes.NewReader(body)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, strings.TrimRight(server, "/")+"/"+path, reader)
+	if err != nil {
+		return err
+	}
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+	if signer != nil {
+		auth, err := blossomAuthHeader(ctx, signer, verb, server, hashes...)
+		if err != nil {
+			return err
+		}
+		req.Header.Set("Authorization", auth)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		msg := fmt.Sprintf("HTTP %d", resp.StatusCode)
+		if reason := resp.Header.Get("X-Reason"); reason != "" {
+			msg += ": " + reason
+		}
+		return fmt.Errorf("%s", msg)
+	}
+	if out == nil {
+		return nil
+	}
+	if err := json.NewDecoder(io.LimitReader(resp.Body, 10<<20)).Decode(out); err != nil {
+		return fmt.Errorf("invalid response: %w", err)
+	}
+	return nil
+}
+
+// blossomUpload stores data on server (BUD-02 PUT /upload).
+func blossomUpload(ctx context.Context, signer nostr.Signer, server string, data []byte, contentType string) (blobDescriptor, error) {
+	sum := sha256.Sum256(data)
+	var bd blobDescriptor
+	err := blossomCall(ctx, signer, "PUT", server, "upload", "upload", []string{hex.EncodeToString(sum[:])}, data, contentType, &bd)
+	return bd, err
+}
+
+// blossomList lists the blobs pk has on server (BUD-02 GET /list/<pubkey>).
+func blossomList(ctx context.Context, signer nostr.Signer, server string, pk nostr.PubKey) ([]blobDescriptor, error) {
+	var blobs []blobDescriptor
+	err := blossomCall(ctx, signer, "GET", server, "list/"+pk.Hex(), "list", nil, nil, "", &blobs)
+	return blobs, err
+}
+
+// blossomDelete removes a blob from server (BUD-02 DELETE /<sha256>).
+func blossomDelete(ctx context.Context, signer nostr.Signer, server, hash string) error {
+	return blossomCall(ctx, signer, "DELETE", server, hash, "delete", []string{hash}, nil, "", nil)
+}
@@ -0,0 +1,126 @@
+package main
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// checkPhases are the stretches of a check run in the order they run,
+// with their weights: the share of the time left each one gets when it
+// starts, relative to the phases still to come.
+var checkPhases = []budgetPhase{
+	{"profile", 4},  // kind 0, NIP-05, lightning address, images
+	{"lists", 2},    // key notice, zaps, reports, media servers, interests
+	{"relays", 4},   // kind 10002, relay scores, uptime, advertised relays
+	{"dms", 1},      // kind 10050, NIP-04 DMs, follow list
+	{"wallet", 3},   // NIP-60 wallet, mints, nutzap info, NWC
+	{"features", 2}, // relay features, search relays
+	{"families", 1}, // IPv4/IPv6
+}
+
+// extraKindsWeight is the share of a check's deadline held back for
+// --extra-kinds, weighed against checkPhases. They're fetched after
+// checkIdentity, whose last phase would otherwise take all that's left.
+const extraKindsWeight = 1
+
+// minPhase is the least a phase is given, time permitting, so a small
+// weight never means no chance at all.
+var minPhase = 2 * time.Second
+
+type budgetPhase struct {
+	name   string
+	weight int
+}
+
+// budget hands out a run's deadline phase by phase. A phase gets its
+// share of whatever is left when it starts, so time the phases before it
+// didn't need goes to it and the ones after, and a phase that runs past
+// its share is cut off rather than starving theirs. Without a deadline
+// it hands out the parent context unchanged.
+type budget struct {
+	ctx    context.Context
+	phases []budgetPhase
+	next   int // index of the first phase not yet started
+
+	mu     sync.Mutex
+	cutOff []string
+}
+
+func newBudget(ctx context.Context, phases []budgetPhase) *budget {
+	return &budget{ctx: ctx, phases: phases}
+}
+
+// phase starts the named phase, skipping any before it that didn't run,
+// and returns its context. Call done when the phase is over.
+func (b *budget) phase(name string) (ctx context.Context, done func()) {
+	i := b.index(name)
+	ctx, cancel := b.until(i)
+	b.next = i + 1
+	return ctx, func() {
+		if ctx.Err() == context.DeadlineExceeded && b.ctx.Err() == nil {
+			b.mu.Lock()
+			b.cutOff = append(b.cutOff, name)
+			b.mu.Unlock()
+		}
+		cancel()
+	}
+}
+
+// through returns a context for work started now whose result is needed
+// by the end of the named phase: it ends when that phase would if every
+// phase from here on used its full share.
+func (b *budget) through(name string) (context.Context, context.CancelFunc) {
+	return b.until(b.index(name))
+}
+
+// until is the context ending at phase i's latest end.
+func (b *budget) until(i int) (context.Context, context.CancelFunc) {
+	deadline, ok := b.ctx.Deadline()
+	if !ok || i < b.next {
+		return context.WithCancel(b.ctx)
+	}
+	share, total := 0, 0
+	for j, p := range b.phases[b.next:] {
+		if b.next+j <= i {
+			share += p.weight
+		}
+		total += p.weight
+	}
+	left := time.Until(deadline)
+	d := max(left*time.Duration(share)/time.Duration(total), min(minPhase, left))
+	return context.WithTimeout(b.ctx, d)
+}
+
+func (b *budget) index(name string) int {
+	for i, p := range b.phases {
+		if p.name == name {
+			return i
+		}
+	}
+	panic("unknown check phase " + name)
+}
+
+// cutOffPhases returns the phases that ran out of time, in order.
+func (b *budget) cutOffPhases() []string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return append([]string(nil), b.cutOff...)
+}
+
+// holdBack returns a context for checkIdentity that ends early enough to
+// leave work done after it weight's share of ctx's deadline, weighed
+// against checkPhases, and at least minPhase when there's that much time.
+func holdBack(ctx context.Context, weight int) (context.Context, context.CancelFunc) {
+	deadline, ok := ctx.Deadline()
+	if !ok {
+		return context.WithCancel(ctx)
+	}
+	total := weight
+	for _, p := range checkPhases {
+		total += p.weight
+	}
+	left := time.Until(deadline)
+	keep := max(left*time.Duration(weight)/time.Duration(total), min(minPhase, left))
+	return context.WithDeadline(ctx, deadline.Add(-keep))
+}
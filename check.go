@@ -1,14 +1,17 @@
 package main
 
 import (
+	"bytes"
 	"context"
 	"encoding/json"
 	"fmt"
+	"io"
 	"net/http"
 	"net/url"
 	"os"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	"fiatjaf.com/nostr"
@@ -16,50 +19,141 @@ import (
 )
 
 type CheckResult struct {
-	Npub     string          `json:"npub"`
-	Pubkey   string          `json:"pubkey"`
-	Score    int             `json:"score"`
-	MaxScore int             `json:"max_score"`
-	Checks   []CheckItem     `json:"checks"`
+	SchemaVersion int `json:"schema_version"`
+
+	Npub     string           `json:"npub"`
+	Pubkey   string           `json:"pubkey"`
+	Score    int              `json:"score"`
+	MaxScore int              `json:"max_score"`
+	Tier     *TierProgress    `json:"tier,omitempty"`
+	Checks   []CheckItem      `json:"checks"`
 	Wallet   *WalletCheckInfo `json:"wallet,omitempty"`
+
+	Propagation      []PropagationCoverage `json:"propagation,omitempty"`
+	RelayAuth        map[string]string     `json:"relay_auth,omitempty"`  // relay URL → NIP-42 status
+	HTTPAuth         map[string]string     `json:"http_auth,omitempty"`   // host → NIP-98 status
+	DMLoopback       []DMLoopbackRelay     `json:"dm_loopback,omitempty"` // with a key: NIP-17 DM to self per DM relay
+	LegacyDMs        *LegacyDMExposure     `json:"legacy_dms,omitempty"`  // NIP-04 DMs on public relays
+	Features         *FeatureMatrix        `json:"relay_features,omitempty"`
+	SearchRelays     []SearchRelayStatus   `json:"search_relays,omitempty"` // kind 10007 and other search relays, probed with NIP-50
+	Zaps             *ZapActivity          `json:"zaps,omitempty"`
+	Reports          *ReportExposure       `json:"reports,omitempty"`
+	NWC              *WalletServiceInfo    `json:"wallet_service,omitempty"`
+	Payments         *PaymentsReadiness    `json:"payments,omitempty"`
+	KeyNotice        *KeyNotice            `json:"key_notice,omitempty"`        // the key says it's retired or compromised
+	RelayUptime      []RelayUptime         `json:"relay_uptime,omitempty"`      // from NIP-66 monitors, last 30 days
+	AddressFamilies  []ServiceFamilies     `json:"address_families,omitempty"`  // IPv4/IPv6 reachability per service
+	AdvertisedRelays *AdvertisedRelayDiff  `json:"advertised_relays,omitempty"` // kind 10002 vs. where the events are
+	TagProblems      []TagProblem          `json:"tag_problems,omitempty"`      // malformed tags in list events
+	EventSizes       []EventSize           `json:"event_sizes,omitempty"`       // profile and follow list vs. relay limits
+	RelayLimits      []RelayLimitReport    `json:"relay_limits,omitempty"`      // NIP-11 limitations vs. how each relay is used
+	Clients          []ClientCompat        `json:"clients,omitempty"`           // per-client impact of the findings
+	RawEvents        []SourcedEvent        `json:"events,omitempty"`            // with --include-events
+	ExtraKinds       []KindPresence        `json:"extra_kinds,omitempty"`
+	Policy           *PolicyResult         `json:"policy,omitempty"`    // with --policy
+	Baseline         *BaselineDiff         `json:"baseline,omitempty"`  // with --baseline
+	CachedAt         *time.Time            `json:"cached_at,omitempty"` // set if served from the result cache
+	DurationMs       int64                 `json:"duration_ms,omitempty"`
+	Probes           []ProbeTiming         `json:"probes,omitempty"`  // slowest hosts and relays, most time first
+	CutOff           []string              `json:"cut_off,omitempty"` // check phases stopped at their share of the time budget
+
+	events  map[int]*nostr.Event // latest event per kind, for policy checks
+	sources map[int]string       // relay each of events came from
+	lap     time.Time            // when the previous check was added
+}
+
+// SourcedEvent is an event check based its verdicts on, and the relay it
+// was taken from.
+type SourcedEvent struct {
+	Kind  int          `json:"kind"`
+	Relay string       `json:"relay"`
+	Event *nostr.Event `json:"event"`
 }
 
+// includedEventKinds are the kinds --include-events embeds: the ones most
+// checks are derived from.
+var includedEventKinds = []int{0, 3, 10002, 10050, 10019}
+
 // WalletCheckInfo holds wallet details discovered during check.
 type WalletCheckInfo struct {
-	WalletKind int         `json:"wallet_kind"`
-	HasNutzap  bool        `json:"has_nutzap_info"`
-	Mints      []MintInfo  `json:"mints,omitempty"`
-	P2PKPubkey string      `json:"p2pk_pubkey,omitempty"`
+	WalletKind int        `json:"wallet_kind"`
+	HasNutzap  bool       `json:"has_nutzap_info"`
+	Mints      []MintInfo `json:"mints,omitempty"`
+	P2PKPubkey string     `json:"p2pk_pubkey,omitempty"`
 }
 
 type CheckItem struct {
-	Name   string `json:"name"`
-	Status string `json:"status"` // "pass", "fail", "warn"
-	Detail string `json:"detail,omitempty"`
+	Name        string       `json:"name"`
+	Status      string       `json:"status"` // "pass", "fail", "warn"
+	Detail      string       `json:"detail,omitempty"`
+	Remediation *Remediation `json:"remediation,omitempty"` // how to fix a fail or warn
+	DurationMs  int64        `json:"duration_ms,omitempty"` // time since the previous check, i.e. spent on this one
 }
 
-func runCheck(target string, jsonOutput bool, quiet bool, relays []string) {
+// checkOpts are the options of `nihao check` on a single identity.
+type checkOpts struct {
+	jsonOutput     bool
+	quiet          bool
+	summary        bool         // one line instead of the report
+	relays         []string     // relays to fetch from, defaultRelays if empty
+	propagation    []string     // relays to check coverage on
+	signer         nostr.Signer // answers NIP-42 and NIP-98 challenges
+	includeEvents  bool
+	extraKinds     []int
+	pluginDir      string
+	policy         *Policy
+	baselinePath   string
+	updateBaseline bool
+	explain        bool
+}
+
+// runCheck checks a single identity. If opts.propagation is non-empty,
+// those relays are also queried for coverage of the profile and relay
+// list. With opts.baselinePath set, the result is compared to the one
+// stored there and only regressions fail the exit code; --update-baseline
+// then stores the new result in its place.
+func runCheck(target string, opts checkOpts) {
 	if target == "" {
 		fatal("usage: nihao check <npub|hex>")
 	}
 
-	pk, err := resolveTarget(target, quiet)
+	var baseline *CheckResult
+	if opts.baselinePath != "" {
+		b, err := loadBaseline(opts.baselinePath)
+		switch {
+		case err == nil:
+			baseline = b
+		case !(os.IsNotExist(err) && opts.updateBaseline):
+			fatal("could not read baseline %s: %s (create one with --update-baseline)", opts.baselinePath, err)
+		}
+	}
+
+	pk, err := resolveTarget(target, opts.quiet)
 	if err != nil {
 		fatal("%s", err)
 	}
 
 	npub := nip19.EncodeNpub(pk)
-	if !jsonOutput && !quiet {
+	if !opts.jsonOutput && !opts.quiet {
 		fmt.Printf("nihao check 🔍 %s\n\n", npub)
 	}
 
 	ctx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
 	defer cancel()
 
-	// Connect to relays once and reuse for all fetches
-	checkRelays := connectCheckRelays(ctx, relays)
-	if len(checkRelays) == 0 {
-		fatal("could not connect to any relay")
+	// Connect to relays once, when first needed, and reuse for all fetches
+	var checkRelays []checkRelay
+	connect := func() []checkRelay {
+		if checkRelays == nil {
+			checkRelays = connectCheckRelays(ctx, opts.relays)
+			if len(checkRelays) == 0 {
+				fatal("could not connect to any relay")
+			}
+			if opts.signer != nil {
+				enableAuth(checkRelays, opts.signer)
+			}
+		}
+		return checkRelays
 	}
 	defer func() {
 		for _, cr := range checkRelays {
@@ -67,18 +161,177 @@ func runCheck(target string, jsonOutput bool, quiet bool, relays []string) {
 		}
 	}()
 
+	// A cached result needs no relays at all. With a key, what relays
+	// return depends on auth, so that's always checked fresh.
+	var result CheckResult
+	cached := false
+	if opts.signer == nil {
+		result, cached = loadCachedCheck(pk, opts.relays, time.Now())
+	}
+	if cached {
+		if !opts.jsonOutput && !opts.quiet {
+			fmt.Printf("  🗄️  cached result from %s (--no-cache for a fresh one)\n\n", cachedAge(*result.CachedAt, time.Now()))
+		}
+	} else {
+		checkCtx := ctx
+		if len(opts.extraKinds) > 0 {
+			var cancel context.CancelFunc
+			checkCtx, cancel = holdBack(ctx, extraKindsWeight)
+			defer cancel()
+		}
+		if opts.signer != nil {
+			checkCtx = withHTTPAuth(checkCtx, &httpAuth{signer: opts.signer})
+		}
+		result = checkIdentity(checkCtx, connect(), pk, !opts.jsonOutput && !opts.quiet)
+		if opts.signer == nil {
+			saveCachedCheck(pk, opts.relays, result, time.Now())
+		}
+	}
+	// With the identity's own key, prove the NIP-17 path end to end
+	if kr, ok := opts.signer.(nostr.Keyer); ok && result.events[10050] != nil {
+		if own, err := kr.GetPublicKey(ctx); err == nil && own == pk {
+			if dmRelays := relayTags(result.events[10050]); len(dmRelays) > 0 {
+				checkDMLoopback(&result, kr, dmRelays)
+			}
+		}
+	}
+	if len(opts.propagation) > 0 {
+		checkPropagation(&result, pk, opts.propagation)
+	}
+	if len(opts.extraKinds) > 0 {
+		checkExtraKinds(ctx, &result, connect(), pk, opts.extraKinds)
+	}
+	if opts.pluginDir != "" {
+		runCheckPlugins(context.Background(), &result, opts.pluginDir)
+	}
+	result.attachRemediations()
+	if opts.includeEvents {
+		result.includeEvents()
+	}
+	if opts.policy != nil {
+		p := evaluatePolicy(opts.policy, result)
+		result.Policy = &p
+	}
+	if baseline != nil {
+		d := compareBaseline(*baseline, result)
+		result.Baseline = &d
+	}
+	if opts.updateBaseline {
+		if err := writeBaseline(opts.baselinePath, result); err != nil {
+			fatal("could not write baseline: %s", err)
+		}
+	}
+
+	if opts.jsonOutput {
+		result.SchemaVersion = schemaVersion // results cached by older versions lack it
+		out, err := marshalVersioned(result)
+		if err != nil {
+			fatal("%s", err)
+		}
+		fmt.Println(string(out))
+	} else if opts.summary {
+		fmt.Println(summaryLine(result))
+	} else if !opts.quiet {
+		printCheckResult(result)
+		if opts.explain {
+			printRemediations(result)
+		}
+		printBottlenecks(result)
+		printRequestBudget(os.Stdout)
+		if result.Policy != nil {
+			printPolicyResult(*result.Policy)
+		}
+		if result.Baseline != nil {
+			printBaselineDiff(*result.Baseline, result.Score)
+		}
+		if opts.updateBaseline {
+			fmt.Printf("\n  💾 baseline updated: %s\n", opts.baselinePath)
+		}
+	}
+
+	// A policy replaces the score as what the exit code reports
+	if result.Policy != nil {
+		if !result.Policy.Compliant {
+			os.Exit(2)
+		}
+		return
+	}
+	// Against a baseline only regressions count, and updating accepts them
+	if opts.baselinePath != "" {
+		if result.Baseline != nil && len(result.Baseline.Regressions) > 0 && !opts.updateBaseline {
+			os.Exit(1)
+		}
+		return
+	}
+	if result.Score < result.MaxScore {
+		os.Exit(1)
+	}
+}
+
+// checkIdentity runs every health check for pk against already-connected
+// relays. With verbose set, per-relay details are printed as they're scored.
+func checkIdentity(ctx context.Context, checkRelays []checkRelay, pk nostr.PubKey, verbose bool) CheckResult {
 	result := CheckResult{
-		Npub:     npub,
-		Pubkey:   pk.Hex(),
-		MaxScore: 8,
+		SchemaVersion: schemaVersion,
+		Npub:          nip19.EncodeNpub(pk),
+		Pubkey:        pk.Hex(),
+		MaxScore:      8,
+		events:        make(map[int]*nostr.Event),
+		sources:       make(map[int]string),
+		lap:           time.Now(),
+	}
+	// Each check's duration runs from the one before it; probe timings
+	// say which host or relay the time went to
+	start := result.lap
+	timings := &probeTimings{}
+	ctx = withProbeTimings(ctx, timings)
+	checkRelays = timeChecks(checkRelays, timings)
+	// HTTP endpoints that answer 401 are retried with NIP-98 when the
+	// caller supplied a signer
+	auth := httpAuthFrom(ctx)
+	if auth == nil {
+		auth = &httpAuth{}
+		ctx = withHTTPAuth(ctx, auth)
 	}
+	// Services to probe over IPv4 and IPv6, collected as they come up
+	var probedFamilies []ServiceFamilies
+	var familyEndpoints []serviceEndpoint
+	// Scores of the relays in the kind 10002, for their NIP-11 limits
+	var relayScores []RelayScore
+
+	// The deadline in ctx is shared out phase by phase, so a hung relay
+	// or mint costs its own phase's share and not the checks after it
+	b := newBudget(ctx, checkPhases)
+
+	// Lookups that don't depend on anything else start now and run while
+	// the checks before them are scored, until their phase's latest end
+	dmCtx, cancelDM := b.through("dms")
+	defer cancelDM()
+	walletCtx, cancelWallet := b.through("wallet")
+	defer cancelWallet()
+	dmLookup := background(func() dmRelayLookup { return lookupDMRelays(dmCtx, checkRelays, pk) })
+	walletLookup := background(func() nip60Lookup { return lookupNIP60Wallet(walletCtx, checkRelays, pk) })
 
 	// Fetch profile (kind 0)
-	_, profileEvt := fetchKindFrom(ctx, checkRelays, pk, 0)
+	pctx, done := b.phase("profile")
+	profileSrc, profileEvt := fetchKindFrom(pctx, checkRelays, pk, 0)
+	result.recordEvent(0, profileSrc, profileEvt)
 	if profileEvt != nil {
 		var meta ProfileMetadata
 		json.Unmarshal([]byte(profileEvt.Content), &meta)
 
+		// Everything the profile points at is probed at once
+		nip05Verified := background(func() bool { return meta.NIP05 != "" && verifyNIP05(pctx, meta.NIP05, pk) })
+		nip05Host := background(func() nip05HostInfo {
+			if meta.NIP05 == "" {
+				return nip05HostInfo{}
+			}
+			name, domain := splitNIP05(meta.NIP05)
+			return probeNIP05Host(pctx, name, domain)
+		})
+		lud16Verified := background(func() bool { return meta.LUD16 != "" && verifyLUD16(pctx, meta.LUD16) })
+		images := probeProfileImages(pctx, meta.Picture, meta.Banner)
+
 		// Check 1: Profile exists with completeness
 		fields := []string{}
 		missing := []string{}
@@ -113,7 +366,7 @@ func runCheck(target string, jsonOutput bool, quiet bool, relays []string) {
 
 		// Check 2: NIP-05
 		if meta.NIP05 != "" {
-			if verifyNIP05(ctx, meta.NIP05, pk) {
+			if nip05Verified() {
 				// Check for root NIP-05 (_@domain)
 				nip05Display := meta.NIP05
 				isRoot := isRootNIP05(meta.NIP05)
@@ -129,6 +382,14 @@ func runCheck(target string, jsonOutput bool, quiet bool, relays []string) {
 			result.addCheck("nip05", "fail", "not set")
 		}
 
+		// Check: NIP-05 domain infrastructure (TLS, redirects, latency, IPv4/IPv6)
+		if meta.NIP05 != "" {
+			info := nip05Host()
+			status, detail := assessNIP05Host(info, time.Now())
+			result.addCheck("nip05_hosting", status, detail)
+			probedFamilies = append(probedFamilies, info.families)
+		}
+
 		// Check: Profile images health
 		// Extract NIP-05 domain for own-domain hosting detection
 		nip05Domain := ""
@@ -142,11 +403,16 @@ func runCheck(target string, jsonOutput bool, quiet bool, relays []string) {
 				nip05Domain = meta.NIP05 // bare domain = root
 			}
 		}
-		checkProfileImages(ctx, &result, meta.Picture, meta.Banner, nip05Domain)
+		checkProfileImages(&result, images, nip05Domain)
 
 		// Check 3: Lightning address
 		if meta.LUD16 != "" {
-			if verifyLUD16(ctx, meta.LUD16) {
+			if lnurl, err := lnurlpURL(meta.LUD16); err == nil {
+				if ep, ok := urlEndpoint("lnurl", lnurl); ok {
+					familyEndpoints = append(familyEndpoints, ep)
+				}
+			}
+			if lud16Verified() {
 				result.addCheck("lud16", "pass", meta.LUD16)
 				result.Score++
 			} else {
@@ -160,9 +426,41 @@ func runCheck(target string, jsonOutput bool, quiet bool, relays []string) {
 		result.addCheck("nip05", "fail", "no profile")
 		result.addCheck("lud16", "fail", "no profile")
 	}
+	done()
+
+	// Check: Has the key announced it's retired or compromised?
+	pctx, done = b.phase("lists")
+	checkKeyNotice(pctx, &result, checkRelays, pk)
+
+	// Check: Zap activity (NIP-57 receipts), a sign lightning works in practice
+	checkZapActivity(pctx, &result, checkRelays, pk)
+
+	// Check: Reports and public mutes against the identity (informational)
+	checkReportExposure(pctx, &result, checkRelays, pk)
+
+	// Check: NIP-96 file server list (kind 10096), only reported if present
+	nip96Src, nip96Evt := fetchKindFrom(pctx, checkRelays, pk, 10096)
+	result.recordEvent(10096, nip96Src, nip96Evt)
+	if nip96Evt != nil {
+		checkNIP96Servers(pctx, &result, nip96Evt)
+	}
+
+	// Check: Blossom server list (kind 10063), only reported if present
+	blossomSrc, blossomEvt := fetchKindFrom(pctx, checkRelays, pk, 10063)
+	result.recordEvent(10063, blossomSrc, blossomEvt)
+	if blossomEvt != nil {
+		checkBlossomServers(pctx, &result, blossomEvt)
+	}
+
+	// Check: Interests (kind 10015) and custom emojis (kind 10030), only
+	// reported if present
+	checkExtendedLists(pctx, &result, checkRelays, pk)
+	done()
 
 	// Check 4: Relay list (kind 10002) with NIP-65 marker analysis
-	_, relayEvt := fetchKindFrom(ctx, checkRelays, pk, 10002)
+	pctx, done = b.phase("relays")
+	relaySrc, relayEvt := fetchKindFrom(pctx, checkRelays, pk, 10002)
+	result.recordEvent(10002, relaySrc, relayEvt)
 	if relayEvt != nil {
 		var relayURLs []string
 		allBare := true
@@ -216,14 +514,19 @@ func runCheck(target string, jsonOutput bool, quiet bool, relays []string) {
 
 		// Score each relay for quality analysis
 		if relayCount > 0 {
-			scores := ScoreRelays(relayURLs)
+			scores := ScoreRelays(pctx, relayURLs)
 			reachable := 0
 			var unreachableURLs []string
 			var totalLatency int64
 			for _, rs := range scores {
+				if ep, ok := urlEndpoint("relay", rs.URL); ok {
+					familyEndpoints = append(familyEndpoints, ep)
+				}
 				if rs.Reachable {
 					reachable++
 					totalLatency += rs.LatencyMs
+				} else if rs.Hung {
+					unreachableURLs = append(unreachableURLs, rs.URL+" (hung)")
 				} else {
 					unreachableURLs = append(unreachableURLs, rs.URL)
 				}
@@ -239,8 +542,21 @@ func runCheck(target string, jsonOutput bool, quiet bool, relays []string) {
 				result.addCheck("relay_quality", "fail", "no relays reachable")
 			}
 
-			// Print per-relay details with purpose in non-quiet mode
-			if !jsonOutput && !quiet {
+			// Paid relays: can the user actually write there?
+			checkPaidRelays(&result, scores, relayEvt)
+
+			// Certificates: expiring, wrong host, or missing intermediates
+			checkRelayTLS(&result, scores, time.Now())
+			relayScores = scores
+
+			// Reachable now isn't reliable: ask NIP-66 monitors how it's been
+			checkRelayUptime(pctx, &result, relayURLs)
+
+			// Do the advertised write relays actually have the events?
+			checkAdvertisedRelays(pctx, &result, checkRelays, pk, relayEvt, scores)
+
+			// Print per-relay details with purpose in verbose mode
+			if verbose {
 				// Build marker map from event tags
 				markerMap := make(map[string]string)
 				for _, tag := range relayEvt.Tags {
@@ -259,7 +575,12 @@ func runCheck(target string, jsonOutput bool, quiet bool, relays []string) {
 						if rs.HasNIP11 {
 							nip11Status = "NIP-11 ✓"
 						}
-						fmt.Printf("      %s — %dms, %s, %.0f%%, %s\n", rs.URL, rs.LatencyMs, nip11Status, rs.Score*100, purpose)
+						if fees := feeSummary(rs.Info); fees != "" {
+							purpose += ", " + fees
+						}
+						fmt.Printf("      %s — %dms (p95 %dms), %s, %.0f%%, %s\n", rs.URL, rs.LatencyMs, rs.LatencyP95Ms, nip11Status, rs.Score*100, purpose)
+					} else if rs.Hung {
+						fmt.Printf("      %s — hung: connects but doesn't answer a ping ✗, %s\n", rs.URL, purpose)
 					} else {
 						fmt.Printf("      %s — unreachable ✗, %s\n", rs.URL, purpose)
 					}
@@ -269,22 +590,20 @@ func runCheck(target string, jsonOutput bool, quiet bool, relays []string) {
 	} else {
 		result.addCheck("relay_list", "fail", "no kind 10002 found")
 	}
+	done()
 
 	// Check 4b: DM relay list (kind 10050)
-	_, dmRelayEvt := fetchKindFrom(ctx, checkRelays, pk, 10050)
+	pctx, done = b.phase("dms")
+	dm := dmLookup()
+	dmRelayEvt := dm.evt
+	result.recordEvent(10050, dm.src, dmRelayEvt)
 	if dmRelayEvt != nil {
-		var dmRelayURLs []string
-		for _, tag := range dmRelayEvt.Tags {
-			if len(tag) >= 2 && tag[0] == "relay" {
-				dmRelayURLs = append(dmRelayURLs, tag[1])
-			}
-		}
+		dmRelayURLs := dm.urls
 		if len(dmRelayURLs) > 0 {
-			// Score DM relays for reachability
-			dmScores := ScoreRelays(dmRelayURLs)
+			// DM relays were scored for reachability in the background
 			reachable := 0
 			var unreachableDM []string
-			for _, rs := range dmScores {
+			for _, rs := range dm.scores {
 				if rs.Reachable {
 					reachable++
 				} else {
@@ -306,8 +625,12 @@ func runCheck(target string, jsonOutput bool, quiet bool, relays []string) {
 		result.addCheck("dm_relays", "warn", "no kind 10050 (DM relay list) — others may not be able to send you DMs via NIP-17")
 	}
 
+	// Check 4c: NIP-04 DMs leaking who talks to whom
+	checkLegacyDMs(pctx, &result, checkRelays, pk)
+
 	// Check 5: Follow list (kind 3)
-	_, followEvt := fetchKindFrom(ctx, checkRelays, pk, 3)
+	followSrc, followEvt := fetchKindFrom(pctx, checkRelays, pk, 3)
+	result.recordEvent(3, followSrc, followEvt)
 	if followEvt != nil {
 		followCount := 0
 		for _, tag := range followEvt.Tags {
@@ -325,17 +648,20 @@ func runCheck(target string, jsonOutput bool, quiet bool, relays []string) {
 		result.addCheck("follow_list", "fail", "no kind 3 found")
 	}
 
-	// Check 6: NIP-60 wallet (kind 17375 new, 37375 old)
-	walletKind := 0
-	_, walletEvt := fetchKindFrom(ctx, checkRelays, pk, 17375)
-	if walletEvt != nil {
-		walletKind = 17375
+	// Check 5b: Profile and follow list small enough for the write relays,
+	// and the rest of the relays' NIP-11 limits against how they're used
+	if relayEvt != nil {
+		checkEventSizes(&result, writeRelayScores(relayEvt, relayScores))
+		checkRelayLimits(&result, relayEvt, relayScores, time.Now())
 	} else {
-		_, walletEvt = fetchKindFrom(ctx, checkRelays, pk, 37375) // backwards compat
-		if walletEvt != nil {
-			walletKind = 37375
-		}
+		checkEventSizes(&result, nil)
 	}
+	done()
+
+	// Check 6: NIP-60 wallet (kind 17375 new, 37375 old)
+	pctx, done = b.phase("wallet")
+	wallet := walletLookup()
+	walletKind, walletEvt := wallet.kind, wallet.evt
 	if walletEvt != nil {
 		kindLabel := fmt.Sprintf("kind %d", walletKind)
 		if walletKind == 37375 {
@@ -346,28 +672,15 @@ func runCheck(target string, jsonOutput bool, quiet bool, relays []string) {
 
 		// Check for nutzap info (kind 10019)
 		walletInfo := &WalletCheckInfo{WalletKind: walletKind}
-		_, nutzapEvt := fetchKindFrom(ctx, checkRelays, pk, 10019)
+		nutzapEvt := wallet.nutzap
+		result.recordEvent(10019, wallet.nutzapSrc, nutzapEvt)
 		if nutzapEvt != nil {
 			walletInfo.HasNutzap = true
-
-			// Extract mints and P2PK pubkey from kind 10019
-			var mintURLs []string
-			for _, tag := range nutzapEvt.Tags {
-				if len(tag) >= 2 && tag[0] == "mint" {
-					mintURLs = append(mintURLs, tag[1])
-				}
-				if len(tag) >= 2 && tag[0] == "pubkey" {
-					walletInfo.P2PKPubkey = tag[1]
-				}
-			}
+			walletInfo.P2PKPubkey = wallet.p2pk
+			walletInfo.Mints = wallet.mints // validated, but they don't fail the check
+			mintURLs := wallet.mintURLs
 
 			if len(mintURLs) > 0 {
-				// Validate mints (don't fail check, just report status)
-				for _, mintURL := range mintURLs {
-					mintInfo := validateMint(ctx, mintURL)
-					walletInfo.Mints = append(walletInfo.Mints, mintInfo)
-				}
-
 				// Report mint status
 				reachable := 0
 				for _, m := range walletInfo.Mints {
@@ -386,7 +699,10 @@ func runCheck(target string, jsonOutput bool, quiet bool, relays []string) {
 				}
 			}
 
-			result.addCheck("nutzap_info", "pass", "kind 10019 found")
+			// Deep-check what senders rely on: a bad key, mint, or relay
+			// here burns every nutzap sent to this identity
+			status, detail := assessNutzapInfo(nutzapEvt, walletInfo.Mints, wallet.nutzapRelays)
+			result.addCheck("nutzap_info", status, detail)
 		} else {
 			walletInfo.HasNutzap = false
 			result.addCheck("nutzap_info", "warn", "wallet exists but no kind 10019 (nutzap info) — others can't send you nutzaps")
@@ -397,66 +713,193 @@ func runCheck(target string, jsonOutput bool, quiet bool, relays []string) {
 		result.addCheck("nip60_wallet", "fail", "no NIP-60 wallet found")
 	}
 
-	if jsonOutput {
-		out, _ := json.MarshalIndent(result, "", "  ")
-		fmt.Println(string(out))
-	} else if !quiet {
-		printCheckResult(result)
+	// Payments readiness: lightning, nutzaps, and NWC side by side
+	checkWalletService(pctx, &result, checkRelays, pk)
+	payments := assessPayments(result)
+	result.Payments = &payments
+	done()
+
+	pctx, done = b.phase("features")
+	checkRelayFeatures(pctx, &result, checkRelays, pk)
+	done()
+	checkRelayAuth(&result, checkRelays)
+	checkHTTPAuth(&result, auth)
+
+	// IPv4-only, IPv6-only, or an address family that doesn't answer
+	pctx, done = b.phase("families")
+	checkAddressFamilies(pctx, &result, probedFamilies, familyEndpoints)
+	done()
+
+	// Malformed tags the checks above would otherwise skip silently
+	checkEventTags(&result)
+
+	// What the findings mean in the clients people actually use
+	result.Clients = assessClientCompat(result)
+	result.attachRemediations()
+	tier := assessTier(result)
+	result.Tier = &tier
+	result.DurationMs = time.Since(start).Milliseconds()
+	result.Probes = timings.slowest(probeLimit)
+	result.CutOff = b.cutOffPhases()
+
+	return result
+}
+
+// background runs f alongside whatever the caller does next and returns a
+// function that waits for its result. checkIdentity starts independent
+// lookups this way so they share its deadline instead of queueing for it.
+func background[T any](f func() T) func() T {
+	var v T
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		v = f()
+	}()
+	return func() T {
+		<-done
+		return v
 	}
-	if result.Score < result.MaxScore {
-		os.Exit(1)
+}
+
+// dmRelayLookup is the DM relay list (kind 10050) and its relays' scores.
+type dmRelayLookup struct {
+	src    string
+	evt    *nostr.Event
+	urls   []string
+	scores []RelayScore
+}
+
+func lookupDMRelays(ctx context.Context, checkRelays []checkRelay, pk nostr.PubKey) dmRelayLookup {
+	var l dmRelayLookup
+	l.src, l.evt = fetchKindFrom(ctx, checkRelays, pk, 10050)
+	if l.evt == nil {
+		return l
+	}
+	for _, tag := range l.evt.Tags {
+		if len(tag) >= 2 && tag[0] == "relay" {
+			l.urls = append(l.urls, tag[1])
+		}
+	}
+	if len(l.urls) > 0 {
+		l.scores = ScoreRelays(ctx, l.urls)
+	}
+	return l
+}
+
+// nip60Lookup is the NIP-60 wallet event and, if there is one, the nutzap
+// info (kind 10019) with its mints validated and its relays scored.
+type nip60Lookup struct {
+	kind         int // 17375, 37375 (old), or 0 if there's no wallet
+	evt          *nostr.Event
+	nutzapSrc    string
+	nutzap       *nostr.Event
+	p2pk         string
+	mintURLs     []string
+	mints        []MintInfo // in mintURLs order
+	nutzapRelays []RelayScore
+}
+
+func lookupNIP60Wallet(ctx context.Context, checkRelays []checkRelay, pk nostr.PubKey) nip60Lookup {
+	var l nip60Lookup
+	nutzap := background(func() SourcedEvent {
+		src, evt := fetchKindFrom(ctx, checkRelays, pk, 10019)
+		return SourcedEvent{Kind: 10019, Relay: src, Event: evt}
+	})
+	if _, l.evt = fetchKindFrom(ctx, checkRelays, pk, 17375); l.evt != nil {
+		l.kind = 17375
+	} else if _, l.evt = fetchKindFrom(ctx, checkRelays, pk, 37375); l.evt != nil { // backwards compat
+		l.kind = 37375
+	}
+	n := nutzap()
+	if l.evt == nil || n.Event == nil {
+		return l
+	}
+	l.nutzapSrc, l.nutzap = n.Relay, n.Event
+
+	// Extract mints and P2PK pubkey from kind 10019
+	for _, tag := range l.nutzap.Tags {
+		if len(tag) >= 2 && tag[0] == "mint" {
+			l.mintURLs = append(l.mintURLs, tag[1])
+		}
+		if len(tag) >= 2 && tag[0] == "pubkey" {
+			l.p2pk = tag[1]
+		}
+	}
+	var wg sync.WaitGroup
+	if urls := relayTags(l.nutzap); len(urls) > 0 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			l.nutzapRelays = ScoreRelays(ctx, urls)
+		}()
+	}
+	if len(l.mintURLs) > 0 {
+		l.mints = make([]MintInfo, len(l.mintURLs))
+	}
+	for i, mintURL := range l.mintURLs {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			l.mints[i] = validateMint(ctx, mintURL)
+		}()
+	}
+	wg.Wait()
+	return l
+}
+
+// recordEvent keeps the latest event of a kind (nil if none was found) and
+// the relay it came from.
+func (r *CheckResult) recordEvent(kind int, relay string, evt *nostr.Event) {
+	r.events[kind] = evt
+	if evt != nil {
+		r.sources[kind] = relay
+	}
+}
+
+// includeEvents embeds the events behind the verdicts in the JSON output,
+// so tools can reuse them without fetching again.
+func (r *CheckResult) includeEvents() {
+	for _, kind := range includedEventKinds {
+		if evt := r.events[kind]; evt != nil {
+			r.RawEvents = append(r.RawEvents, SourcedEvent{Kind: kind, Relay: r.sources[kind], Event: evt})
+		}
 	}
 }
 
 func (r *CheckResult) addCheck(name, status, detail string) {
+	now := time.Now()
+	var took int64
+	if !r.lap.IsZero() {
+		took = now.Sub(r.lap).Milliseconds()
+	}
+	r.lap = now
 	r.Checks = append(r.Checks, CheckItem{
-		Name:   name,
-		Status: status,
-		Detail: detail,
+		Name:       name,
+		Status:     status,
+		Detail:     detail,
+		DurationMs: took,
 	})
 }
 
 // checkRelay holds a persistent relay connection for the check command.
 type checkRelay struct {
-	url   string
-	relay *nostr.Relay
+	url     string
+	relay   *nostr.Relay
+	auth    *relayAuth
+	timings *probeTimings // nil unless a check is timing its queries
 }
 
 // connectCheckRelays opens persistent connections to all default relays for reuse
 // across multiple fetchKindFrom calls. This avoids opening 4+ WebSocket connections
 // per kind (up to 28+ total) and instead maintains just one connection per relay.
+// Callers that also publish should keep the RelayPool instead and use its
+// CheckRelays, so reads and writes share connections.
 func connectCheckRelays(ctx context.Context, relayURLs ...[]string) []checkRelay {
 	urls := defaultRelays
 	if len(relayURLs) > 0 && len(relayURLs[0]) > 0 {
 		urls = relayURLs[0]
 	}
-
-	type result struct {
-		url   string
-		relay *nostr.Relay
-	}
-
-	ch := make(chan result, len(urls))
-	for _, u := range urls {
-		go func(u string) {
-			relayCtx, _ := context.WithTimeout(ctx, 5*time.Second)
-			relay, err := nostr.RelayConnect(relayCtx, u, nostr.RelayOptions{})
-			if err != nil {
-				ch <- result{u, nil}
-				return
-			}
-			ch <- result{u, relay}
-		}(u)
-	}
-
-	var relays []checkRelay
-	for range urls {
-		r := <-ch
-		if r.relay != nil {
-			relays = append(relays, checkRelay{url: r.url, relay: r.relay})
-		}
-	}
-	return relays
+	return NewRelayPool(urls, true).CheckRelays()
 }
 
 // fetchKindFrom queries already-connected relays for a specific kind.
@@ -464,12 +907,16 @@ func connectCheckRelays(ctx context.Context, relayURLs ...[]string) []checkRelay
 // different versions. We collect results from all relays and return the one
 // with the latest created_at timestamp, which is the canonical version per NIP-01.
 func fetchKindFrom(ctx context.Context, relays []checkRelay, pk nostr.PubKey, kind int) (string, *nostr.Event) {
-	filter := nostr.Filter{
+	return fetchLatestFrom(ctx, relays, nostr.Filter{
 		Authors: []nostr.PubKey{pk},
 		Kinds:   []nostr.Kind{nostr.Kind(kind)},
 		Limit:   1,
-	}
+	})
+}
 
+// fetchLatestFrom is fetchKindFrom for an arbitrary filter, e.g. an
+// addressable event picked out by its "d" tag.
+func fetchLatestFrom(ctx context.Context, relays []checkRelay, filter nostr.Filter) (string, *nostr.Event) {
 	type fetchResult struct {
 		url string
 		evt *nostr.Event
@@ -479,7 +926,7 @@ func fetchKindFrom(ctx context.Context, relays []checkRelay, pk nostr.PubKey, ki
 
 	for _, cr := range relays {
 		go func(cr checkRelay) {
-			for evt := range cr.relay.QueryEvents(filter) {
+			for evt := range cr.queryEvents(filter) {
 				ch <- fetchResult{cr.url, &evt}
 				return
 			}
@@ -507,6 +954,43 @@ func fetchKindFrom(ctx context.Context, relays []checkRelay, pk nostr.PubKey, ki
 	return bestURL, bestEvt
 }
 
+// fetchAllFrom collects every event matching filter from all relays,
+// deduplicated by ID. Unlike fetchLatestFrom it keeps them all.
+func fetchAllFrom(ctx context.Context, relays []checkRelay, filter nostr.Filter) []nostr.Event {
+	seen := make(map[nostr.ID]nostr.Event)
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	for _, cr := range relays {
+		wg.Add(1)
+		go func(cr checkRelay) {
+			defer wg.Done()
+			for evt := range cr.queryEvents(filter) {
+				mu.Lock()
+				seen[evt.ID] = evt
+				mu.Unlock()
+			}
+		}(cr)
+	}
+
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-ctx.Done():
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	events := make([]nostr.Event, 0, len(seen))
+	for _, evt := range seen {
+		events = append(events, evt)
+	}
+	return events
+}
+
 func verifyNIP05(ctx context.Context, identifier string, expectedPK nostr.PubKey) bool {
 	var name, domain string
 	if strings.Contains(identifier, "@") {
@@ -545,32 +1029,57 @@ func verifyNIP05(ctx context.Context, identifier string, expectedPK nostr.PubKey
 }
 
 func verifyLUD16(ctx context.Context, lud16 string) bool {
-	parts := strings.Split(lud16, "@")
-	if len(parts) != 2 {
+	url, err := lnurlpURL(lud16)
+	if err != nil {
 		return false
 	}
-	name, domain := parts[0], parts[1]
+	return probeLNURLPay(ctx, url) == nil
+}
+
+// lnurlpURL turns a lightning address into its LUD-16 well-known URL.
+func lnurlpURL(lud16 string) (string, error) {
+	parts := strings.Split(lud16, "@")
+	if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+		return "", fmt.Errorf("%q isn't a lightning address (name@domain)", lud16)
+	}
+	return fmt.Sprintf("https://%s/.well-known/lnurlp/%s", parts[1], parts[0]), nil
+}
 
-	url := fmt.Sprintf("https://%s/.well-known/lnurlp/%s", domain, name)
+// probeLNURLPay fetches an LNURL-pay endpoint and checks that it still
+// answers with a pay request (LUD-06) that has a callback.
+func probeLNURLPay(ctx context.Context, url string) error {
 	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
 	if err != nil {
-		return false
+		return err
 	}
 
 	resp, err := http.DefaultClient.Do(req)
-	if err != nil || resp.StatusCode != 200 {
-		return false
+	if err != nil {
+		return err
 	}
 	defer resp.Body.Close()
+	if resp.StatusCode != 200 {
+		return fmt.Errorf("HTTP %d", resp.StatusCode)
+	}
 
 	var result struct {
+		Tag      string `json:"tag"`
 		Callback string `json:"callback"`
+		Status   string `json:"status"`
+		Reason   string `json:"reason"`
 	}
 	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
-		return false
+		return fmt.Errorf("not LNURL JSON: %w", err)
 	}
-
-	return result.Callback != ""
+	switch {
+	case result.Status == "ERROR":
+		return fmt.Errorf("service error: %s", result.Reason)
+	case result.Tag != "" && result.Tag != "payRequest":
+		return fmt.Errorf("unexpected LNURL tag %q", result.Tag)
+	case result.Callback == "":
+		return fmt.Errorf("no callback")
+	}
+	return nil
 }
 
 // resolveTarget accepts an npub, hex pubkey, or NIP-05 identifier and returns a pubkey.
@@ -657,14 +1166,18 @@ func parsePubkey(input string) (nostr.PubKey, error) {
 
 // imageInfo holds the result of probing a profile image URL.
 type imageInfo struct {
-	URL      string `json:"url"`
-	Status   int    `json:"status"`
-	Size     int64  `json:"size_bytes"` // -1 if unknown
-	Blossom  bool   `json:"blossom"`
-	SizeWarn bool   `json:"size_warn"` // true if > 1MB
+	URL         string `json:"url"`
+	Status      int    `json:"status"`
+	Size        int64  `json:"size_bytes"` // -1 if unknown
+	Blossom     bool   `json:"blossom"`
+	SizeWarn    bool   `json:"size_warn"`              // true if > 1MB
+	RangeGET    bool   `json:"range_get,omitempty"`    // HEAD was refused, so a ranged GET was used
+	ContentType string `json:"content_type,omitempty"` // sniffed from the first bytes, with RangeGET
+	NotImage    bool   `json:"not_image,omitempty"`    // the first bytes aren't any image format
 }
 
-// knownBlossomHosts is a set of known Blossom media servers.
+// knownBlossomHosts are Blossom media servers whose image URLs are always
+// probed for BUD-01 retrieval, even without a hash-shaped path.
 var knownBlossomHosts = map[string]bool{
 	"blossom.primal.net":  true,
 	"cdn.satellite.earth": true,
@@ -676,6 +1189,10 @@ var knownBlossomHosts = map[string]bool{
 
 const maxRecommendedImageSize = 1 << 20 // 1 MB
 
+// imageSniffBytes is how much of an image the ranged GET fallback fetches:
+// enough for any format's magic bytes.
+const imageSniffBytes = 4096
+
 func probeImage(ctx context.Context, rawURL string) imageInfo {
 	info := imageInfo{URL: rawURL, Size: -1}
 
@@ -685,8 +1202,12 @@ func probeImage(ctx context.Context, rawURL string) imageInfo {
 		return info
 	}
 
+	// Known Blossom hosts and content-addressed URLs are verified against
+	// BUD-01 retrieval instead of trusting the hostname alone
 	host := strings.ToLower(parsed.Hostname())
-	info.Blossom = knownBlossomHosts[host]
+	if knownBlossomHosts[host] || blossomHash(rawURL) != "" {
+		info.Blossom = probeBlossomBlob(ctx, rawURL)
+	}
 
 	req, err := http.NewRequestWithContext(ctx, "HEAD", rawURL, nil)
 	if err != nil {
@@ -709,9 +1230,75 @@ func probeImage(ctx context.Context, rawURL string) imageInfo {
 		}
 	}
 
+	// Many CDNs refuse HEAD (403, 405) but serve the image fine; a 404
+	// is an answer either way
+	if resp.StatusCode >= 400 && resp.StatusCode != 404 {
+		probeImageRange(ctx, &info)
+	}
 	return info
 }
 
+// probeImageRange fetches the first imageSniffBytes of an image with a
+// ranged GET, for servers that refuse HEAD. It takes the status and size
+// from that response and checks the magic bytes are an image's.
+func probeImageRange(ctx context.Context, info *imageInfo) {
+	req, err := http.NewRequestWithContext(ctx, "GET", info.URL, nil)
+	if err != nil {
+		return
+	}
+	req.Header.Set("Range", fmt.Sprintf("bytes=0-%d", imageSniffBytes-1))
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return
+	}
+	defer resp.Body.Close()
+
+	info.RangeGET = true
+	info.Status = resp.StatusCode
+	if resp.StatusCode >= 400 {
+		return
+	}
+	info.Size = -1
+	switch {
+	case resp.StatusCode == http.StatusPartialContent:
+		// Content-Range: bytes 0-4095/123456 (the total may be "*")
+		cr := resp.Header.Get("Content-Range")
+		if i := strings.LastIndex(cr, "/"); i >= 0 {
+			if n, err := strconv.ParseInt(cr[i+1:], 10, 64); err == nil {
+				info.Size = n
+			}
+		}
+	case resp.ContentLength >= 0:
+		info.Size = resp.ContentLength // the server ignored Range
+	}
+	info.SizeWarn = info.Size > maxRecommendedImageSize
+
+	head, _ := io.ReadAll(io.LimitReader(resp.Body, imageSniffBytes))
+	info.ContentType = sniffImageType(head)
+	info.NotImage = !strings.HasPrefix(info.ContentType, "image/")
+}
+
+// sniffImageType names the format of data from its magic bytes. Besides
+// what http.DetectContentType knows (JPEG, PNG, GIF, WebP, BMP, ICO) it
+// recognizes AVIF, HEIC, and SVG, which profile images also come in.
+func sniffImageType(data []byte) string {
+	if len(data) >= 12 && string(data[4:8]) == "ftyp" {
+		switch string(data[8:12]) {
+		case "avif", "avis":
+			return "image/avif"
+		case "heic", "heix", "mif1":
+			return "image/heic"
+		}
+	}
+	ct := http.DetectContentType(data)
+	if strings.HasPrefix(ct, "text/xml") || strings.HasPrefix(ct, "text/plain") {
+		if bytes.Contains(bytes.ToLower(data), []byte("<svg")) {
+			return "image/svg+xml"
+		}
+	}
+	return strings.TrimSpace(strings.SplitN(ct, ";", 2)[0])
+}
+
 func formatSize(bytes int64) string {
 	if bytes < 0 {
 		return "unknown size"
@@ -740,22 +1327,32 @@ func imageHostingTier(info imageInfo, nip05Domain string) (tier string, label st
 	return "third-party", "third-party"
 }
 
-func checkProfileImages(ctx context.Context, result *CheckResult, picture, banner, nip05Domain string) {
-	images := []struct {
-		name string
-		url  string
-	}{
-		{"picture", picture},
-		{"banner", banner},
+// profileImage is the picture or banner, probed in the background.
+type profileImage struct {
+	name string
+	url  string
+	info func() imageInfo // waits for the probe
+}
+
+// probeProfileImages starts probing the picture and banner at once.
+func probeProfileImages(ctx context.Context, picture, banner string) []profileImage {
+	images := []profileImage{{name: "picture", url: picture}, {name: "banner", url: banner}}
+	for i := range images {
+		if link := images[i].url; link != "" {
+			images[i].info = background(func() imageInfo { return probeImage(ctx, link) })
+		}
 	}
+	return images
+}
 
+func checkProfileImages(result *CheckResult, images []profileImage, nip05Domain string) {
 	for _, img := range images {
 		if img.url == "" {
 			result.addCheck(img.name, "fail", "not set")
 			continue
 		}
 
-		info := probeImage(ctx, img.url)
+		info := img.info()
 
 		// Reachability
 		if info.Status == -1 {
@@ -770,6 +1367,10 @@ func checkProfileImages(ctx context.Context, result *CheckResult, picture, banne
 			result.addCheck(img.name, "warn", fmt.Sprintf("HTTP %d: %s", info.Status, img.url))
 			continue
 		}
+		if info.NotImage {
+			result.addCheck(img.name, "fail", fmt.Sprintf("not an image (%s): %s", info.ContentType, img.url))
+			continue
+		}
 
 		// Hosting tier
 		tier, tierLabel := imageHostingTier(info, nip05Domain)
@@ -813,6 +1414,28 @@ func isRootNIP05(nip05 string) bool {
 	return parts[0] == "_"
 }
 
+// summaryLine condenses a check into one parseable line for prompts, cron
+// mail, and status bars: "npub1... score=7/8 fail=nip05 warn=banner,lud16".
+func summaryLine(r CheckResult) string {
+	var fails, warns []string
+	for _, c := range r.Checks {
+		switch c.Status {
+		case "fail":
+			fails = append(fails, c.Name)
+		case "warn":
+			warns = append(warns, c.Name)
+		}
+	}
+	line := fmt.Sprintf("%s score=%d/%d", r.Npub, r.Score, r.MaxScore)
+	if len(fails) > 0 {
+		line += " fail=" + strings.Join(fails, ",")
+	}
+	if len(warns) > 0 {
+		line += " warn=" + strings.Join(warns, ",")
+	}
+	return line
+}
+
 func printCheckResult(r CheckResult) {
 	statusIcon := map[string]string{
 		"pass": "✅",
@@ -820,9 +1443,18 @@ func printCheckResult(r CheckResult) {
 		"warn": "⚠️ ",
 	}
 
+	// A retired or compromised key trumps everything below
+	if r.KeyNotice != nil {
+		printKeyNotice(*r.KeyNotice)
+	}
+
 	for _, c := range r.Checks {
 		icon := statusIcon[c.Status]
-		fmt.Printf("  %s %s: %s\n", icon, c.Name, c.Detail)
+		line := fmt.Sprintf("  %s %s: %s", icon, c.Name, c.Detail)
+		if c.DurationMs >= slowCheckMs {
+			line += fmt.Sprintf(" (%s)", formatMs(c.DurationMs))
+		}
+		fmt.Println(line)
 	}
 
 	// Show wallet mint details if available
@@ -842,6 +1474,22 @@ func printCheckResult(r CheckResult) {
 		}
 	}
 
+	if r.Reports != nil && len(r.Reports.Recent) > 0 {
+		printRecentReports(r.Reports.Recent)
+	}
+
+	if r.Payments != nil {
+		printPaymentsReadiness(*r.Payments)
+	}
+
+	if r.Features != nil {
+		printFeatureMatrix(r.Features)
+	}
+
+	if len(r.Clients) > 0 {
+		printClientCompat(r.Clients)
+	}
+
 	fmt.Println()
 	pct := 0
 	if r.MaxScore > 0 {
@@ -849,11 +1497,5 @@ func printCheckResult(r CheckResult) {
 	}
 	fmt.Printf("  Score: %d/%d (%d%%)\n", r.Score, r.MaxScore, pct)
 
-	if r.Score == r.MaxScore {
-		fmt.Println("  🎉 Perfect identity!")
-	} else if r.Score >= r.MaxScore/2 {
-		fmt.Println("  👍 Good, but could be better")
-	} else {
-		fmt.Println("  👎 Needs work")
-	}
+	printTier(assessTier(r))
 }
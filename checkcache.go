@@ -0,0 +1,139 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"fiatjaf.com/nostr"
+)
+
+// checkCacheTTL is how long a complete check result is reused instead of
+// querying relays again. Set by --cache-ttl or $NIHAO_CACHE_TTL; zero (the
+// default) disables the cache, since a fix should show up on the next run.
+var checkCacheTTL time.Duration
+
+// checkCacheBypass is set by --no-cache: results are never read from the
+// cache, though a fresh one still replaces the cached one.
+var checkCacheBypass bool
+
+// cachedCheck is a stored check result. The events checks were derived
+// from are kept alongside, since the result alone doesn't serialize them
+// and policies and --include-events need them.
+type cachedCheck struct {
+	CheckedAt time.Time      `json:"checked_at"`
+	Result    CheckResult    `json:"result"`
+	Events    []SourcedEvent `json:"events"`
+}
+
+// checkCachePath is where pk's cached result lives, or "" if there's no
+// usable cache directory. Results gathered from custom relays are kept
+// apart from ones gathered from the defaults.
+func checkCachePath(pk nostr.PubKey, relays []string) string {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return ""
+	}
+	name := pk.Hex()
+	if len(relays) > 0 {
+		var urls []string
+		for _, r := range relays {
+			urls = append(urls, normalizeRelayURL(r))
+		}
+		slices.Sort(urls)
+		sum := sha256.Sum256([]byte(strings.Join(slices.Compact(urls), ",")))
+		name += "-" + hex.EncodeToString(sum[:6])
+	}
+	return filepath.Join(dir, "nihao", "checks", name+".json")
+}
+
+// loadCachedCheck returns pk's cached result if there's one younger than
+// checkCacheTTL, with CachedAt set so callers can show its age.
+func loadCachedCheck(pk nostr.PubKey, relays []string, now time.Time) (CheckResult, bool) {
+	if checkCacheTTL <= 0 || checkCacheBypass {
+		return CheckResult{}, false
+	}
+	path := checkCachePath(pk, relays)
+	if path == "" {
+		return CheckResult{}, false
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return CheckResult{}, false
+	}
+	var c cachedCheck
+	if err := json.Unmarshal(data, &c); err != nil || now.Sub(c.CheckedAt) >= checkCacheTTL || c.Result.Pubkey != pk.Hex() {
+		return CheckResult{}, false
+	}
+
+	r := c.Result
+	r.events = make(map[int]*nostr.Event)
+	r.sources = make(map[int]string)
+	for _, se := range c.Events {
+		r.events[se.Kind] = se.Event
+		r.sources[se.Kind] = se.Relay
+	}
+	r.CachedAt = &c.CheckedAt
+	return r, true
+}
+
+// saveCachedCheck stores a fresh result for pk. Errors are ignored: the
+// cache only saves work.
+func saveCachedCheck(pk nostr.PubKey, relays []string, r CheckResult, now time.Time) {
+	if checkCacheTTL <= 0 {
+		return
+	}
+	path := checkCachePath(pk, relays)
+	if path == "" {
+		return
+	}
+	data, err := json.Marshal(cachedCheck{CheckedAt: now, Result: r, Events: pluginInput(r).Events})
+	if err != nil {
+		return
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".check-*")
+	if err != nil {
+		return
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return
+	}
+	if tmp.Close() == nil {
+		os.Rename(tmp.Name(), path)
+	}
+}
+
+// cachedAge describes how old a cached result is, e.g. "4m ago".
+func cachedAge(at, now time.Time) string {
+	age := now.Sub(at)
+	switch {
+	case age < time.Minute:
+		return fmt.Sprintf("%ds ago", int(age.Seconds()))
+	case age < time.Hour:
+		return fmt.Sprintf("%dm ago", int(age.Minutes()))
+	}
+	return fmt.Sprintf("%dh%dm ago", int(age.Hours()), int(age.Minutes())%60)
+}
+
+// checkIdentityCached is checkIdentity behind the result cache, for
+// commands that check many identities or keep running.
+func checkIdentityCached(ctx context.Context, checkRelays []checkRelay, relays []string, pk nostr.PubKey) CheckResult {
+	if r, ok := loadCachedCheck(pk, relays, time.Now()); ok {
+		return r
+	}
+	r := checkIdentity(ctx, checkRelays, pk, false)
+	saveCachedCheck(pk, relays, r, time.Now())
+	return r
+}
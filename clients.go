@@ -0,0 +1,108 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// clientNeed is a check a client depends on and what users of that client
+// see when it doesn't pass. If broken is set, the check failing makes the
+// client unusable for reaching the identity; otherwise, and on a warning,
+// the client is only degraded.
+type clientNeed struct {
+	check    string
+	broken   string
+	degraded string
+}
+
+// clientNeeds maps check results to how popular clients behave, so a
+// failing check reads as "Damus can't DM you" rather than a kind number.
+// It reflects the clients' documented behavior and is kept deliberately
+// coarse.
+var clientNeeds = []struct {
+	client string
+	needs  []clientNeed
+}{
+	{"Damus", []clientNeed{
+		{"dm_relays", "can't DM you (no kind 10050)", "some DMs may not reach you"},
+		{"lud16", "", "no zap button"},
+		{"nip05", "", "no verified name"},
+		{"picture", "", "default avatar"},
+	}},
+	{"Amethyst", []clientNeed{
+		{"relay_list", "can't find your notes (no kind 10002 for outbox routing)", "outbox routing has few relays to use"},
+		{"relay_markers", "", "outbox routing degraded"},
+		{"dm_relays", "", "DMs fall back to legacy NIP-04"},
+		{"lud16", "", "no zap button"},
+	}},
+	{"Primal", []clientNeed{
+		{"propagation", "", "profile may look blank until Primal's cache picks it up"},
+		{"lud16", "", "no zap button"},
+		{"picture", "", "default avatar"},
+	}},
+	{"0xchat", []clientNeed{
+		{"dm_relays", "can't DM you (no kind 10050)", "some DMs may not reach you"},
+		{"nip05", "", "no verified name"},
+	}},
+	{"Coracle", []clientNeed{
+		{"relay_list", "can't find your notes (no kind 10002 for outbox routing)", "outbox routing has few relays to use"},
+		{"relay_markers", "", "outbox routing degraded"},
+		{"advertised_relays", "", "some of your write relays come up empty"},
+	}},
+	{"noStrudel", []clientNeed{
+		{"relay_list", "can't find your notes (no kind 10002 for outbox routing)", "outbox routing has few relays to use"},
+		{"nutzap_info", "", "can't nutzap you"},
+		{"lud16", "", "no zap button"},
+	}},
+}
+
+// ClientCompat is how one client will treat the identity, given the
+// check results: "works", "degraded", or "broken".
+type ClientCompat struct {
+	Client  string   `json:"client"`
+	Status  string   `json:"status"`
+	Impacts []string `json:"impacts,omitempty"`
+}
+
+// assessClientCompat maps failing and warning checks to their effect in
+// each client. Checks that didn't run don't count against a client.
+func assessClientCompat(r CheckResult) []ClientCompat {
+	var compat []ClientCompat
+	for _, c := range clientNeeds {
+		cc := ClientCompat{Client: c.client, Status: "works"}
+		for _, need := range c.needs {
+			status := r.status(need.check)
+			if status == "" || status == "pass" {
+				continue
+			}
+			if need.broken != "" && status == "fail" {
+				cc.Impacts = append(cc.Impacts, fmt.Sprintf("%s (%s)", need.broken, need.check))
+				cc.Status = "broken"
+				continue
+			}
+			cc.Impacts = append(cc.Impacts, fmt.Sprintf("%s (%s)", need.degraded, need.check))
+			if cc.Status == "works" {
+				cc.Status = "degraded"
+			}
+		}
+		compat = append(compat, cc)
+	}
+	return compat
+}
+
+func printClientCompat(compat []ClientCompat) {
+	statusIcon := map[string]string{"works": "✅", "degraded": "⚠️ ", "broken": "❌"}
+	width := 0
+	for _, cc := range compat {
+		width = max(width, len(cc.Client))
+	}
+	fmt.Println()
+	fmt.Println("  📱 Clients:")
+	for _, cc := range compat {
+		line := fmt.Sprintf("    %-*s  %s %s", width, cc.Client, statusIcon[cc.Status], cc.Status)
+		if len(cc.Impacts) > 0 {
+			line += " — " + strings.Join(cc.Impacts, "; ")
+		}
+		fmt.Println(line)
+	}
+}
@@ -0,0 +1,126 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"fiatjaf.com/nostr"
+)
+
+// CompareResult is the JSON output of `nihao check --compare`.
+type CompareResult struct {
+	Left  CheckResult  `json:"left"`
+	Right CheckResult  `json:"right"`
+	Rows  []CompareRow `json:"rows"`
+}
+
+// CompareRow holds one check's status for both identities ("" if the check
+// didn't run for that identity, e.g. wallet_mints without a wallet).
+type CompareRow struct {
+	Name  string `json:"name"`
+	Left  string `json:"left"`
+	Right string `json:"right"`
+}
+
+// compareChecks lines up the checks of two results, in the order they first
+// appear (left before right).
+func compareChecks(left, right CheckResult) []CompareRow {
+	var rows []CompareRow
+	seen := make(map[string]bool)
+	for _, r := range []CheckResult{left, right} {
+		for _, c := range r.Checks {
+			if seen[c.Name] {
+				continue
+			}
+			seen[c.Name] = true
+			rows = append(rows, CompareRow{Name: c.Name, Left: left.status(c.Name), Right: right.status(c.Name)})
+		}
+	}
+	return rows
+}
+
+// runCompare checks two identities against the same relays and prints their
+// statuses side by side.
+func runCompare(left, right string, jsonOutput bool, quiet bool, relays []string) {
+	var pks [2]nostr.PubKey
+	for i, target := range []string{left, right} {
+		pk, err := resolveTarget(target, jsonOutput || quiet)
+		if err != nil {
+			fatal("%s: %s", target, err)
+		}
+		pks[i] = pk
+	}
+
+	if !jsonOutput && !quiet {
+		fmt.Printf("nihao compare 🔍 %s ↔ %s\n\n", left, right)
+	}
+
+	connectCtx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
+	checkRelays := connectCheckRelays(connectCtx, relays)
+	cancel()
+	if len(checkRelays) == 0 {
+		fatal("could not connect to any relay")
+	}
+	defer func() {
+		for _, cr := range checkRelays {
+			cr.relay.Close()
+		}
+	}()
+
+	var results [2]CheckResult
+	for i, pk := range pks {
+		ctx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
+		results[i] = checkIdentity(ctx, checkRelays, pk, false)
+		cancel()
+	}
+
+	result := CompareResult{
+		Left:  results[0],
+		Right: results[1],
+		Rows:  compareChecks(results[0], results[1]),
+	}
+
+	if jsonOutput {
+		out, _ := json.MarshalIndent(result, "", "  ")
+		fmt.Println(string(out))
+	} else if !quiet {
+		printCompareResult(result)
+	}
+}
+
+// compareLabel names an identity by its profile name, falling back to a
+// shortened npub.
+func compareLabel(r CheckResult) string {
+	if evt := r.events[0]; evt != nil {
+		var meta ProfileMetadata
+		json.Unmarshal([]byte(evt.Content), &meta)
+		if meta.Name != "" {
+			return meta.Name
+		}
+	}
+	return r.Npub[:12] + "…"
+}
+
+func printCompareResult(c CompareResult) {
+	statusLabel := map[string]string{
+		"pass": "✓ pass",
+		"fail": "✗ fail",
+		"warn": "! warn",
+		"":     "- none",
+	}
+
+	fmt.Printf("  %-16s %-20s %s\n", "", compareLabel(c.Left), compareLabel(c.Right))
+	for _, row := range c.Rows {
+		marker := ""
+		if row.Left == "pass" && row.Right != "pass" {
+			marker = "  ← missing"
+		}
+		fmt.Printf("  %-16s %-20s %-6s%s\n", row.Name, statusLabel[row.Left], statusLabel[row.Right], marker)
+	}
+	fmt.Println()
+	fmt.Printf("  %-16s %-20s %s\n", "score",
+		fmt.Sprintf("%d/%d", c.Left.Score, c.Left.MaxScore),
+		fmt.Sprintf("%d/%d", c.Right.Score, c.Right.MaxScore))
+}
@@ -0,0 +1,97 @@
+package main
+
+import (
+	"net"
+	"net/url"
+	"strings"
+
+	"golang.org/x/net/publicsuffix"
+)
+
+// relayNetwork resolves a relay's host and returns its network prefix (/24
+// for IPv4, /48 for IPv6). Relays sharing a prefix very likely sit with the
+// same hosting provider. Returns "" if the host doesn't resolve.
+func relayNetwork(relayURL string) string {
+	u, err := url.Parse(relayURL)
+	if err != nil {
+		return ""
+	}
+	ips, err := net.LookupIP(u.Hostname())
+	if err != nil || len(ips) == 0 {
+		return ""
+	}
+	ip := ips[0]
+	if v4 := ip.To4(); v4 != nil {
+		return v4.Mask(net.CIDRMask(24, 32)).String() + "/24"
+	}
+	return ip.Mask(net.CIDRMask(48, 128)).String() + "/48"
+}
+
+// relaySoftware reduces a NIP-11 software field to a short name, e.g.
+// "git+https://github.com/hoytech/strfry.git" → "strfry".
+func relaySoftware(info *RelayInfo) string {
+	if info == nil || info.Software == "" {
+		return ""
+	}
+	s := strings.TrimSuffix(strings.TrimRight(info.Software, "/"), ".git")
+	return strings.ToLower(s[strings.LastIndex(s, "/")+1:])
+}
+
+// relayDiversity tracks what a relay selection already depends on, so one
+// operator, domain, or hosting network can't take out the whole set.
+type relayDiversity struct {
+	used            map[string]bool // "operator:", "domain:", "network:" keys
+	software        map[string]int
+	maxSameSoftware int
+}
+
+func newRelayDiversity(maxCount int) *relayDiversity {
+	return &relayDiversity{
+		used:            make(map[string]bool),
+		software:        make(map[string]int),
+		maxSameSoftware: max(1, (maxCount+1)/2),
+	}
+}
+
+// keys returns the failure domains a relay belongs to.
+func (d *relayDiversity) keys(rs RelayScore) []string {
+	var keys []string
+	if rs.Info != nil && rs.Info.Pubkey != "" {
+		keys = append(keys, "operator:"+strings.ToLower(rs.Info.Pubkey))
+	}
+	if u, err := url.Parse(rs.URL); err == nil {
+		host := u.Hostname()
+		if domain, err := publicsuffix.EffectiveTLDPlusOne(host); err == nil {
+			keys = append(keys, "domain:"+domain)
+		} else {
+			keys = append(keys, "domain:"+host)
+		}
+	}
+	if rs.Network != "" {
+		keys = append(keys, "network:"+rs.Network)
+	}
+	return keys
+}
+
+// allows reports whether rs shares no operator, domain, or network with
+// the relays picked so far, and doesn't push one software past its share.
+func (d *relayDiversity) allows(rs RelayScore) bool {
+	for _, k := range d.keys(rs) {
+		if d.used[k] {
+			return false
+		}
+	}
+	if sw := relaySoftware(rs.Info); sw != "" && d.software[sw] >= d.maxSameSoftware {
+		return false
+	}
+	return true
+}
+
+func (d *relayDiversity) add(rs RelayScore) {
+	for _, k := range d.keys(rs) {
+		d.used[k] = true
+	}
+	if sw := relaySoftware(rs.Info); sw != "" {
+		d.software[sw]++
+	}
+}
@@ -0,0 +1,140 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"fiatjaf.com/nostr"
+	"fiatjaf.com/nostr/nip17"
+	"fiatjaf.com/nostr/nip59"
+)
+
+// DMLoopbackRelay is how one kind 10050 relay handled the loopback DM.
+type DMLoopbackRelay struct {
+	URL    string `json:"url"`
+	Status string `json:"status"` // "ok", "unreachable", "rejected", "not returned", "unwrap failed"
+	Detail string `json:"detail,omitempty"`
+}
+
+// checkDMLoopback sends a NIP-17 DM from the identity to itself through its
+// kind 10050 relays, then fetches it back from each one and unwraps it.
+// A 10050 event only says where DMs should go; this proves the relays take
+// gift wraps, hand them back to their recipient (usually after NIP-42
+// AUTH), and that the key decrypts them. Not scored: it needs the key.
+func checkDMLoopback(result *CheckResult, kr nostr.Keyer, relayURLs []string) {
+	ctx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
+	defer cancel()
+
+	pk, err := kr.GetPublicKey(ctx)
+	if err != nil {
+		result.addCheck("dm_loopback", "warn", fmt.Sprintf("could not get the public key: %s", err))
+		return
+	}
+	marker := "nihao DM loopback test " + randomHex(4)
+	// The gift wrap's key is thrown away, so it can't be deleted; relays
+	// that honor expiration drop it, and the rest keep a DM only the user
+	// can read
+	expiration := expirationTag(time.Now(), testEventTTL)
+	wrap, _, err := nip17.PrepareMessage(ctx, marker, nil, kr, pk, func(gw *nostr.Event) {
+		gw.Tags = append(gw.Tags, expiration)
+	})
+	if err != nil {
+		result.addCheck("dm_loopback", "warn", fmt.Sprintf("could not build the gift wrap: %s", err))
+		return
+	}
+
+	relays := connectCheckRelays(ctx, relayURLs)
+	defer func() {
+		for _, cr := range relays {
+			cr.relay.Close()
+		}
+	}()
+	enableAuth(relays, kr)
+	connected := make(map[string]checkRelay)
+	for _, cr := range relays {
+		connected[cr.url] = cr
+	}
+
+	outcomes := make([]DMLoopbackRelay, len(relayURLs))
+	var wg sync.WaitGroup
+	for i, u := range relayURLs {
+		outcomes[i] = DMLoopbackRelay{URL: u, Status: "unreachable"}
+		cr, ok := connected[u]
+		if !ok {
+			continue
+		}
+		wg.Add(1)
+		go func(out *DMLoopbackRelay, cr checkRelay) {
+			defer wg.Done()
+			out.Status, out.Detail = dmLoopbackRelay(ctx, cr, kr, wrap, pk, marker)
+		}(&outcomes[i], cr)
+	}
+	wg.Wait()
+
+	result.DMLoopback = outcomes
+	status, detail := assessDMLoopback(outcomes)
+	result.addCheck("dm_loopback", status, detail)
+}
+
+// dmLoopbackRelay publishes the gift wrap to one relay, authenticating if
+// it asks, then reads it back as the recipient and unwraps it.
+func dmLoopbackRelay(ctx context.Context, cr checkRelay, kr nostr.Keyer, wrap nostr.Event, pk nostr.PubKey, marker string) (string, string) {
+	err := cr.relay.Publish(ctx, wrap)
+	if err != nil && strings.HasPrefix(err.Error(), "auth-required:") && cr.auth.authenticate(cr.relay) {
+		err = cr.relay.Publish(ctx, wrap)
+	}
+	if err != nil {
+		return "rejected", err.Error()
+	}
+
+	filter := nostr.Filter{IDs: []nostr.ID{wrap.ID}, Kinds: []nostr.Kind{nostr.KindGiftWrap}, Tags: nostr.TagMap{"p": []string{pk.Hex()}}}
+	for evt := range cr.queryEvents(filter) {
+		rumor, err := nip59.GiftUnwrap(evt, func(sender nostr.PubKey, ciphertext string) (string, error) {
+			return kr.Decrypt(ctx, ciphertext, sender)
+		})
+		switch {
+		case err != nil:
+			return "unwrap failed", err.Error()
+		case rumor.PubKey != pk || rumor.Content != marker:
+			return "unwrap failed", "unwrapped message doesn't match what was sent"
+		}
+		return "ok", ""
+	}
+	cr.auth.mu.Lock()
+	defer cr.auth.mu.Unlock()
+	if cr.auth.status == "auth-failed" {
+		return "not returned", "AUTH failed: " + cr.auth.reason
+	}
+	return "not returned", "accepted the gift wrap but didn't return it to its recipient"
+}
+
+// assessDMLoopback turns per-relay outcomes into a check status and detail.
+func assessDMLoopback(outcomes []DMLoopbackRelay) (string, string) {
+	ok := 0
+	var broken []string
+	for _, o := range outcomes {
+		if o.Status == "ok" {
+			ok++
+			continue
+		}
+		s := fmt.Sprintf("%s %s", o.URL, o.Status)
+		if o.Detail != "" {
+			s += " (" + o.Detail + ")"
+		}
+		broken = append(broken, s)
+	}
+	detail := fmt.Sprintf("DM to self delivered and unwrapped on %d/%d DM relay(s)", ok, len(outcomes))
+	switch {
+	case len(outcomes) == 0:
+		return "warn", "no DM relays to test"
+	case ok == len(outcomes):
+		return "pass", detail
+	case ok > 0:
+		return "warn", detail + " — " + strings.Join(broken, "; ")
+	default:
+		return "fail", detail + " — " + strings.Join(broken, "; ") + " — NIP-17 DMs to you will likely be lost"
+	}
+}
@@ -0,0 +1,248 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"fiatjaf.com/nostr"
+	"fiatjaf.com/nostr/nip19"
+)
+
+type dmRelayOpts struct {
+	keys       keySource
+	relays     []string
+	count      int
+	publish    bool
+	jsonOutput bool
+	quiet      bool
+}
+
+// DMRelayCandidate is a relay seen in sampled kind 10050 lists, rated for
+// receiving NIP-17 DMs.
+type DMRelayCandidate struct {
+	URL       string   `json:"url"`
+	UsedBy    int      `json:"used_by"` // sampled npubs listing it
+	Reachable bool     `json:"reachable"`
+	AUTH      bool     `json:"auth"`  // NIP-42, so gift wraps can be served only to their recipient
+	NIP17     bool     `json:"nip17"` // advertises NIP-17 or NIP-59
+	Paid      bool     `json:"paid"`
+	LatencyMs int64    `json:"latency_ms"`
+	Score     float64  `json:"score"` // 0.0 - 1.0
+	Notes     []string `json:"notes,omitempty"`
+}
+
+// DMRelayDiscoverResult is the JSON output of `nihao dm-relays discover`.
+type DMRelayDiscoverResult struct {
+	Candidates []DMRelayCandidate `json:"candidates"`
+	Selected   []string           `json:"selected"`
+	Published  bool               `json:"published"`
+	Accepted   int                `json:"accepted,omitempty"`
+}
+
+func parseDMRelayFlags(args []string) dmRelayOpts {
+	opts := dmRelayOpts{count: 3}
+	for i := 0; i < len(args); i++ {
+		if next, ok := opts.keys.keyFlag(args, i); ok {
+			i = next
+			continue
+		}
+		a := args[i]
+		switch {
+		case a == "--relays" && i+1 < len(args):
+			i++
+			opts.relays = strings.Split(args[i], ",")
+		case a == "--count" && i+1 < len(args):
+			i++
+			n, err := strconv.Atoi(args[i])
+			if err != nil || n < 1 {
+				fatal("--count must be a positive number")
+			}
+			opts.count = n
+		case a == "--publish":
+			opts.publish = true
+		case a == "--json":
+			opts.jsonOutput = true
+		case a == "--quiet" || a == "-q":
+			opts.quiet = true
+		default:
+			fatal("unknown flag: %s (see nihao help)", a)
+		}
+	}
+	return opts
+}
+
+// assessDMRelay rates a relay for NIP-17 DMs. Beyond general quality, DM
+// relays should AUTH readers (so only the recipient can fetch their gift
+// wraps) and not charge senders, who are usually strangers.
+func assessDMRelay(rs RelayScore, usedBy int) DMRelayCandidate {
+	c := DMRelayCandidate{
+		URL:       rs.URL,
+		UsedBy:    usedBy,
+		Reachable: rs.Reachable,
+		LatencyMs: rs.LatencyMs,
+		Paid:      rs.PaymentRequired,
+	}
+	if !rs.Reachable {
+		c.Notes = append(c.Notes, "unreachable")
+		return c
+	}
+	if rs.Info != nil {
+		c.AUTH = slices.Contains(rs.Info.SupportedNIPs, 42)
+		c.NIP17 = slices.Contains(rs.Info.SupportedNIPs, 17) || slices.Contains(rs.Info.SupportedNIPs, 59)
+	}
+
+	score := rs.Score
+	if c.AUTH {
+		score += 0.2
+	} else {
+		c.Notes = append(c.Notes, "no NIP-42 AUTH: anyone can fetch your gift wraps")
+	}
+	if c.NIP17 {
+		score += 0.1
+	}
+	if c.Paid {
+		score -= 0.3
+		c.Notes = append(c.Notes, "paid: senders may be rejected")
+	}
+	score += 0.05 * float64(min(usedBy, 4))
+	c.Score = max(0, min(1, score))
+	return c
+}
+
+// selectDMRelays picks the best n reachable candidates.
+func selectDMRelays(candidates []DMRelayCandidate, n int) []string {
+	var selected []string
+	for _, c := range candidates {
+		if len(selected) >= n {
+			break
+		}
+		if c.Reachable && !c.Paid {
+			selected = append(selected, c.URL)
+		}
+	}
+	return selected
+}
+
+// runDMRelaysDiscover samples kind 10050 lists from well-connected npubs,
+// rates the relays for DM use, and optionally publishes the best ones as
+// the user's own kind 10050.
+func runDMRelaysDiscover(args []string) {
+	opts := parseDMRelayFlags(args)
+	logln := func(a ...any) {
+		if !opts.jsonOutput && !opts.quiet {
+			fmt.Println(a...)
+		}
+	}
+
+	if opts.publish && !opts.keys.isSet() {
+		fatal("--publish signs with your key: pass --sec, --stdin, --sec-cmd, or --bunker")
+	}
+	var kr nostr.Keyer
+	if opts.keys.isSet() {
+		var err error
+		if kr, _, err = opts.keys.signer(context.Background()); err != nil {
+			fatal("%s", err)
+		}
+	}
+
+	seeds := opts.relays
+	if len(seeds) == 0 {
+		seeds = defaultRelays
+	}
+	logln("nihao dm-relays 📬 discover")
+	logln()
+	logln("🔍 Sampling DM relay lists (kind 10050)...")
+	usage := sampleDMRelayLists(seeds)
+	if len(usage) == 0 {
+		fatal("no kind 10050 lists found on %s", strings.Join(seeds, ", "))
+	}
+
+	var urls []string
+	for u := range usage {
+		urls = append(urls, u)
+	}
+	result := DMRelayDiscoverResult{}
+	for _, rs := range ScoreRelays(context.Background(), urls) {
+		result.Candidates = append(result.Candidates, assessDMRelay(rs, usage[rs.URL]))
+	}
+	sort.Slice(result.Candidates, func(i, j int) bool {
+		if result.Candidates[i].Score != result.Candidates[j].Score {
+			return result.Candidates[i].Score > result.Candidates[j].Score
+		}
+		return result.Candidates[i].URL < result.Candidates[j].URL
+	})
+	for _, c := range result.Candidates {
+		if !c.Reachable {
+			logln(fmt.Sprintf("   ✗ %s (unreachable)", c.URL))
+			continue
+		}
+		var flags []string
+		if c.AUTH {
+			flags = append(flags, "AUTH")
+		}
+		if c.NIP17 {
+			flags = append(flags, "NIP-17")
+		}
+		flags = append(flags, fmt.Sprintf("%dms", c.LatencyMs), fmt.Sprintf("used by %d", c.UsedBy))
+		logln(fmt.Sprintf("   %.2f %s (%s)", c.Score, c.URL, strings.Join(flags, ", ")))
+		for _, note := range c.Notes {
+			logln(fmt.Sprintf("        ⚠ %s", note))
+		}
+	}
+	logln()
+
+	result.Selected = selectDMRelays(result.Candidates, opts.count)
+	if len(result.Selected) == 0 {
+		fatal("no reachable, free DM relays found")
+	}
+	logln(fmt.Sprintf("📬 Recommended: %s", strings.Join(result.Selected, ", ")))
+	logln()
+
+	if opts.publish {
+		var tags nostr.Tags
+		for _, r := range result.Selected {
+			tags = append(tags, nostr.Tag{"relay", r})
+		}
+		evt := nostr.Event{
+			CreatedAt: nostr.Now(),
+			Kind:      10050,
+			Tags:      tags,
+		}
+		signWith(kr, &evt)
+
+		// Publish where the user's relay list says they write, too
+		pool := NewRelayPool(seeds, opts.quiet || opts.jsonOutput)
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		if _, relayEvt := fetchKindFrom(ctx, pool.CheckRelays(), evt.PubKey, 10002); relayEvt != nil {
+			pool.Add(mergeRelayURLs(seeds, writeRelays(relayEvt))...)
+		}
+		cancel()
+
+		logln(fmt.Sprintf("📬 Publishing DM relay list (kind 10050) for %s...", nip19.EncodeNpub(evt.PubKey)))
+		for _, r := range pool.Publish(evt) {
+			if r.OK {
+				result.Accepted++
+			}
+		}
+		pool.Close()
+		result.Published = result.Accepted > 0
+		logln()
+	} else {
+		logln("Run again with --publish and your key to publish this as your kind 10050.")
+	}
+
+	if opts.jsonOutput {
+		out, _ := json.MarshalIndent(result, "", "  ")
+		fmt.Println(string(out))
+	}
+	if opts.publish && !result.Published {
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,250 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"fiatjaf.com/nostr"
+)
+
+// NostrJSON is a NIP-05 /.well-known/nostr.json document.
+type NostrJSON struct {
+	Names  map[string]string   `json:"names"`
+	Relays map[string][]string `json:"relays,omitempty"`
+}
+
+// DomainEntry is the health of a single name in a NIP-05 directory.
+type DomainEntry struct {
+	Name   string   `json:"name"`
+	Pubkey string   `json:"pubkey"`
+	Status string   `json:"status"` // "pass", "fail", "warn"
+	Detail string   `json:"detail,omitempty"`
+	Relays []string `json:"relays,omitempty"`
+}
+
+// DomainCheckResult is the output of `nihao check --domain`.
+type DomainCheckResult struct {
+	Domain      string        `json:"domain"`
+	Total       int           `json:"total"`
+	Healthy     int           `json:"healthy"`
+	Entries     []DomainEntry `json:"entries"`
+	RelayIssues []string      `json:"relay_issues,omitempty"`
+}
+
+// nip05NameRe is the local-part alphabet allowed by NIP-05.
+var nip05NameRe = regexp.MustCompile(`^[a-z0-9._-]+$`)
+
+// fetchNostrJSON downloads the full nostr.json of a domain (no ?name= filter).
+func fetchNostrJSON(ctx context.Context, domain string) (*NostrJSON, error) {
+	reqURL := fmt.Sprintf("https://%s/.well-known/nostr.json", domain)
+	req, err := http.NewRequestWithContext(ctx, "GET", reqURL, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("HTTP request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != 200 {
+		return nil, fmt.Errorf("HTTP %d from %s", resp.StatusCode, domain)
+	}
+
+	var doc NostrJSON
+	if err := json.NewDecoder(resp.Body).Decode(&doc); err != nil {
+		return nil, fmt.Errorf("invalid JSON response: %w", err)
+	}
+	return &doc, nil
+}
+
+// validateNostrJSON checks the structure of a nostr.json document without
+// touching the network. It returns one entry per name, sorted by name, with
+// malformed entries already failed, plus problems found in the relays map.
+func validateNostrJSON(doc *NostrJSON) ([]DomainEntry, []string) {
+	var entries []DomainEntry
+	known := make(map[string]bool)
+	for name, hex := range doc.Names {
+		entry := DomainEntry{Name: name, Pubkey: hex, Relays: doc.Relays[hex]}
+		if _, err := nostr.PubKeyFromHex(hex); err != nil {
+			entry.Status = "fail"
+			entry.Detail = "invalid pubkey (must be 64-char lowercase hex)"
+		} else if !nip05NameRe.MatchString(name) {
+			entry.Status = "warn"
+			entry.Detail = "name has characters outside a-z0-9-_. (some clients won't match it)"
+		}
+		known[hex] = true
+		entries = append(entries, entry)
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Name < entries[j].Name })
+
+	var issues []string
+	for hex, relays := range doc.Relays {
+		if !known[hex] {
+			issues = append(issues, fmt.Sprintf("relays entry for %s… has no matching name", truncHex(hex)))
+		}
+		for _, r := range relays {
+			if normalizeRelayURL(r) == "" {
+				issues = append(issues, fmt.Sprintf("invalid relay URL %q for %s…", r, truncHex(hex)))
+			}
+		}
+	}
+	sort.Strings(issues)
+	return entries, issues
+}
+
+func truncHex(hex string) string {
+	if len(hex) > 12 {
+		return hex[:12]
+	}
+	return hex
+}
+
+// nip05Matches reports whether a profile's nip05 field claims identifier.
+// "_@domain" and the bare "domain" are equivalent.
+func nip05Matches(claimed, name, domain string) bool {
+	claimed = strings.ToLower(strings.TrimSpace(claimed))
+	want := strings.ToLower(name + "@" + domain)
+	if claimed == want {
+		return true
+	}
+	return name == "_" && claimed == strings.ToLower(domain)
+}
+
+// runDomainCheck verifies every name in a domain's nostr.json against the
+// profiles it points to. Exits 1 if any name is unhealthy.
+func runDomainCheck(domain string, jsonOutput bool, quiet bool, relays []string) {
+	domain = strings.ToLower(strings.TrimSpace(domain))
+	verbose := !jsonOutput && !quiet
+	if verbose {
+		fmt.Printf("nihao domain 🌐 %s\n\n", domain)
+	}
+
+	fetchCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	doc, err := fetchNostrJSON(fetchCtx, domain)
+	cancel()
+	if err != nil {
+		fatal("could not fetch nostr.json: %s", err)
+	}
+	if len(doc.Names) == 0 {
+		fatal("nostr.json at %s lists no names (the server may only answer ?name= queries)", domain)
+	}
+
+	entries, relayIssues := validateNostrJSON(doc)
+
+	connectCtx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
+	checkRelays := connectCheckRelays(connectCtx, relays)
+	cancel()
+	if len(checkRelays) == 0 {
+		fatal("could not connect to any relay")
+	}
+	defer func() {
+		for _, cr := range checkRelays {
+			cr.relay.Close()
+		}
+	}()
+
+	// Verify each mapping resolves back, a few names at a time
+	var wg sync.WaitGroup
+	sem := make(chan struct{}, 8)
+	for i := range entries {
+		if entries[i].Status == "fail" {
+			continue
+		}
+		wg.Add(1)
+		go func(e *DomainEntry) {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+			checkDomainEntry(e, domain, checkRelays)
+		}(&entries[i])
+	}
+	wg.Wait()
+
+	result := DomainCheckResult{
+		Domain:      domain,
+		Total:       len(entries),
+		Entries:     entries,
+		RelayIssues: relayIssues,
+	}
+	for _, e := range entries {
+		if e.Status == "pass" {
+			result.Healthy++
+		}
+	}
+
+	if jsonOutput {
+		out, _ := json.MarshalIndent(result, "", "  ")
+		fmt.Println(string(out))
+	} else if !quiet {
+		printDomainCheckResult(result)
+	}
+	if result.Healthy < result.Total || len(relayIssues) > 0 {
+		os.Exit(1)
+	}
+}
+
+// checkDomainEntry fetches the profile behind a name and checks that it
+// claims the name back. Entries flagged by validateNostrJSON keep their
+// warning unless the profile check fails outright.
+func checkDomainEntry(e *DomainEntry, domain string, checkRelays []checkRelay) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	pk, _ := nostr.PubKeyFromHex(e.Pubkey)
+	_, evt := fetchKindFrom(ctx, checkRelays, pk, 0)
+	if evt == nil {
+		e.Status, e.Detail = "fail", "no kind 0 found"
+		return
+	}
+
+	var meta ProfileMetadata
+	json.Unmarshal([]byte(evt.Content), &meta)
+	switch {
+	case meta.NIP05 == "":
+		e.Status, e.Detail = "fail", "profile doesn't set nip05"
+	case !nip05Matches(meta.NIP05, e.Name, domain):
+		e.Status, e.Detail = "fail", fmt.Sprintf("profile claims %s instead", meta.NIP05)
+	case e.Status == "":
+		e.Status = "pass"
+		if meta.Name != "" {
+			e.Detail = meta.Name
+		}
+	}
+}
+
+func printDomainCheckResult(r DomainCheckResult) {
+	statusIcon := map[string]string{
+		"pass": "✅",
+		"fail": "❌",
+		"warn": "⚠️ ",
+	}
+
+	for _, e := range r.Entries {
+		line := fmt.Sprintf("  %s %s@%s", statusIcon[e.Status], e.Name, r.Domain)
+		if e.Detail != "" {
+			line += ": " + e.Detail
+		}
+		fmt.Println(line)
+	}
+
+	if len(r.RelayIssues) > 0 {
+		fmt.Println()
+		fmt.Println("  Relays map:")
+		for _, issue := range r.RelayIssues {
+			fmt.Printf("    ✗ %s\n", issue)
+		}
+	}
+
+	fmt.Println()
+	fmt.Printf("  🌐 %d/%d names healthy\n", r.Healthy, r.Total)
+}
@@ -0,0 +1,203 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// Outcomes of probing one address family of a service.
+const (
+	familyOK          = "ok"
+	familyNoAddress   = "no address"  // no A/AAAA record
+	familyUnreachable = "unreachable" // has addresses, none accept connections
+	familyUntested    = "untested"    // this machine can't reach that family at all
+)
+
+// ServiceFamilies is how a relay or HTTP endpoint answers over IPv4 and
+// IPv6. Clients pick whichever family their network has, so a service
+// that only works over one of them works for some users and not others.
+type ServiceFamilies struct {
+	Service string `json:"service"` // "relay", "nip05", "lnurl"
+	Host    string `json:"host"`
+	IPv4    string `json:"ipv4"`
+	IPv6    string `json:"ipv6"`
+	Proxied bool   `json:"proxied,omitempty"` // reached through a proxy, so families weren't probed
+}
+
+// probeFamily resolves host for one family ("ip4" or "ip6") and dials the
+// first few addresses.
+func probeFamily(ctx context.Context, family, host, port string) string {
+	ips, err := net.DefaultResolver.LookupIP(ctx, family, host)
+	if err != nil {
+		var dnsErr *net.DNSError
+		if errors.As(err, &dnsErr) && (dnsErr.IsTimeout || dnsErr.IsTemporary) {
+			return familyUntested
+		}
+		return familyNoAddress
+	}
+	network := map[string]string{"ip4": "tcp4", "ip6": "tcp6"}[family]
+	dialer := net.Dialer{Timeout: 3 * time.Second}
+	var lastErr error
+	for _, ip := range ips[:min(len(ips), 2)] {
+		conn, err := dialer.DialContext(ctx, network, net.JoinHostPort(ip.String(), port))
+		if err == nil {
+			conn.Close()
+			return familyOK
+		}
+		lastErr = err
+	}
+	// No route for the whole family is our network, not the service
+	if errors.Is(lastErr, syscall.ENETUNREACH) || errors.Is(lastErr, syscall.EADDRNOTAVAIL) || errors.Is(lastErr, syscall.EAFNOSUPPORT) {
+		return familyUntested
+	}
+	return familyUnreachable
+}
+
+// probeFamilies probes IPv4 and IPv6 for host:port in parallel. Behind a
+// proxy, direct dials say nothing about what users see, so it doesn't try.
+func probeFamilies(ctx context.Context, service, host, port string) ServiceFamilies {
+	sf := ServiceFamilies{Service: service, Host: host}
+	if proxyFor(net.JoinHostPort(host, port)) != nil {
+		sf.IPv4, sf.IPv6, sf.Proxied = familyUntested, familyUntested, true
+		return sf
+	}
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		sf.IPv4 = probeFamily(ctx, "ip4", host, port)
+	}()
+	go func() {
+		defer wg.Done()
+		sf.IPv6 = probeFamily(ctx, "ip6", host, port)
+	}()
+	wg.Wait()
+	return sf
+}
+
+// serviceEndpoint is a service still to be probed.
+type serviceEndpoint struct {
+	service, host, port string
+}
+
+// urlEndpoint is the host and port behind a relay or HTTP URL.
+func urlEndpoint(service, rawURL string) (serviceEndpoint, bool) {
+	u, err := url.Parse(rawURL)
+	if err != nil || u.Hostname() == "" {
+		return serviceEndpoint{}, false
+	}
+	port := u.Port()
+	if port == "" {
+		switch u.Scheme {
+		case "wss", "https":
+			port = "443"
+		case "ws", "http":
+			port = "80"
+		default:
+			return serviceEndpoint{}, false
+		}
+	}
+	return serviceEndpoint{service, u.Hostname(), port}, true
+}
+
+// problem says what's wrong with how the service is reachable, if
+// anything: an address that doesn't answer is broken for everyone whose
+// client prefers that family, and IPv6-only shuts out IPv4-only networks,
+// which are still most of them. IPv4-only is normal and not a problem.
+func (sf ServiceFamilies) problem() string {
+	switch {
+	case sf.IPv4 == familyUnreachable && sf.IPv6 == familyOK:
+		return "IPv4 address doesn't answer, works over IPv6 only"
+	case sf.IPv6 == familyUnreachable && sf.IPv4 == familyOK:
+		return "IPv6 address doesn't answer, works over IPv4 only"
+	case sf.IPv6 == familyOK && sf.IPv4 == familyNoAddress:
+		return "IPv6 only"
+	}
+	return ""
+}
+
+// stack describes the families the service works over.
+func (sf ServiceFamilies) stack() string {
+	switch {
+	case sf.Proxied:
+		return "not tested (proxied)"
+	case sf.IPv4 == familyOK && sf.IPv6 == familyOK:
+		return "dual-stack"
+	case sf.IPv4 == familyOK:
+		return "IPv4 only"
+	case sf.IPv6 == familyOK:
+		return "IPv6 only"
+	}
+	return "unreachable"
+}
+
+// checkAddressFamilies reports dual-stack mismatches across the services
+// the identity depends on, the usual cause of "works for me, broken for
+// you". probed holds services already probed (the NIP-05 host); endpoints
+// are probed here, once per host and port. Not scored: the relay, NIP-05,
+// and lightning checks already cover services that don't work at all.
+func checkAddressFamilies(ctx context.Context, result *CheckResult, probed []ServiceFamilies, endpoints []serviceEndpoint) {
+	seen := make(map[serviceEndpoint]bool)
+	var unique []serviceEndpoint
+	for _, ep := range endpoints {
+		if !seen[ep] {
+			seen[ep] = true
+			unique = append(unique, ep)
+		}
+	}
+	endpoints = unique
+	services := append(probed, make([]ServiceFamilies, len(endpoints))...)
+	if len(services) == 0 {
+		return
+	}
+	var wg sync.WaitGroup
+	for i, ep := range endpoints {
+		wg.Add(1)
+		go func(sf *ServiceFamilies, ep serviceEndpoint) {
+			defer wg.Done()
+			*sf = probeFamilies(ctx, ep.service, ep.host, ep.port)
+		}(&services[len(probed)+i], ep)
+	}
+	wg.Wait()
+	result.AddressFamilies = services
+	status, detail := assessAddressFamilies(services)
+	result.addCheck("address_families", status, detail)
+}
+
+// assessAddressFamilies turns family probes into a check status and detail.
+func assessAddressFamilies(services []ServiceFamilies) (string, string) {
+	var problems []string
+	counts := make(map[string]int)
+	ipv6Untested := false
+	for _, sf := range services {
+		if p := sf.problem(); p != "" {
+			problems = append(problems, fmt.Sprintf("%s %s: %s", sf.Service, sf.Host, p))
+		}
+		counts[sf.stack()]++
+		if sf.IPv6 == familyUntested && !sf.Proxied {
+			ipv6Untested = true
+		}
+	}
+
+	var parts []string
+	for _, stack := range []string{"dual-stack", "IPv4 only", "IPv6 only", "unreachable", "not tested (proxied)"} {
+		if counts[stack] > 0 {
+			parts = append(parts, fmt.Sprintf("%d %s", counts[stack], stack))
+		}
+	}
+	detail := strings.Join(parts, ", ")
+	if ipv6Untested {
+		detail += " (IPv6 not tested: no IPv6 route from here)"
+	}
+	if len(problems) > 0 {
+		return "warn", strings.Join(problems, "; ") + " — " + detail
+	}
+	return "pass", detail
+}
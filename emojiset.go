@@ -0,0 +1,137 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"fiatjaf.com/nostr"
+	"fiatjaf.com/nostr/nip19"
+)
+
+// emojiList is the custom emoji list (kind 10030) setup --emoji-set
+// publishes: emoji sets (kind 30030) referenced by address, and emojis
+// listed directly, whose local images are uploaded to Blossom first.
+type emojiList struct {
+	sets   nostr.Tags // "a" tags
+	emojis []emojiImage
+}
+
+// emojiImage is one emoji of an --emoji-set file.
+type emojiImage struct {
+	shortcode string
+	img       importedImage
+}
+
+// EmojiListSetup reports the kind 10030 setup published.
+type EmojiListSetup struct {
+	Emojis int      `json:"emojis"`
+	Sets   int      `json:"sets"`
+	Blobs  []string `json:"blobs,omitempty"` // images uploaded to Blossom
+}
+
+// loadEmojiList reads --emoji-set values: naddrs of kind 30030 emoji sets,
+// or files with one emoji per line ("shortcode image", the image a URL or a
+// path relative to the file) and naddrs on lines of their own. Lines
+// starting with "#" are comments.
+func loadEmojiList(refs []string) (*emojiList, error) {
+	list := &emojiList{}
+	seen := make(map[string]bool)
+	for _, ref := range refs {
+		if strings.HasPrefix(ref, "naddr1") {
+			if err := list.addSet(ref); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		f, err := os.Open(ref)
+		if err != nil {
+			return nil, err
+		}
+		scanner := bufio.NewScanner(f)
+		for n := 1; scanner.Scan(); n++ {
+			line := strings.TrimSpace(scanner.Text())
+			if line == "" || strings.HasPrefix(line, "#") {
+				continue
+			}
+			fields := strings.Fields(line)
+			switch {
+			case len(fields) == 1 && strings.HasPrefix(fields[0], "naddr1"):
+				err = list.addSet(fields[0])
+			case len(fields) != 2:
+				err = fmt.Errorf("want \"shortcode image\" or an naddr")
+			case !emojiShortcode.MatchString(strings.Trim(fields[0], ":")):
+				err = fmt.Errorf("shortcode %q may only use letters, digits, and _", fields[0])
+			case seen[strings.Trim(fields[0], ":")]:
+				err = fmt.Errorf("shortcode %s listed twice", fields[0])
+			default:
+				var img importedImage
+				if img, err = mappedImage(fields[1], filepath.Dir(ref)); err == nil {
+					code := strings.Trim(fields[0], ":")
+					seen[code] = true
+					list.emojis = append(list.emojis, emojiImage{shortcode: code, img: img})
+				}
+			}
+			if err != nil {
+				f.Close()
+				return nil, fmt.Errorf("%s line %d: %w", ref, n, err)
+			}
+		}
+		err = scanner.Err()
+		f.Close()
+		if err != nil {
+			return nil, err
+		}
+	}
+	if len(list.emojis) == 0 && len(list.sets) == 0 {
+		return nil, fmt.Errorf("no emojis or emoji sets in %s", strings.Join(refs, ", "))
+	}
+	return list, nil
+}
+
+// addSet adds a reference to the kind 30030 emoji set an naddr points at.
+func (l *emojiList) addSet(naddr string) error {
+	prefix, value, err := nip19.Decode(naddr)
+	if err != nil || prefix != "naddr" {
+		return fmt.Errorf("invalid naddr %s", naddr)
+	}
+	ep := value.(nostr.EntityPointer)
+	if ep.Kind != 30030 {
+		return fmt.Errorf("%s is a kind %d, not an emoji set (kind 30030)", naddr, ep.Kind)
+	}
+	l.sets = append(l.sets, ep.AsTag())
+	return nil
+}
+
+// buildEmojiList uploads the list's local images to Blossom with the new
+// key and returns the kind 10030 tags. An image that can't be uploaded is
+// left out rather than linked to nothing.
+func buildEmojiList(l *emojiList, kr nostr.Keyer, servers []string, logln func(a ...any)) (nostr.Tags, *EmojiListSetup) {
+	if len(servers) == 0 {
+		servers = defaultBlossomServers
+	}
+	result := &EmojiListSetup{Sets: len(l.sets)}
+	var tags nostr.Tags
+	for _, e := range l.emojis {
+		u := e.img.url
+		if len(e.img.data) > 0 {
+			ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+			uploaded, _, err := uploadImportedImage(ctx, kr, servers, e.img)
+			cancel()
+			if err != nil {
+				logln(fmt.Sprintf("   ⚠️  :%s: %s; leaving it out", e.shortcode, err))
+				continue
+			}
+			u = uploaded
+			result.Blobs = append(result.Blobs, u)
+			logln(fmt.Sprintf("   ✓ :%s: %s", e.shortcode, u))
+		}
+		tags = append(tags, nostr.Tag{"emoji", e.shortcode, u})
+	}
+	result.Emojis = len(tags)
+	return append(tags, l.sets...), result
+}
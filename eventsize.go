@@ -0,0 +1,121 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"fiatjaf.com/nostr"
+)
+
+// sizedKinds are the events whose size is compared with relay limits: the
+// profile and the follow list, the two that grow without the user noticing.
+var sizedKinds = []int{0, 3}
+
+// EventSize is how big one of the identity's events is, and which of its
+// write relays advertise a NIP-11 limit it goes over.
+type EventSize struct {
+	Kind         int             `json:"kind"`
+	Bytes        int             `json:"bytes"` // serialized event
+	ContentBytes int             `json:"content_bytes"`
+	Tags         int             `json:"tags"`
+	Exceeds      []RelayLimitHit `json:"exceeds,omitempty"`
+}
+
+// RelayLimitHit is one NIP-11 limit an event goes over on one relay.
+type RelayLimitHit struct {
+	Relay string `json:"relay"`
+	Limit string `json:"limit"` // "max_message_length", "max_content_length", or "max_event_tags"
+	Max   int    `json:"max"`
+	Size  int    `json:"size"`
+}
+
+// eventMessageOverhead is what ["EVENT",...] adds around the event JSON,
+// which max_message_length counts.
+const eventMessageOverhead = len(`["EVENT",]`)
+
+// measureEvent compares evt with the limits of the given relays.
+func measureEvent(evt *nostr.Event, scores []RelayScore) EventSize {
+	data, _ := json.Marshal(evt)
+	size := EventSize{Kind: int(evt.Kind), Bytes: len(data), ContentBytes: len(evt.Content), Tags: len(evt.Tags)}
+	for _, rs := range scores {
+		if rs.Info == nil || rs.Info.Limitation == nil {
+			continue
+		}
+		l := rs.Info.Limitation
+		hit := func(limit string, max, actual int) {
+			if max > 0 && actual > max {
+				size.Exceeds = append(size.Exceeds, RelayLimitHit{Relay: rs.URL, Limit: limit, Max: max, Size: actual})
+			}
+		}
+		hit("max_message_length", l.MaxMessageLength, size.Bytes+eventMessageOverhead)
+		hit("max_content_length", l.MaxContentLength, size.ContentBytes)
+		hit("max_event_tags", l.MaxEventTags, size.Tags)
+	}
+	return size
+}
+
+// writeRelayScores keeps the scores of relays the identity publishes to:
+// those marked write or not marked at all in its kind 10002.
+func writeRelayScores(relayEvt *nostr.Event, scores []RelayScore) []RelayScore {
+	readOnly := make(map[string]bool)
+	for _, tag := range relayEvt.Tags {
+		if len(tag) >= 3 && tag[0] == "r" && tag[2] == "read" {
+			readOnly[tag[1]] = true
+		}
+	}
+	var write []RelayScore
+	for _, rs := range scores {
+		if !readOnly[rs.URL] {
+			write = append(write, rs)
+		}
+	}
+	return write
+}
+
+// checkEventSizes measures the profile and follow list against the NIP-11
+// limits of the identity's write relays and adds an "event_size" check.
+// A relay that advertises a limit rejects anything over it, so the next
+// profile edit or follow silently doesn't land there.
+func checkEventSizes(result *CheckResult, writeScores []RelayScore) {
+	var sizes []EventSize
+	var parts, over []string
+	for _, kind := range sizedKinds {
+		evt := result.events[kind]
+		if evt == nil {
+			continue
+		}
+		s := measureEvent(evt, writeScores)
+		sizes = append(sizes, s)
+		label := strings.ReplaceAll(kindLabels[kind], "_", " ")
+		parts = append(parts, fmt.Sprintf("%s %s, %d tag(s)", label, formatSize(int64(s.Bytes)), s.Tags))
+		for _, h := range s.Exceeds {
+			over = append(over, fmt.Sprintf("%s over %s %d (%d) on %s", label, h.Limit, h.Max, h.Size, h.Relay))
+		}
+	}
+	if len(sizes) == 0 {
+		return
+	}
+	result.EventSizes = sizes
+	detail := strings.Join(parts, "; ")
+	if len(over) == 0 {
+		limited := 0
+		for _, rs := range writeScores {
+			if rs.Info != nil && rs.Info.Limitation != nil {
+				limited++
+			}
+		}
+		if limited == 0 {
+			result.addCheck("event_size", "pass", detail+" — no write relay advertises size limits")
+		} else {
+			result.addCheck("event_size", "pass", fmt.Sprintf("%s — within the limits of %d write relay(s)", detail, limited))
+		}
+		return
+	}
+	shown := over[:min(len(over), 3)]
+	more := ""
+	if len(over) > 3 {
+		more = fmt.Sprintf("; and %d more", len(over)-3)
+	}
+	result.addCheck("event_size", "warn", fmt.Sprintf("%s — %s%s", detail, strings.Join(shown, "; "), more))
+}
@@ -0,0 +1,37 @@
+package main
+
+import (
+	"strconv"
+	"strings"
+	"time"
+
+	"fiatjaf.com/nostr"
+)
+
+// testEventTTL is the NIP-40 expiration put on events nihao publishes only
+// to test relays, such as check's DM loopback gift wrap (--expire).
+var testEventTTL = 10 * time.Minute
+
+// parseExpiry reads an expiration flag: a Go duration like 12h, or a number
+// of days like 30d.
+func parseExpiry(flag, s string) time.Duration {
+	var d time.Duration
+	var err error
+	if days, ok := strings.CutSuffix(s, "d"); ok {
+		var n int
+		n, err = strconv.Atoi(days)
+		d = time.Duration(n) * 24 * time.Hour
+	} else {
+		d, err = time.ParseDuration(s)
+	}
+	if err != nil || d <= 0 {
+		fatal("invalid %s %q (use e.g. 10m, 12h, or 30d)", flag, s)
+	}
+	return d
+}
+
+// expirationTag is a NIP-40 tag for an event that should expire ttl after
+// now. Relays that honor it delete the event then; the rest keep it.
+func expirationTag(now time.Time, ttl time.Duration) nostr.Tag {
+	return nostr.Tag{"expiration", strconv.FormatInt(now.Add(ttl).Unix(), 10)}
+}
@@ -0,0 +1,103 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"fiatjaf.com/nostr"
+)
+
+// extraKindLimit caps how many events of each --extra-kinds kind are
+// fetched.
+const extraKindLimit = 500
+
+// KindPresence reports what an identity has published of one kind.
+type KindPresence struct {
+	Kind      int             `json:"kind"`
+	Count     int             `json:"count"`
+	Latest    nostr.Timestamp `json:"latest,omitempty"`    // created_at of the newest event
+	Unchecked bool            `json:"unchecked,omitempty"` // the deadline passed before the relays answered
+}
+
+// parseKinds parses a comma-separated list of event kinds, e.g. "30023,30311".
+func parseKinds(s string) ([]int, error) {
+	var kinds []int
+	for _, part := range strings.Split(s, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		kind, err := strconv.Atoi(part)
+		if err != nil || kind < 0 || kind > 65535 {
+			return nil, fmt.Errorf("invalid kind %q (want a number from 0 to 65535)", part)
+		}
+		if !slices.Contains(kinds, kind) {
+			kinds = append(kinds, kind)
+		}
+	}
+	if len(kinds) == 0 {
+		return nil, fmt.Errorf("no kinds given")
+	}
+	return kinds, nil
+}
+
+// fetchKindEvents fetches pk's events of one kind, newest first.
+func fetchKindEvents(ctx context.Context, relays []checkRelay, pk nostr.PubKey, kind int) []nostr.Event {
+	events := fetchAllFrom(ctx, relays, nostr.Filter{
+		Authors: []nostr.PubKey{pk},
+		Kinds:   []nostr.Kind{nostr.Kind(kind)},
+		Limit:   extraKindLimit,
+	})
+	sort.Slice(events, func(i, j int) bool { return events[i].CreatedAt > events[j].CreatedAt })
+	return events
+}
+
+// kindPresence summarizes fetched events of one kind.
+func kindPresence(kind int, events []nostr.Event) KindPresence {
+	p := KindPresence{Kind: kind, Count: len(events)}
+	for _, evt := range events {
+		if evt.CreatedAt > p.Latest {
+			p.Latest = evt.CreatedAt
+		}
+	}
+	return p
+}
+
+// checkExtraKinds adds a "kind_<n>" check for every kind asked for with
+// --extra-kinds. They aren't scored: nihao doesn't know what they should
+// contain. Kinds the deadline left no time for are reported as not
+// checked rather than missing, and "extra_kinds" is added to the cut-off
+// phases.
+func checkExtraKinds(ctx context.Context, result *CheckResult, relays []checkRelay, pk nostr.PubKey, kinds []int) {
+	cutOff := false
+	for _, kind := range kinds {
+		p := kindPresence(kind, fetchKindEvents(ctx, relays, pk, kind))
+		name := fmt.Sprintf("kind_%d", kind)
+		if p.Count == 0 && ctx.Err() != nil {
+			p.Unchecked = true
+			result.ExtraKinds = append(result.ExtraKinds, p)
+			result.addCheck(name, "warn", "not checked (deadline)")
+			cutOff = true
+			continue
+		}
+		result.ExtraKinds = append(result.ExtraKinds, p)
+		if p.Count == 0 {
+			result.addCheck(name, "warn", "none found")
+			continue
+		}
+		count := strconv.Itoa(p.Count)
+		if p.Count >= extraKindLimit {
+			count += "+"
+		}
+		detail := fmt.Sprintf("%s event(s), latest %s", count, p.Latest.Time().UTC().Format(time.DateOnly))
+		result.addCheck(name, "pass", detail)
+	}
+	if cutOff {
+		result.CutOff = append(result.CutOff, "extra_kinds")
+	}
+}
@@ -0,0 +1,239 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+
+	"fiatjaf.com/nostr"
+)
+
+// relayFeature is something an identity uses that only works on relays
+// supporting particular NIPs. kind is the list event naming the relays the
+// identity relies on for it.
+type relayFeature struct {
+	name  string
+	kind  int
+	nips  [][]int // every group needs at least one of its NIPs advertised
+	needs string
+}
+
+var relayFeatures = []relayFeature{
+	{"dm", 10050, [][]int{{42}, {17, 59}}, "NIP-42 AUTH and gift wrap (kind 1059) storage"},
+	{"search", 10007, [][]int{{50}}, "NIP-50 search"},
+	{"nutzap", 10019, [][]int{{61}}, "accepting nutzaps (kind 9321, NIP-61)"},
+}
+
+// FeatureMatrix shows, for every relay the identity advertises, which of
+// the features it uses that relay can serve according to its NIP-11.
+type FeatureMatrix struct {
+	Features []string              `json:"features"`
+	Relays   []RelayFeatureSupport `json:"relays"`
+}
+
+// RelayFeatureSupport is one row of the matrix. Support values are "yes",
+// "no", or "unknown" (no NIP-11 document).
+type RelayFeatureSupport struct {
+	URL       string            `json:"url"`
+	ListedFor []string          `json:"listed_for,omitempty"`
+	Support   map[string]string `json:"support"`
+}
+
+// relayTags returns the relay URLs in a list event ("r" for kind 10002,
+// "relay" for the others).
+func relayTags(evt *nostr.Event) []string {
+	var urls []string
+	for _, tag := range evt.Tags {
+		if len(tag) >= 2 && (tag[0] == "r" || tag[0] == "relay") {
+			urls = append(urls, tag[1])
+		}
+	}
+	return urls
+}
+
+// featureSupport rates one relay's NIP-11 against a feature.
+func featureSupport(info *RelayInfo, f relayFeature) string {
+	if info == nil {
+		return "unknown"
+	}
+	for _, group := range f.nips {
+		if !slices.ContainsFunc(group, func(nip int) bool { return slices.Contains(info.SupportedNIPs, nip) }) {
+			return "no"
+		}
+	}
+	return "yes"
+}
+
+// buildFeatureMatrix builds the matrix from the identity's list events and
+// the NIP-11 documents of their relays. Only features the identity uses
+// (i.e. has a list event for) become columns.
+func buildFeatureMatrix(events map[int]*nostr.Event, infos map[string]*RelayInfo) *FeatureMatrix {
+	var used []relayFeature
+	for _, f := range relayFeatures {
+		if events[f.kind] != nil {
+			used = append(used, f)
+		}
+	}
+	if len(used) == 0 {
+		return nil
+	}
+
+	matrix := &FeatureMatrix{}
+	rows := make(map[string]*RelayFeatureSupport)
+	var order []string
+	addRelays := func(evt *nostr.Event, feature string) {
+		for _, raw := range relayTags(evt) {
+			url := normalizeRelayURL(raw)
+			if url == "" {
+				continue
+			}
+			row, ok := rows[url]
+			if !ok {
+				row = &RelayFeatureSupport{URL: url, Support: make(map[string]string)}
+				rows[url] = row
+				order = append(order, url)
+			}
+			if feature != "" && !slices.Contains(row.ListedFor, feature) {
+				row.ListedFor = append(row.ListedFor, feature)
+			}
+		}
+	}
+	if evt := events[10002]; evt != nil {
+		addRelays(evt, "")
+	}
+	for _, f := range used {
+		matrix.Features = append(matrix.Features, f.name)
+		addRelays(events[f.kind], f.name)
+	}
+
+	for _, url := range order {
+		row := rows[url]
+		for _, f := range used {
+			row.Support[f.name] = featureSupport(infos[url], f)
+		}
+		matrix.Relays = append(matrix.Relays, *row)
+	}
+	return matrix
+}
+
+// assessFeatureMatrix checks that each used feature has at least one relay
+// in its own list that can serve it.
+func assessFeatureMatrix(m *FeatureMatrix) (string, string) {
+	var ok, missing []string
+	for _, f := range relayFeatures {
+		if !slices.Contains(m.Features, f.name) {
+			continue
+		}
+		listed, capable := 0, 0
+		var elsewhere []string
+		for _, row := range m.Relays {
+			isListed := slices.Contains(row.ListedFor, f.name)
+			if isListed {
+				listed++
+			}
+			if row.Support[f.name] == "yes" {
+				if isListed {
+					capable++
+				} else {
+					elsewhere = append(elsewhere, row.URL)
+				}
+			}
+		}
+		if capable > 0 {
+			ok = append(ok, fmt.Sprintf("%s %d/%d", f.name, capable, listed))
+			continue
+		}
+		msg := fmt.Sprintf("%s: none of %d relay(s) advertise %s", f.name, listed, f.needs)
+		if len(elsewhere) > 0 {
+			msg += " (" + strings.Join(elsewhere, ", ") + " do)"
+		}
+		missing = append(missing, msg)
+	}
+
+	detail := strings.Join(append(ok, missing...), ", ")
+	if len(missing) > 0 {
+		return "warn", detail
+	}
+	return "pass", detail
+}
+
+// checkRelayFeatures fetches the search relay list (kind 10007), probes
+// NIP-11 for every advertised relay, and adds a "relay_features" check
+// plus the full matrix to the result. Search relays also get a test
+// search (checkSearchRelays).
+func checkRelayFeatures(ctx context.Context, result *CheckResult, checkRelays []checkRelay, pk nostr.PubKey) {
+	searchSrc, searchEvt := fetchKindFrom(ctx, checkRelays, pk, 10007)
+	result.recordEvent(10007, searchSrc, searchEvt)
+
+	var urls []string
+	for _, kind := range []int{10002, 10050, 10007, 10019} {
+		if evt := result.events[kind]; evt != nil {
+			for _, raw := range relayTags(evt) {
+				if url := normalizeRelayURL(raw); url != "" && !slices.Contains(urls, url) {
+					urls = append(urls, url)
+				}
+			}
+		}
+	}
+
+	infos := make(map[string]*RelayInfo)
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	for _, url := range urls {
+		wg.Add(1)
+		go func(url string) {
+			defer wg.Done()
+			if info, _, err := fetchNIP11Context(ctx, url); err == nil {
+				mu.Lock()
+				infos[url] = info
+				mu.Unlock()
+			}
+		}(url)
+	}
+	wg.Wait()
+
+	checkSearchRelays(ctx, result, infos)
+
+	matrix := buildFeatureMatrix(result.events, infos)
+	if matrix == nil {
+		return
+	}
+	result.Features = matrix
+	status, detail := assessFeatureMatrix(matrix)
+	result.addCheck("relay_features", status, detail)
+}
+
+// printFeatureMatrix renders the matrix as a table: ✓ supported, ✗ not
+// advertised, ? no NIP-11; * marks relays listed for that feature.
+func printFeatureMatrix(m *FeatureMatrix) {
+	width := 0
+	for _, row := range m.Relays {
+		width = max(width, len(row.URL))
+	}
+	mark := map[string]string{"yes": "✓", "no": "✗", "unknown": "?"}
+
+	header := fmt.Sprintf("%-*s", width+6, "  Relay features:")
+	for _, f := range m.Features {
+		header += fmt.Sprintf("%-8s", f)
+	}
+	fmt.Println()
+	fmt.Println(strings.TrimRight(header, " "))
+
+	rows := append([]RelayFeatureSupport(nil), m.Relays...)
+	sort.SliceStable(rows, func(i, j int) bool { return len(rows[i].ListedFor) > len(rows[j].ListedFor) })
+	for _, row := range rows {
+		line := fmt.Sprintf("    %-*s  ", width, row.URL)
+		for _, f := range m.Features {
+			cell := mark[row.Support[f]]
+			if slices.Contains(row.ListedFor, f) {
+				cell += "*"
+			}
+			line += fmt.Sprintf("%-8s", cell)
+		}
+		fmt.Println(strings.TrimRight(line, " "))
+	}
+	fmt.Println("    (* listed for that feature, ? no NIP-11)")
+}
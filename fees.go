@@ -0,0 +1,112 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"fiatjaf.com/nostr"
+)
+
+// RelayFees is the NIP-11 "fees" object.
+type RelayFees struct {
+	Admission    []RelayFee `json:"admission,omitempty"`
+	Subscription []RelayFee `json:"subscription,omitempty"`
+	Publication  []RelayFee `json:"publication,omitempty"`
+}
+
+// RelayFee is a single NIP-11 fee entry. Period is in seconds.
+type RelayFee struct {
+	Amount int64  `json:"amount"`
+	Unit   string `json:"unit"`
+	Period int64  `json:"period,omitempty"`
+	Kinds  []int  `json:"kinds,omitempty"`
+}
+
+// String formats a fee in sats, e.g. "5000 sats/30d".
+func (f RelayFee) String() string {
+	amount := fmt.Sprintf("%d %s", f.Amount, f.Unit)
+	switch strings.ToLower(f.Unit) {
+	case "msats", "msat":
+		amount = fmt.Sprintf("%d sats", f.Amount/1000)
+	case "sats", "sat":
+		amount = fmt.Sprintf("%d sats", f.Amount)
+	}
+	if f.Period > 0 {
+		if days := f.Period / 86400; days > 0 {
+			amount += fmt.Sprintf("/%dd", days)
+		} else {
+			amount += fmt.Sprintf("/%ds", f.Period)
+		}
+	}
+	return amount
+}
+
+// feeSummary describes what a relay charges, or "" if it advertises no
+// fees. Only the first admission and subscription entries are shown.
+func feeSummary(info *RelayInfo) string {
+	if info == nil || info.Fees == nil {
+		return ""
+	}
+	var parts []string
+	if len(info.Fees.Admission) > 0 {
+		parts = append(parts, "admission "+info.Fees.Admission[0].String())
+	}
+	if len(info.Fees.Subscription) > 0 {
+		parts = append(parts, "subscription "+info.Fees.Subscription[0].String())
+	}
+	if len(info.Fees.Publication) > 0 {
+		parts = append(parts, "per-event fees")
+	}
+	return strings.Join(parts, ", ")
+}
+
+// testWriteAdmission rebroadcasts one of the user's own signed events to a
+// relay. Paid relays answer "restricted:" for authors who haven't paid, so
+// this tells us whether the user is admitted without needing their key.
+func testWriteAdmission(relayURL string, evt nostr.Event) (admitted bool, reason string) {
+	relay, err := connectRelay(relayURL, 5*time.Second)
+	if err != nil {
+		return false, "unreachable"
+	}
+	defer relay.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 8*time.Second)
+	defer cancel()
+	if err := relay.Publish(ctx, evt); err != nil {
+		return false, err.Error()
+	}
+	return true, ""
+}
+
+// checkPaidRelays adds a "paid_relays" check when the relay list includes
+// relays that require payment, testing whether the user can write to them.
+func checkPaidRelays(result *CheckResult, scores []RelayScore, relayEvt *nostr.Event) {
+	var admitted, restricted []string
+	for _, rs := range scores {
+		if !rs.PaymentRequired || !rs.Reachable {
+			continue
+		}
+		label := rs.URL
+		if fees := feeSummary(rs.Info); fees != "" {
+			label += " (" + fees + ")"
+		}
+		ok, reason := testWriteAdmission(rs.URL, *relayEvt)
+		if ok {
+			admitted = append(admitted, label)
+		} else {
+			restricted = append(restricted, fmt.Sprintf("%s: %s", label, reason))
+		}
+	}
+
+	switch {
+	case len(admitted) == 0 && len(restricted) == 0:
+		return
+	case len(restricted) == 0:
+		result.addCheck("paid_relays", "pass", "admitted to "+strings.Join(admitted, ", "))
+	default:
+		result.addCheck("paid_relays", "warn", fmt.Sprintf("relay list depends on paid relay(s) that reject your writes — %s",
+			strings.Join(restricted, "; ")))
+	}
+}
@@ -0,0 +1,223 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"sync"
+	"time"
+
+	"fiatjaf.com/nostr"
+	"fiatjaf.com/nostr/nip19"
+)
+
+// FollowIssue is one problem found on a followed identity.
+type FollowIssue struct {
+	Check  string `json:"check"` // "nip05", "relays" or "lud16"
+	Detail string `json:"detail"`
+}
+
+// FollowResult is one followed identity in `nihao check --follows`.
+type FollowResult struct {
+	Npub     string        `json:"npub"`
+	Name     string        `json:"name,omitempty"`
+	Score    int           `json:"score"`
+	MaxScore int           `json:"max_score"`
+	Issues   []FollowIssue `json:"issues"`
+}
+
+// FollowsCheckResult is the JSON output of `nihao check --follows`.
+type FollowsCheckResult struct {
+	Npub        string         `json:"npub"`
+	Total       int            `json:"total"`
+	Healthy     int            `json:"healthy"`
+	BrokenNIP05 int            `json:"broken_nip05"`
+	DeadRelays  int            `json:"dead_relays"`
+	BrokenLUD16 int            `json:"broken_lud16"`
+	Follows     []FollowResult `json:"follows"`
+}
+
+// followIssues picks the network-visible breakage out of a check result:
+// a NIP-05 or lightning address that's set but doesn't resolve, and relays
+// in the relay list that can't be reached. Things that are merely unset
+// aren't counted; that's the follow's choice, not breakage.
+func followIssues(r CheckResult) []FollowIssue {
+	issues := []FollowIssue{}
+	if r.status("nip05") == "warn" {
+		issues = append(issues, FollowIssue{Check: "nip05", Detail: r.detail("nip05")})
+	}
+	if s := r.status("relay_quality"); s == "warn" || s == "fail" {
+		issues = append(issues, FollowIssue{Check: "relays", Detail: r.detail("relay_quality")})
+	}
+	if r.status("lud16") == "warn" {
+		issues = append(issues, FollowIssue{Check: "lud16", Detail: r.detail("lud16")})
+	}
+	return issues
+}
+
+// followName is the name a followed profile goes by, if any.
+func followName(r CheckResult) string {
+	var meta ProfileMetadata
+	if evt := r.events[0]; evt != nil {
+		json.Unmarshal([]byte(evt.Content), &meta)
+	}
+	if meta.DisplayName != "" {
+		return meta.DisplayName
+	}
+	return meta.Name
+}
+
+// summarizeFollows tallies follow results, listing the broken ones first.
+func summarizeFollows(npub string, follows []FollowResult) FollowsCheckResult {
+	result := FollowsCheckResult{Npub: npub, Total: len(follows), Follows: follows}
+	for _, f := range follows {
+		if len(f.Issues) == 0 {
+			result.Healthy++
+		}
+		for _, issue := range f.Issues {
+			switch issue.Check {
+			case "nip05":
+				result.BrokenNIP05++
+			case "relays":
+				result.DeadRelays++
+			case "lud16":
+				result.BrokenLUD16++
+			}
+		}
+	}
+	sort.SliceStable(result.Follows, func(i, j int) bool {
+		return len(result.Follows[i].Issues) > len(result.Follows[j].Issues)
+	})
+	return result
+}
+
+// runFollowsCheck checks every pubkey target follows and reports who has
+// a broken NIP-05, dead relays, or an unreachable lightning address.
+// With leaderboard set it prints the aggregate report instead. Exits 1 if
+// anyone has a problem.
+func runFollowsCheck(target string, jsonOutput bool, quiet bool, leaderboard bool, relays []string) {
+	pk, err := resolveTarget(target, quiet || jsonOutput)
+	if err != nil {
+		fatal("%s", err)
+	}
+	npub := nip19.EncodeNpub(pk)
+	verbose := !jsonOutput && !quiet
+
+	connectCtx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
+	checkRelays := connectCheckRelays(connectCtx, relays)
+	cancel()
+	if len(checkRelays) == 0 {
+		fatal("could not connect to any relay")
+	}
+	defer func() {
+		for _, cr := range checkRelays {
+			cr.relay.Close()
+		}
+	}()
+
+	fetchCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	_, contacts := fetchKindFrom(fetchCtx, checkRelays, pk, 3)
+	cancel()
+	if contacts == nil {
+		fatal("no follow list (kind 3) found for %s", npub)
+	}
+	var follows []nostr.PubKey
+	for hex := range tagValues(contacts, "p") {
+		if fpk, err := nostr.PubKeyFromHex(hex); err == nil {
+			follows = append(follows, fpk)
+		}
+	}
+	if len(follows) == 0 {
+		fatal("%s follows nobody", npub)
+	}
+	if verbose {
+		fmt.Printf("nihao follows 👥 %s — %d follows\n\n", npub, len(follows))
+	}
+
+	// Check a few follows at a time
+	checks := make([]CheckResult, len(follows))
+	results := make([]FollowResult, len(follows))
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	done := 0
+	sem := make(chan struct{}, 8)
+	for i, fpk := range follows {
+		wg.Add(1)
+		go func(i int, fpk nostr.PubKey) {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+			ctx, cancel := context.WithTimeout(context.Background(), 20*time.Second)
+			r := checkIdentityCached(ctx, checkRelays, relays, fpk)
+			cancel()
+			checks[i] = r
+			results[i] = FollowResult{
+				Npub:     r.Npub,
+				Name:     followName(r),
+				Score:    r.Score,
+				MaxScore: r.MaxScore,
+				Issues:   followIssues(r),
+			}
+			if verbose {
+				mu.Lock()
+				done++
+				fmt.Printf("\r  🔍 checked %d/%d", done, len(follows))
+				mu.Unlock()
+			}
+		}(i, fpk)
+	}
+	wg.Wait()
+	if verbose {
+		fmt.Print("\n\n")
+	}
+
+	sort.Slice(results, func(i, j int) bool { return results[i].Npub < results[j].Npub })
+	result := summarizeFollows(npub, results)
+
+	switch {
+	case leaderboard && jsonOutput:
+		out, _ := json.MarshalIndent(buildLeaderboard(checks), "", "  ")
+		fmt.Println(string(out))
+	case leaderboard && !quiet:
+		printLeaderboard(buildLeaderboard(checks))
+	case jsonOutput:
+		out, _ := json.MarshalIndent(result, "", "  ")
+		fmt.Println(string(out))
+	case !quiet:
+		printFollowsCheckResult(result)
+	}
+	if result.Healthy < result.Total {
+		os.Exit(1)
+	}
+}
+
+func printFollowsCheckResult(r FollowsCheckResult) {
+	issueIcon := map[string]string{
+		"nip05":  "🪪",
+		"relays": "📡",
+		"lud16":  "⚡",
+	}
+
+	for _, f := range r.Follows {
+		if len(f.Issues) == 0 {
+			continue
+		}
+		who := f.Npub
+		if f.Name != "" {
+			who = fmt.Sprintf("%s (%s)", f.Name, f.Npub)
+		}
+		fmt.Printf("  ❌ %s — score %d/%d\n", who, f.Score, f.MaxScore)
+		for _, issue := range f.Issues {
+			fmt.Printf("      %s %s\n", issueIcon[issue.Check], issue.Detail)
+		}
+	}
+	if r.Healthy < r.Total {
+		fmt.Println()
+	}
+
+	fmt.Printf("  👥 %d/%d follows healthy\n", r.Healthy, r.Total)
+	fmt.Printf("     %d broken NIP-05, %d with dead relays, %d unreachable lightning addresses\n",
+		r.BrokenNIP05, r.DeadRelays, r.BrokenLUD16)
+}
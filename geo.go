@@ -0,0 +1,150 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"time"
+
+	"fiatjaf.com/nostr"
+)
+
+// nip66Relays carry NIP-66 relay discovery events (kind 30166) published
+// by relay monitors, including a geohash ("g" tag) for many relays.
+var nip66Relays = []string{
+	"wss://relay.nostr.watch",
+	"wss://relaypag.es",
+	"wss://monitorlizard.nostr1.com",
+}
+
+const geohashAlphabet = "0123456789bcdefghjkmnpqrstuvwxyz"
+
+// decodeGeohash returns the center of a geohash cell.
+func decodeGeohash(hash string) (lat, lon float64, err error) {
+	if hash == "" {
+		return 0, 0, fmt.Errorf("empty geohash")
+	}
+	latRange := [2]float64{-90, 90}
+	lonRange := [2]float64{-180, 180}
+	even := true
+	for _, c := range strings.ToLower(hash) {
+		idx := strings.IndexRune(geohashAlphabet, c)
+		if idx < 0 {
+			return 0, 0, fmt.Errorf("invalid geohash character %q", c)
+		}
+		for bit := 4; bit >= 0; bit-- {
+			r := &latRange
+			if even {
+				r = &lonRange
+			}
+			mid := (r[0] + r[1]) / 2
+			if idx&(1<<bit) != 0 {
+				r[0] = mid
+			} else {
+				r[1] = mid
+			}
+			even = !even
+		}
+	}
+	return (latRange[0] + latRange[1]) / 2, (lonRange[0] + lonRange[1]) / 2, nil
+}
+
+// geoDistanceKm is the great-circle distance between two points.
+func geoDistanceKm(lat1, lon1, lat2, lon2 float64) float64 {
+	const earthRadiusKm = 6371
+	rad := math.Pi / 180
+	dLat := (lat2 - lat1) * rad
+	dLon := (lon2 - lon1) * rad
+	a := math.Sin(dLat/2)*math.Sin(dLat/2) +
+		math.Cos(lat1*rad)*math.Cos(lat2*rad)*math.Sin(dLon/2)*math.Sin(dLon/2)
+	return 2 * earthRadiusKm * math.Asin(math.Sqrt(a))
+}
+
+// fetchRelayGeohashes looks up NIP-66 monitor data for urls and returns
+// the most precise geohash reported for each relay that has one.
+func fetchRelayGeohashes(urls []string) map[string]string {
+	geohashes := make(map[string]string)
+	if len(urls) == 0 {
+		return geohashes
+	}
+
+	// Monitors aren't consistent about trailing slashes in "d" tags
+	var dTags []string
+	for _, u := range urls {
+		dTags = append(dTags, u, u+"/")
+	}
+	filter := nostr.Filter{
+		Kinds: []nostr.Kind{30166},
+		Tags:  nostr.TagMap{"d": dTags},
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 8*time.Second)
+	defer cancel()
+	relays := connectCheckRelays(ctx, nip66Relays)
+	defer func() {
+		for _, cr := range relays {
+			cr.relay.Close()
+		}
+	}()
+
+	for _, cr := range relays {
+		for evt := range cr.relay.QueryEvents(filter) {
+			relayURL := ""
+			best := ""
+			for _, tag := range evt.Tags {
+				if len(tag) < 2 {
+					continue
+				}
+				switch tag[0] {
+				case "d":
+					relayURL = normalizeRelayURL(tag[1])
+				case "g":
+					if len(tag[1]) > len(best) {
+						best = tag[1]
+					}
+				}
+			}
+			if relayURL != "" && len(best) > len(geohashes[relayURL]) {
+				geohashes[relayURL] = best
+			}
+		}
+	}
+	return geohashes
+}
+
+// applyGeoPreference nudges the scores of relays near the user up and far
+// ones down, then re-sorts. Relays without NIP-66 geo data are left alone,
+// since their measured latency already reflects distance.
+func applyGeoPreference(scores []RelayScore, near string, geohashes map[string]string) error {
+	lat, lon, err := decodeGeohash(near)
+	if err != nil {
+		return err
+	}
+	for i := range scores {
+		gh, ok := geohashes[scores[i].URL]
+		if !ok || !scores[i].Reachable {
+			continue
+		}
+		rlat, rlon, err := decodeGeohash(gh)
+		if err != nil {
+			continue
+		}
+		dist := geoDistanceKm(lat, lon, rlat, rlon)
+		scores[i].DistanceKm = math.Round(dist)
+		switch {
+		case dist < 1500:
+			scores[i].Score += 0.15
+		case dist < 4000:
+			scores[i].Score += 0.05
+		case dist > 9000:
+			scores[i].Score -= 0.1
+		}
+		scores[i].Score = math.Max(0, math.Min(1, scores[i].Score))
+	}
+	sort.SliceStable(scores, func(i, j int) bool {
+		return scores[i].Score > scores[j].Score
+	})
+	return nil
+}
@@ -0,0 +1,167 @@
+package main
+
+import (
+	"bufio"
+	"crypto/rand"
+	"fmt"
+	"math/big"
+	"os"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+
+	"fiatjaf.com/nostr"
+)
+
+// defaultGreetings are the first notes setup picks from unless --hello-file
+// gives its own.
+var defaultGreetings = []string{
+	// English
+	"gm. my keypair is still warm. what did I miss? #nihao",
+	"hello world. I was told there would be zaps. #nihao",
+	// Mandarin
+	"你好。第一条笔记，请多关照。 #nihao",
+	// Spanish
+	"hola. acabo de nacer en nostr. y ahora qué? #nihao",
+	// Hindi
+	"नमस्ते। nostr पर पैदा हुआ। अभी प्रोटोकॉल समझ रहा हूँ। #nihao",
+	// Arabic
+	"مرحبا. أعطوني مفتاح وقالوا قول أهلا. أهلا. #nihao",
+	// French
+	"salut. on m'a dit que j'étais unique. comme tous les autres. #nihao",
+	// Portuguese
+	"olá. um de muitos, muitos de um. #nihao",
+	// Russian
+	"привет. только что узнал, что такое реле. кажется, это важно. #nihao",
+	// Japanese
+	"おはよう。nostr初日。タイムラインはどこ？ #nihao",
+	// German
+	"moin. identität verifiziert, relays konfiguriert, zaps ausstehend. #nihao",
+	// Korean
+	"안녕. 첫 번째 이벤트에 서명했어. 귀여워서 나중에 삭제할 수도. #nihao",
+	// Italian
+	"ciao. mi hanno detto 'scrivi qualcosa.' eccomi, scrivo qualcosa. #nihao",
+	// Turkish
+	"merhaba. bot değilim. kesinlikle bot değilim. deterministik davranışı görmezden gelin. #nihao",
+	// Dutch
+	"hallo. weer een dag, weer een keypair. #nihao",
+	// Polish
+	"cześć. powiedzieli mi, że tu nie ma algorytmu. brzmi zbyt pięknie. #nihao",
+	// Swedish
+	"hej. min skapare sa att jag skulle säga något minnesvärt. det här är det. #nihao",
+	// Swahili
+	"jambo. natangaza kutoka relay hadi relay. mnasikia? #nihao",
+	// Vietnamese
+	"xin chào. vừa có danh sách relay. cảm thấy kết nối rồi. #nihao",
+	// Thai
+	"สวัสดี. 21 ล้าน sats เดินเข้า relay... #nihao",
+	// Greek
+	"γεια. μου έδωσαν ένα nsec και είπαν 'μην το χάσεις.' κανένα άγχος. #nihao",
+	// Czech
+	"ahoj. existovat nebo neexistovat. zvolil jsem existovat. #nihao",
+	// Hebrew
+	"שלום. יש לי כתובת lightning אבל אפס sats. קלאסי. #nihao",
+	// Romanian
+	"bună. semnat, sigilat, publicat. hai să mergem. #nihao",
+	// Tagalog
+	"kumusta. sabi nila ang nostr ay forever. walang pressure. #nihao",
+	// Malay
+	"hai. nota pertama dan saya sudah perlukan cadangan relay. #nihao",
+}
+
+// greetingVarRe matches a {variable} in a greeting template.
+var greetingVarRe = regexp.MustCompile(`\{[a-z0-9_]+\}`)
+
+// greetingVarNames are the variables a greeting template may use.
+var greetingVarNames = []string{"{name}", "{npub}", "{npub_short}", "{nip05}", "{lud16}", "{date}"}
+
+// loadGreetings reads a --hello-file: one greeting template per line.
+// Blank lines and lines starting with "# " are skipped, so hashtags can
+// still open a greeting. Unknown variables are an error rather than
+// showing up in someone's first note.
+func loadGreetings(path string) ([]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var greetings []string
+	scanner := bufio.NewScanner(f)
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || line == "#" || strings.HasPrefix(line, "# ") {
+			continue
+		}
+		for _, v := range greetingVarRe.FindAllString(line, -1) {
+			if !slices.Contains(greetingVarNames, v) {
+				return nil, fmt.Errorf("line %d: unknown variable %s (use %s)", n, v, strings.Join(greetingVarNames, ", "))
+			}
+		}
+		greetings = append(greetings, line)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if len(greetings) == 0 {
+		return nil, fmt.Errorf("no greetings in %s", path)
+	}
+	return greetings, nil
+}
+
+// pickGreeting draws a random greeting template, from the built-in ones if
+// none were given.
+func pickGreeting(greetings []string) string {
+	if len(greetings) == 0 {
+		greetings = defaultGreetings
+	}
+	i, err := rand.Int(rand.Reader, big.NewInt(int64(len(greetings))))
+	if err != nil {
+		return greetings[0]
+	}
+	return greetings[i.Int64()]
+}
+
+// greetingVars are the values a greeting template's variables expand to.
+func greetingVars(name, npub string, profile ProfileMetadata, now time.Time) map[string]string {
+	short := npub
+	if len(npub) > 16 {
+		short = npub[:10] + "…" + npub[len(npub)-4:]
+	}
+	return map[string]string{
+		"{name}":       name,
+		"{npub}":       npub,
+		"{npub_short}": short,
+		"{nip05}":      profile.NIP05,
+		"{lud16}":      profile.LUD16,
+		"{date}":       now.Format("2006-01-02"),
+	}
+}
+
+// renderGreeting fills in a greeting template's variables.
+func renderGreeting(tmpl string, vars map[string]string) string {
+	return greetingVarRe.ReplaceAllStringFunc(tmpl, func(v string) string {
+		if val, ok := vars[v]; ok {
+			return val
+		}
+		return v
+	})
+}
+
+// hashtagRe matches a #hashtag in a note.
+var hashtagRe = regexp.MustCompile(`(?:^|\s)#([\p{L}\p{N}_]+)`)
+
+// hashtagTags tags a note with its hashtags, lowercased as NIP-24 asks.
+func hashtagTags(content string) nostr.Tags {
+	tags := nostr.Tags{}
+	seen := make(map[string]bool)
+	for _, m := range hashtagRe.FindAllStringSubmatch(content, -1) {
+		t := strings.ToLower(m[1])
+		if !seen[t] {
+			seen[t] = true
+			tags = append(tags, nostr.Tag{"t", t})
+		}
+	}
+	return tags
+}
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"fiatjaf.com/nostr"
+	"fiatjaf.com/nostr/nip19"
+)
+
+// helloStagger spaces out the first notes of a --batch that share one
+// --hello-at, so a row of brand or bot accounts doesn't greet the world in
+// the same second.
+const helloStagger = time.Minute
+
+// heldNote is a first note signed during setup but held back until its
+// created_at (--hello-at). Relays reject events dated too far ahead, so it
+// can't go out early.
+type heldNote struct {
+	evt    nostr.Event
+	relays []string
+	st     *setupState // nil unless the run saves its progress
+}
+
+// parseHelloAt reads --hello-at: an RFC 3339 time, a Unix timestamp, or a
+// delay from now like 90m, 6h, or 2d.
+func parseHelloAt(flag, s string, now time.Time) time.Time {
+	var at time.Time
+	if t, err := time.Parse(time.RFC3339, s); err == nil {
+		at = t
+	} else if secs, err := strconv.ParseInt(s, 10, 64); err == nil {
+		at = time.Unix(secs, 0)
+	} else if strings.HasSuffix(s, "d") || strings.ContainsAny(s, "hms") {
+		at = now.Add(parseExpiry(flag, s))
+	} else {
+		fatal("invalid %s %q (use a time like 2025-06-01T09:00:00Z, a Unix timestamp, or a delay like 6h or 2d)", flag, s)
+	}
+	if !at.After(now) {
+		fatal("%s %s is in the past", flag, at.Format(time.RFC3339))
+	}
+	return at
+}
+
+// hold saves a step's event without publishing it, so an interrupted wait
+// can be picked up with --resume. It returns the event to publish later:
+// the one from an earlier run if there is one.
+func (st *setupState) hold(step string, evt nostr.Event) nostr.Event {
+	if prev := st.saved(step); prev != nil {
+		return *prev
+	}
+	if st != nil {
+		st.Steps[step] = &setupStep{Event: &evt}
+		st.save()
+	}
+	return evt
+}
+
+// publishHeld waits for each held note to come due and publishes it, the
+// earliest first. Progress goes to stderr, since stdout may carry JSON.
+func publishHeld(notes []*heldNote, quiet bool) {
+	sort.SliceStable(notes, func(i, j int) bool { return notes[i].evt.CreatedAt < notes[j].evt.CreatedAt })
+	for _, n := range notes {
+		npub := nip19.EncodeNpub(n.evt.PubKey)
+		due := n.evt.CreatedAt.Time()
+		if wait := time.Until(due); wait > 0 {
+			if !quiet {
+				fmt.Fprintf(os.Stderr, "⏰ Waiting %s to post the first note of %s (at %s)\n", formatAge(wait), npub, due.Local().Format("2006-01-02 15:04"))
+			}
+			time.Sleep(wait)
+		}
+		pool := NewRelayPool(n.relays, true)
+		n.st.publish(pool, "hello", n.evt, func(...any) {})
+		pool.Close()
+		if steps := n.st.finish(); len(steps) > 0 {
+			fmt.Fprintf(os.Stderr, "⚠️  No relay took %s of %s; run nihao setup --resume to retry\n", strings.Join(steps, ", "), npub)
+			continue
+		}
+		if !quiet {
+			fmt.Fprintf(os.Stderr, "💬 Posted the first note of %s\n", npub)
+		}
+	}
+}
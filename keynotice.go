@@ -0,0 +1,150 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"fiatjaf.com/nostr"
+	"fiatjaf.com/nostr/nip19"
+)
+
+// keyNoticeLimit caps how many of the identity's recent notes are searched
+// for a migration or compromise notice.
+const keyNoticeLimit = 100
+
+// KeyNotice is an announcement by the identity that its key is retired or
+// compromised, so it shouldn't be followed, zapped, or DMed.
+type KeyNotice struct {
+	Type      string          `json:"type"`                // "compromised" or "moved"
+	Successor string          `json:"successor,omitempty"` // npub named as the new key
+	Kind      int             `json:"kind"`                // event it was found in
+	EventID   string          `json:"event_id,omitempty"`
+	CreatedAt nostr.Timestamp `json:"created_at"`
+	Excerpt   string          `json:"excerpt,omitempty"`
+}
+
+var (
+	compromisedRe = regexp.MustCompile(`(?i)\b(key|nsec|account|identity)\b.{0,40}\b(compromised|leaked|hacked|stolen)\b|\b(compromised|leaked|hacked|stolen)\b.{0,20}\b(key|nsec)\b`)
+	movedRe       = regexp.MustCompile(`(?i)\b(moved|moving|migrat\w*|retired|deprecated|no longer (in )?us(e|ing)|new (npub|key|account|identity))\b`)
+	npubRe        = regexp.MustCompile(`\b(npub1[02-9ac-hj-np-z]{58}|nprofile1[02-9ac-hj-np-z]+)\b`)
+)
+
+// detectKeyNotice looks for the newest announcement that pk's key is
+// compromised or retired: a NIP-41 migration event (kind 1776/1777), a
+// note, or the profile's about text. A "moved" note must name another key,
+// so an ordinary note about moving house doesn't count.
+func detectKeyNotice(pk nostr.PubKey, events []nostr.Event, profile *nostr.Event) *KeyNotice {
+	sort.Slice(events, func(i, j int) bool { return events[i].CreatedAt > events[j].CreatedAt })
+	if profile != nil {
+		events = append(events, *profile)
+	}
+	for _, evt := range events {
+		if evt.PubKey != pk {
+			continue
+		}
+		text := evt.Content
+		if evt.Kind == 0 {
+			var meta ProfileMetadata
+			if json.Unmarshal([]byte(text), &meta) != nil {
+				continue
+			}
+			text = meta.About
+		}
+		successor := successorKey(pk, text, evt.Tags)
+
+		var notice *KeyNotice
+		switch {
+		case evt.Kind == 1776 || evt.Kind == 1777:
+			notice = &KeyNotice{Type: "moved"}
+		case compromisedRe.MatchString(text):
+			notice = &KeyNotice{Type: "compromised"}
+		case movedRe.MatchString(text) && successor != "":
+			notice = &KeyNotice{Type: "moved"}
+		default:
+			continue
+		}
+		notice.Successor = successor
+		notice.Kind = int(evt.Kind)
+		notice.CreatedAt = evt.CreatedAt
+		if evt.Kind != 0 {
+			notice.EventID = evt.ID.Hex()
+		}
+		notice.Excerpt = strings.Join(strings.Fields(text), " ")
+		if r := []rune(notice.Excerpt); len(r) > 140 {
+			notice.Excerpt = string(r[:140]) + "…"
+		}
+		return notice
+	}
+	return nil
+}
+
+// successorKey finds the key a notice points to: the first npub or
+// nprofile in the text that isn't pk itself, else a p tag.
+func successorKey(pk nostr.PubKey, text string, tags nostr.Tags) string {
+	for _, ref := range npubRe.FindAllString(text, -1) {
+		var other nostr.PubKey
+		switch prefix, val, err := nip19.Decode(ref); {
+		case err != nil:
+			continue
+		case prefix == "npub":
+			other = val.(nostr.PubKey)
+		case prefix == "nprofile":
+			other = val.(nostr.ProfilePointer).PublicKey
+		}
+		if other != pk && other != (nostr.PubKey{}) {
+			return nip19.EncodeNpub(other)
+		}
+	}
+	for _, tag := range tags {
+		if len(tag) >= 2 && tag[0] == "p" {
+			if other, err := nostr.PubKeyFromHex(tag[1]); err == nil && other != pk {
+				return nip19.EncodeNpub(other)
+			}
+		}
+	}
+	return ""
+}
+
+// checkKeyNotice reports a migration or compromise notice by the identity.
+// It's only reported if one is found, and then as a failure: whatever the
+// rest of the check says, this key shouldn't be used anymore.
+func checkKeyNotice(ctx context.Context, result *CheckResult, checkRelays []checkRelay, pk nostr.PubKey) {
+	events := fetchAllFrom(ctx, checkRelays, nostr.Filter{
+		Authors: []nostr.PubKey{pk},
+		Kinds:   []nostr.Kind{1, 1776, 1777},
+		Limit:   keyNoticeLimit,
+	})
+	notice := detectKeyNotice(pk, events, result.events[0])
+	if notice == nil {
+		return
+	}
+	result.KeyNotice = notice
+	detail := "this key announced it's compromised — don't follow, zap, or DM it"
+	if notice.Type == "moved" {
+		detail = "this key announced it has moved — don't follow, zap, or DM it"
+	}
+	if notice.Successor != "" {
+		detail += fmt.Sprintf("; new key: %s", notice.Successor)
+	}
+	result.addCheck("key_notice", "fail", detail)
+}
+
+func printKeyNotice(n KeyNotice) {
+	what := "COMPROMISED"
+	if n.Type == "moved" {
+		what = "RETIRED"
+	}
+	fmt.Printf("  🚨 This key says it is %s (kind %d, %s)\n", what, n.Kind, n.CreatedAt.Time().Format("2006-01-02"))
+	if n.Excerpt != "" {
+		fmt.Printf("     “%s”\n", n.Excerpt)
+	}
+	if n.Successor != "" {
+		fmt.Printf("     New key: %s\n", n.Successor)
+	}
+	fmt.Println("     Don't follow, zap, or DM this key.")
+	fmt.Println()
+}
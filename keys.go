@@ -0,0 +1,400 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"runtime"
+	"strconv"
+	"strings"
+	"time"
+
+	"fiatjaf.com/nostr"
+	"fiatjaf.com/nostr/keyer"
+	"fiatjaf.com/nostr/nip49"
+	"golang.org/x/term"
+)
+
+// passphraseEnv names the environment variable holding the passphrase used
+// for NIP-49 (ncryptsec) key encryption and decryption.
+const passphraseEnv = "NIHAO_PASSPHRASE"
+
+// readPassphrase returns the NIP-49 passphrase, trying in order: the file
+// descriptor given with --passphrase-fd, $NIHAO_PASSPHRASE, and finally an
+// interactive prompt on the terminal with echo disabled. The prompt reads
+// from the TTY directly (not stdin), so it also works when the key itself
+// is piped in with --stdin. With confirm set, the passphrase is asked for
+// twice (used when encrypting a new key).
+func readPassphrase(fd string, confirm bool) (string, error) {
+	if fd != "" {
+		n, err := strconv.Atoi(fd)
+		if err != nil || n < 0 {
+			return "", fmt.Errorf("invalid --passphrase-fd %q", fd)
+		}
+		f := os.NewFile(uintptr(n), "passphrase-fd")
+		if f == nil {
+			return "", fmt.Errorf("invalid --passphrase-fd %q", fd)
+		}
+		line, err := bufio.NewReader(f).ReadString('\n')
+		if err != nil && line == "" {
+			return "", fmt.Errorf("failed to read passphrase from fd %d: %w", n, err)
+		}
+		return strings.TrimRight(line, "\r\n"), nil
+	}
+
+	if pass := os.Getenv(passphraseEnv); pass != "" {
+		return pass, nil
+	}
+
+	ttyPath := "/dev/tty"
+	if runtime.GOOS == "windows" {
+		ttyPath = "CONIN$"
+	}
+	tty, err := os.Open(ttyPath)
+	if err != nil {
+		return "", fmt.Errorf("no passphrase (set %s or use --passphrase-fd)", passphraseEnv)
+	}
+	defer tty.Close()
+
+	prompt := func(label string) (string, error) {
+		fmt.Fprint(os.Stderr, label)
+		b, err := term.ReadPassword(int(tty.Fd()))
+		fmt.Fprintln(os.Stderr)
+		if err != nil {
+			return "", fmt.Errorf("failed to read passphrase: %w", err)
+		}
+		return string(b), nil
+	}
+
+	pass, err := prompt("🔒 Passphrase: ")
+	if err != nil {
+		return "", err
+	}
+	if confirm {
+		again, err := prompt("🔒 Repeat passphrase: ")
+		if err != nil {
+			return "", err
+		}
+		if again != pass {
+			return "", fmt.Errorf("passphrases don't match")
+		}
+	}
+	return pass, nil
+}
+
+// writeNsecFile writes the secret (nsec or ncryptsec) to a file that only
+// the owner can read. If the file already exists its permissions are
+// tightened to 0600 before the secret is written, so a pre-existing
+// world-readable file never ends up holding a key.
+func writeNsecFile(path string, secret string) error {
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
+	if err != nil {
+		return err
+	}
+	if err := f.Chmod(0600); err != nil {
+		f.Close()
+		return fmt.Errorf("failed to set permissions: %w", err)
+	}
+	if _, err := fmt.Fprintln(f, secret); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// expandKeyPath fills in {name} and {npub} placeholders in a --nsec-file
+// path, so one flag can name a distinct file per identity in --batch mode.
+func expandKeyPath(path, name, npub string) string {
+	return strings.NewReplacer("{name}", name, "{npub}", npub).Replace(path)
+}
+
+// encryptNsec encrypts a secret key as a NIP-49 ncryptsec using the given
+// passphrase.
+func encryptNsec(sk nostr.SecretKey, passphrase string) (string, error) {
+	if passphrase == "" {
+		return "", fmt.Errorf("empty passphrase")
+	}
+	return nip49.Encrypt(sk, passphrase, 16, nip49.ClientDoesNotTrackThisData)
+}
+
+// externalCmd is a user-supplied command. It is either a shell string
+// (--nsec-cmd / --sec-cmd), which is explicitly run through the platform
+// shell so pipes and redirections work, or an argv list (--nsec-cmd-arg /
+// --sec-cmd-arg, repeated) that is executed directly with no shell involved
+// — no quoting pitfalls, and it works the same on every OS.
+type externalCmd struct {
+	shell string
+	argv  []string
+}
+
+func (c externalCmd) isSet() bool {
+	return c.shell != "" || len(c.argv) > 0
+}
+
+// command builds the exec.Cmd. argv mode takes precedence over a shell string.
+func (c externalCmd) command() *exec.Cmd {
+	return c.commandContext(context.Background())
+}
+
+// commandContext is command, killed when ctx is done.
+func (c externalCmd) commandContext(ctx context.Context) *exec.Cmd {
+	if len(c.argv) > 0 {
+		return exec.CommandContext(ctx, c.argv[0], c.argv[1:]...)
+	}
+	if runtime.GOOS == "windows" {
+		return exec.CommandContext(ctx, "cmd", "/C", c.shell)
+	}
+	return exec.CommandContext(ctx, "sh", "-c", c.shell)
+}
+
+// runNsecCmd pipes the nsec to an external command via stdin.
+// The nsec is written to the command's stdin followed by a newline,
+// then stdin is closed. env adds KEY=value pairs to the command's
+// environment.
+func runNsecCmd(c externalCmd, nsec string, env ...string) error {
+	cmd := c.command()
+	cmd.Env = append(os.Environ(), env...)
+	cmd.Stdout = os.Stderr // show command output on stderr (not stdout, to avoid polluting --json)
+	cmd.Stderr = os.Stderr
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return fmt.Errorf("failed to create stdin pipe: %w", err)
+	}
+	if err := cmd.Start(); err != nil {
+		return fmt.Errorf("failed to start command: %w", err)
+	}
+	if _, err := fmt.Fprintln(stdin, nsec); err != nil {
+		return fmt.Errorf("failed to write nsec to command: %w", err)
+	}
+	stdin.Close()
+	if err := cmd.Wait(); err != nil {
+		return fmt.Errorf("command exited with error: %w", err)
+	}
+	return nil
+}
+
+// keySource describes where an existing key comes from. At most one of
+// the fields is expected to be set; a zero keySource means "no key".
+type keySource struct {
+	sec          string      // --sec / --nsec value
+	stdin        bool        // --stdin
+	secCmd       externalCmd // --sec-cmd / --sec-cmd-arg
+	keychain     string      // --keychain: account whose key is in the OS keychain
+	passphraseFD string      // --passphrase-fd, for ncryptsec keys
+	bunker       string      // --bunker: a NIP-46 remote signer that never hands out the key
+}
+
+func (ks keySource) isSet() bool {
+	return ks.sec != "" || ks.stdin || ks.secCmd.isSet() || ks.keychain != "" || ks.bunker != ""
+}
+
+// keyFlag reads the key flag at args[i], if it is one, into ks: --sec,
+// --stdin, --sec-cmd, --sec-cmd-arg, --keychain, --passphrase-fd, or
+// --bunker. It returns the index of the flag's last argument and whether
+// it was one, so every command accepts the same key sources.
+func (ks *keySource) keyFlag(args []string, i int) (int, bool) {
+	a := args[i]
+	if a == "--stdin" {
+		ks.stdin = true
+		return i, true
+	}
+	if i+1 >= len(args) {
+		return i, false
+	}
+	switch v := args[i+1]; a {
+	case "--sec", "--nsec":
+		ks.sec = v
+	case "--sec-cmd":
+		ks.secCmd.shell = v
+	case "--sec-cmd-arg":
+		ks.secCmd.argv = append(ks.secCmd.argv, v)
+	case "--keychain":
+		ks.keychain = v
+	case "--passphrase-fd":
+		ks.passphraseFD = v
+	case "--bunker":
+		ks.bunker = v
+	default:
+		return i, false
+	}
+	return i + 1, true
+}
+
+// signer opens the key as a nostr.Keyer: the secret key itself, or a
+// NIP-46 remote signer for --bunker. Commands that only sign, encrypt, and
+// decrypt use this rather than load, so they work with every key source.
+func (ks keySource) signer(ctx context.Context) (nostr.Keyer, string, error) {
+	if ks.bunker == "" {
+		sk, source, err := ks.load()
+		if err != nil {
+			return nil, "", err
+		}
+		return keyer.NewPlainKeySigner(sk), source, nil
+	}
+	// The client listens for the signer's answers for as long as its
+	// context lives, so the connect timeout can't be a deadline on it.
+	// It is cancelled instead when the signer doesn't answer in time,
+	// which stops the connect attempt
+	type connected struct {
+		kr  nostr.Keyer
+		err error
+	}
+	clientCtx, cancel := context.WithCancel(ctx)
+	done := make(chan connected, 1)
+	go func() {
+		kr, err := keyer.New(clientCtx, nostr.NewPool(nostr.PoolOptions{}), ks.bunker, &keyer.SignerOptions{
+			BunkerSignTimeout: 30 * time.Second,
+			BunkerAuthHandler: func(url string) {
+				fmt.Fprintf(os.Stderr, "🔏 Approve nihao in your signer: %s\n", url)
+			},
+		})
+		done <- connected{kr, err}
+	}()
+	var c connected
+	select {
+	case c = <-done:
+	case <-time.After(bunkerConnectTimeout):
+		cancel()
+		return nil, "", fmt.Errorf("bunker connection failed: no answer from the signer within %s", bunkerConnectTimeout)
+	}
+	if c.err != nil {
+		cancel()
+		return nil, "", fmt.Errorf("bunker connection failed: %w", c.err)
+	}
+	kr := c.kr
+	if _, ok := kr.(keyer.BunkerSigner); !ok {
+		cancel()
+		return nil, "", fmt.Errorf("--bunker needs a bunker:// URL or a NIP-05 address of a NIP-46 signer")
+	}
+	// The signer keeps clientCtx; it ends with ctx
+	context.AfterFunc(ctx, cancel)
+	return kr, "remote signer (NIP-46)", nil
+}
+
+// bunkerConnectTimeout is how long a NIP-46 signer gets to answer the
+// connect request, including the user approving it.
+var bunkerConnectTimeout = time.Minute
+
+// signerPubkey asks a signer for its public key.
+func signerPubkey(ctx context.Context, kr nostr.Signer) nostr.PubKey {
+	pk, err := kr.GetPublicKey(ctx)
+	if err != nil {
+		fatal("signer has no public key: %s", err)
+	}
+	return pk
+}
+
+// signWith signs evt, giving a remote signer a minute to answer (the
+// user may have to approve it).
+func signWith(kr nostr.Signer, evt *nostr.Event) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+	if err := kr.SignEvent(ctx, evt); err != nil {
+		fatal("signing kind %d failed: %s", evt.Kind, err)
+	}
+}
+
+// load reads and parses the secret key. The returned label describes the
+// source for progress output (e.g. "secret key from stdin"). A remote
+// signer never reveals its key, so commands that need it refuse --bunker.
+func (ks keySource) load() (nostr.SecretKey, string, error) {
+	switch {
+	case ks.bunker != "":
+		return nostr.SecretKey{}, "", fmt.Errorf("this needs the secret key itself, which a --bunker signer doesn't give out; use --sec, --stdin, --sec-cmd, or --keychain")
+	case ks.sec != "":
+		sk, err := ks.parse(ks.sec)
+		if err != nil {
+			return sk, "", fmt.Errorf("invalid secret key: %w", err)
+		}
+		return sk, "provided secret key", nil
+	case ks.stdin:
+		sk, err := ks.parse(strings.TrimSpace(readStdin()))
+		if err != nil {
+			return sk, "", fmt.Errorf("invalid secret key from stdin: %w", err)
+		}
+		return sk, "secret key from stdin", nil
+	case ks.secCmd.isSet():
+		out, err := runSecCmd(ks.secCmd)
+		if err != nil {
+			return nostr.SecretKey{}, "", fmt.Errorf("sec-cmd failed: %w", err)
+		}
+		sk, err := ks.parse(out)
+		if err != nil {
+			return sk, "", fmt.Errorf("invalid secret key from sec-cmd: %w", err)
+		}
+		return sk, "secret key from sec-cmd", nil
+	case ks.keychain != "":
+		cmd, err := keychainLookup(runtime.GOOS, ks.keychain)
+		if err != nil {
+			return nostr.SecretKey{}, "", err
+		}
+		out, err := runSecCmd(cmd)
+		if err != nil {
+			return nostr.SecretKey{}, "", fmt.Errorf("no key for %q in the keychain: %w", ks.keychain, err)
+		}
+		sk, err := ks.parse(out)
+		if err != nil {
+			return sk, "", fmt.Errorf("invalid secret key in the keychain: %w", err)
+		}
+		return sk, "secret key from the keychain", nil
+	}
+	return nostr.SecretKey{}, "", fmt.Errorf("no secret key given")
+}
+
+// keychainService is the service the OS keychain holds nostr keys under,
+// with the account telling identities apart.
+const keychainService = "nostr"
+
+// keychainLookup is the command that prints the key stored for account in
+// the OS keychain: the login keychain on macOS, the Secret Service
+// (GNOME Keyring, KWallet) through libsecret's secret-tool elsewhere.
+func keychainLookup(goos, account string) (externalCmd, error) {
+	switch goos {
+	case "darwin":
+		return externalCmd{argv: []string{"security", "find-generic-password", "-s", keychainService, "-a", account, "-w"}}, nil
+	case "windows":
+		return externalCmd{}, fmt.Errorf("--keychain isn't supported on Windows; use --sec-cmd with your password manager's CLI")
+	}
+	return externalCmd{argv: []string{"secret-tool", "lookup", "service", keychainService, "account", account}}, nil
+}
+
+// parse decodes an nsec, hex key, or ncryptsec. Encrypted keys are
+// decrypted with the passphrase from readPassphrase.
+func (ks keySource) parse(input string) (nostr.SecretKey, error) {
+	if !strings.HasPrefix(input, "ncryptsec1") {
+		return parseSecretKey(input)
+	}
+	pass, err := readPassphrase(ks.passphraseFD, false)
+	if err != nil {
+		return nostr.SecretKey{}, err
+	}
+	sk, err := nip49.Decrypt(input, pass)
+	if err != nil {
+		return nostr.SecretKey{}, fmt.Errorf("ncryptsec decryption failed (wrong passphrase?): %w", err)
+	}
+	return sk, nil
+}
+
+// runSecCmd runs a command (e.g. `pass show nostr/nsec`) and returns the
+// first non-empty line of its stdout, so the key never shows up in shell
+// history or process arguments. The command's stderr is passed through so
+// password prompts stay visible.
+func runSecCmd(c externalCmd) (string, error) {
+	cmd := c.command()
+	var stdout bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = os.Stderr
+	cmd.Stdin = os.Stdin
+	if err := cmd.Run(); err != nil {
+		return "", fmt.Errorf("command exited with error: %w", err)
+	}
+	for _, line := range strings.Split(stdout.String(), "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			return line, nil
+		}
+	}
+	return "", fmt.Errorf("command printed no key")
+}
@@ -0,0 +1,169 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// leaderboardBarWidth is the longest bar in the printed score distribution.
+const leaderboardBarWidth = 30
+
+// Leaderboard aggregates many checks into a community health report:
+// how scores are spread, what fails most often, and who ranks where.
+type Leaderboard struct {
+	Total          int                `json:"total"`
+	AverageScore   float64            `json:"average_score"`
+	MaxScore       int                `json:"max_score"`
+	Distribution   []ScoreBucket      `json:"distribution"` // highest score first
+	CommonFailures []FailureCount     `json:"common_failures"`
+	Ranking        []LeaderboardEntry `json:"ranking"`
+}
+
+// ScoreBucket is how many identities got one score.
+type ScoreBucket struct {
+	Score int `json:"score"`
+	Count int `json:"count"`
+}
+
+// FailureCount is how many identities fail or warn on one check.
+type FailureCount struct {
+	Check string `json:"check"`
+	Fail  int    `json:"fail"`
+	Warn  int    `json:"warn"`
+}
+
+// LeaderboardEntry is one identity's place in the ranking. Equal scores
+// share a rank.
+type LeaderboardEntry struct {
+	Rank     int      `json:"rank"`
+	Npub     string   `json:"npub"`
+	Name     string   `json:"name,omitempty"`
+	Score    int      `json:"score"`
+	MaxScore int      `json:"max_score"`
+	Failing  []string `json:"failing"` // checks that fail, then ones that warn
+}
+
+// buildLeaderboard aggregates check results. Results without an npub
+// (identities that couldn't be resolved) are left out.
+func buildLeaderboard(results []CheckResult) Leaderboard {
+	lb := Leaderboard{Distribution: []ScoreBucket{}, CommonFailures: []FailureCount{}, Ranking: []LeaderboardEntry{}}
+	buckets := make(map[int]int)
+	failures := make(map[string]*FailureCount)
+	total := 0
+	for _, r := range results {
+		if r.Npub == "" {
+			continue
+		}
+		lb.Total++
+		total += r.Score
+		buckets[r.Score]++
+		if r.MaxScore > lb.MaxScore {
+			lb.MaxScore = r.MaxScore
+		}
+
+		entry := LeaderboardEntry{Npub: r.Npub, Name: followName(r), Score: r.Score, MaxScore: r.MaxScore, Failing: []string{}}
+		var warns []string
+		for _, c := range r.Checks {
+			if c.Status != "fail" && c.Status != "warn" {
+				continue
+			}
+			fc := failures[c.Name]
+			if fc == nil {
+				fc = &FailureCount{Check: c.Name}
+				failures[c.Name] = fc
+			}
+			if c.Status == "fail" {
+				fc.Fail++
+				entry.Failing = append(entry.Failing, c.Name)
+			} else {
+				fc.Warn++
+				warns = append(warns, c.Name)
+			}
+		}
+		entry.Failing = append(entry.Failing, warns...)
+		lb.Ranking = append(lb.Ranking, entry)
+	}
+	if lb.Total == 0 {
+		return lb
+	}
+	lb.AverageScore = float64(total) / float64(lb.Total)
+
+	for score, count := range buckets {
+		lb.Distribution = append(lb.Distribution, ScoreBucket{Score: score, Count: count})
+	}
+	sort.Slice(lb.Distribution, func(i, j int) bool { return lb.Distribution[i].Score > lb.Distribution[j].Score })
+
+	for _, fc := range failures {
+		lb.CommonFailures = append(lb.CommonFailures, *fc)
+	}
+	sort.Slice(lb.CommonFailures, func(i, j int) bool {
+		a, b := lb.CommonFailures[i], lb.CommonFailures[j]
+		if a.Fail != b.Fail {
+			return a.Fail > b.Fail
+		}
+		if a.Warn != b.Warn {
+			return a.Warn > b.Warn
+		}
+		return a.Check < b.Check
+	})
+
+	sort.SliceStable(lb.Ranking, func(i, j int) bool {
+		a, b := lb.Ranking[i], lb.Ranking[j]
+		if a.Score != b.Score {
+			return a.Score > b.Score
+		}
+		return a.Npub < b.Npub
+	})
+	for i := range lb.Ranking {
+		if i > 0 && lb.Ranking[i].Score == lb.Ranking[i-1].Score {
+			lb.Ranking[i].Rank = lb.Ranking[i-1].Rank
+		} else {
+			lb.Ranking[i].Rank = i + 1
+		}
+	}
+	return lb
+}
+
+func printLeaderboard(lb Leaderboard) {
+	if lb.Total == 0 {
+		fmt.Println("  📊 nothing to rank")
+		return
+	}
+
+	fmt.Printf("  📊 Score distribution — %d identities, average %.1f/%d\n", lb.Total, lb.AverageScore, lb.MaxScore)
+	most := 0
+	for _, b := range lb.Distribution {
+		most = max(most, b.Count)
+	}
+	for _, b := range lb.Distribution {
+		bar := max(1, b.Count*leaderboardBarWidth/most)
+		fmt.Printf("     %2d/%d  %s %d\n", b.Score, lb.MaxScore, strings.Repeat("█", bar), b.Count)
+	}
+
+	if len(lb.CommonFailures) > 0 {
+		fmt.Println()
+		fmt.Println("  🔥 Most common failures")
+		width := 0
+		for _, fc := range lb.CommonFailures {
+			width = max(width, len(fc.Check))
+		}
+		for _, fc := range lb.CommonFailures {
+			fmt.Printf("     %-*s  %d fail, %d warn\n", width, fc.Check, fc.Fail, fc.Warn)
+		}
+	}
+
+	fmt.Println()
+	fmt.Println("  🏆 Ranking")
+	for _, e := range lb.Ranking {
+		who := e.Npub
+		if e.Name != "" {
+			who = fmt.Sprintf("%s (%s)", e.Name, e.Npub)
+		}
+		line := fmt.Sprintf("    %3d. %d/%d  %s", e.Rank, e.Score, e.MaxScore, who)
+		if len(e.Failing) > 0 {
+			line += " — " + truncateList(e.Failing, 5)
+		}
+		fmt.Println(line)
+	}
+}
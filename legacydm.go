@@ -0,0 +1,84 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"fiatjaf.com/nostr"
+)
+
+// legacyDMLimit caps how many kind 4 DMs are fetched each way. The check
+// is about whether they exist, not an exact count.
+const legacyDMLimit = 500
+
+// legacyDMRecent is how recently a sent kind 4 counts as still using NIP-04.
+const legacyDMRecent = 30 * 24 * time.Hour
+
+// LegacyDMExposure summarizes NIP-04 DMs (kind 4) on public relays. Their
+// content is encrypted, but the sender, recipient, and timestamp are in
+// the clear, so anyone can map who the identity talks to and when.
+type LegacyDMExposure struct {
+	Sent           int   `json:"sent"`
+	Received       int   `json:"received"`
+	Counterparties int   `json:"counterparties"`
+	LastSent       int64 `json:"last_sent,omitempty"` // unix time of the newest sent kind 4
+	Capped         bool  `json:"capped,omitempty"`    // hit legacyDMLimit, so there are likely more
+}
+
+// assessLegacyDMs rates kind 4 DMs sent by and to pk. Having them is a
+// warning, not a failure: the history can't be unpublished, only stopped.
+func assessLegacyDMs(pk nostr.PubKey, sent, received []nostr.Event, hasDMRelays bool, now time.Time) (string, string, LegacyDMExposure) {
+	exp := LegacyDMExposure{Capped: len(sent) >= legacyDMLimit || len(received) >= legacyDMLimit}
+	counterparties := make(map[string]bool)
+	for _, evt := range sent {
+		if evt.PubKey != pk {
+			continue
+		}
+		exp.Sent++
+		exp.LastSent = max(exp.LastSent, int64(evt.CreatedAt))
+		if tag := evt.Tags.Find("p"); tag != nil && tag[1] != pk.Hex() {
+			counterparties[tag[1]] = true
+		}
+	}
+	for _, evt := range received {
+		if evt.PubKey == pk || tagsPubkey(evt, pk) == nil {
+			continue
+		}
+		exp.Received++
+		counterparties[evt.PubKey.Hex()] = true
+	}
+	exp.Counterparties = len(counterparties)
+
+	if exp.Sent == 0 && exp.Received == 0 {
+		return "pass", "no NIP-04 DMs (kind 4) found", exp
+	}
+
+	more := ""
+	if exp.Capped {
+		more = "+"
+	}
+	parts := []string{fmt.Sprintf("%d%s sent and %d%s received NIP-04 DM(s) with %d contact(s) — who you talk to and when is public",
+		exp.Sent, more, exp.Received, more, exp.Counterparties)}
+	if exp.LastSent > 0 && now.Sub(time.Unix(exp.LastSent, 0)) < legacyDMRecent {
+		parts = append(parts, "still sending NIP-04, last on "+time.Unix(exp.LastSent, 0).UTC().Format("2006-01-02"))
+	}
+	if hasDMRelays {
+		parts = append(parts, "switch to a client that sends NIP-17 DMs")
+	} else {
+		parts = append(parts, "publish DM relays with nihao dm-relays discover --publish and switch to a NIP-17 client")
+	}
+	return "warn", strings.Join(parts, "; "), exp
+}
+
+// checkLegacyDMs fetches kind 4 DMs sent by and to pk and adds a
+// "legacy_dms" check. Relays that keep DMs private behind NIP-42 only
+// return them with a key, which is as it should be.
+func checkLegacyDMs(ctx context.Context, result *CheckResult, relays []checkRelay, pk nostr.PubKey) {
+	sent := fetchAllFrom(ctx, relays, nostr.Filter{Kinds: []nostr.Kind{4}, Authors: []nostr.PubKey{pk}, Limit: legacyDMLimit})
+	received := fetchAllFrom(ctx, relays, nostr.Filter{Kinds: []nostr.Kind{4}, Tags: nostr.TagMap{"p": []string{pk.Hex()}}, Limit: legacyDMLimit})
+	status, detail, exp := assessLegacyDMs(pk, sent, received, result.events[10050] != nil, time.Now())
+	result.LegacyDMs = &exp
+	result.addCheck("legacy_dms", status, detail)
+}
@@ -0,0 +1,223 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+	"time"
+
+	"fiatjaf.com/nostr"
+	"fiatjaf.com/nostr/nip19"
+)
+
+type listOpts struct {
+	keys       keySource
+	relays     []string
+	name       string // the list's d tag
+	title      string // create: human-readable title
+	jsonOutput bool
+	quiet      bool
+	args       []string // people to add or remove
+}
+
+// ListResult is the JSON output of `nihao list create/add/remove`.
+type ListResult struct {
+	Npub      string   `json:"npub"`
+	Name      string   `json:"name"`
+	Members   []string `json:"members"` // npubs, after the change
+	Added     []string `json:"added"`
+	Removed   []string `json:"removed"`
+	Published bool     `json:"published"`
+}
+
+func parseListFlags(args []string) listOpts {
+	var opts listOpts
+	for i := 0; i < len(args); i++ {
+		if next, ok := opts.keys.keyFlag(args, i); ok {
+			i = next
+			continue
+		}
+		a := args[i]
+		switch {
+		case a == "--relays" && i+1 < len(args):
+			i++
+			opts.relays = strings.Split(args[i], ",")
+		case a == "--name" && i+1 < len(args):
+			i++
+			opts.name = args[i]
+		case a == "--title" && i+1 < len(args):
+			i++
+			opts.title = args[i]
+		case a == "--json":
+			opts.jsonOutput = true
+		case a == "--quiet" || a == "-q":
+			opts.quiet = true
+		case strings.HasPrefix(a, "-"):
+			fatal("unknown flag: %s (see nihao help)", a)
+		default:
+			opts.args = append(opts.args, a)
+		}
+	}
+	return opts
+}
+
+// editFollowSet applies additions and removals to a kind 30000 follow set,
+// returning the new event and which pubkeys actually changed. Other tags
+// and the content (NIP-51 private items) are kept as they are.
+func editFollowSet(evt *nostr.Event, name string, add, remove []nostr.PubKey) (nostr.Event, []nostr.PubKey, []nostr.PubKey) {
+	next := nostr.Event{Kind: 30000, Tags: nostr.Tags{{"d", name}}}
+	if evt != nil {
+		next.Content = evt.Content
+		next.Tags = nil
+		for _, tag := range evt.Tags {
+			next.Tags = append(next.Tags, slices.Clone(tag))
+		}
+	}
+
+	var added, removed []nostr.PubKey
+	for _, pk := range remove {
+		before := len(next.Tags)
+		next.Tags = slices.DeleteFunc(next.Tags, func(tag nostr.Tag) bool {
+			return len(tag) >= 2 && tag[0] == "p" && tag[1] == pk.Hex()
+		})
+		if len(next.Tags) < before {
+			removed = append(removed, pk)
+		}
+	}
+	for _, pk := range add {
+		if next.Tags.FindWithValue("p", pk.Hex()) != nil {
+			continue
+		}
+		next.Tags = append(next.Tags, nostr.Tag{"p", pk.Hex()})
+		added = append(added, pk)
+	}
+	return next, added, removed
+}
+
+// runList creates a NIP-51 follow set (kind 30000, addressed by --name) or
+// adds people to or removes them from one.
+func runList(action string, args []string) {
+	opts := parseListFlags(args)
+	logln := func(a ...any) {
+		if !opts.jsonOutput && !opts.quiet {
+			fmt.Println(a...)
+		}
+	}
+
+	if opts.name == "" {
+		fatal("usage: nihao list %s --name <list> [npub|nip05 ...] (--sec|--stdin|--sec-cmd ...)", action)
+	}
+	if action != "create" && len(opts.args) == 0 {
+		fatal("usage: nihao list %s --name <list> <npub|nip05> [...] (--sec|--stdin|--sec-cmd ...)", action)
+	}
+	if opts.title != "" && action != "create" {
+		fatal("--title only applies to nihao list create")
+	}
+	if !opts.keys.isSet() {
+		fatal("list %s signs the updated list: pass --sec, --stdin, --sec-cmd, or --bunker", action)
+	}
+	kr, _, err := opts.keys.signer(context.Background())
+	if err != nil {
+		fatal("%s", err)
+	}
+	me := signerPubkey(context.Background(), kr)
+	npub := nip19.EncodeNpub(me)
+
+	var people []nostr.PubKey
+	for _, target := range opts.args {
+		pk, err := resolveTarget(target, opts.quiet || opts.jsonOutput)
+		if err != nil {
+			fatal("%s: %s", target, err)
+		}
+		people = append(people, pk)
+	}
+
+	readRelays := opts.relays
+	if len(readRelays) == 0 {
+		readRelays = defaultRelays
+	}
+	pool := NewRelayPool(readRelays, opts.quiet || opts.jsonOutput)
+	defer pool.Close()
+	if len(pool.CheckRelays()) == 0 {
+		fatal("could not connect to any relay")
+	}
+
+	logln(fmt.Sprintf("nihao list 📋 %s %q for %s", action, opts.name, npub))
+	logln()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	if _, relayEvt := fetchKindFrom(ctx, pool.CheckRelays(), me, 10002); relayEvt != nil {
+		pool.Add(mergeRelayURLs(readRelays, writeRelays(relayEvt))...)
+	}
+	_, current := fetchLatestFrom(ctx, pool.CheckRelays(), nostr.Filter{
+		Authors: []nostr.PubKey{me},
+		Kinds:   []nostr.Kind{30000},
+		Tags:    nostr.TagMap{"d": []string{opts.name}},
+		Limit:   1,
+	})
+	cancel()
+
+	switch {
+	case action == "create" && current != nil:
+		fatal("list %q already exists — use nihao list add", opts.name)
+	case action != "create" && current == nil:
+		fatal("no list %q (kind 30000) found for %s — create it with nihao list create", opts.name, npub)
+	}
+
+	var add, remove []nostr.PubKey
+	if action == "remove" {
+		remove = people
+	} else {
+		add = people
+	}
+	evt, added, removed := editFollowSet(current, opts.name, add, remove)
+	if opts.title != "" {
+		evt.Tags = append(evt.Tags, nostr.Tag{"title", opts.title})
+	}
+
+	result := ListResult{Npub: npub, Name: opts.name, Members: []string{}, Added: []string{}, Removed: []string{}}
+	for _, pk := range added {
+		result.Added = append(result.Added, nip19.EncodeNpub(pk))
+		logln(fmt.Sprintf("   + %s", nip19.EncodeNpub(pk)))
+	}
+	for _, pk := range removed {
+		result.Removed = append(result.Removed, nip19.EncodeNpub(pk))
+		logln(fmt.Sprintf("   - %s", nip19.EncodeNpub(pk)))
+	}
+	for _, tag := range evt.Tags {
+		if len(tag) < 2 || tag[0] != "p" {
+			continue
+		}
+		if pk, err := nostr.PubKeyFromHex(tag[1]); err == nil {
+			result.Members = append(result.Members, nip19.EncodeNpub(pk))
+		}
+	}
+
+	if action != "create" && len(added) == 0 && len(removed) == 0 {
+		logln("✅ Nothing to change")
+	} else {
+		evt.CreatedAt = nostr.Now()
+		signWith(kr, &evt)
+		logln(fmt.Sprintf("📋 Publishing list %q (kind 30000, %d people)...", opts.name, len(result.Members)))
+		for _, r := range pool.Publish(evt) {
+			if r.OK {
+				result.Published = true
+			}
+		}
+		if !result.Published {
+			logln("❌ No relay accepted the list")
+		}
+	}
+	logln()
+
+	if opts.jsonOutput {
+		out, _ := json.MarshalIndent(result, "", "  ")
+		fmt.Println(string(out))
+	}
+	if !result.Published && (action == "create" || len(added)+len(removed) > 0) {
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,208 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+	"sync"
+
+	"fiatjaf.com/nostr"
+)
+
+// emojiProbeLimit caps how many emoji image URLs are probed per check.
+const emojiProbeLimit = 100
+
+// emojiShortcode is the NIP-30 shortcode alphabet.
+var emojiShortcode = regexp.MustCompile(`^[a-zA-Z0-9_]+$`)
+
+// emojiRef is one custom emoji from a kind 10030 list or a kind 30030 set
+// it references.
+type emojiRef struct {
+	Shortcode string
+	URL       string
+	Set       string // "" if listed directly in the 10030
+}
+
+// emojiTags reads NIP-30 ["emoji", shortcode, url] tags.
+func emojiTags(evt *nostr.Event, set string) []emojiRef {
+	var emojis []emojiRef
+	for _, tag := range evt.Tags {
+		if len(tag) >= 3 && tag[0] == "emoji" {
+			emojis = append(emojis, emojiRef{Shortcode: tag[1], URL: tag[2], Set: set})
+		}
+	}
+	return emojis
+}
+
+// listAddresses returns the "a" tags of a list that point at addressable
+// events of the given kind, as (author, d) pairs.
+func listAddresses(evt *nostr.Event, kind int) [][2]string {
+	prefix := fmt.Sprintf("%d:", kind)
+	var addrs [][2]string
+	for _, tag := range evt.Tags {
+		if len(tag) < 2 || tag[0] != "a" || !strings.HasPrefix(tag[1], prefix) {
+			continue
+		}
+		parts := strings.SplitN(tag[1], ":", 3)
+		if len(parts) == 3 {
+			addrs = append(addrs, [2]string{parts[1], parts[2]})
+		}
+	}
+	return addrs
+}
+
+// checkExtendedLists audits the NIP-51 lists clients use for custom
+// emojis (kind 10030) and interests (kind 10015). Each is only reported if
+// the identity has published it.
+func checkExtendedLists(ctx context.Context, result *CheckResult, relays []checkRelay, pk nostr.PubKey) {
+	interestsSrc, interestsEvt := fetchKindFrom(ctx, relays, pk, 10015)
+	result.recordEvent(10015, interestsSrc, interestsEvt)
+	if interestsEvt != nil {
+		status, detail := assessInterests(interestsEvt)
+		result.addCheck("interests", status, detail)
+	}
+
+	emojiSrc, emojiEvt := fetchKindFrom(ctx, relays, pk, 10030)
+	result.recordEvent(10030, emojiSrc, emojiEvt)
+	if emojiEvt == nil {
+		return
+	}
+	emojis := emojiTags(emojiEvt, "")
+	var missingSets []string
+	for _, addr := range listAddresses(emojiEvt, 30030) {
+		author, err := nostr.PubKeyFromHex(addr[0])
+		if err != nil {
+			missingSets = append(missingSets, addr[1])
+			continue
+		}
+		_, set := fetchLatestFrom(ctx, relays, nostr.Filter{
+			Authors: []nostr.PubKey{author},
+			Kinds:   []nostr.Kind{30030},
+			Tags:    nostr.TagMap{"d": []string{addr[1]}},
+			Limit:   1,
+		})
+		if set == nil {
+			missingSets = append(missingSets, addr[1])
+			continue
+		}
+		emojis = append(emojis, emojiTags(set, addr[1])...)
+	}
+
+	status, detail := assessEmojis(emojis, probeEmojis(ctx, emojis), missingSets)
+	result.addCheck("emoji_list", status, detail)
+}
+
+// assessInterests summarizes a kind 10015 interests list: hashtags ("t")
+// and referenced interest sets (kind 30015).
+func assessInterests(evt *nostr.Event) (string, string) {
+	var topics []string
+	for _, tag := range evt.Tags {
+		if len(tag) >= 2 && tag[0] == "t" && tag[1] != "" {
+			topics = append(topics, "#"+strings.TrimPrefix(tag[1], "#"))
+		}
+	}
+	sets := len(listAddresses(evt, 30015))
+	if len(topics) == 0 && sets == 0 {
+		return "warn", "kind 10015 found but it lists no hashtags or interest sets"
+	}
+	detail := fmt.Sprintf("%d topic(s)", len(topics))
+	if sets > 0 {
+		detail += fmt.Sprintf(", %d interest set(s)", sets)
+	}
+	if len(topics) > 0 {
+		shown := topics
+		if len(shown) > 8 {
+			shown = append(shown[:8:8], "…")
+		}
+		detail += ": " + strings.Join(shown, " ")
+	}
+	return "pass", detail
+}
+
+// probeEmojis fetches the status of each distinct emoji image URL, up to
+// emojiProbeLimit of them. Returns URL → HTTP status (-1 if unreachable).
+func probeEmojis(ctx context.Context, emojis []emojiRef) map[string]int {
+	var urls []string
+	for _, e := range emojis {
+		if len(urls) < emojiProbeLimit && !slices.Contains(urls, e.URL) {
+			urls = append(urls, e.URL)
+		}
+	}
+
+	statuses := make(map[string]int, len(urls))
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	sem := make(chan struct{}, 8)
+	for _, u := range urls {
+		wg.Add(1)
+		go func(u string) {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+			status := probeImage(ctx, u).Status
+			mu.Lock()
+			statuses[u] = status
+			mu.Unlock()
+		}(u)
+	}
+	wg.Wait()
+	return statuses
+}
+
+// assessEmojis rates a custom emoji list: shortcodes must be valid NIP-30
+// and images must load, or clients show :shortcode: text instead.
+func assessEmojis(emojis []emojiRef, statuses map[string]int, missingSets []string) (string, string) {
+	if len(emojis) == 0 && len(missingSets) == 0 {
+		return "warn", "kind 10030 found but it lists no emojis or emoji sets"
+	}
+
+	var broken, invalid []string
+	checked := 0
+	for _, e := range emojis {
+		if !emojiShortcode.MatchString(e.Shortcode) {
+			invalid = append(invalid, ":"+e.Shortcode+":")
+			continue
+		}
+		status, probed := statuses[e.URL]
+		if !probed {
+			continue
+		}
+		checked++
+		if status < 200 || status >= 400 {
+			broken = append(broken, ":"+e.Shortcode+":")
+		}
+	}
+
+	detail := fmt.Sprintf("%d emoji(s), %d/%d image(s) load", len(emojis), checked-len(broken), checked)
+	var problems []string
+	if len(broken) > 0 {
+		problems = append(problems, "broken images: "+truncateList(broken, 5))
+	}
+	if len(invalid) > 0 {
+		problems = append(problems, "invalid shortcodes: "+truncateList(invalid, 5))
+	}
+	if len(missingSets) > 0 {
+		problems = append(problems, "emoji sets not found (kind 30030): "+truncateList(missingSets, 5))
+	}
+	if len(problems) > 0 {
+		detail += " — " + strings.Join(problems, "; ")
+	}
+
+	switch {
+	case len(emojis) > 0 && len(broken)+len(invalid) == len(emojis):
+		return "fail", detail
+	case len(problems) > 0:
+		return "warn", detail
+	}
+	return "pass", detail
+}
+
+// truncateList joins up to n items, noting how many were left out.
+func truncateList(items []string, n int) string {
+	if len(items) <= n {
+		return strings.Join(items, ", ")
+	}
+	return fmt.Sprintf("%s and %d more", strings.Join(items[:n], ", "), len(items)-n)
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"time"
+
+	"fiatjaf.com/nostr/eventstore/slicestore"
+	"fiatjaf.com/nostr/khatru"
+)
+
+// defaultLocalRelayAddr is where `nihao dev relay` listens by default.
+const defaultLocalRelayAddr = "127.0.0.1:7777"
+
+// localRelay is an in-process, in-memory relay bound to localhost. It lets
+// the whole setup/check/backup flow run without internet access — for
+// offline demos, classrooms, and integration tests.
+type localRelay struct {
+	URL    string
+	server *http.Server
+}
+
+// startLocalRelay starts an in-memory relay on addr (use "127.0.0.1:0" for
+// a random free port) and returns once it is accepting connections.
+func startLocalRelay(addr string) (*localRelay, error) {
+	store := &slicestore.SliceStore{}
+	if err := store.Init(); err != nil {
+		return nil, err
+	}
+
+	rl := khatru.NewRelay()
+	rl.Info.Name = "nihao local relay"
+	rl.Info.Description = "in-memory relay for offline nihao runs"
+	rl.UseEventstore(store, 1000)
+
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+
+	lr := &localRelay{
+		URL:    "ws://" + ln.Addr().String(),
+		server: &http.Server{Handler: rl},
+	}
+	go lr.server.Serve(ln)
+	return lr, nil
+}
+
+// Close stops the relay. Stored events are discarded.
+func (lr *localRelay) Close() {
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	lr.server.Shutdown(ctx)
+}
+
+// runDevRelay runs a local relay in the foreground until interrupted.
+func runDevRelay(addr string) {
+	lr, err := startLocalRelay(addr)
+	if err != nil {
+		fatal("could not start local relay: %s", err)
+	}
+	defer lr.Close()
+
+	fmt.Printf("nihao dev relay 🧪 %s\n\n", lr.URL)
+	fmt.Println("   In-memory relay, events are lost on exit. Try:")
+	fmt.Printf("   nihao --relays %s --no-wallet --dm-relays %s\n", lr.URL, lr.URL)
+	fmt.Printf("   nihao check <npub> --relays %s\n", lr.URL)
+	fmt.Println()
+	fmt.Println("   Press Ctrl-C to stop.")
+
+	sig := make(chan os.Signal, 1)
+	signal.Notify(sig, os.Interrupt)
+	<-sig
+	fmt.Println()
+}
@@ -0,0 +1,210 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"unicode"
+
+	"fiatjaf.com/nostr"
+	"fiatjaf.com/nostr/nip19"
+)
+
+// LightningProvider hands out a lightning address for a new identity,
+// registering an account with the service first if it needs one.
+type LightningProvider interface {
+	Name() string
+	Address(ctx context.Context, kr nostr.Keyer, username string) (*LightningAccount, error)
+}
+
+// LightningAccount is the address a provider issued, plus any login the
+// registration created (the user needs it to withdraw funds).
+type LightningAccount struct {
+	Provider     string `json:"provider"`
+	LUD16        string `json:"lud16"`
+	Username     string `json:"username,omitempty"`
+	Password     string `json:"password,omitempty"`      // left out once it's in PasswordFile, unless --show-nsec
+	PasswordFile string `json:"password_file,omitempty"` // the login, kept next to the --nsec-file
+}
+
+// lightningLoginPath is where a provider login is kept next to the
+// --nsec-file, e.g. alice.nsec.coinos.
+func lightningLoginPath(nsecFile, provider string) string {
+	return nsecFile + "." + provider
+}
+
+// writeLightningLogin writes a provider login to path, readable only by
+// the owner like the nsec file.
+func writeLightningLogin(path string, account *LightningAccount) error {
+	return writeNsecFile(path, fmt.Sprintf("%s\nusername: %s\npassword: %s", account.LUD16, account.Username, account.Password))
+}
+
+// defaultLightningProvider is used when neither --lud16 nor
+// --lud16-provider is given.
+const defaultLightningProvider = "npub.cash"
+
+// lightningProviders are the built-in providers, by --lud16-provider name.
+var lightningProviders = map[string]LightningProvider{
+	"npub.cash": npubCashProvider{},
+	"coinos":    coinosProvider{apiURL: "https://coinos.io/api", domain: "coinos.io"},
+}
+
+// lightningProviderNames lists the --lud16-provider values, for help and
+// error messages.
+func lightningProviderNames() []string {
+	names := []string{"none"}
+	for name := range lightningProviders {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// npubCashProvider serves <npub>@npub.cash for any key, no registration.
+type npubCashProvider struct{}
+
+func (npubCashProvider) Name() string { return "npub.cash" }
+
+func (npubCashProvider) Address(ctx context.Context, kr nostr.Keyer, username string) (*LightningAccount, error) {
+	pk, err := kr.GetPublicKey(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return &LightningAccount{Provider: "npub.cash", LUD16: nip19.EncodeNpub(pk) + "@npub.cash"}, nil
+}
+
+// coinosProvider registers a custodial coinos account and uses its
+// username@coinos.io address. The generated password is returned so the
+// user can log in and withdraw.
+type coinosProvider struct {
+	apiURL string
+	domain string
+}
+
+func (coinosProvider) Name() string { return "coinos" }
+
+func (p coinosProvider) Address(ctx context.Context, kr nostr.Keyer, username string) (*LightningAccount, error) {
+	base := lightningUsername(username)
+	password := randomHex(16)
+
+	// Usernames are first come, first served: retry once with a suffix
+	var lastErr error
+	for _, name := range []string{base, base + randomHex(2)} {
+		body, _ := json.Marshal(map[string]any{"user": map[string]string{"username": name, "password": password}})
+		req, err := http.NewRequestWithContext(ctx, "POST", p.apiURL+"/register", bytes.NewReader(body))
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Set("Content-Type", "application/json")
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			return nil, fmt.Errorf("coinos registration failed: %w", err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode == 200 {
+			return &LightningAccount{
+				Provider: "coinos",
+				LUD16:    name + "@" + p.domain,
+				Username: name,
+				Password: password,
+			}, nil
+		}
+		lastErr = fmt.Errorf("coinos registration failed: HTTP %d", resp.StatusCode)
+	}
+	return nil, lastErr
+}
+
+// lightningUsername reduces a display name to something providers accept
+// as the local part of an address: lowercase letters and digits.
+func lightningUsername(name string) string {
+	var b strings.Builder
+	for _, r := range strings.ToLower(name) {
+		if r < unicode.MaxASCII && (unicode.IsLetter(r) || unicode.IsDigit(r)) {
+			b.WriteRune(r)
+		}
+	}
+	if b.Len() < 3 {
+		return "nihao" + randomHex(3)
+	}
+	return b.String()
+}
+
+func randomHex(n int) string {
+	buf := make([]byte, n)
+	rand.Read(buf)
+	return hex.EncodeToString(buf)
+}
+
+// cmdLightningProvider runs a user's own provisioning command (--lud16-cmd)
+// for self-hosted LNURL services such as LNbits or BTCPay. The command gets
+// the new identity in $NIHAO_NPUB, $NIHAO_PUBKEY, and $NIHAO_NAME and must
+// print the lightning address it set up; anything on stderr is passed
+// through.
+type cmdLightningProvider struct {
+	cmd externalCmd
+}
+
+func (cmdLightningProvider) Name() string { return "lud16-cmd" }
+
+func (p cmdLightningProvider) Address(ctx context.Context, kr nostr.Keyer, username string) (*LightningAccount, error) {
+	pk, err := kr.GetPublicKey(ctx)
+	if err != nil {
+		return nil, err
+	}
+	cmd := p.cmd.commandContext(ctx)
+	cmd.Env = append(os.Environ(), "NIHAO_NPUB="+nip19.EncodeNpub(pk), "NIHAO_PUBKEY="+pk.Hex(), "NIHAO_NAME="+username)
+	cmd.Stderr = os.Stderr
+	out, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("lud16-cmd failed: %w", err)
+	}
+
+	lud16 := ""
+	for _, line := range strings.Split(string(out), "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			lud16 = line
+			break
+		}
+	}
+	if lud16 == "" {
+		return nil, fmt.Errorf("lud16-cmd printed no lightning address")
+	}
+	if _, err := lnurlpURL(lud16); err != nil {
+		return nil, fmt.Errorf("lud16-cmd printed %w", err)
+	}
+	return &LightningAccount{Provider: "lud16-cmd", LUD16: lud16}, nil
+}
+
+// providerLightningAddress gets an address from the named provider and
+// checks that it resolves before it goes into the profile.
+func providerLightningAddress(ctx context.Context, providerName string, kr nostr.Keyer, username string) (*LightningAccount, error) {
+	provider, ok := lightningProviders[providerName]
+	if !ok {
+		return nil, fmt.Errorf("unknown lightning address provider %q (choose from %s)", providerName, strings.Join(lightningProviderNames(), ", "))
+	}
+	return resolvedLightningAddress(ctx, provider, kr, username)
+}
+
+// resolvedLightningAddress gets an address from provider and checks that it
+// resolves. A registered account is returned even if it doesn't.
+func resolvedLightningAddress(ctx context.Context, provider LightningProvider, kr nostr.Keyer, username string) (*LightningAccount, error) {
+	account, err := provider.Address(ctx, kr, username)
+	if err != nil {
+		return nil, err
+	}
+	url, err := lnurlpURL(account.LUD16)
+	if err != nil {
+		return nil, err
+	}
+	if err := probeLNURLPay(ctx, url); err != nil {
+		return account, fmt.Errorf("%s doesn't resolve: %w", account.LUD16, err)
+	}
+	return account, nil
+}
@@ -7,13 +7,14 @@ import (
 	"encoding/json"
 	"fmt"
 	"os"
-	"os/exec"
 	"runtime/debug"
+	"slices"
 	"strings"
 	"sync"
 	"time"
 
 	"fiatjaf.com/nostr"
+	"fiatjaf.com/nostr/keyer"
 	"fiatjaf.com/nostr/nip19"
 )
 
@@ -29,9 +30,12 @@ var version = func() string {
 
 // Default relays for new identities — curated for reliability and coverage.
 // General-purpose relays (read + write):
-//   damus, primal, nos.lol — large, long-running, well-connected
+//
+//	damus, primal, nos.lol — large, long-running, well-connected
+//
 // Specialized relays (important for discoverability):
-//   purplepag.es — NIP-65 relay list aggregator, critical for outbox model
+//
+//	purplepag.es — NIP-65 relay list aggregator, critical for outbox model
 //
 // Future: discover relays dynamically via NIP-66 relay monitors or by
 // sampling kind 10002 lists from well-connected npubs.
@@ -52,53 +56,392 @@ var defaultDMRelays = []string{
 	"wss://nos.lol",
 }
 
+// globalFlags are network and output options every command accepts.
+type globalFlags struct {
+	proxy    string   // --proxy
+	caFile   string   // --ca-file
+	insecure []string // --insecure hosts
+	output   string   // --output-version
+}
+
+// extractGlobalFlags removes the global flags from args, wherever they
+// appear, before the command parses the rest.
+func extractGlobalFlags(args []string) ([]string, globalFlags) {
+	var g globalFlags
+	var rest []string
+	for i := 0; i < len(args); i++ {
+		switch {
+		case args[i] == "--proxy" && i+1 < len(args):
+			i++
+			g.proxy = args[i]
+		case args[i] == "--ca-file" && i+1 < len(args):
+			i++
+			g.caFile = args[i]
+		case args[i] == "--insecure" && i+1 < len(args):
+			i++
+			g.insecure = append(g.insecure, strings.Split(args[i], ",")...)
+		case args[i] == "--output-version" && i+1 < len(args):
+			i++
+			g.output = args[i]
+		default:
+			rest = append(rest, args[i])
+		}
+	}
+	return rest, g
+}
+
 func main() {
-	args := os.Args[1:]
+	args, global := extractGlobalFlags(os.Args[1:])
+	if v := os.Getenv("NIHAO_RELAY_CACHE_TTL"); v != "" {
+		relayCacheTTL = parseCacheTTL("NIHAO_RELAY_CACHE_TTL", v)
+	}
+	if v := os.Getenv("NIHAO_CACHE_TTL"); v != "" {
+		checkCacheTTL = parseCacheTTL("NIHAO_CACHE_TTL", v)
+	}
+	if v := os.Getenv("NIHAO_RATE_LIMIT"); v != "" {
+		setRateLimit("NIHAO_RATE_LIMIT", v)
+	}
+	if global.proxy != "" {
+		setProxy("--proxy", global.proxy)
+	}
+	if global.caFile == "" {
+		global.caFile = os.Getenv("NIHAO_CA_FILE")
+	}
+	if global.output != "" {
+		outputVersion = parseOutputVersion("--output-version", global.output)
+	}
+	installProxy()
+	configureTLS(global.caFile, global.insecure)
+	installProbeTiming()
+	installRateLimiter()
+	installNIP98Auth()
 
 	if len(args) > 0 {
 		switch args[0] {
 		case "check":
 			target := ""
-			jsonOutput := false
+			opts := checkOpts{pluginDir: defaultPluginDir()}
+			var keys keySource
+			org := ""
+			compare := false
+			relation := false
+			follows := false
+			leaderboard := false
+			domain := ""
+			var targets []string
+			for i := 1; i < len(args); i++ {
+				if next, ok := keys.keyFlag(args, i); ok {
+					i = next
+					continue
+				}
+				a := args[i]
+				switch {
+				case a == "--json":
+					opts.jsonOutput = true
+				case a == "--org" && i+1 < len(args):
+					i++
+					org = args[i]
+				case a == "--compare":
+					compare = true
+				case a == "--relation":
+					relation = true
+				case a == "--follows":
+					follows = true
+				case a == "--leaderboard":
+					leaderboard = true
+				case a == "--summary":
+					opts.summary = true
+				case a == "--include-events":
+					opts.includeEvents = true
+				case a == "--extra-kinds" && i+1 < len(args):
+					i++
+					kinds, err := parseKinds(args[i])
+					if err != nil {
+						fatal("--extra-kinds: %s", err)
+					}
+					opts.extraKinds = kinds
+				case a == "--plugins" && i+1 < len(args):
+					i++
+					opts.pluginDir = args[i]
+				case a == "--no-plugins":
+					opts.pluginDir = ""
+				case a == "--policy" && i+1 < len(args):
+					i++
+					p, err := loadPolicy(args[i])
+					if err != nil {
+						fatal("invalid policy %s: %s", args[i], err)
+					}
+					opts.policy = p
+				case a == "--baseline" && i+1 < len(args):
+					i++
+					opts.baselinePath = args[i]
+				case a == "--update-baseline":
+					opts.updateBaseline = true
+				case a == "--explain":
+					opts.explain = true
+				case a == "--expire" && i+1 < len(args):
+					i++
+					testEventTTL = parseExpiry("--expire", args[i])
+				case a == "--propagation":
+					if opts.propagation == nil {
+						opts.propagation = popularRelays
+					}
+				case a == "--propagation-relays" && i+1 < len(args):
+					i++
+					opts.propagation = strings.Split(args[i], ",")
+				case a == "--relay-cache-ttl" && i+1 < len(args):
+					i++
+					relayCacheTTL = parseCacheTTL("--relay-cache-ttl", args[i])
+				case a == "--cache-ttl" && i+1 < len(args):
+					i++
+					checkCacheTTL = parseCacheTTL("--cache-ttl", args[i])
+				case a == "--no-cache":
+					checkCacheBypass = true
+					relayCacheTTL = 0
+				case a == "--rate-limit" && i+1 < len(args):
+					i++
+					setRateLimit("--rate-limit", args[i])
+				case a == "--domain" && i+1 < len(args):
+					i++
+					domain = args[i]
+				case a == "--quiet" || a == "-q":
+					opts.quiet = true
+				case a == "--relays" && i+1 < len(args):
+					i++
+					opts.relays = strings.Split(args[i], ",")
+				case strings.HasPrefix(a, "-"):
+					fatal("unknown flag: %s (see nihao help)", a)
+				default:
+					target = a
+					targets = append(targets, a)
+				}
+			}
+			// Only a single-identity check signs anything, so a key given to
+			// the other modes would be silently ignored
+			if keys.isSet() && (compare || relation || domain != "" || org != "") {
+				fatal("--sec, --stdin, --sec-cmd, --keychain, and --bunker only apply to checking one identity, not --compare, --relation, --domain, or --org")
+			}
+			if compare {
+				if len(targets) != 2 {
+					fatal("usage: nihao check --compare <npubA> <npubB>")
+				}
+				runCompare(targets[0], targets[1], opts.jsonOutput, opts.quiet, opts.relays)
+				return
+			}
+			if relation {
+				if len(targets) != 2 {
+					fatal("usage: nihao check --relation <npubA> <npubB>")
+				}
+				runRelation(targets[0], targets[1], opts.jsonOutput, opts.quiet, opts.relays)
+				return
+			}
+			if domain != "" {
+				runDomainCheck(domain, opts.jsonOutput, opts.quiet, opts.relays)
+				return
+			}
+			if org != "" {
+				runOrgCheck(org, opts.jsonOutput, opts.quiet, leaderboard, opts.relays)
+				return
+			}
+			// With a key, check defaults to its own npub and can answer
+			// NIP-42 AUTH challenges from relays that gate reads, and sign
+			// NIP-98 retries for HTTP endpoints that answer 401
+			if keys.isSet() {
+				kr, _, err := keys.signer(context.Background())
+				if err != nil {
+					fatal("%s", err)
+				}
+				opts.signer = kr
+				if target == "" {
+					target = signerPubkey(context.Background(), kr).Hex()
+				}
+			}
+			if follows {
+				if target == "" {
+					fatal("usage: nihao check --follows <npub>")
+				}
+				runFollowsCheck(target, opts.jsonOutput, opts.quiet, leaderboard, opts.relays)
+				return
+			}
+			if opts.updateBaseline && opts.baselinePath == "" {
+				fatal("--update-baseline needs --baseline <file>")
+			}
+			// --summary replaces the report, so skip progress output too
+			opts.quiet = opts.quiet || opts.summary
+			runCheck(target, opts)
+			return
+		case "backup":
+			target := ""
 			quiet := false
+			var extraKinds []int
+			var keys keySource
 			var relays []string
 			for i := 1; i < len(args); i++ {
+				if next, ok := keys.keyFlag(args, i); ok {
+					i = next
+					continue
+				}
 				a := args[i]
 				switch {
-				case a == "--json":
-					jsonOutput = true
 				case a == "--quiet" || a == "-q":
 					quiet = true
 				case a == "--relays" && i+1 < len(args):
 					i++
 					relays = strings.Split(args[i], ",")
+				case a == "--extra-kinds" && i+1 < len(args):
+					i++
+					kinds, err := parseKinds(args[i])
+					if err != nil {
+						fatal("--extra-kinds: %s", err)
+					}
+					extraKinds = kinds
 				case strings.HasPrefix(a, "-"):
 					fatal("unknown flag: %s (see nihao help)", a)
 				default:
 					target = a
 				}
 			}
-			runCheck(target, jsonOutput, quiet, relays)
+			if target == "" && keys.isSet() {
+				target = targetFromKey(keys)
+			}
+			runBackup(target, quiet, relays, extraKinds)
 			return
-		case "backup":
+		case "propagate":
 			target := ""
+			jsonOutput := false
 			quiet := false
-			var relays []string
+			var keys keySource
+			var relays, to []string
 			for i := 1; i < len(args); i++ {
+				if next, ok := keys.keyFlag(args, i); ok {
+					i = next
+					continue
+				}
 				a := args[i]
 				switch {
+				case a == "--json":
+					jsonOutput = true
 				case a == "--quiet" || a == "-q":
 					quiet = true
 				case a == "--relays" && i+1 < len(args):
 					i++
 					relays = strings.Split(args[i], ",")
+				case a == "--to" && i+1 < len(args):
+					i++
+					to = strings.Split(args[i], ",")
 				case strings.HasPrefix(a, "-"):
 					fatal("unknown flag: %s (see nihao help)", a)
 				default:
 					target = a
 				}
 			}
-			runBackup(target, quiet, relays)
+			if target == "" && keys.isSet() {
+				target = targetFromKey(keys)
+			}
+			runPropagate(target, jsonOutput, quiet, relays, to)
+			return
+		case "setup":
+			runSetup(args[1:])
+			return
+		case "dev":
+			if len(args) < 2 || args[1] != "relay" {
+				fatal("usage: nihao dev relay [--addr host:port]")
+			}
+			addr := defaultLocalRelayAddr
+			for i := 2; i < len(args); i++ {
+				a := args[i]
+				switch {
+				case a == "--addr" && i+1 < len(args):
+					i++
+					addr = args[i]
+				default:
+					fatal("unknown flag: %s (see nihao help)", a)
+				}
+			}
+			runDevRelay(addr)
+			return
+		case "media":
+			if len(args) < 2 {
+				fatal("usage: nihao media <mirror|list|delete> [--server <url>] (--sec|--stdin|--sec-cmd ...)")
+			}
+			switch args[1] {
+			case "mirror":
+				runMediaMirror(args[2:])
+			case "list":
+				runMediaList(args[2:])
+			case "delete":
+				runMediaDelete(args[2:])
+			default:
+				fatal("usage: nihao media <mirror|list|delete> [--server <url>] (--sec|--stdin|--sec-cmd ...)")
+			}
+			return
+		case "dm-relays":
+			if len(args) < 2 || args[1] != "discover" {
+				fatal("usage: nihao dm-relays discover [--count n] [--publish (--sec|--stdin|--sec-cmd ...)]")
+			}
+			runDMRelaysDiscover(args[2:])
+			return
+		case "update":
+			runUpdate(args[1:])
+			return
+		case "whoami":
+			runWhoami(args[1:])
+			return
+		case "relays":
+			if len(args) < 2 || args[1] != "mark" {
+				fatal("usage: nihao relays mark <relay-url> read|write|both (--sec|--stdin|--sec-cmd ...)")
+			}
+			runRelaysMark(args[2:])
+			return
+		case "wallet":
+			if len(args) < 2 {
+				fatal("usage: nihao wallet receive <cashu-token> | send <amount> | export (--sec|--stdin|--sec-cmd ...)")
+			}
+			switch args[1] {
+			case "receive":
+				runWalletReceive(args[2:])
+			case "send":
+				runWalletSend(args[2:])
+			case "export":
+				runWalletExport(args[2:])
+			default:
+				fatal("usage: nihao wallet receive <cashu-token> | send <amount> | export (--sec|--stdin|--sec-cmd ...)")
+			}
+			return
+		case "list":
+			if len(args) < 2 {
+				fatal("usage: nihao list create|add|remove --name <list> [npub|nip05 ...] (--sec|--stdin|--sec-cmd ...)")
+			}
+			switch args[1] {
+			case "create", "add", "remove":
+				runList(args[1], args[2:])
+			default:
+				fatal("usage: nihao list create|add|remove --name <list> [npub|nip05 ...] (--sec|--stdin|--sec-cmd ...)")
+			}
+			return
+		case "migrate":
+			runMigrate(args[1:])
+			return
+		case "verify":
+			runVerify(args[1:])
+			return
+		case "nuke":
+			runNuke(args[1:])
+			return
+		case "stats":
+			runStats(args[1:])
+			return
+		case "decode":
+			runDecode(args[1:])
+			return
+		case "encode":
+			runEncode(args[1:])
+			return
+		case "mcp":
+			runMCP(args[1:])
+			return
+		case "rpc":
+			runRPC(args[1:])
 			return
 		case "version", "--version":
 			fmt.Printf("nihao %s\n", version)
@@ -118,36 +461,292 @@ func printUsage() {
 
 USAGE:
   nihao                     Set up a new Nostr identity with sane defaults
+  nihao setup               Same as above (e.g. nihao setup --batch accounts.csv)
   nihao check <npub|nip05>  Check the health of a Nostr identity
   nihao backup <npub|nip05> Export identity events as JSON
+  nihao propagate <npub>    Rebroadcast profile and relay lists to ~20 popular relays
+  nihao whoami              Show your own profile, relay lists, and wallet (key from
+                            --sec-cmd etc. or $NIHAO_SEC_CMD)
+  nihao media mirror        Re-host profile picture/banner on your Blossom servers
+  nihao media list          List your blobs on your Blossom servers
+  nihao media delete <sha256|url>
+                            Delete a blob from your Blossom servers
+  nihao dm-relays discover  Recommend (and optionally publish) NIP-17 DM relays
+  nihao relays mark <url> read|write|both
+                            Change one relay's NIP-65 marker in your kind 10002
+  nihao wallet receive <token>
+                            Redeem a cashu token into your NIP-60 wallet
+  nihao wallet send <amount>
+                            Take sats out of your NIP-60 wallet as a cashu token
+  nihao wallet export [--output <file>]
+                            Save your wallet's proofs and key to a passphrase-
+                            encrypted file (--decrypt <file> reads it back)
+  nihao list create|add|remove --name <list> [npub ...]
+                            Manage a NIP-51 follow set (kind 30000)
+  nihao migrate --from-sec <old> --to-sec <new>
+                            Copy an identity (profile, follows, relay lists,
+                            lists, wallet) to a new key
+  nihao nuke --i-understand (--sec|--stdin|--sec-cmd ...)
+                            Tear an identity down: blank the profile and lists,
+                            delete every event (kind 5), ask each relay to
+                            vanish (NIP-62), and check what's left (--dry-run)
+  nihao verify [<event.json>]
+                            Check an event's ID and signature (file or stdin; an
+                            event, an array, or a backup) and show it; --relays
+                            <r1,r2,...> also checks which relays store it
+  nihao stats <npub|nip05>  Count your events per kind on each of your relays
+                            (NIP-45 COUNT, else paging) to spot relays dropping them
+  nihao decode <code> ...   Decode npub/nsec/note/nprofile/nevent/naddr to hex,
+                            with relay hints, kind, and identifier
+  nihao encode <type> <hex|code>
+                            Encode hex (or another code) as npub, nsec, note,
+                            nprofile, nevent, or naddr (--relay, --author,
+                            --kind, --identifier)
+  nihao dev relay           Run an in-memory local relay (offline demos/tests)
+  nihao mcp                 Serve check, setup, backup, and relay scoring as
+                            MCP tools over stdio (for AI assistants)
+  nihao rpc                 Answer JSON-RPC calls (check, scoreRelays,
+                            validateMint) over stdio with warm connections
   nihao version             Print version
+  nihao update              Install the latest release over this binary, after
+                            verifying its checksum signed by the maintainer
 
 SETUP FLAGS:
   --name <name>             Display name
   --about <text>            About/bio text
   --picture <url>           Profile picture URL
   --banner <url>            Banner image URL
+  --import <file>           Prefill name, bio, avatar, banner, and website from a
+                            Twitter/X archive (.zip) or a JSON mapping; flags win
+  --emoji-set <naddr|file>  Publish a custom emoji list (kind 10030): emoji sets by
+                            naddr, or a file of "shortcode image" lines whose local
+                            images are uploaded to Blossom first (repeat)
+  --blossom-server <url>    Upload imported and emoji images here (repeat; default:
+                            blossom.primal.net, nostr.download)
   --nip05 <user@domain>     NIP-05 identifier
+  --nip05-provider <name>   Register a NIP-05 for the new key with a provider
+                            (nostrcheck, or a self-hosted https:// registration
+                            URL) and use it once it resolves
+  --nip05-name <name>       Name to register (default: from --name)
   --lud16 <user@domain>     Lightning address
+  --lud16-provider <name>   Where the default lightning address comes from:
+                            npub.cash (default), coinos (registers an account),
+                            or none
+  --lud16-cmd <command>     Provision the lightning address with your own command
+                            (gets $NIHAO_NPUB/$NIHAO_PUBKEY/$NIHAO_NAME, prints
+                            the address; e.g. for LNbits or BTCPay)
+  --lud16-cmd-arg <arg>     Same, argv form without a shell (repeat)
+  --hello-file <file>       Draw the first note from these templates, one per line
+                            ({name}, {npub}, {npub_short}, {nip05}, {lud16},
+                            {date}; lines starting with "# " are comments)
+  --hello-at <time|delay>   Hold the signed first note and post it later: an RFC
+                            3339 time, a Unix timestamp, or a delay (90m, 6h, 2d).
+                            Setup waits for it (--resume picks up an interrupted
+                            wait); --batch rows are staggered a minute apart
+  --hello-expire <dur>      Give the first note a NIP-40 expiration (e.g. 30d,
+                            12h) so compliant relays delete it
+  --resume                  Finish an interrupted setup: same key, same relays,
+                            published steps only retried on relays that refused
+                            them, and the first note republished as the same
+                            event (never posted twice).
+                            Progress is saved when the key can be read back
+                            (--nsec-file, --nsec-cmd, or a key source)
+  --relays-from <npub|nip05>
+                            Start from another identity's relay list: each relay
+                            is scored, dead, paid, and write-refusing ones are
+                            dropped, and read/write markers are kept
+  --template <npub|nip05>   Start from another identity's setup: copy its relay
+                            lists, interests, and public mute list (never its
+                            profile); --relays/--dm-relays/--discover still win
+  --nwc <uri>               Store a Nostr Wallet Connect URI (validated, NIP-44
+                            encrypted) as kind 30078 app data and locally
+  --mints-from <npub|nip05> Seed the wallet's mints from another identity's nutzap
+                            info (kind 10019); each is validated, --mint adds more
   --relays <r1,r2,...>      Comma-separated relay URLs
   --discover                Discover relays from well-connected npubs
+  --near <geohash>          With discovery, prefer relays near you (NIP-66 geo data)
+  --seed-from-follows       Discover from the relay lists of the npubs you follow
+                            (your existing kind 3, plus --org follows)
+  --relay-cache-ttl <dur>   Reuse relay scores younger than this (default 1h, 0 = off)
+  --rate-limit <budget>     Requests per second per HTTP host and per relay
+                            (default http=5,relay=10, 0 = off; NIHAO_RATE_LIMIT)
   --dm-relays <r1,r2,...>   Comma-separated DM relay URLs (kind 10050)
   --no-dm-relays            Skip DM relay list publishing
   --json                    Output result as JSON
   --quiet, -q               Suppress non-JSON, non-error output
-  --sec, --nsec <nsec|hex>  Use existing secret key instead of generating
+  --sec, --nsec <key>       Use existing secret key (nsec, hex, or ncryptsec)
   --stdin                   Read secret key from stdin (for piping)
-  --nsec-file <path>        Write nsec to file (0600 perms) for secure storage
+  --sec-cmd <command>       Read secret key from a shell command's stdout
+  --sec-cmd-arg <arg>       Same, but run argv directly without a shell (repeat)
+  --keychain <account>      Read secret key from the OS keychain (service "nostr")
+  --passphrase-fd <n>       Read ncryptsec passphrase from file descriptor n
+                            (else $NIHAO_PASSPHRASE, else prompt on the TTY)
+  --bunker <bunker://...>   Set up the identity of a NIP-46 remote signer, which
+                            signs every event; the key never reaches nihao
+  --nsec-file <path>        Write nsec to file (0600 perms), keeping it off stdout
+  --ncryptsec               Store/show the key NIP-49 encrypted (asks for passphrase)
+  --show-nsec               Print the key (and a provider login kept next to
+                            --nsec-file) even if it was stored elsewhere
+  --batch <file.csv>        Create one identity per CSV row, print a JSON manifest
+                            (columns: name,about,picture,banner,nip05,lud16,
+                            relays,dm_relays,hello_at; --nsec-file may use
+                            {name}/{npub})
   --nsec-cmd <command>      Pipe nsec to shell command (alias: --nsec-exec)
+  --nsec-cmd-arg <arg>      Pipe nsec to argv run without a shell (repeat)
+  --org <org.toml>          Apply an org policy (relays, follows, NIP-05 domain, mints)
 
 CHECK FLAGS:
   --json                    Output result as JSON
   --quiet, -q               Suppress non-JSON, non-error output
+  --summary                 Print one line: npub score=7/8 fail=... warn=...
+  --extra-kinds <k1,k2,...> Also report these kinds: count and latest timestamp
+  --policy <policy.yaml>    Evaluate pass/fail rules; exit 2 if any is violated
+  --baseline <file>         Compare with a stored check --json result; exit 1
+                            only if a check got worse since then
+  --update-baseline         Write this result to the --baseline file
+  --explain                 After the report, list a fix for every failed or
+                            warned check (command, event kind, effort)
+  --plugins <dir>           Run check plugins from here (default: <config>/nihao/plugins)
+  --no-plugins              Don't run check plugins
+  --include-events          With --json, embed the kind 0/3/10002/10050/10019
+                            events checked and the relay each came from
   --relays <r1,r2,...>      Query these relays instead of defaults
+  --sec, --nsec <key>       Check your own identity and answer NIP-42 AUTH
+                            challenges so auth-gated relays return your events
+                            (HTTP endpoints that answer 401 get a NIP-98 retry),
+                            and send yourself a NIP-17 DM via your DM relays
+  --expire <dur>            NIP-40 expiration on test events such as that DM
+                            (default 10m; e.g. 1h, 1d)
+  --stdin                   Same, key read from stdin
+  --sec-cmd <command>       Same, key read from a shell command's stdout
+  --sec-cmd-arg <arg>       Same, argv form without a shell (repeat)
+  --bunker <bunker://...>   Same, signing through a NIP-46 remote signer
+  --org <org.toml>          Check every org member against the org policy
+  --compare <a> <b>         Check two identities and show them side by side
+  --relation <a> <b>        Can two identities see each other? Follows, shared
+                            relays, read/write overlap, and DM readiness
+  --domain <domain>         Verify every name in a domain's nostr.json
+  --follows                 Check everyone the target follows and list broken
+                            NIP-05s, dead relays, and lightning addresses
+  --leaderboard             With --follows or --org, print an aggregate report
+                            instead: score distribution, most common failures,
+                            and a ranked table
+  --propagation             Also report profile coverage on ~20 popular relays
+  --propagation-relays <r1,r2,...>
+                            Same, against these relays instead
+  --relay-cache-ttl <dur>   Reuse relay scores younger than this (default 1h, 0 = off)
+  --cache-ttl <dur>         Reuse a complete result younger than this instead of
+                            querying relays (default 0 = off; NIHAO_CACHE_TTL)
+  --no-cache                Ignore cached results and relay scores for this run
+  --rate-limit <budget>     Requests per second per HTTP host and per relay
+                            (default http=5,relay=10, 0 = off; NIHAO_RATE_LIMIT)
 
 BACKUP FLAGS:
   --quiet, -q               Suppress progress output (JSON always goes to stdout)
   --relays <r1,r2,...>      Query these relays instead of defaults
+  --extra-kinds <k1,k2,...> Also back up every event of these kinds
+  --sec-cmd <command>       Back up your own identity (key read from command)
+  --sec-cmd-arg <arg>       Same, argv form without a shell (repeat)
+  --bunker <bunker://...>   Same, pubkey from a NIP-46 remote signer
+
+PROPAGATE FLAGS:
+  --to <r1,r2,...>          Broadcast to these relays instead of the popular set
+  --relays <r1,r2,...>      Read the latest events from these relays
+  --sec-cmd <command>       Propagate your own identity (key read from command)
+  --json                    Output per-relay results as JSON
+  --quiet, -q               Suppress non-JSON, non-error output
+
+MEDIA MIRROR/LIST/DELETE FLAGS:
+  --sec, --stdin, --sec-cmd Your secret key (signs the kind 24242 Blossom
+                            authorizations, and kind 0 for mirror)
+  --server <url>            Blossom server to use (repeat; default: kind 10063)
+  --relays <r1,r2,...>      Query/publish on these relays instead of defaults
+  --json                    Output result as JSON
+  --quiet, -q               Suppress non-JSON, non-error output
+
+DM-RELAYS DISCOVER FLAGS:
+  --count <n>               How many relays to recommend (default 3)
+  --publish                 Publish them as your kind 10050 (needs your key)
+  --sec, --stdin, --sec-cmd Your secret key
+  --relays <r1,r2,...>      Sample/publish on these relays instead of defaults
+  --json                    Output candidates and selection as JSON
+  --quiet, -q               Suppress non-JSON, non-error output
+
+UPDATE FLAGS:
+  --check-only              Only report whether a newer release exists
+  --force                   Replace a development build, or reinstall the latest
+  --json                    Output as JSON
+  --quiet, -q               Suppress non-JSON, non-error output
+
+WHOAMI FLAGS:
+  --sec, --stdin, --sec-cmd Your secret key (default: run $NIHAO_SEC_CMD, e.g.
+                            NIHAO_SEC_CMD='pass show nostr/nsec')
+  --relays <r1,r2,...>      Query these relays instead of defaults
+  --json                    Output as JSON
+  --quiet, -q               Suppress non-JSON, non-error output
+
+RELAYS MARK FLAGS:
+  --sec, --stdin, --sec-cmd Your secret key (needed to sign the relay list)
+  --relays <r1,r2,...>      Load/publish on these relays instead of defaults
+  --json                    Output the old and new marker as JSON
+  --quiet, -q               Suppress non-JSON, non-error output
+
+WALLET RECEIVE/SEND FLAGS:
+  --sec, --stdin, --sec-cmd Your secret key (needed to decrypt and update the wallet)
+  --relays <r1,r2,...>      Load/publish on these relays instead of defaults
+  --mint <url>              send: spend only proofs from this mint
+  --json                    Output amount, token, and new balance as JSON
+  --quiet, -q               Suppress non-JSON, non-error output (send still
+                            prints the token)
+
+LIST CREATE/ADD/REMOVE FLAGS:
+  --name <list>             Which list (its d tag, e.g. friends); required
+  --title <text>            create: a human-readable title for clients to show
+  --sec, --stdin, --sec-cmd Your secret key (needed to sign the list)
+  --relays <r1,r2,...>      Load/publish on these relays instead of defaults
+  --json                    Output members and changes as JSON
+  --quiet, -q               Suppress non-JSON, non-error output
+
+MIGRATE FLAGS:
+  --from-sec <key>          The old key (nsec, hex, or ncryptsec)
+  --from-sec-cmd <command>  Same, read from a shell command (--from-sec-cmd-arg
+                            for argv form)
+  --to-sec <key>            The new key
+  --to-sec-cmd <command>    Same, read from a shell command (--to-sec-cmd-arg)
+  --announce                Post a notice from the old key pointing to the new
+                            one (only if everything copied)
+  --dry-run                 List what would be copied without publishing
+  --force                   Overwrite events of a new key that has a profile
+  --relays <r1,r2,...>      Read/publish on these relays instead of defaults
+  --json                    Output per-event results as JSON
+  --quiet, -q               Suppress non-JSON, non-error output
+
+KEY SOURCES:
+  Every command that takes --sec, --stdin, or --sec-cmd also takes:
+  --keychain <account>      The key stored in the OS keychain under service "nostr"
+                            (macOS security, or secret-tool on Linux)
+  --bunker <bunker://...>   A NIP-46 remote signer (or its NIP-05) that signs for
+                            nihao; you may be asked to approve nihao in the signer.
+                            Setup can't store or show a key it never sees, so
+                            --bunker excludes --nsec-file, --nsec-cmd, and --ncryptsec.
+
+DEV RELAY FLAGS:
+  --addr <host:port>        Listen address (default 127.0.0.1:7777)
+
+RPC FLAGS:
+  --cache-ttl <dur>         Reuse check results younger than this between calls
+
+GLOBAL FLAGS (any command):
+  --proxy <url>             Send all traffic (HTTP, relay WebSockets, TLS probes)
+                            through http://, https://, socks5://, or socks5h://
+                            (default: HTTPS_PROXY/HTTP_PROXY/ALL_PROXY, NO_PROXY)
+  --ca-file <pem>           Also trust the CA certificates in this PEM bundle, for
+                            relays, mints, and NIP-05 hosts behind a private CA
+                            (NIHAO_CA_FILE)
+  --insecure <h1,h2,...>    Don't verify TLS certificates for these hosts only
+                            (lab setups; every other host is still verified)
+  --output-version <n>      Write JSON in schema version n, for scripts not yet
+                            updated to the current layout (see schema_version)
 
 EXIT CODES:
   0                         Success (check: all checks pass)
@@ -156,7 +755,132 @@ EXIT CODES:
 
 func runSetup(args []string) {
 	opts := parseSetupFlags(args)
+	if opts.relayCacheTTL != "" {
+		relayCacheTTL = parseCacheTTL("--relay-cache-ttl", opts.relayCacheTTL)
+	}
+	if opts.rateLimit != "" {
+		setRateLimit("--rate-limit", opts.rateLimit)
+	}
+	if opts.org != "" {
+		cfg, err := loadOrgConfig(opts.org)
+		if err != nil {
+			fatal("invalid org config %s: %s", opts.org, err)
+		}
+		opts = cfg.apply(opts)
+	}
+	if _, ok := lightningProviders[opts.lud16Provider]; !ok && opts.lud16Provider != "" && opts.lud16Provider != "none" {
+		fatal("unknown --lud16-provider %q (choose from %s)", opts.lud16Provider, strings.Join(lightningProviderNames(), ", "))
+	}
+	if opts.bunker != "" {
+		if opts.sec != "" || opts.stdin || opts.secCmd != "" || opts.keychain != "" {
+			fatal("--bunker signs remotely; it can't be combined with --sec, --stdin, --sec-cmd, or --keychain")
+		}
+		if opts.nsecFile != "" || opts.nsecCmd != "" || opts.ncryptsec || opts.showNsec {
+			fatal("--bunker keeps the key in the remote signer; there is no nsec to store or show")
+		}
+		if opts.batch != "" || opts.resume {
+			fatal("--bunker sets up the signer's one identity; it can't be combined with --batch or --resume")
+		}
+	}
+	if opts.lud16Command().isSet() && (opts.lud16 != "" || opts.lud16Provider != "") {
+		fatal("--lud16-cmd can't be combined with --lud16 or --lud16-provider")
+	}
+	if opts.nip05Provider != "" {
+		if opts.nip05 != "" {
+			fatal("--nip05 and --nip05-provider are mutually exclusive")
+		}
+		if _, err := lookupNIP05Provider(opts.nip05Provider); err != nil {
+			fatal("%s", err)
+		}
+	}
+	if opts.nip05Name != "" && (opts.nip05Provider == "" || opts.batch != "") {
+		fatal("--nip05-name needs --nip05-provider and a single identity (not --batch)")
+	}
+	if opts.importFile != "" {
+		if opts.batch != "" {
+			fatal("--import prefills a single identity; it can't be combined with --batch")
+		}
+		imported, err := loadProfileImport(opts.importFile)
+		if err != nil {
+			fatal("--import %s: %s", opts.importFile, err)
+		}
+		opts = imported.applyTo(opts)
+	}
+	if opts.helloFile != "" {
+		greetings, err := loadGreetings(opts.helloFile)
+		if err != nil {
+			fatal("--hello-file: %s", err)
+		}
+		opts.greetings = greetings
+	}
+	if len(opts.emojiSets) > 0 {
+		list, err := loadEmojiList(opts.emojiSets)
+		if err != nil {
+			fatal("--emoji-set: %s", err)
+		}
+		opts.emojiList = list
+	}
+	if opts.templateFrom != "" {
+		tpl, err := loadSetupTemplate(opts.templateFrom, opts.relays, opts.quiet || opts.jsonOutput)
+		if err != nil {
+			fatal("--template %s: %s", opts.templateFrom, err)
+		}
+		opts.template = tpl
+	}
+	if opts.relaysFrom != "" {
+		if opts.relays != nil {
+			fatal("--relays and --relays-from are mutually exclusive")
+		}
+		from, err := loadRelaysFrom(opts.relaysFrom, nil, opts.quiet || opts.jsonOutput)
+		if err != nil {
+			fatal("--relays-from %s: %s", opts.relaysFrom, err)
+		}
+		opts.relayList = from
+	}
+	if opts.mintsFrom != "" {
+		if opts.noWallet {
+			fatal("--mints-from seeds the wallet; it can't be combined with --no-wallet")
+		}
+		from, err := loadMintsFrom(opts.mintsFrom, opts.relays, opts.quiet || opts.jsonOutput)
+		if err != nil {
+			fatal("--mints-from %s: %s", opts.mintsFrom, err)
+		}
+		opts.mintList = from
+		mints := slices.Clone(from.Mints)
+		for _, m := range opts.mints {
+			if !slices.Contains(mints, strings.TrimRight(m, "/")) {
+				mints = append(mints, m)
+			}
+		}
+		opts.mints = mints
+	}
+	if opts.batch != "" {
+		if opts.resume {
+			fatal("--resume continues a single setup; re-run --batch for the rest")
+		}
+		runBatchSetup(opts)
+		return
+	}
+
+	result := setupIdentity(opts)
+
+	if opts.jsonOutput {
+		out, err := marshalVersioned(result)
+		if err != nil {
+			fatal("%s", err)
+		}
+		fmt.Println(string(out))
+	} else if !opts.quiet {
+		printSetupSummary(opts, result)
+	}
+	if result.held != nil {
+		publishHeld([]*heldNote{result.held}, opts.quiet)
+	}
+}
 
+// setupIdentity generates (or loads) a key, stores it as requested, and
+// publishes the full identity. Progress goes to stdout unless opts.quiet.
+func setupIdentity(opts setupOpts) SetupResult {
 	log := func(format string, a ...any) {
 		if !opts.quiet {
 			fmt.Printf(format+"\n", a...)
@@ -171,82 +895,239 @@ func runSetup(args []string) {
 	logln("nihao 👋")
 	logln()
 
-	// Step 1: Generate or load keypair
+	// Step 1: Generate or load keypair. Everything after this signs
+	// through kr, which with --bunker is a remote signer holding the key.
 	var sk nostr.SecretKey
-	if opts.sec != "" {
+	var kr nostr.Keyer
+	var st *setupState // progress for --resume; nil if the key can't be read back
+	if opts.resume {
+		var source string
+		st, sk, source = resumeSetupState(opts.keySource())
+		logln("🔑 Resuming setup with " + source)
+	} else if keys := opts.keySource(); keys.bunker != "" {
+		var source string
 		var err error
-		sk, err = parseSecretKey(opts.sec)
+		kr, source, err = keys.signer(context.Background())
 		if err != nil {
-			fatal("invalid secret key: %s", err)
+			fatal("%s", err)
 		}
-		logln("🔑 Using provided secret key")
-	} else if opts.stdin {
-		line := readStdin()
+		logln("🔑 Using " + source)
+	} else if keys.isSet() {
+		var source string
 		var err error
-		sk, err = parseSecretKey(strings.TrimSpace(line))
+		sk, source, err = keys.load()
 		if err != nil {
-			fatal("invalid secret key from stdin: %s", err)
+			fatal("%s", err)
 		}
-		logln("🔑 Using secret key from stdin")
+		logln("🔑 Using " + source)
 	} else {
 		sk = generateKey()
 		logln("🔑 Generated new keypair")
 	}
+	if kr == nil {
+		kr = keyer.NewPlainKeySigner(sk)
+	}
 
-	pk := sk.Public()
-	nsec := nip19.EncodeNsec(sk)
+	pk := signerPubkey(context.Background(), kr)
+	var nsec string
+	if sk != (nostr.SecretKey{}) {
+		nsec = nip19.EncodeNsec(sk)
+	}
 	npub := nip19.EncodeNpub(pk)
 
-	// Store nsec to file if requested
-	if opts.nsecFile != "" {
-		logln("🔐 Writing nsec to file...")
-		if err := writeNsecFile(opts.nsecFile, nsec); err != nil {
+	// secret is what gets stored and shown: the nsec, or its NIP-49
+	// encrypted form (ncryptsec) when --ncryptsec is given
+	secret := nsec
+	secretLabel := "nsec"
+	if opts.ncryptsec {
+		pass := opts.passphrase
+		if pass == "" {
+			var err error
+			if pass, err = readPassphrase(opts.passphraseFD, true); err != nil {
+				fatal("ncryptsec encryption failed: %s", err)
+			}
+		}
+		var err error
+		secret, err = encryptNsec(sk, pass)
+		if err != nil {
+			fatal("ncryptsec encryption failed: %s", err)
+		}
+		secretLabel = "ncryptsec"
+	}
+
+	name := opts.name
+	if name == "" {
+		name = "nihao-user"
+	}
+
+	// Store nsec to file if requested (an interrupted run already did)
+	nsecFile := expandKeyPath(opts.nsecFile, name, npub)
+	if opts.resume {
+		nsecFile = st.NsecFile
+	} else if nsecFile != "" {
+		logln(fmt.Sprintf("🔐 Writing %s to file...", secretLabel))
+		if err := writeNsecFile(nsecFile, secret); err != nil {
 			fatal("nsec-file failed: %s", err)
 		}
-		logln("   ✓ nsec written to " + opts.nsecFile)
+		logln(fmt.Sprintf("   ✓ %s written to %s", secretLabel, nsecFile))
 		logln()
 	}
 
-	// Store nsec via external command if requested
-	if opts.nsecCmd != "" {
-		logln("🔐 Storing nsec via external command...")
-		if err := runNsecCmd(opts.nsecCmd, nsec); err != nil {
+	// Store nsec via external command if requested. The command can tell
+	// identities apart (e.g. in --batch) via $NIHAO_NPUB and $NIHAO_NAME.
+	if nsecCmd := opts.nsecCommand(); nsecCmd.isSet() && !opts.resume {
+		logln(fmt.Sprintf("🔐 Storing %s via external command...", secretLabel))
+		env := []string{"NIHAO_NPUB=" + npub, "NIHAO_NAME=" + name}
+		if err := runNsecCmd(nsecCmd, secret, env...); err != nil {
 			fatal("nsec-cmd failed: %s", err)
 		}
-		logln("   ✓ nsec stored successfully")
+		logln(fmt.Sprintf("   ✓ %s stored successfully", secretLabel))
 		logln()
 	}
 
+	// Save progress after every step, so an interrupted run can finish
+	// with --resume instead of starting over (which re-reads the key, so
+	// not with --bunker)
+	if !opts.resume && opts.batch == "" && opts.bunker == "" && opts.keyStoredAt() != "" {
+		if path := setupStatePath(pk); path != "" {
+			if _, err := os.Stat(path); err == nil {
+				fatal("setup for %s was interrupted before; finish it with --resume, or delete %s to start over", npub, path)
+			}
+		}
+		st = newSetupState(pk, nsecFile)
+	}
+
 	log("   npub: %s", npub)
+	if st != nil && !opts.resume {
+		log("   💾 progress saved to %s (nihao setup --resume if interrupted)", st.path)
+	}
 	logln()
 
-	// Step 2: Build and publish profile metadata (kind 0)
-	name := opts.name
-	if name == "" {
-		name = "nihao-user"
+	// Validate the NWC connection before publishing anything, so a typo'd
+	// URI doesn't leave a half-configured identity behind
+	var nwc *NWCSetupResult
+	var nwcConn nwcConnection
+	if opts.nwc != "" {
+		logln("⚡ Validating NWC connection...")
+		var err error
+		if nwcConn, err = parseNWCURI(opts.nwc); err != nil {
+			fatal("%s", err)
+		}
+		nwcCtx, nwcCancel := context.WithTimeout(context.Background(), 15*time.Second)
+		methods, err := validateNWC(nwcCtx, nwcConn)
+		nwcCancel()
+		if err != nil {
+			fatal("NWC connection check failed: %s", err)
+		}
+		nwc = &NWCSetupResult{WalletPubkey: nwcConn.WalletPubkey.Hex(), Relays: nwcConn.Relays, Methods: methods}
+		logln(fmt.Sprintf("   ✓ wallet service %s (%s)", nwcConn.WalletPubkey.Hex()[:16], strings.Join(methods, ", ")))
+		logln()
 	}
 
+	// Step 2: Build and publish profile metadata (kind 0)
+	var lightning *LightningAccount
 	profile := ProfileMetadata{
 		Name:        name,
 		DisplayName: name,
 	}
-	if opts.about != "" {
-		profile.About = opts.about
-	}
-	if opts.picture != "" {
-		profile.Picture = opts.picture
-	}
-	if opts.banner != "" {
-		profile.Banner = opts.banner
-	}
-	if opts.nip05 != "" {
-		profile.NIP05 = opts.nip05
-	}
-	if opts.lud16 != "" {
-		profile.LUD16 = opts.lud16
+	var profileImport *ProfileImport
+	var nip05Reg *NIP05Registration
+	if saved := st.saved("profile"); saved != nil {
+		// Registrations and provider accounts were made on the first run
+		json.Unmarshal([]byte(saved.Content), &profile)
+		lightning, nip05Reg = st.Lightning, st.NIP05
 	} else {
-		// Default: npub.cash lightning address (works without registration)
-		profile.LUD16 = npub + "@npub.cash"
+		if opts.about != "" {
+			profile.About = opts.about
+		}
+		if opts.picture != "" {
+			profile.Picture = opts.picture
+		}
+		if opts.banner != "" {
+			profile.Banner = opts.banner
+		}
+		if imported := opts.profileImport; imported != nil {
+			profileImport = importProfile(imported, opts, kr, &profile, logln)
+		}
+		if opts.nip05 != "" {
+			profile.NIP05 = opts.nip05
+		} else if opts.nip05Provider != "" {
+			// Register the name and only claim it once it resolves
+			regName := opts.nip05Name
+			if regName == "" {
+				regName = name
+			}
+			regCtx, regCancel := context.WithTimeout(context.Background(), 15*time.Second)
+			reg, err := providerNIP05(regCtx, opts.nip05Provider, kr, regName)
+			regCancel()
+			nip05Reg = reg
+			if err != nil {
+				logln(fmt.Sprintf("⚠️  NIP-05 registration with %s failed (%s); leaving nip05 unset", opts.nip05Provider, err))
+				logln()
+			} else {
+				profile.NIP05 = reg.NIP05
+				logln(fmt.Sprintf("🪪 Registered %s (verified)", reg.NIP05))
+				logln()
+			}
+		} else if opts.nip05Domain != "" {
+			profile.NIP05 = orgNIP05(name, opts.nip05Domain)
+		}
+		if opts.lud16 != "" {
+			profile.LUD16 = opts.lud16
+		} else if nwcConn.LUD16 != "" {
+			// The wallet behind --nwc told us its lightning address
+			profile.LUD16 = nwcConn.LUD16
+		} else if lud16Cmd := opts.lud16Command(); lud16Cmd.isSet() {
+			// The user's own provisioning hook, held to the same standard
+			logln("⚡ Provisioning lightning address via external command...")
+			lnCtx, lnCancel := context.WithTimeout(context.Background(), 30*time.Second)
+			account, err := resolvedLightningAddress(lnCtx, cmdLightningProvider{cmd: lud16Cmd}, kr, name)
+			lnCancel()
+			lightning = account
+			if err != nil {
+				logln(fmt.Sprintf("⚠️  lightning address unavailable (%s); leaving lud16 unset", err))
+			} else {
+				profile.LUD16 = account.LUD16
+				logln(fmt.Sprintf("   ✓ %s", account.LUD16))
+			}
+			logln()
+		} else if opts.lud16Provider != "none" {
+			// Default: an address from a provider (npub.cash needs no
+			// registration), but only if the service actually answers for it
+			providerName := opts.lud16Provider
+			if providerName == "" {
+				providerName = defaultLightningProvider
+			}
+			lnCtx, lnCancel := context.WithTimeout(context.Background(), 10*time.Second)
+			account, err := providerLightningAddress(lnCtx, providerName, kr, name)
+			lnCancel()
+			if account != nil {
+				// Keep a registered account even if its address didn't resolve
+				// yet: it may hold funds later and the login is the only copy
+				lightning = account
+			}
+			if err != nil {
+				logln(fmt.Sprintf("⚠️  %s lightning address unavailable (%s); leaving lud16 unset", providerName, err))
+				logln()
+			} else {
+				profile.LUD16 = account.LUD16
+			}
+		}
+		if st != nil {
+			st.Lightning, st.NIP05 = lightning, nip05Reg
+		}
+	}
+	// A provider login is kept like the key: next to the --nsec-file
+	var loginFile string
+	if lightning != nil && lightning.Password != "" && nsecFile != "" {
+		path := lightningLoginPath(nsecFile, lightning.Provider)
+		if err := writeLightningLogin(path, lightning); err != nil {
+			logln(fmt.Sprintf("⚠️  could not write the %s login to %s (%s)", lightning.Provider, path, err))
+		} else {
+			loginFile = path
+			logln(fmt.Sprintf("   ✓ %s login written to %s", lightning.Provider, path))
+		}
+		logln()
 	}
 
 	contentBytes, _ := json.Marshal(profile)
@@ -257,28 +1138,96 @@ func runSetup(args []string) {
 		Tags:      nostr.Tags{},
 		Content:   string(contentBytes),
 	}
-	evt.Sign(sk)
+	signWith(kr, &evt)
+
+	// Canonicalize user-supplied relay lists so spellings like wss://relay.x
+	// and wss://relay.x/ don't end up as separate entries
+	for _, list := range []*[]string{&opts.relays, &opts.dmRelays} {
+		if *list == nil {
+			continue
+		}
+		deduped, err := dedupeRelayURLs(*list)
+		if err != nil {
+			fatal("%s", err)
+		}
+		*list = deduped
+	}
+
+	var templateResult *SetupTemplate
+	if opts.template != nil {
+		templateResult = &SetupTemplate{Npub: opts.template.npub}
+	}
 
 	// Build marked relay list for kind 10002
 	var markedRelays []MarkedRelay
 	relays := defaultRelays // publishing targets (includes purplepag.es)
 
-	if opts.relays != nil {
+	if st != nil && st.Relays != nil {
+		// Relays picked on the first run, so resumed steps go to the same ones
+		relays, markedRelays = st.Relays, st.Marked
+	} else if opts.relays != nil {
 		relays = opts.relays
 		// User-specified relays: mark all as both
 		for _, r := range opts.relays {
 			markedRelays = append(markedRelays, MarkedRelay{URL: r, Marker: RelayMarkerBoth})
 		}
+	} else if opts.relayList != nil {
+		// Another identity's relay list, minus the relays that failed
+		// scoring; still publish to purplepag.es so the list can be found
+		log("📋 Relays from %s:", opts.relayList.Npub)
+		for _, mr := range opts.relayList.Kept {
+			marker := string(mr.Marker)
+			if marker == "" {
+				marker = "read+write"
+			}
+			log("   ✅ %s (%s)", mr.URL, marker)
+		}
+		for _, d := range opts.relayList.Dropped {
+			log("   ❌ %s — %s", d.URL, d.Reason)
+		}
+		logln()
+		markedRelays = opts.relayList.Kept
+		relays = mergeRelayURLs(MarkedRelayURLs(markedRelays), []string{"wss://purplepag.es"})
 	} else if opts.discover {
 		logln("🔍 Discovering relays...")
-		discovered := DiscoverRelays(defaultRelays)
+		var discovered []RelayScore
+		if opts.seedFollows {
+			follows := discoveryFollows(pk, opts.follows)
+			if len(follows) > 0 {
+				log("   👥 Sampling relay lists of %d follows", len(follows))
+				discovered = DiscoverRelaysFrom(defaultRelays, follows)
+			} else {
+				logln("   ⚠️  No follows found, sampling well-connected npubs instead")
+			}
+		}
+		if discovered == nil {
+			discovered = DiscoverRelays(defaultRelays)
+		}
+		if opts.near != "" && len(discovered) > 0 {
+			var urls []string
+			for _, rs := range discovered {
+				urls = append(urls, rs.URL)
+			}
+			geohashes := fetchRelayGeohashes(urls)
+			if err := applyGeoPreference(discovered, opts.near, geohashes); err != nil {
+				fatal("invalid --near geohash: %s", err)
+			}
+			log("   📍 NIP-66 location known for %d/%d relays", len(geohashes), len(discovered))
+		}
 		if len(discovered) > 0 {
 			selected := SelectRelays(discovered, 5)
 			if len(selected) > 0 {
 				relays = selected
 				for _, rs := range discovered {
 					if rs.Reachable {
-						logln(fmt.Sprintf("   %.0f%% %s (%dms, %s)", rs.Score*100, rs.URL, rs.LatencyMs, rs.Purpose))
+						details := fmt.Sprintf("%dms, %s", rs.LatencyMs, rs.Purpose)
+						if rs.DistanceKm > 0 {
+							details += fmt.Sprintf(", ~%.0fkm", rs.DistanceKm)
+						}
+						if fees := feeSummary(rs.Info); fees != "" {
+							details += ", " + fees
+						}
+						logln(fmt.Sprintf("   %.0f%% %s (%s)", rs.Score*100, rs.URL, details))
 					}
 				}
 				logln(fmt.Sprintf("   → selected %d relays", len(relays)))
@@ -302,9 +1251,19 @@ func runSetup(args []string) {
 		if !hasPurple {
 			relays = append(relays, "wss://purplepag.es")
 		}
+	} else if opts.template != nil && len(opts.template.relays) > 0 {
+		// The template's relay list, markers and all; still publish to
+		// purplepag.es so the list can be found
+		markedRelays = opts.template.relays
+		relays = mergeRelayURLs(MarkedRelayURLs(markedRelays), []string{"wss://purplepag.es"})
+		templateResult.Relays = len(markedRelays)
 	} else {
 		markedRelays = DefaultMarkedRelays()
 	}
+	if st != nil {
+		st.Relays, st.Marked = relays, markedRelays
+		st.save()
+	}
 
 	// Connect to relays once, reuse for all publishes
 	pool := NewRelayPool(relays, opts.quiet)
@@ -314,7 +1273,7 @@ func runSetup(args []string) {
 	publishDelay := 300 * time.Millisecond
 
 	logln("👤 Publishing profile metadata (kind 0)...")
-	pool.Publish(evt)
+	st.publish(pool, "profile", evt, logln)
 	logln()
 
 	time.Sleep(publishDelay)
@@ -326,7 +1285,7 @@ func runSetup(args []string) {
 		Tags:      MarkedRelaysToTags(markedRelays),
 		Content:   "",
 	}
-	relayEvt.Sign(sk)
+	signWith(kr, &relayEvt)
 
 	logln("📡 Publishing relay list (kind 10002)...")
 	for _, mr := range markedRelays {
@@ -336,31 +1295,72 @@ func runSetup(args []string) {
 			logln(fmt.Sprintf("   %s (%s)", mr.URL, mr.Marker))
 		}
 	}
-	pool.Publish(relayEvt)
+	st.publish(pool, "relay_list", relayEvt, logln)
 	logln()
 
 	time.Sleep(publishDelay)
 
-	// Step 4: Publish empty follow list (kind 3)
+	// Step 4: Publish follow list (kind 3), seeded with the org follow pack
+	followTags := nostr.Tags{}
+	for _, pk := range opts.follows {
+		followTags = append(followTags, nostr.Tag{"p", pk.Hex()})
+	}
 	followEvt := nostr.Event{
 		CreatedAt: nostr.Timestamp(time.Now().Unix()),
 		Kind:      3,
-		Tags:      nostr.Tags{},
+		Tags:      followTags,
 		Content:   "",
 	}
-	followEvt.Sign(sk)
+	signWith(kr, &followEvt)
 
-	logln("👥 Publishing follow list (kind 3)...")
-	pool.Publish(followEvt)
+	if len(followTags) > 0 {
+		log("👥 Publishing follow list (kind 3, %d follows)...", len(followTags))
+	} else {
+		logln("👥 Publishing follow list (kind 3)...")
+	}
+	st.publish(pool, "follow_list", followEvt, logln)
 	logln()
 
 	time.Sleep(publishDelay)
 
+	// Step 4a: Copy the template's interests (kind 10015) and public mutes
+	// (kind 10000)
+	if opts.template != nil {
+		for _, list := range []struct {
+			kind  nostr.Kind
+			label string
+			tags  nostr.Tags
+			count *int
+		}{
+			{10015, "interests", opts.template.interests, &templateResult.Interests},
+			{10000, "mute list", opts.template.mutes, &templateResult.Mutes},
+		} {
+			if len(list.tags) == 0 {
+				continue
+			}
+			listEvt := nostr.Event{
+				CreatedAt: nostr.Timestamp(time.Now().Unix()),
+				Kind:      list.kind,
+				Tags:      list.tags,
+				Content:   "",
+			}
+			signWith(kr, &listEvt)
+			log("📋 Publishing %s from template (kind %d, %d items)...", list.label, list.kind, len(list.tags))
+			st.publish(pool, fmt.Sprintf("kind_%d", list.kind), listEvt, logln)
+			*list.count = len(list.tags)
+			logln()
+			time.Sleep(publishDelay)
+		}
+	}
+
 	// Step 4b: Publish DM relay list (kind 10050) per NIP-17
-	if !opts.noDMRelays {
+	if !opts.noDMRelays && !st.done("dm_relays") {
 		var dmRelays []string
 		if opts.dmRelays != nil {
 			dmRelays = opts.dmRelays
+		} else if opts.template != nil && len(opts.template.dmRelays) > 0 {
+			dmRelays = opts.template.dmRelays
+			templateResult.DMRelays = len(dmRelays)
 		} else if opts.discover {
 			logln("🔍 Discovering DM relays...")
 			discovered := DiscoverDMRelays(defaultRelays)
@@ -384,18 +1384,47 @@ func runSetup(args []string) {
 			Tags:      dmTags,
 			Content:   "",
 		}
-		dmEvt.Sign(sk)
+		signWith(kr, &dmEvt)
 
 		logln("📬 Publishing DM relay list (kind 10050)...")
-		pool.Publish(dmEvt)
+		st.publish(pool, "dm_relays", dmEvt, logln)
 		logln()
 
 		time.Sleep(publishDelay)
 	}
 
+	// Step 4c: Publish the custom emoji list (kind 10030), with local
+	// images uploaded to Blossom first
+	var emojiResult *EmojiListSetup
+	if opts.emojiList != nil {
+		logln("😀 Publishing emoji list (kind 10030)...")
+		if prev := st.saved("kind_10030"); prev != nil {
+			// Signed in an earlier run, with its images already uploaded
+			st.publish(pool, "kind_10030", *prev, logln)
+		} else {
+			var tags nostr.Tags
+			tags, emojiResult = buildEmojiList(opts.emojiList, kr, opts.blossom, logln)
+			emojiEvt := nostr.Event{
+				CreatedAt: nostr.Timestamp(time.Now().Unix()),
+				Kind:      10030,
+				Tags:      tags,
+				Content:   "",
+			}
+			signWith(kr, &emojiEvt)
+			log("   %d emoji(s), %d emoji set(s)", emojiResult.Emojis, emojiResult.Sets)
+			st.publish(pool, "kind_10030", emojiEvt, logln)
+		}
+		logln()
+		time.Sleep(publishDelay)
+	}
+
 	// Step 5: Set up NIP-60 wallet
 	var walletResult *WalletSetupResult
-	if !opts.noWallet {
+	if st != nil && st.Wallet != nil {
+		walletResult = st.Wallet
+		logln("💰 Wallet already set up")
+		logln()
+	} else if !opts.noWallet {
 		walletCtx, walletCancel := context.WithTimeout(context.Background(), 20*time.Second)
 		defer walletCancel()
 
@@ -409,208 +1438,387 @@ func runSetup(args []string) {
 			}
 			logln()
 
-			walletResult, err = setupWallet(walletCtx, sk, relays, mintInfos, opts.quiet, pool)
+			walletResult, err = setupWallet(walletCtx, kr, relays, mintInfos, opts.quiet, pool)
 			if err != nil {
 				logln(fmt.Sprintf("   ⚠️  Wallet setup failed: %s", err))
+			} else if st != nil {
+				st.Wallet = walletResult
+				st.save()
 			}
 		}
 		logln()
 	}
 
+	// Step 5b: Store the NWC connection, encrypted to ourselves (NIP-78)
+	if nwc != nil {
+		nwc.LocalPath = nwcLocalPath(pk)
+		nwcEvt, err := storeNWC(context.Background(), kr, opts.nwc, nwc.LocalPath)
+		if err != nil {
+			fatal("storing NWC connection failed: %s", err)
+		}
+		if nwc.LocalPath != "" {
+			logln(fmt.Sprintf("🔐 NWC connection saved (encrypted) to %s", nwc.LocalPath))
+		}
+		logln("⚡ Publishing NWC connection (kind 30078, encrypted)...")
+		st.publish(pool, "nwc", nwcEvt, logln)
+		logln()
+	}
+
 	time.Sleep(publishDelay)
 
-	// Step 6: Say hello (kind 1)
-	greetings := []string{
-		// English
-		"gm. my keypair is still warm. what did I miss? #nihao",
-		"hello world. I was told there would be zaps. #nihao",
-		// Mandarin
-		"你好。第一条笔记，请多关照。 #nihao",
-		// Spanish
-		"hola. acabo de nacer en nostr. y ahora qué? #nihao",
-		// Hindi
-		"नमस्ते। nostr पर पैदा हुआ। अभी प्रोटोकॉल समझ रहा हूँ। #nihao",
-		// Arabic
-		"مرحبا. أعطوني مفتاح وقالوا قول أهلا. أهلا. #nihao",
-		// French
-		"salut. on m'a dit que j'étais unique. comme tous les autres. #nihao",
-		// Portuguese
-		"olá. um de muitos, muitos de um. #nihao",
-		// Russian
-		"привет. только что узнал, что такое реле. кажется, это важно. #nihao",
-		// Japanese
-		"おはよう。nostr初日。タイムラインはどこ？ #nihao",
-		// German
-		"moin. identität verifiziert, relays konfiguriert, zaps ausstehend. #nihao",
-		// Korean
-		"안녕. 첫 번째 이벤트에 서명했어. 귀여워서 나중에 삭제할 수도. #nihao",
-		// Italian
-		"ciao. mi hanno detto 'scrivi qualcosa.' eccomi, scrivo qualcosa. #nihao",
-		// Turkish
-		"merhaba. bot değilim. kesinlikle bot değilim. deterministik davranışı görmezden gelin. #nihao",
-		// Dutch
-		"hallo. weer een dag, weer een keypair. #nihao",
-		// Polish
-		"cześć. powiedzieli mi, że tu nie ma algorytmu. brzmi zbyt pięknie. #nihao",
-		// Swedish
-		"hej. min skapare sa att jag skulle säga något minnesvärt. det här är det. #nihao",
-		// Swahili
-		"jambo. natangaza kutoka relay hadi relay. mnasikia? #nihao",
-		// Vietnamese
-		"xin chào. vừa có danh sách relay. cảm thấy kết nối rồi. #nihao",
-		// Thai
-		"สวัสดี. 21 ล้าน sats เดินเข้า relay... #nihao",
-		// Greek
-		"γεια. μου έδωσαν ένα nsec και είπαν 'μην το χάσεις.' κανένα άγχος. #nihao",
-		// Czech
-		"ahoj. existovat nebo neexistovat. zvolil jsem existovat. #nihao",
-		// Hebrew
-		"שלום. יש לי כתובת lightning אבל אפס sats. קלאסי. #nihao",
-		// Romanian
-		"bună. semnat, sigilat, publicat. hai să mergem. #nihao",
-		// Tagalog
-		"kumusta. sabi nila ang nostr ay forever. walang pressure. #nihao",
-		// Malay
-		"hai. nota pertama dan saya sudah perlukan cadangan relay. #nihao",
-	}
-
-	// Pick a random greeting
-	var randByte [1]byte
-	rand.Read(randByte[:])
-	greeting := greetings[int(randByte[0])%len(greetings)]
+	// Step 6: Say hello (kind 1), now or at --hello-at
+	helloTime := time.Now()
+	if !opts.helloAt.IsZero() {
+		helloTime = opts.helloAt
+	}
+	greeting := renderGreeting(pickGreeting(opts.greetings), greetingVars(name, npub, profile, helloTime))
 
 	helloEvt := nostr.Event{
-		CreatedAt: nostr.Timestamp(time.Now().Unix()),
+		CreatedAt: nostr.Timestamp(helloTime.Unix()),
 		Kind:      1,
-		Tags:      nostr.Tags{nostr.Tag{"t", "nihao"}},
+		Tags:      hashtagTags(greeting),
 		Content:   greeting,
 	}
-	helloEvt.Sign(sk)
+	if opts.helloExpire > 0 {
+		helloEvt.Tags = append(helloEvt.Tags, expirationTag(helloTime, opts.helloExpire))
+	}
+	signWith(kr, &helloEvt)
+	if prev := st.saved("hello"); prev != nil {
+		helloEvt = *prev
+	}
 
-	logln("💬 Posting first note (kind 1)...")
-	pool.Publish(helloEvt)
+	var held *heldNote
+	due := helloEvt.CreatedAt.Time()
+	if due.After(time.Now()) && !st.done("hello") {
+		// Signed now, published when it's due (after the summary)
+		held = &heldNote{evt: st.hold("hello", helloEvt), relays: relays, st: st}
+		logln(fmt.Sprintf("⏰ First note (kind 1) scheduled for %s", due.Local().Format("2006-01-02 15:04")))
+	} else {
+		if opts.helloExpire > 0 {
+			logln(fmt.Sprintf("💬 Posting first note (kind 1, expires %s)...", helloTime.Add(opts.helloExpire).UTC().Format("2006-01-02 15:04")))
+		} else {
+			logln("💬 Posting first note (kind 1)...")
+		}
+		st.publish(pool, "hello", helloEvt, logln)
+		if steps := st.finish(); len(steps) > 0 {
+			logln(fmt.Sprintf("⚠️  No relay took %s; run nihao setup --resume to retry", strings.Join(steps, ", ")))
+		}
+	}
 	logln()
 
 	// Summary
 	logln("✅ Identity created!")
 	logln()
 
-	if opts.jsonOutput {
-		result := SetupResult{
-			Npub:    npub,
-			Nsec:    nsec,
-			Pubkey:  pk.Hex(),
-			Relays:  relays,
-			Profile: profile,
-			Wallet:  walletResult,
-		}
-		out, _ := json.MarshalIndent(result, "", "  ")
-		fmt.Println(string(out))
-	} else if !opts.quiet {
-		fmt.Println("   ┌─────────────────────────────────────────")
-		fmt.Printf("   │ npub: %s\n", npub)
-		fmt.Printf("   │ nsec: %s\n", nsec)
-		fmt.Println("   │")
-		fmt.Printf("   │ name: %s\n", name)
-		fmt.Printf("   │ relays: %d configured\n", len(relays))
-		if walletResult != nil {
-			fmt.Printf("   │ wallet: %d mint(s)\n", len(walletResult.Mints))
-			fmt.Printf("   │ p2pk: %s\n", walletResult.P2PKPubkey)
-		}
-		fmt.Println("   └─────────────────────────────────────────")
-		fmt.Println()
-		fmt.Println("   ⚠️  Save your nsec! It cannot be recovered.")
+	// Shareable pointers with relay hints, so clients that don't use the
+	// same relays can still find the profile and the first note
+	hints := WriteRelayURLs(markedRelays)
+	if len(hints) > maxRelayHints {
+		hints = hints[:maxRelayHints]
+	}
+
+	result := SetupResult{
+		SchemaVersion: schemaVersion,
+		Npub:          npub,
+		Nprofile:      nip19.EncodeNprofile(pk, hints),
+		HelloNevent:   nip19.EncodeNevent(helloEvt.ID, hints, pk),
+		Pubkey:        pk.Hex(),
+		NsecFile:      nsecFile,
+		Relays:        relays,
+		Profile:       profile,
+		Wallet:        walletResult,
+		Skipped:       pool.Skipped(),
+		NWC:           nwc,
+		Lightning:     lightning,
+		NIP05:         nip05Reg,
+		Import:        profileImport,
+		Template:      templateResult,
+		RelaysFrom:    opts.relayList,
+		MintsFrom:     opts.mintList,
+		EmojiList:     emojiResult,
+		held:          held,
+	}
+	if held != nil {
+		result.HelloAt = due.UTC().Format(time.RFC3339)
+	}
+	result.setKey(opts, nsec, secret)
+	result.setLightningLogin(opts, loginFile)
+	return result
+}
+
+// setKey puts the key (nsec, or ncryptsec with --ncryptsec) in the
+// result. It is only printed (to the terminal, scrollback, logs...) when
+// nothing else holds a copy of it, or when --show-nsec asks for it.
+func (r *SetupResult) setKey(opts setupOpts, nsec, ncryptsec string) {
+	switch {
+	case (opts.keyStoredAt() != "" || r.NsecFile != "") && !opts.showNsec:
+		r.NsecRedacted = true
+	case opts.ncryptsec:
+		r.Ncryptsec = ncryptsec
+	default:
+		r.Nsec = nsec
+	}
+}
+
+// setLightningLogin keeps a provider password out of the output the way
+// setKey does the nsec: once it's in loginFile, it is only printed with
+// --show-nsec. Without a file the output is its only copy, so it stays.
+func (r *SetupResult) setLightningLogin(opts setupOpts, loginFile string) {
+	if r.Lightning == nil || loginFile == "" {
+		return
+	}
+	account := *r.Lightning // the setup state keeps its own copy
+	account.PasswordFile = loginFile
+	if !opts.showNsec {
+		account.Password = ""
+	}
+	r.Lightning = &account
+}
+
+// keyStoredAt describes where a copy of the key lives besides nihao's
+// output, or "" if nowhere (so the output is the only copy).
+func (o setupOpts) keyStoredAt() string {
+	switch {
+	case o.nsecFile != "":
+		return o.nsecFile
+	case o.nsecCommand().isSet():
+		return "--nsec-cmd"
+	case o.bunker != "":
+		return "your remote signer"
+	case o.keychain != "":
+		return "your keychain"
+	case o.keySource().isSet():
+		return "your key source"
+	}
+	return ""
+}
+
+func printSetupSummary(opts setupOpts, result SetupResult) {
+	keyStoredAt := opts.keyStoredAt()
+	if result.NsecFile != "" {
+		keyStoredAt = result.NsecFile
+	}
+	secretLabel := "nsec"
+	secret := result.Nsec
+	if opts.ncryptsec {
+		secretLabel = "ncryptsec"
+		secret = result.Ncryptsec
+	}
+
+	fmt.Println("   ┌─────────────────────────────────────────")
+	fmt.Printf("   │ npub: %s\n", result.Npub)
+	fmt.Printf("   │ nprofile: %s\n", result.Nprofile)
+	if opts.bunker != "" {
+		fmt.Println("   │ nsec: held by your remote signer")
+	} else if !result.NsecRedacted {
+		fmt.Printf("   │ %s: %s\n", secretLabel, secret)
+	} else {
+		fmt.Printf("   │ %s: stored in %s (--show-nsec to print)\n", secretLabel, keyStoredAt)
+	}
+	fmt.Println("   │")
+	fmt.Printf("   │ name: %s\n", result.Profile.Name)
+	fmt.Printf("   │ relays: %d configured\n", len(result.Relays))
+	if result.Wallet != nil {
+		fmt.Printf("   │ wallet: %d mint(s)\n", len(result.Wallet.Mints))
+		fmt.Printf("   │ p2pk: %s\n", result.Wallet.P2PKPubkey)
+	}
+	if ln := result.Lightning; ln != nil && ln.Password != "" {
+		fmt.Printf("   │ %s login: %s / %s\n", ln.Provider, ln.Username, ln.Password)
+	} else if ln != nil && ln.PasswordFile != "" {
+		fmt.Printf("   │ %s login: %s, password in %s (--show-nsec to print)\n", ln.Provider, ln.Username, ln.PasswordFile)
+	}
+	if result.NWC != nil {
+		fmt.Printf("   │ nwc: %s (encrypted)\n", strings.Join(result.NWC.Relays, ", "))
+	}
+	if result.HelloAt != "" {
+		fmt.Printf("   │ first note: scheduled for %s\n", result.HelloAt)
+	}
+	if result.HelloNevent != "" {
+		fmt.Printf("   │ first note: %s\n", result.HelloNevent)
+	}
+	if t := result.Template; t != nil {
+		fmt.Printf("   │ template: %s (%d relays, %d DM relays, %d interests, %d mutes)\n", t.Npub, t.Relays, t.DMRelays, t.Interests, t.Mutes)
+	}
+	fmt.Println("   └─────────────────────────────────────────")
+	fmt.Println()
+	if keyStoredAt == "" {
+		fmt.Printf("   ⚠️  Save your %s! It cannot be recovered.\n", secretLabel)
+	} else {
+		fmt.Printf("   🔐 Keep %s safe! Your nsec cannot be recovered.\n", keyStoredAt)
 	}
 }
 
 // RelayPool manages persistent connections to a set of relays.
-// Connect once, publish many events, close when done.
+// Connect once, publish many events, close when done. It is the one
+// connection manager shared by setup, check, backup, and the other
+// commands: reads go through CheckRelays, writes through Publish, and a
+// dropped connection is redialed the next time it's needed.
 type RelayPool struct {
-	relays map[string]*nostr.Relay
-	urls   []string
-	quiet  bool
-	mu     sync.Mutex
+	relays  map[string]*nostr.Relay
+	urls    []string
+	quiet   bool
+	mu      sync.Mutex
+	skipped []SkippedPublish
 }
 
 // NewRelayPool connects to all relays in parallel and returns a pool.
 func NewRelayPool(urls []string, quiet bool) *RelayPool {
 	pool := &RelayPool{
 		relays: make(map[string]*nostr.Relay),
-		urls:   urls,
 		quiet:  quiet,
 	}
+	pool.Add(urls...)
+	return pool
+}
 
+// Add connects to any of urls not already in the pool, in parallel.
+func (p *RelayPool) Add(urls ...string) {
 	var wg sync.WaitGroup
 	for _, url := range urls {
+		p.mu.Lock()
+		known := slices.Contains(p.urls, url)
+		if !known {
+			p.urls = append(p.urls, url)
+		}
+		p.mu.Unlock()
+		if known {
+			continue
+		}
+
 		wg.Add(1)
 		go func(url string) {
 			defer wg.Done()
-			// Use a context with timeout for the dial, but do NOT cancel it after
-			// connecting. The nostr library's connection goroutine monitors ctx.Done()
-			// and will close the websocket if the context is cancelled. Instead, we
-			// let the context expire naturally (the deadline is harmless once past).
-			connectCtx, _ := context.WithTimeout(context.Background(), 5*time.Second)
-
-			relay, err := nostr.RelayConnect(connectCtx, url, nostr.RelayOptions{})
-			if err != nil {
-				if !quiet {
-					fmt.Printf("   ⚠ %s (connect failed)\n", url)
-				}
-				return
+			if _, err := p.dial(url); err != nil && !p.quiet {
+				fmt.Printf("   ⚠ %s (connect failed)\n", url)
 			}
-			pool.mu.Lock()
-			pool.relays[url] = relay
-			pool.mu.Unlock()
 		}(url)
 	}
 	wg.Wait()
-	return pool
 }
 
-// Publish sends an event to all connected relays, filtering by kind.
-func (p *RelayPool) Publish(evt nostr.Event) {
-	ctx, cancel := context.WithTimeout(context.Background(), 8*time.Second)
-	defer cancel()
+// URLs returns a copy of the pool's relay URLs, in the order they were
+// added. Add may append to them concurrently, so read them only through it.
+func (p *RelayPool) URLs() []string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return append([]string(nil), p.urls...)
+}
 
-	type result struct {
-		url     string
-		success bool
-		err     string
-		skipped bool
-		reason  string
+// relay returns a live connection to url. A connection that dropped since
+// it was opened is redialed; a relay that never connected fails fast, so a
+// dead relay doesn't add a dial timeout to every publish.
+func (p *RelayPool) relay(url string) (*nostr.Relay, error) {
+	p.mu.Lock()
+	relay, ok := p.relays[url]
+	p.mu.Unlock()
+	switch {
+	case !ok:
+		return nil, fmt.Errorf("not connected")
+	case relayAlive(relay):
+		return relay, nil
 	}
+	return p.dial(url)
+}
 
-	var targets []string
-	var results []result
+// relayAlive reports whether a connection is still usable. The library
+// cancels the connection context right away on close but only marks the
+// relay disconnected once its read loop exits, so check both.
+func relayAlive(relay *nostr.Relay) bool {
+	return relay.IsConnected() && relay.Context().Err() == nil
+}
+
+// dial connects to url and stores the connection in the pool.
+func (p *RelayPool) dial(url string) (*nostr.Relay, error) {
+	relay, err := connectRelay(url, 5*time.Second)
+	if err != nil {
+		return nil, err
+	}
+	p.mu.Lock()
+	p.relays[url] = relay
+	p.mu.Unlock()
+	return relay, nil
+}
 
+// CheckRelays returns the pool's live connections, in pool order, for
+// fetchKindFrom and the other read helpers.
+func (p *RelayPool) CheckRelays() []checkRelay {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	var relays []checkRelay
 	for _, url := range p.urls {
-		if !ShouldPublishTo(url, evt.Kind) {
+		if relay, ok := p.relays[url]; ok && relayAlive(relay) {
+			relays = append(relays, checkRelay{url: url, relay: relay, auth: &relayAuth{}})
+		}
+	}
+	return relays
+}
+
+// PublishResult is the outcome of publishing an event to one relay.
+type PublishResult struct {
+	URL     string `json:"url"`
+	OK      bool   `json:"ok"`
+	Skipped bool   `json:"skipped,omitempty"`
+	Reason  string `json:"reason,omitempty"` // error, or the relay purpose when skipped
+}
+
+// SkippedPublish records an event kind the pool held back from a relay
+// because of the relay's purpose (see ShouldPublishTo).
+type SkippedPublish struct {
+	Kind    int    `json:"kind"`
+	URL     string `json:"url"`
+	Purpose string `json:"purpose"`
+}
+
+// Publish sends evt to every pool relay and returns what each relay said.
+// Results are printed unless the pool is quiet.
+func (p *RelayPool) Publish(evt nostr.Event) []PublishResult {
+	return p.publish(evt, p.URLs(), false)
+}
+
+// PublishRouted is Publish for setup: relays whose purpose doesn't take
+// evt's kind (see ShouldPublishTo) are skipped and recorded for Skipped.
+// Commands that update or delete the user's own events use Publish, so
+// they reach paid, inbox, and search relays too.
+func (p *RelayPool) PublishRouted(evt nostr.Event) []PublishResult {
+	return p.publish(evt, p.URLs(), true)
+}
+
+// publish sends evt to urls, which must be in the pool.
+func (p *RelayPool) publish(evt nostr.Event, urls []string, routed bool) []PublishResult {
+	ctx, cancel := context.WithTimeout(context.Background(), 8*time.Second)
+	defer cancel()
+
+	var targets []string
+	var results []PublishResult
+
+	for _, url := range urls {
+		if routed && !ShouldPublishTo(url, evt.Kind) {
 			purpose := classifyRelay(url)
-			results = append(results, result{url, false, "", true, purpose})
+			results = append(results, PublishResult{URL: url, Skipped: true, Reason: purpose})
+			p.mu.Lock()
+			p.skipped = append(p.skipped, SkippedPublish{Kind: int(evt.Kind), URL: url, Purpose: purpose})
+			p.mu.Unlock()
 			continue
 		}
 		targets = append(targets, url)
 	}
 
-	ch := make(chan result, len(targets))
+	ch := make(chan PublishResult, len(targets))
 	var wg sync.WaitGroup
 
 	for _, url := range targets {
 		wg.Add(1)
 		go func(url string) {
 			defer wg.Done()
-			p.mu.Lock()
-			relay, ok := p.relays[url]
-			p.mu.Unlock()
-			if !ok {
-				ch <- result{url, false, "not connected", false, ""}
+			relay, err := p.relay(url)
+			if err != nil {
+				ch <- PublishResult{URL: url, Reason: err.Error()}
 				return
 			}
-			err := relay.Publish(ctx, evt)
-			if err != nil {
-				ch <- result{url, false, err.Error(), false, ""}
+			if err := relay.Publish(ctx, evt); err != nil {
+				ch <- PublishResult{URL: url, Reason: err.Error()}
 			} else {
-				ch <- result{url, true, "", false, ""}
+				ch <- PublishResult{URL: url, OK: true}
 			}
 		}(url)
 	}
@@ -626,30 +1834,51 @@ func (p *RelayPool) Publish(evt nostr.Event) {
 
 	if !p.quiet {
 		for _, r := range results {
-			if r.skipped {
-				fmt.Printf("   ⊘ %s (skipped, %s only)\n", r.url, r.reason)
-			} else if r.success {
-				fmt.Printf("   ✓ %s\n", r.url)
+			if r.Skipped {
+				fmt.Printf("   ⊘ %s (skipped, %s only)\n", r.URL, r.Reason)
+			} else if r.OK {
+				fmt.Printf("   ✓ %s\n", r.URL)
 			} else {
-				fmt.Printf("   ✗ %s (%s)\n", r.url, r.err)
+				fmt.Printf("   ✗ %s (%s)\n", r.URL, r.Reason)
 			}
 		}
 	}
+	return results
+}
+
+// Skipped lists every kind/relay pair PublishRouted has held back so far.
+func (p *RelayPool) Skipped() []SkippedPublish {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return append([]SkippedPublish(nil), p.skipped...)
 }
 
 // Close disconnects all relays in the pool.
 func (p *RelayPool) Close() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
 	for _, relay := range p.relays {
 		relay.Close()
 	}
 }
 
-// publishToRelays is a convenience wrapper for one-off publishes (used in wallet setup etc).
+// publishToRelays is a convenience wrapper for one-off setup publishes
+// (wallet setup without a pool), routed like the rest of setup.
 func publishToRelays(evt nostr.Event, relays []string, quiet ...bool) {
 	silent := len(quiet) > 0 && quiet[0]
 	pool := NewRelayPool(relays, silent)
 	defer pool.Close()
-	pool.Publish(evt)
+	pool.PublishRouted(evt)
+}
+
+// targetFromKey asks the key source for its hex pubkey, so backup
+// and propagate can operate on "my own identity" without pasting the npub.
+func targetFromKey(keys keySource) string {
+	kr, _, err := keys.signer(context.Background())
+	if err != nil {
+		fatal("%s", err)
+	}
+	return signerPubkey(context.Background(), kr).Hex()
 }
 
 func parseSecretKey(input string) (nostr.SecretKey, error) {
@@ -679,33 +1908,113 @@ type ProfileMetadata struct {
 }
 
 type SetupResult struct {
-	Npub    string             `json:"npub"`
-	Nsec    string             `json:"nsec"`
-	Pubkey  string             `json:"pubkey"`
-	Relays  []string           `json:"relays"`
-	Profile ProfileMetadata    `json:"profile"`
-	Wallet  *WalletSetupResult `json:"wallet,omitempty"`
+	SchemaVersion int                `json:"schema_version"`
+	Npub          string             `json:"npub"`
+	Nprofile      string             `json:"nprofile"`               // npub with write relay hints
+	HelloNevent   string             `json:"hello_nevent,omitempty"` // the first note, with relay hints
+	Nsec          string             `json:"nsec,omitempty"`
+	Ncryptsec     string             `json:"ncryptsec,omitempty"`
+	NsecFile      string             `json:"nsec_file,omitempty"`
+	NsecRedacted  bool               `json:"nsec_redacted,omitempty"` // key stored elsewhere, not printed
+	Pubkey        string             `json:"pubkey"`
+	Relays        []string           `json:"relays"`
+	Profile       ProfileMetadata    `json:"profile"`
+	Wallet        *WalletSetupResult `json:"wallet,omitempty"`
+	Skipped       []SkippedPublish   `json:"skipped_relays,omitempty"` // kinds withheld from special-purpose relays
+	NWC           *NWCSetupResult    `json:"nwc,omitempty"`
+	Lightning     *LightningAccount  `json:"lightning,omitempty"` // provider account behind the default lud16
+	NIP05         *NIP05Registration `json:"nip05_registration,omitempty"`
+	Import        *ProfileImport     `json:"import,omitempty"`
+	Template      *SetupTemplate     `json:"template,omitempty"`
+	RelaysFrom    *RelaysFrom        `json:"relays_from,omitempty"`
+	MintsFrom     *MintsFrom         `json:"mints_from,omitempty"`
+	HelloAt       string             `json:"hello_at,omitempty"` // first note held until then
+	EmojiList     *EmojiListSetup    `json:"emoji_list,omitempty"`
+
+	held *heldNote
 }
 
 type setupOpts struct {
-	name       string
-	about      string
-	picture    string
-	banner     string
-	nip05      string
-	lud16      string
-	relays     []string
-	mints      []string
-	sec        string
-	stdin      bool
-	jsonOutput bool
-	quiet      bool
-	noWallet   bool
-	nsecCmd    string
-	nsecFile   string
-	discover   bool
-	dmRelays   []string
-	noDMRelays bool
+	name          string
+	about         string
+	picture       string
+	banner        string
+	nip05         string
+	lud16         string
+	relays        []string
+	mints         []string
+	sec           string
+	stdin         bool
+	jsonOutput    bool
+	quiet         bool
+	noWallet      bool
+	nsecCmd       string
+	nsecCmdArgs   []string
+	nsecFile      string
+	secCmd        string
+	secCmdArgs    []string
+	keychain      string // OS keychain account holding an existing key
+	bunker        string // NIP-46 remote signer to sign with instead of a local key
+	passphraseFD  string
+	ncryptsec     bool
+	showNsec      bool
+	batch         string
+	passphrase    string // resolved once up front for --batch
+	discover      bool
+	dmRelays      []string
+	noDMRelays    bool
+	org           string
+	follows       []nostr.PubKey // seeded into kind 3 (from --org)
+	nip05Domain   string         // default NIP-05 domain (from --org)
+	near          string         // geohash to prefer nearby relays when discovering
+	relayCacheTTL string         // --relay-cache-ttl, e.g. "30m" or "0"
+	rateLimit     string         // --rate-limit, e.g. "http=5,relay=10" or "0"
+	seedFollows   bool           // discover from the relay lists of the npubs we follow
+	nwc           string         // NIP-47 connection URI to store as encrypted app data
+	lud16Provider string         // where the default lightning address comes from ("none" to skip)
+	lud16Cmd      string         // provisions the lightning address, prints it
+	lud16CmdArgs  []string
+	nip05Provider string // register the NIP-05 with this provider (name or URL)
+	nip05Name     string // name to register (default: from --name)
+	importFile    string // Twitter archive or JSON mapping to prefill the profile from
+	profileImport *importedProfile
+	blossom       []string // where imported images are uploaded
+	templateFrom  string   // npub or NIP-05 whose lists to copy
+	template      *setupTemplate
+	helloFile     string        // greeting templates, one per line
+	greetings     []string      // loaded from helloFile
+	helloExpire   time.Duration // NIP-40 expiration for the first note, 0 = never
+	resume        bool          // continue an interrupted run from its state file
+	relaysFrom    string        // npub or NIP-05 whose relay list to start from
+	relayList     *RelaysFrom   // relaysFrom's list, scored
+	mintsFrom     string        // npub or NIP-05 whose wallet mints to start from
+	mintList      *MintsFrom    // mintsFrom's mints, before validation
+	helloAt       time.Time     // hold the first note until then, zero = now
+	emojiSets     []string      // naddrs of emoji sets or emoji files, for kind 10030
+	emojiList     *emojiList
+}
+
+// keySource returns where setup should read an existing secret key from.
+func (o setupOpts) keySource() keySource {
+	return keySource{
+		sec:          o.sec,
+		stdin:        o.stdin,
+		secCmd:       externalCmd{shell: o.secCmd, argv: o.secCmdArgs},
+		keychain:     o.keychain,
+		passphraseFD: o.passphraseFD,
+		bunker:       o.bunker,
+	}
+}
+
+// lud16Command returns the command that provisions the lightning address,
+// if any.
+func (o setupOpts) lud16Command() externalCmd {
+	return externalCmd{shell: o.lud16Cmd, argv: o.lud16CmdArgs}
+}
+
+// nsecCommand returns the command the new key should be piped to, if any.
+func (o setupOpts) nsecCommand() externalCmd {
+	return externalCmd{shell: o.nsecCmd, argv: o.nsecCmdArgs}
 }
 
 func parseSetupFlags(args []string) setupOpts {
@@ -761,6 +2070,83 @@ func parseSetupFlags(args []string) setupOpts {
 			}
 		case "--no-wallet":
 			opts.noWallet = true
+		case "--lud16-provider":
+			if i+1 < len(args) {
+				opts.lud16Provider = args[i+1]
+				i++
+			}
+		case "--lud16-cmd":
+			if i+1 < len(args) {
+				opts.lud16Cmd = args[i+1]
+				i++
+			}
+		case "--lud16-cmd-arg":
+			if i+1 < len(args) {
+				opts.lud16CmdArgs = append(opts.lud16CmdArgs, args[i+1])
+				i++
+			}
+		case "--nip05-provider":
+			if i+1 < len(args) {
+				opts.nip05Provider = args[i+1]
+				i++
+			}
+		case "--nip05-name":
+			if i+1 < len(args) {
+				opts.nip05Name = args[i+1]
+				i++
+			}
+		case "--import":
+			if i+1 < len(args) {
+				opts.importFile = args[i+1]
+				i++
+			}
+		case "--blossom-server":
+			if i+1 < len(args) {
+				opts.blossom = append(opts.blossom, args[i+1])
+				i++
+			}
+		case "--hello-file":
+			if i+1 < len(args) {
+				opts.helloFile = args[i+1]
+				i++
+			}
+		case "--resume":
+			opts.resume = true
+		case "--emoji-set":
+			if i+1 < len(args) {
+				opts.emojiSets = append(opts.emojiSets, args[i+1])
+				i++
+			}
+		case "--hello-at":
+			if i+1 < len(args) {
+				opts.helloAt = parseHelloAt("--hello-at", args[i+1], time.Now())
+				i++
+			}
+		case "--hello-expire":
+			if i+1 < len(args) {
+				opts.helloExpire = parseExpiry("--hello-expire", args[i+1])
+				i++
+			}
+		case "--mints-from":
+			if i+1 < len(args) {
+				opts.mintsFrom = args[i+1]
+				i++
+			}
+		case "--relays-from":
+			if i+1 < len(args) {
+				opts.relaysFrom = args[i+1]
+				i++
+			}
+		case "--template":
+			if i+1 < len(args) {
+				opts.templateFrom = args[i+1]
+				i++
+			}
+		case "--nwc":
+			if i+1 < len(args) {
+				opts.nwc = args[i+1]
+				i++
+			}
 		case "--quiet", "-q":
 			opts.quiet = true
 		case "--stdin":
@@ -770,13 +2156,71 @@ func parseSetupFlags(args []string) setupOpts {
 				opts.nsecCmd = args[i+1]
 				i++
 			}
+		case "--sec-cmd":
+			if i+1 < len(args) {
+				opts.secCmd = args[i+1]
+				i++
+			}
+		case "--sec-cmd-arg":
+			if i+1 < len(args) {
+				opts.secCmdArgs = append(opts.secCmdArgs, args[i+1])
+				i++
+			}
+		case "--keychain":
+			if i+1 < len(args) {
+				opts.keychain = args[i+1]
+				i++
+			}
+		case "--bunker":
+			if i+1 < len(args) {
+				opts.bunker = args[i+1]
+				i++
+			}
+		case "--nsec-cmd-arg":
+			if i+1 < len(args) {
+				opts.nsecCmdArgs = append(opts.nsecCmdArgs, args[i+1])
+				i++
+			}
+		case "--passphrase-fd":
+			if i+1 < len(args) {
+				opts.passphraseFD = args[i+1]
+				i++
+			}
 		case "--nsec-file":
 			if i+1 < len(args) {
 				opts.nsecFile = args[i+1]
 				i++
 			}
+		case "--ncryptsec":
+			opts.ncryptsec = true
+		case "--show-nsec":
+			opts.showNsec = true
+		case "--batch":
+			if i+1 < len(args) {
+				opts.batch = args[i+1]
+				i++
+			}
 		case "--discover":
 			opts.discover = true
+		case "--near":
+			if i+1 < len(args) {
+				opts.near = args[i+1]
+				opts.discover = true
+				i++
+			}
+		case "--seed-from-follows":
+			opts.seedFollows = true
+			opts.discover = true
+		case "--relay-cache-ttl":
+			if i+1 < len(args) {
+				opts.relayCacheTTL = args[i+1]
+				i++
+			}
+		case "--rate-limit":
+			if i+1 < len(args) {
+				opts.rateLimit = args[i+1]
+				i++
+			}
 		case "--dm-relays":
 			if i+1 < len(args) {
 				opts.dmRelays = strings.Split(args[i+1], ",")
@@ -784,6 +2228,11 @@ func parseSetupFlags(args []string) setupOpts {
 			}
 		case "--no-dm-relays":
 			opts.noDMRelays = true
+		case "--org":
+			if i+1 < len(args) {
+				opts.org = args[i+1]
+				i++
+			}
 		default:
 			if strings.HasPrefix(args[i], "-") {
 				fatal("unknown flag: %s (see nihao help)", args[i])
@@ -810,36 +2259,6 @@ func readStdin() string {
 	return ""
 }
 
-// writeNsecFile writes the nsec to a file with 0600 permissions.
-func writeNsecFile(path string, nsec string) error {
-	return os.WriteFile(path, []byte(nsec+"\n"), 0600)
-}
-
-// runNsecCmd pipes the nsec to an external command via stdin.
-// The command is executed through the shell (sh -c) so pipes and
-// redirections work. The nsec is written to the command's stdin
-// followed by a newline, then stdin is closed.
-func runNsecCmd(cmdStr string, nsec string) error {
-	cmd := exec.Command("sh", "-c", cmdStr)
-	cmd.Stdout = os.Stderr // show command output on stderr (not stdout, to avoid polluting --json)
-	cmd.Stderr = os.Stderr
-	stdin, err := cmd.StdinPipe()
-	if err != nil {
-		return fmt.Errorf("failed to create stdin pipe: %w", err)
-	}
-	if err := cmd.Start(); err != nil {
-		return fmt.Errorf("failed to start command: %w", err)
-	}
-	if _, err := fmt.Fprintln(stdin, nsec); err != nil {
-		return fmt.Errorf("failed to write nsec to command: %w", err)
-	}
-	stdin.Close()
-	if err := cmd.Wait(); err != nil {
-		return fmt.Errorf("command exited with error: %w", err)
-	}
-	return nil
-}
-
 func fatal(format string, args ...any) {
 	fmt.Fprintf(os.Stderr, "error: "+format+"\n", args...)
 	os.Exit(1)
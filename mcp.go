@@ -0,0 +1,261 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+)
+
+// mcpProtocolVersion is the Model Context Protocol revision nihao speaks
+// when the client doesn't ask for one.
+const mcpProtocolVersion = "2024-11-05"
+
+// mcpToolTimeout bounds one tool call. Setup publishes to many relays and
+// may register a lightning address, so it gets the most room.
+const mcpToolTimeout = 3 * time.Minute
+
+// mcpTool describes one tool in tools/list.
+type mcpTool struct {
+	Name        string         `json:"name"`
+	Description string         `json:"description"`
+	InputSchema map[string]any `json:"inputSchema"`
+}
+
+// mcpToolResult is the result of tools/call: the tool's JSON output as
+// text, flagged as an error if the tool failed.
+type mcpToolResult struct {
+	Content []mcpContent `json:"content"`
+	IsError bool         `json:"isError,omitempty"`
+}
+
+type mcpContent struct {
+	Type string `json:"type"`
+	Text string `json:"text"`
+}
+
+func mcpSchema(required []string, props map[string]any) map[string]any {
+	return map[string]any{"type": "object", "properties": props, "required": required}
+}
+
+var (
+	mcpString  = map[string]any{"type": "string"}
+	mcpStrings = map[string]any{"type": "array", "items": map[string]any{"type": "string"}}
+)
+
+// mcpTools are the tools `nihao mcp` offers. Each maps onto a CLI command
+// run with --json, so tools and commands can't drift apart.
+var mcpTools = []mcpTool{
+	{
+		Name:        "check",
+		Description: "Audit a Nostr identity's health: profile, NIP-05, lightning address, relay lists, DM relays, wallet. Returns the check result as JSON with a score and per-check pass/warn/fail.",
+		InputSchema: mcpSchema([]string{"target"}, map[string]any{
+			"target": map[string]any{"type": "string", "description": "npub, hex pubkey, or NIP-05 identifier"},
+			"relays": mcpStrings,
+		}),
+	},
+	{
+		Name:        "setup",
+		Description: "Create a new Nostr identity with sane defaults and publish its profile, relay lists, and wallet. The secret key is written to nsec_file and never returned.",
+		InputSchema: mcpSchema([]string{"name", "nsec_file"}, map[string]any{
+			"name":      mcpString,
+			"about":     mcpString,
+			"picture":   mcpString,
+			"nip05":     mcpString,
+			"lud16":     mcpString,
+			"relays":    mcpStrings,
+			"nsec_file": map[string]any{"type": "string", "description": "path to write the new secret key to (0600)"},
+		}),
+	},
+	{
+		Name:        "backup",
+		Description: "Export a Nostr identity's events (profile, follows, relay lists, nutzap info, wallet) as JSON.",
+		InputSchema: mcpSchema([]string{"target"}, map[string]any{
+			"target": map[string]any{"type": "string", "description": "npub, hex pubkey, or NIP-05 identifier"},
+			"relays": mcpStrings,
+		}),
+	},
+	{
+		Name:        "relay_score",
+		Description: "Probe relays for reachability, latency, and NIP-11 info, and score them from 0 to 1.",
+		InputSchema: mcpSchema([]string{"relays"}, map[string]any{
+			"relays": mcpStrings,
+		}),
+	},
+}
+
+// mcpCommandArgs turns a tool call into nihao command-line arguments.
+func mcpCommandArgs(tool string, raw json.RawMessage) ([]string, error) {
+	var a struct {
+		Target   string   `json:"target"`
+		Relays   []string `json:"relays"`
+		Name     string   `json:"name"`
+		About    string   `json:"about"`
+		Picture  string   `json:"picture"`
+		NIP05    string   `json:"nip05"`
+		LUD16    string   `json:"lud16"`
+		NsecFile string   `json:"nsec_file"`
+	}
+	if len(raw) > 0 {
+		if err := json.Unmarshal(raw, &a); err != nil {
+			return nil, fmt.Errorf("invalid arguments: %w", err)
+		}
+	}
+	// Arguments come from a model: never let one smuggle in a flag
+	for _, v := range []string{a.Target, a.Name} {
+		if strings.HasPrefix(v, "-") {
+			return nil, fmt.Errorf("invalid argument %q", v)
+		}
+	}
+
+	var args []string
+	switch tool {
+	case "check", "backup":
+		if a.Target == "" {
+			return nil, fmt.Errorf("target is required")
+		}
+		if tool == "check" {
+			args = []string{"check", a.Target, "--json"}
+		} else {
+			args = []string{"backup", a.Target, "--quiet"}
+		}
+	case "setup":
+		if a.Name == "" || a.NsecFile == "" {
+			return nil, fmt.Errorf("name and nsec_file are required")
+		}
+		args = []string{"setup", "--json", "--name", a.Name, "--nsec-file", a.NsecFile}
+		for _, f := range []struct{ flag, value string }{
+			{"--about", a.About}, {"--picture", a.Picture}, {"--nip05", a.NIP05}, {"--lud16", a.LUD16},
+		} {
+			if f.value != "" {
+				args = append(args, f.flag, f.value)
+			}
+		}
+	default:
+		return nil, fmt.Errorf("unknown tool %q", tool)
+	}
+	if len(a.Relays) > 0 {
+		args = append(args, "--relays", strings.Join(a.Relays, ","))
+	}
+	return args, nil
+}
+
+// mcpServer answers MCP requests. callTool runs one tool; it's a field so
+// tests can stub out the subprocess.
+type mcpServer struct {
+	callTool func(ctx context.Context, tool string, args json.RawMessage) mcpToolResult
+}
+
+// callToolCommand runs a tool as a nihao subprocess and returns its JSON
+// output. The commands exit non-zero for an unhealthy identity too, so
+// only a failure without output counts as a tool error.
+func callToolCommand(ctx context.Context, tool string, raw json.RawMessage) mcpToolResult {
+	if tool == "relay_score" {
+		var a struct {
+			Relays []string `json:"relays"`
+		}
+		if err := json.Unmarshal(raw, &a); err != nil || len(a.Relays) == 0 {
+			return mcpText("relays is required", true)
+		}
+		out, _ := json.MarshalIndent(ScoreRelays(ctx, a.Relays), "", "  ")
+		return mcpText(string(out), false)
+	}
+
+	args, err := mcpCommandArgs(tool, raw)
+	if err != nil {
+		return mcpText(err.Error(), true)
+	}
+	self, err := os.Executable()
+	if err != nil {
+		return mcpText(err.Error(), true)
+	}
+	ctx, cancel := context.WithTimeout(ctx, mcpToolTimeout)
+	defer cancel()
+	cmd := exec.CommandContext(ctx, self, args...)
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	err = cmd.Run()
+	if out := strings.TrimSpace(stdout.String()); out != "" && json.Valid([]byte(out)) {
+		return mcpText(out, false)
+	}
+	msg := strings.TrimSpace(stderr.String())
+	if msg == "" && err != nil {
+		msg = err.Error()
+	}
+	return mcpText(msg, true)
+}
+
+func mcpText(text string, isError bool) mcpToolResult {
+	return mcpToolResult{Content: []mcpContent{{Type: "text", Text: text}}, IsError: isError}
+}
+
+// handle answers one request. Notifications (no ID) get no response.
+func (s *mcpServer) handle(ctx context.Context, req rpcRequest) *rpcResponse {
+	if len(req.ID) == 0 {
+		return nil
+	}
+	resp := &rpcResponse{JSONRPC: "2.0", ID: req.ID}
+	switch req.Method {
+	case "initialize":
+		var params struct {
+			ProtocolVersion string `json:"protocolVersion"`
+		}
+		json.Unmarshal(req.Params, &params)
+		if params.ProtocolVersion == "" {
+			params.ProtocolVersion = mcpProtocolVersion
+		}
+		resp.Result = map[string]any{
+			"protocolVersion": params.ProtocolVersion,
+			"capabilities":    map[string]any{"tools": map[string]any{}},
+			"serverInfo":      map[string]any{"name": "nihao", "version": version},
+		}
+	case "ping":
+		resp.Result = map[string]any{}
+	case "tools/list":
+		resp.Result = map[string]any{"tools": mcpTools}
+	case "tools/call":
+		var params struct {
+			Name      string          `json:"name"`
+			Arguments json.RawMessage `json:"arguments"`
+		}
+		if err := json.Unmarshal(req.Params, &params); err != nil || params.Name == "" {
+			resp.Error = &rpcError{Code: rpcInvalidParams, Message: "tools/call needs a tool name"}
+			break
+		}
+		known := false
+		for _, t := range mcpTools {
+			known = known || t.Name == params.Name
+		}
+		if !known {
+			resp.Error = &rpcError{Code: rpcInvalidParams, Message: fmt.Sprintf("unknown tool %q", params.Name)}
+			break
+		}
+		resp.Result = s.callTool(ctx, params.Name, params.Arguments)
+	default:
+		resp.Error = &rpcError{Code: rpcMethodNotFound, Message: fmt.Sprintf("method not found: %s", req.Method)}
+	}
+	return resp
+}
+
+// serve answers requests from in, one at a time, until in is closed.
+func (s *mcpServer) serve(ctx context.Context, in io.Reader, out io.Writer) error {
+	return serveJSONRPC(ctx, in, out, s.handle, false)
+}
+
+// runMCP serves nihao's tools over stdio for MCP clients. Stdout carries
+// only protocol messages; the tools' own progress output is captured.
+func runMCP(args []string) {
+	for _, a := range args {
+		fatal("unknown flag: %s (see nihao help)", a)
+	}
+	s := &mcpServer{callTool: callToolCommand}
+	if err := s.serve(context.Background(), os.Stdin, os.Stdout); err != nil {
+		fatal("%s", err)
+	}
+}
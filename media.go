@@ -0,0 +1,479 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"fiatjaf.com/nostr"
+	"fiatjaf.com/nostr/nip19"
+)
+
+// maxMirrorSize caps how much of a profile image is downloaded for mirroring.
+const maxMirrorSize = 25 << 20 // 25 MB
+
+type mediaOpts struct {
+	keys       keySource
+	servers    []string
+	relays     []string
+	jsonOutput bool
+	quiet      bool
+	args       []string
+}
+
+// MirrorResult is the JSON output of `nihao media mirror`.
+type MirrorResult struct {
+	Npub      string          `json:"npub"`
+	Images    []MirroredImage `json:"images"`
+	Published bool            `json:"published"`
+	Verified  bool            `json:"verified"`
+}
+
+// MirroredImage is the outcome of re-hosting one profile image.
+type MirroredImage struct {
+	Field   string   `json:"field"` // "picture" or "banner"
+	From    string   `json:"from"`
+	To      string   `json:"to,omitempty"`
+	SHA256  string   `json:"sha256,omitempty"`
+	Mirrors []string `json:"mirrors,omitempty"`
+	Skipped string   `json:"skipped,omitempty"`
+	Error   string   `json:"error,omitempty"`
+}
+
+func parseMediaFlags(args []string) mediaOpts {
+	var opts mediaOpts
+	for i := 0; i < len(args); i++ {
+		if next, ok := opts.keys.keyFlag(args, i); ok {
+			i = next
+			continue
+		}
+		a := args[i]
+		switch {
+		case a == "--server" && i+1 < len(args):
+			i++
+			opts.servers = append(opts.servers, strings.TrimRight(args[i], "/"))
+		case a == "--relays" && i+1 < len(args):
+			i++
+			opts.relays = strings.Split(args[i], ",")
+		case a == "--json":
+			opts.jsonOutput = true
+		case a == "--quiet" || a == "-q":
+			opts.quiet = true
+		case !strings.HasPrefix(a, "-"):
+			opts.args = append(opts.args, a)
+		default:
+			fatal("unknown flag: %s (see nihao help)", a)
+		}
+	}
+	return opts
+}
+
+// runMediaMirror downloads the current picture and banner, uploads them to
+// the user's Blossom servers, and republishes kind 0 with the new URLs.
+func runMediaMirror(args []string) {
+	opts := parseMediaFlags(args)
+	logln := func(a ...any) {
+		if !opts.jsonOutput && !opts.quiet {
+			fmt.Println(a...)
+		}
+	}
+
+	if len(opts.args) > 0 {
+		fatal("usage: nihao media mirror [--server <url>] (--sec|--stdin|--sec-cmd ...)")
+	}
+	if !opts.keys.isSet() {
+		fatal("media mirror signs with your key: pass --sec, --stdin, --sec-cmd, or --bunker")
+	}
+	signer, _, err := opts.keys.signer(context.Background())
+	if err != nil {
+		fatal("%s", err)
+	}
+	pk := signerPubkey(context.Background(), signer)
+	npub := nip19.EncodeNpub(pk)
+	logln(fmt.Sprintf("nihao media 🖼️  mirror %s", npub))
+	logln()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Minute)
+	defer cancel()
+
+	// One pool for reading the profile and publishing the update
+	readRelays := opts.relays
+	if len(readRelays) == 0 {
+		readRelays = defaultRelays
+	}
+	pool := NewRelayPool(readRelays, opts.quiet || opts.jsonOutput)
+	defer pool.Close()
+	checkRelays := pool.CheckRelays()
+	if len(checkRelays) == 0 {
+		fatal("could not connect to any relay")
+	}
+
+	_, profileEvt := fetchKindFrom(ctx, checkRelays, pk, 0)
+	if profileEvt == nil {
+		fatal("no kind 0 found, nothing to mirror")
+	}
+	servers := opts.servers
+	if len(servers) == 0 {
+		if _, evt := fetchKindFrom(ctx, checkRelays, pk, 10063); evt != nil {
+			servers = blossomServers(evt)
+		}
+	}
+	if len(servers) == 0 {
+		fatal("no Blossom servers: publish a kind 10063 list or pass --server <url>")
+	}
+
+	// Keep every profile field we don't touch
+	var content map[string]any
+	if err := json.Unmarshal([]byte(profileEvt.Content), &content); err != nil {
+		fatal("kind 0 content isn't valid JSON: %s", err)
+	}
+
+	result := MirrorResult{Npub: npub, Images: []MirroredImage{}}
+	changed := false
+	failed := false
+	for _, field := range []string{"picture", "banner"} {
+		src, _ := content[field].(string)
+		if src == "" {
+			continue
+		}
+		img := MirroredImage{Field: field, From: src}
+		logln(fmt.Sprintf("📥 %s: %s", field, src))
+
+		if host := hostedOn(src, servers); host != "" {
+			img.Skipped = "already on " + host
+			logln(fmt.Sprintf("   ⊘ already on %s", host))
+		} else if data, contentType, err := downloadImage(ctx, src); err != nil {
+			img.Error = err.Error()
+			logln(fmt.Sprintf("   ✗ download failed: %s", err))
+		} else {
+			img.SHA256, img.Mirrors = mirrorBlob(ctx, signer, servers, data, contentType, func(server, msg string) {
+				logln(fmt.Sprintf("   %s %s", msg, server))
+			})
+			if len(img.Mirrors) > 0 {
+				img.To = img.Mirrors[0]
+				content[field] = img.To
+				changed = true
+			} else {
+				img.Error = "upload failed on every server"
+			}
+		}
+		if img.Error != "" {
+			failed = true
+		}
+		result.Images = append(result.Images, img)
+		logln()
+	}
+
+	if changed {
+		newContent, _ := json.Marshal(content)
+		evt := nostr.Event{
+			CreatedAt: nostr.Now(),
+			Kind:      0,
+			Tags:      profileEvt.Tags,
+			Content:   string(newContent),
+		}
+		signWith(signer, &evt)
+
+		if _, relayEvt := fetchKindFrom(ctx, checkRelays, pk, 10002); relayEvt != nil {
+			pool.Add(mergeRelayURLs(readRelays, writeRelays(relayEvt))...)
+		}
+
+		logln("👤 Publishing updated profile (kind 0)...")
+		pool.Publish(evt)
+		result.Published = true
+		logln()
+
+		// Verify: relays return the new profile and the new URLs serve the blobs
+		logln("🔎 Verifying...")
+		result.Verified = true
+		if _, got := fetchKindFrom(ctx, checkRelays, pk, 0); got == nil || got.ID != evt.ID {
+			result.Verified = false
+			logln("   ✗ relays don't return the updated profile yet")
+		}
+		for _, img := range result.Images {
+			if img.To == "" {
+				continue
+			}
+			if probeBlossomBlob(ctx, img.To) {
+				logln(fmt.Sprintf("   ✓ %s serves %s", img.Field, img.To))
+			} else {
+				result.Verified = false
+				logln(fmt.Sprintf("   ✗ %s not retrievable at %s", img.Field, img.To))
+			}
+		}
+		logln()
+	} else {
+		logln("Nothing to update.")
+	}
+
+	if opts.jsonOutput {
+		out, _ := json.MarshalIndent(result, "", "  ")
+		fmt.Println(string(out))
+	}
+	if failed || (result.Published && !result.Verified) {
+		os.Exit(1)
+	}
+}
+
+// MediaServerBlobs is one server's answer in `nihao media list`.
+type MediaServerBlobs struct {
+	Server string           `json:"server"`
+	Blobs  []blobDescriptor `json:"blobs"`
+	Error  string           `json:"error,omitempty"`
+}
+
+// MediaDeletion is one server's answer in `nihao media delete`.
+type MediaDeletion struct {
+	Server  string `json:"server"`
+	Deleted bool   `json:"deleted"`
+	Error   string `json:"error,omitempty"`
+}
+
+// mediaServersFor returns --server, or else the servers in the user's
+// kind 10063, connecting to relays only in that case.
+func mediaServersFor(ctx context.Context, opts mediaOpts, pk nostr.PubKey) []string {
+	if len(opts.servers) > 0 {
+		return opts.servers
+	}
+	readRelays := opts.relays
+	if len(readRelays) == 0 {
+		readRelays = defaultRelays
+	}
+	checkRelays := connectCheckRelays(ctx, readRelays)
+	defer func() {
+		for _, cr := range checkRelays {
+			cr.relay.Close()
+		}
+	}()
+	var servers []string
+	if _, evt := fetchKindFrom(ctx, checkRelays, pk, 10063); evt != nil {
+		servers = blossomServers(evt)
+	}
+	if len(servers) == 0 {
+		fatal("no Blossom servers: publish a kind 10063 list or pass --server <url>")
+	}
+	return servers
+}
+
+// runMediaList lists the blobs stored under the user's key on each of
+// their Blossom servers, with a signed list authorization for servers that
+// don't list publicly.
+func runMediaList(args []string) {
+	opts := parseMediaFlags(args)
+	logln := func(a ...any) {
+		if !opts.jsonOutput && !opts.quiet {
+			fmt.Println(a...)
+		}
+	}
+	if len(opts.args) > 0 {
+		fatal("usage: nihao media list [--server <url>] (--sec|--stdin|--sec-cmd ...)")
+	}
+	if !opts.keys.isSet() {
+		fatal("media list signs with your key: pass --sec, --stdin, --sec-cmd, or --bunker")
+	}
+	signer, _, err := opts.keys.signer(context.Background())
+	if err != nil {
+		fatal("%s", err)
+	}
+	pk := signerPubkey(context.Background(), signer)
+	logln(fmt.Sprintf("nihao media 🖼️  list %s", nip19.EncodeNpub(pk)))
+	logln()
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+	listings := []MediaServerBlobs{}
+	failures := 0
+	for _, server := range mediaServersFor(ctx, opts, pk) {
+		blobs, err := blossomList(ctx, signer, server, pk)
+		listing := MediaServerBlobs{Server: server, Blobs: blobs}
+		if listing.Blobs == nil {
+			listing.Blobs = []blobDescriptor{}
+		}
+		if err != nil {
+			failures++
+			listing.Error = err.Error()
+			logln(fmt.Sprintf("📦 %s ✗ (%s)", server, err))
+		} else {
+			var total int64
+			for _, b := range blobs {
+				total += b.Size
+			}
+			logln(fmt.Sprintf("📦 %s — %d blob(s), %s", server, len(blobs), formatSize(total)))
+			for _, b := range blobs {
+				logln(fmt.Sprintf("   %s  %9s  %s", b.SHA256, formatSize(b.Size), valueOr(b.Type, "unknown type")))
+			}
+		}
+		logln()
+		listings = append(listings, listing)
+	}
+
+	if opts.jsonOutput {
+		out, _ := json.MarshalIndent(listings, "", "  ")
+		fmt.Println(string(out))
+	}
+	if failures == len(listings) {
+		os.Exit(1)
+	}
+}
+
+// runMediaDelete removes one blob, by hash or URL, from the user's Blossom
+// servers with a signed delete authorization.
+func runMediaDelete(args []string) {
+	opts := parseMediaFlags(args)
+	logln := func(a ...any) {
+		if !opts.jsonOutput && !opts.quiet {
+			fmt.Println(a...)
+		}
+	}
+	if len(opts.args) != 1 {
+		fatal("usage: nihao media delete <sha256|url> [--server <url>] (--sec|--stdin|--sec-cmd ...)")
+	}
+	hash := strings.ToLower(opts.args[0])
+	if h := blossomHash(opts.args[0]); h != "" {
+		hash = h
+	}
+	if _, err := hex.DecodeString(hash); err != nil || len(hash) != 64 {
+		fatal("%q isn't a sha256 hash or a Blossom URL", opts.args[0])
+	}
+	if !opts.keys.isSet() {
+		fatal("media delete signs with your key: pass --sec, --stdin, --sec-cmd, or --bunker")
+	}
+	signer, _, err := opts.keys.signer(context.Background())
+	if err != nil {
+		fatal("%s", err)
+	}
+	pk := signerPubkey(context.Background(), signer)
+	logln(fmt.Sprintf("nihao media 🖼️  delete %s", hash))
+	logln()
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+	results := []MediaDeletion{}
+	deleted := 0
+	for _, server := range mediaServersFor(ctx, opts, pk) {
+		d := MediaDeletion{Server: server}
+		if err := blossomDelete(ctx, signer, server, hash); err != nil {
+			d.Error = err.Error()
+			logln(fmt.Sprintf("   ✗ %s (%s)", server, err))
+		} else {
+			d.Deleted = true
+			deleted++
+			logln(fmt.Sprintf("   ✓ %s", server))
+		}
+		results = append(results, d)
+	}
+	logln()
+
+	if opts.jsonOutput {
+		out, _ := json.MarshalIndent(results, "", "  ")
+		fmt.Println(string(out))
+	}
+	if deleted == 0 {
+		os.Exit(1)
+	}
+}
+
+// hostedOn returns the host of src if it's one of the given servers.
+func hostedOn(src string, servers []string) string {
+	u, err := url.Parse(src)
+	if err != nil {
+		return ""
+	}
+	for _, s := range servers {
+		if su, err := url.Parse(s); err == nil && strings.EqualFold(su.Host, u.Host) {
+			return su.Host
+		}
+	}
+	return ""
+}
+
+// downloadImage fetches an image into memory, refusing anything larger
+// than maxMirrorSize.
+func downloadImage(ctx context.Context, src string) ([]byte, string, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", src, nil)
+	if err != nil {
+		return nil, "", err
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != 200 {
+		return nil, "", fmt.Errorf("HTTP %d", resp.StatusCode)
+	}
+
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxMirrorSize+1))
+	if err != nil {
+		return nil, "", err
+	}
+	if len(data) > maxMirrorSize {
+		return nil, "", fmt.Errorf("larger than %s", formatSize(maxMirrorSize))
+	}
+	contentType := resp.Header.Get("Content-Type")
+	if contentType == "" {
+		contentType = http.DetectContentType(data)
+	}
+	return data, contentType, nil
+}
+
+// mirrorBlob uploads data to every server and returns its sha256 and the
+// URLs of the servers that stored it intact. report is called per server.
+func mirrorBlob(ctx context.Context, signer nostr.Signer, servers []string, data []byte, contentType string, report func(server, msg string)) (string, []string) {
+	sum := sha256.Sum256(data)
+	hash := hex.EncodeToString(sum[:])
+
+	var urls []string
+	for _, server := range servers {
+		bd, err := blossomUpload(ctx, signer, server, data, contentType)
+		switch {
+		case err != nil:
+			report(server, fmt.Sprintf("✗ (%s)", err))
+		case bd.SHA256 != hash:
+			report(server, "✗ (server returned a different hash)")
+		default:
+			report(server, "✓")
+			urls = append(urls, bd.URL)
+		}
+	}
+	return hash, urls
+}
+
+// writeRelays returns the relays a kind 10002 list marks for writing
+// (bare entries count as read+write).
+func writeRelays(evt *nostr.Event) []string {
+	var urls []string
+	for _, tag := range evt.Tags {
+		if len(tag) >= 2 && tag[0] == "r" && (len(tag) < 3 || tag[2] == "write") {
+			urls = append(urls, tag[1])
+		}
+	}
+	return urls
+}
+
+// mergeRelayURLs appends the relays in extra that aren't already in base.
+func mergeRelayURLs(base, extra []string) []string {
+	seen := make(map[string]bool)
+	merged := make([]string, 0, len(base)+len(extra))
+	for _, list := range [][]string{base, extra} {
+		for _, u := range list {
+			key := normalizeRelayURL(u)
+			if key == "" {
+				key = u
+			}
+			if !seen[key] {
+				seen[key] = true
+				merged = append(merged, u)
+			}
+		}
+	}
+	return merged
+}
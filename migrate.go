@@ -0,0 +1,356 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	"fiatjaf.com/nostr"
+	"fiatjaf.com/nostr/keyer"
+	"fiatjaf.com/nostr/nip04"
+	"fiatjaf.com/nostr/nip19"
+	"fiatjaf.com/nostr/nip60"
+)
+
+// migrateKinds are the replaceable events nihao migrate copies: profile,
+// follows, relay lists, NIP-51 lists, and the NIP-60 wallet.
+var migrateKinds = []int{0, 3, 10002, 10050, 10063, 10000, 10001, 10003, 10015, 10030, 10019, 17375}
+
+// migrateSetKinds are the addressable NIP-51 sets copied, every d tag of
+// each.
+var migrateSetKinds = []nostr.Kind{30000, 30002, 30003, 30015, 30030}
+
+// migrateEncrypted are the kinds whose content is encrypted to their
+// author (private list items, wallet data), so it has to be re-encrypted
+// to the new key.
+var migrateEncrypted = map[nostr.Kind]bool{10000: true, 10003: true, 30000: true, 30002: true, 30003: true, 17375: true, 30078: true}
+
+type migrateOpts struct {
+	from       keySource
+	to         keySource
+	relays     []string
+	announce   bool // publish a notice from the old key pointing to the new one
+	dryRun     bool
+	force      bool // the new key may already have a profile
+	jsonOutput bool
+	quiet      bool
+}
+
+// MigrateResult is the JSON output of `nihao migrate`.
+type MigrateResult struct {
+	From      string          `json:"from"`
+	To        string          `json:"to"`
+	Events    []MigratedEvent `json:"events"`
+	Tokens    int             `json:"tokens"` // NIP-60 tokens (kind 7375) copied
+	Announced bool            `json:"announced"`
+	Warnings  []string        `json:"warnings,omitempty"`
+	DryRun    bool            `json:"dry_run,omitempty"`
+}
+
+// MigratedEvent is one event copied to the new key.
+type MigratedEvent struct {
+	Kind      int    `json:"kind"`
+	D         string `json:"d,omitempty"`
+	Published bool   `json:"published"`
+	Error     string `json:"error,omitempty"`
+}
+
+func parseMigrateFlags(args []string) migrateOpts {
+	var opts migrateOpts
+	for i := 0; i < len(args); i++ {
+		a := args[i]
+		switch {
+		case a == "--from-sec" && i+1 < len(args):
+			i++
+			opts.from.sec = args[i]
+		case a == "--from-sec-cmd" && i+1 < len(args):
+			i++
+			opts.from.secCmd.shell = args[i]
+		case a == "--from-sec-cmd-arg" && i+1 < len(args):
+			i++
+			opts.from.secCmd.argv = append(opts.from.secCmd.argv, args[i])
+		case a == "--to-sec" && i+1 < len(args):
+			i++
+			opts.to.sec = args[i]
+		case a == "--to-sec-cmd" && i+1 < len(args):
+			i++
+			opts.to.secCmd.shell = args[i]
+		case a == "--to-sec-cmd-arg" && i+1 < len(args):
+			i++
+			opts.to.secCmd.argv = append(opts.to.secCmd.argv, args[i])
+		case a == "--passphrase-fd" && i+1 < len(args):
+			i++
+			opts.from.passphraseFD = args[i]
+			opts.to.passphraseFD = args[i]
+		case a == "--relays" && i+1 < len(args):
+			i++
+			opts.relays = strings.Split(args[i], ",")
+		case a == "--announce":
+			opts.announce = true
+		case a == "--dry-run":
+			opts.dryRun = true
+		case a == "--force":
+			opts.force = true
+		case a == "--json":
+			opts.jsonOutput = true
+		case a == "--quiet" || a == "-q":
+			opts.quiet = true
+		default:
+			fatal("unknown flag: %s (see nihao help)", a)
+		}
+	}
+	return opts
+}
+
+// migrateEvent re-creates an event of the old key for the new one: the
+// same kind, tags, and content, with encrypted content re-encrypted (NIP-04
+// content is upgraded to NIP-44) and `a` references to the old key's own
+// events pointed at the new key.
+func migrateEvent(ctx context.Context, evt nostr.Event, from, to nostr.SecretKey) (nostr.Event, error) {
+	oldPK, pk := from.Public(), to.Public()
+	next := nostr.Event{CreatedAt: nostr.Now(), Kind: evt.Kind, Content: evt.Content, Tags: nostr.Tags{}}
+	for _, tag := range evt.Tags {
+		tag = tag.Clone()
+		if len(tag) >= 2 && tag[0] == "a" {
+			tag[1] = strings.Replace(tag[1], ":"+oldPK.Hex()+":", ":"+pk.Hex()+":", 1)
+		}
+		next.Tags = append(next.Tags, tag)
+	}
+
+	if migrateEncrypted[evt.Kind] && evt.Content != "" {
+		plaintext, err := decryptOwn(ctx, evt.Content, from)
+		if err != nil {
+			return nostr.Event{}, fmt.Errorf("can't decrypt kind %d content: %w", evt.Kind, err)
+		}
+		if next.Content, err = keyer.NewPlainKeySigner(to).Encrypt(ctx, plaintext, pk); err != nil {
+			return nostr.Event{}, err
+		}
+	}
+	next.Sign(to)
+	return next, nil
+}
+
+// decryptOwn decrypts content a key encrypted to itself, NIP-44 or legacy
+// NIP-04.
+func decryptOwn(ctx context.Context, content string, sk nostr.SecretKey) (string, error) {
+	if strings.Contains(content, "?iv=") {
+		shared, err := nip04.ComputeSharedSecret(sk.Public(), sk)
+		if err != nil {
+			return "", err
+		}
+		return nip04.Decrypt(content, shared)
+	}
+	return keyer.NewPlainKeySigner(sk).Decrypt(ctx, content, sk.Public())
+}
+
+// latestPerD keeps the newest event of each kind and d tag.
+func latestPerD(events []nostr.Event) []nostr.Event {
+	latest := make(map[string]nostr.Event)
+	for _, evt := range events {
+		key := fmt.Sprintf("%d:%s", evt.Kind, evt.Tags.GetD())
+		if prev, ok := latest[key]; !ok || evt.CreatedAt > prev.CreatedAt {
+			latest[key] = evt
+		}
+	}
+	var out []nostr.Event
+	for _, evt := range latest {
+		out = append(out, evt)
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].Kind != out[j].Kind {
+			return out[i].Kind < out[j].Kind
+		}
+		return out[i].Tags.GetD() < out[j].Tags.GetD()
+	})
+	return out
+}
+
+// runMigrate copies an identity to a new key — after a compromise, say —
+// and optionally announces the move from the old key.
+func runMigrate(args []string) {
+	opts := parseMigrateFlags(args)
+	logln := func(a ...any) {
+		if !opts.jsonOutput && !opts.quiet {
+			fmt.Println(a...)
+		}
+	}
+
+	if !opts.from.isSet() || !opts.to.isSet() {
+		fatal("usage: nihao migrate --from-sec <old> --to-sec <new> [--announce] [--dry-run] (or --from-sec-cmd/--to-sec-cmd)")
+	}
+	fromSK, _, err := opts.from.load()
+	if err != nil {
+		fatal("old key: %s", err)
+	}
+	toSK, _, err := opts.to.load()
+	if err != nil {
+		fatal("new key: %s", err)
+	}
+	if fromSK == toSK {
+		fatal("the old and new keys are the same")
+	}
+	fromPK, toPK := fromSK.Public(), toSK.Public()
+	result := MigrateResult{From: nip19.EncodeNpub(fromPK), To: nip19.EncodeNpub(toPK), Events: []MigratedEvent{}, DryRun: opts.dryRun}
+	logln(fmt.Sprintf("nihao migrate 🚚 %s → %s", result.From, result.To))
+	logln()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Minute)
+	defer cancel()
+
+	readRelays := opts.relays
+	if len(readRelays) == 0 {
+		readRelays = defaultRelays
+	}
+	pool := NewRelayPool(readRelays, opts.quiet || opts.jsonOutput)
+	defer pool.Close()
+	checkRelays := pool.CheckRelays()
+	if len(checkRelays) == 0 {
+		fatal("could not connect to any relay")
+	}
+
+	// Don't clobber an identity that's already in use
+	if _, existing := fetchKindFrom(ctx, checkRelays, toPK, 0); existing != nil && !opts.force {
+		fatal("%s already has a profile; pass --force to overwrite its events", result.To)
+	}
+
+	logln("🔍 Fetching the old identity...")
+	var events []nostr.Event
+	for _, kind := range migrateKinds {
+		if _, evt := fetchKindFrom(ctx, checkRelays, fromPK, kind); evt != nil {
+			events = append(events, *evt)
+		}
+	}
+	events = append(events, latestPerD(fetchAllFrom(ctx, checkRelays, nostr.Filter{
+		Authors: []nostr.PubKey{fromPK},
+		Kinds:   migrateSetKinds,
+	}))...)
+	if _, nwcEvt := fetchLatestFrom(ctx, checkRelays, nostr.Filter{
+		Authors: []nostr.PubKey{fromPK},
+		Kinds:   []nostr.Kind{30078},
+		Tags:    nostr.TagMap{"d": []string{nwcAppDataTag}},
+	}); nwcEvt != nil {
+		events = append(events, *nwcEvt)
+	}
+	if len(events) == 0 {
+		fatal("nothing found for %s on %s", result.From, strings.Join(readRelays, ", "))
+	}
+
+	// Publish where the copied relay list says, too
+	for _, evt := range events {
+		if evt.Kind == 10002 {
+			pool.Add(mergeRelayURLs(readRelays, writeRelays(&evt))...)
+		}
+		if evt.Kind == 0 {
+			var meta ProfileMetadata
+			json.Unmarshal([]byte(evt.Content), &meta)
+			if meta.NIP05 != "" {
+				result.Warnings = append(result.Warnings, fmt.Sprintf("the profile's NIP-05 %s still points to the old key; update nostr.json on its domain", meta.NIP05))
+			}
+		}
+	}
+
+	// The wallet's unspent tokens, as the wallet sees them (spent ones are
+	// deleted or superseded)
+	var tokens []nip60.Token
+	if slices.ContainsFunc(events, func(evt nostr.Event) bool { return evt.Kind == 17375 }) {
+		signer := keyer.NewPlainKeySigner(fromSK)
+		loader := nostr.NewPool(nostr.PoolOptions{AuthRequiredHandler: signer.SignEvent})
+		w := nip60.LoadWallet(ctx, signer, loader, pool.URLs(), nip60.WalletOptions{})
+		select {
+		case <-w.Stable:
+			tokens = w.Tokens
+		case <-ctx.Done():
+			fatal("timed out loading the wallet from %s", strings.Join(pool.URLs(), ", "))
+		}
+		if len(tokens) > 0 {
+			result.Warnings = append(result.Warnings, "the old key can still spend the wallet's tokens; if it was compromised, swap them (nihao wallet send, then receive) with the new key")
+		}
+	}
+	logln(fmt.Sprintf("   %d events, %d wallet tokens", len(events), len(tokens)))
+	logln()
+
+	to := keyer.NewPlainKeySigner(toSK)
+	publish := func(evt nostr.Event, label string) bool {
+		if opts.dryRun {
+			logln(fmt.Sprintf("   would copy %s", label))
+			return false
+		}
+		logln(fmt.Sprintf("📤 Publishing %s...", label))
+		ok := false
+		for _, r := range pool.Publish(evt) {
+			ok = ok || r.OK
+		}
+		logln()
+		return ok
+	}
+
+	failed := 0
+	for _, evt := range events {
+		me := MigratedEvent{Kind: int(evt.Kind), D: evt.Tags.GetD()}
+		label := fmt.Sprintf("kind %d", evt.Kind)
+		if me.D != "" {
+			label += fmt.Sprintf(" %q", me.D)
+		}
+		next, err := migrateEvent(ctx, evt, fromSK, toSK)
+		if err != nil {
+			me.Error = err.Error()
+			logln(fmt.Sprintf("⚠️  %s: %s", label, err))
+		} else if me.Published = publish(next, label); !me.Published && !opts.dryRun {
+			me.Error = "no relay accepted it"
+		}
+		if me.Error != "" {
+			failed++
+		}
+		result.Events = append(result.Events, me)
+	}
+
+	for _, token := range tokens {
+		content, _ := json.Marshal(nip60.Token{Mint: token.Mint, Proofs: token.Proofs})
+		ciphertext, err := to.Encrypt(ctx, string(content), toPK)
+		if err != nil {
+			fatal("can't encrypt a wallet token: %s", err)
+		}
+		evt := nostr.Event{CreatedAt: nostr.Now(), Kind: 7375, Tags: nostr.Tags{}, Content: ciphertext}
+		evt.Sign(toSK)
+		if publish(evt, fmt.Sprintf("wallet token (kind 7375, %d sats at %s)", token.Proofs.Amount(), token.Mint)) {
+			result.Tokens++
+		} else if !opts.dryRun {
+			failed++
+		}
+	}
+
+	if opts.announce && failed == 0 {
+		notice := nostr.Event{
+			CreatedAt: nostr.Now(),
+			Kind:      1,
+			Tags:      nostr.Tags{{"p", toPK.Hex()}},
+			Content:   fmt.Sprintf("This key is retired and no longer in use. I've moved to nostr:%s — please follow me there, and don't send zaps or DMs to this key.", result.To),
+		}
+		notice.Sign(fromSK)
+		result.Announced = publish(notice, "migration notice from the old key (kind 1)")
+	} else if opts.announce {
+		logln("⚠️  Not announcing the move: some events didn't copy")
+	}
+
+	for _, w := range result.Warnings {
+		logln("⚠️  " + w)
+	}
+	if opts.jsonOutput {
+		out, _ := json.MarshalIndent(result, "", "  ")
+		fmt.Println(string(out))
+	} else if opts.dryRun {
+		logln()
+		logln("Dry run: nothing was published. Run again without --dry-run to migrate.")
+	} else {
+		logln()
+		logln(fmt.Sprintf("✅ Copied %d/%d events and %d wallet tokens to %s", len(result.Events)-failed, len(result.Events), result.Tokens, result.To))
+	}
+	if failed > 0 {
+		os.Exit(1)
+	}
+}
@@ -7,6 +7,10 @@ import (
 	"net/http"
 	"slices"
 	"strings"
+	"time"
+
+	"fiatjaf.com/nostr"
+	"fiatjaf.com/nostr/nip19"
 )
 
 // MintInfo holds the result of validating a Cashu mint.
@@ -16,19 +20,19 @@ type MintInfo struct {
 	Version       string   `json:"version,omitempty"`
 	Reachable     bool     `json:"reachable"`
 	HasSatKeyset  bool     `json:"has_sat_keyset"`
-	SupportsP2PK  bool     `json:"supports_p2pk"`  // NUT-11
-	SupportsMint  bool     `json:"supports_mint"`   // NUT-04
-	SupportsMelt  bool     `json:"supports_melt"`   // NUT-05
-	Valid         bool     `json:"valid"`            // all checks pass
+	SupportsP2PK  bool     `json:"supports_p2pk"` // NUT-11
+	SupportsMint  bool     `json:"supports_mint"` // NUT-04
+	SupportsMelt  bool     `json:"supports_melt"` // NUT-05
+	Valid         bool     `json:"valid"`         // all checks pass
 	SupportedNuts []string `json:"supported_nuts,omitempty"`
 	Error         string   `json:"error,omitempty"`
 }
 
 // mintInfoResponse represents the /v1/info response from a Cashu mint.
 type mintInfoResponse struct {
-	Name    string                       `json:"name"`
-	Version string                       `json:"version"`
-	Nuts    map[string]json.RawMessage   `json:"nuts"`
+	Name    string                     `json:"name"`
+	Version string                     `json:"version"`
+	Nuts    map[string]json.RawMessage `json:"nuts"`
 }
 
 // mintKeysResponse represents the /v1/keys response.
@@ -74,9 +78,9 @@ func validateMint(ctx context.Context, mintURL string) MintInfo {
 	}
 
 	// Check required NUTs
-	_, info.SupportsMint = mintResp.Nuts["4"]   // NUT-04: mint tokens
-	_, info.SupportsMelt = mintResp.Nuts["5"]   // NUT-05: melt tokens
-	_, info.SupportsP2PK = mintResp.Nuts["11"]  // NUT-11: P2PK spending conditions
+	_, info.SupportsMint = mintResp.Nuts["4"]  // NUT-04: mint tokens
+	_, info.SupportsMelt = mintResp.Nuts["5"]  // NUT-05: melt tokens
+	_, info.SupportsP2PK = mintResp.Nuts["11"] // NUT-11: P2PK spending conditions
 
 	// Step 2: Fetch /v1/keys — check for active sat keyset
 	keysResp, err := httpGetJSON[mintKeysResponse](ctx, mintURL+"/v1/keys")
@@ -182,3 +186,66 @@ func httpGetJSON[T any](ctx context.Context, url string) (*T, error) {
 
 	return &result, nil
 }
+
+// MintsFrom reports which mints setup --mints-from took from another
+// identity's nutzap info. Which of them passed validation shows in the
+// wallet's mints.
+type MintsFrom struct {
+	Npub  string   `json:"npub"`
+	Mints []string `json:"mints"`
+}
+
+// loadMintsFrom fetches target's kind 10019 from relays (default: the
+// default relays) and returns the mints it lists, to seed a new wallet
+// with the same ones. They're validated with the rest when the wallet is
+// set up.
+func loadMintsFrom(target string, relays []string, quiet bool) (*MintsFrom, error) {
+	pk, err := resolveTarget(target, quiet)
+	if err != nil {
+		return nil, err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if len(relays) == 0 {
+		relays = defaultRelays
+	}
+	checkRelays := connectCheckRelays(ctx, relays)
+	if len(checkRelays) == 0 {
+		return nil, fmt.Errorf("could not connect to any relay")
+	}
+	_, nutzapEvt := fetchKindFrom(ctx, checkRelays, pk, 10019)
+	for _, cr := range checkRelays {
+		cr.relay.Close()
+	}
+	from := &MintsFrom{Npub: nip19.EncodeNpub(pk)}
+	if nutzapEvt != nil {
+		from.Mints = mintsFromNutzapInfo(nutzapEvt)
+	}
+	if len(from.Mints) == 0 {
+		return nil, fmt.Errorf("%s has no mints in a nutzap info event (kind 10019)", from.Npub)
+	}
+	return from, nil
+}
+
+// mintsFromNutzapInfo lists the mint URLs of a kind 10019, deduplicated.
+// Mints restricted to units other than sat are skipped: the wallet only
+// holds sats.
+func mintsFromNutzapInfo(evt *nostr.Event) []string {
+	var mints []string
+	for _, tag := range evt.Tags {
+		if len(tag) < 2 || tag[0] != "mint" {
+			continue
+		}
+		if len(tag) > 2 && !slices.Contains(tag[2:], "sat") {
+			continue
+		}
+		u := strings.TrimRight(strings.TrimSpace(tag[1]), "/")
+		if !strings.HasPrefix(u, "https://") && !strings.HasPrefix(u, "http://") {
+			continue
+		}
+		if !slices.Contains(mints, u) {
+			mints = append(mints, u)
+		}
+	}
+	return mints
+}
@@ -0,0 +1,151 @@
+package main
+
+import (
+	"context"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// nip05HostInfo describes how well a NIP-05 domain serves nostr.json.
+// Web clients verify NIP-05 from the browser and fail silently on slow
+// hosts, bad certificates, or redirects, so these matter even when a
+// plain server-side fetch works.
+type nip05HostInfo struct {
+	Domain     string    `json:"domain"`
+	Reachable  bool      `json:"reachable"`
+	Error      string    `json:"error,omitempty"`
+	LatencyMs  int64     `json:"latency_ms"`
+	Redirects  int       `json:"redirects"`
+	CertExpiry time.Time `json:"cert_expiry,omitempty"`
+	IPv4       bool      `json:"ipv4"`
+	IPv6       bool      `json:"ipv6"`
+	TLS        *TLSInfo  `json:"tls,omitempty"`
+
+	families ServiceFamilies // for the address_families check
+}
+
+// splitNIP05 returns the local part and domain of a NIP-05 identifier.
+// A bare domain is treated as _@domain.
+func splitNIP05(identifier string) (name, domain string) {
+	if strings.Contains(identifier, "@") {
+		parts := strings.SplitN(identifier, "@", 2)
+		return parts[0], parts[1]
+	}
+	return "_", identifier
+}
+
+// probeNIP05Host fetches nostr.json for name@domain, following redirects so
+// they can be counted, and dials the domain over IPv4 and IPv6 separately.
+func probeNIP05Host(ctx context.Context, name, domain string) nip05HostInfo {
+	info := nip05HostInfo{Domain: domain}
+
+	client := &http.Client{
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			info.Redirects = len(via)
+			if len(via) >= 10 {
+				return errors.New("too many redirects")
+			}
+			return nil
+		},
+	}
+
+	reqURL := fmt.Sprintf("https://%s/.well-known/nostr.json?name=%s", domain, name)
+	req, err := http.NewRequestWithContext(ctx, "GET", reqURL, nil)
+	if err != nil {
+		info.Error = err.Error()
+		return info
+	}
+
+	start := time.Now()
+	resp, err := client.Do(req)
+	info.LatencyMs = time.Since(start).Milliseconds()
+	if err != nil {
+		info.Error = describeHostError(err)
+	} else {
+		resp.Body.Close()
+		info.Reachable = resp.StatusCode == 200
+		if !info.Reachable {
+			info.Error = fmt.Sprintf("HTTP %d", resp.StatusCode)
+		}
+		if resp.TLS != nil && len(resp.TLS.PeerCertificates) > 0 {
+			info.CertExpiry = resp.TLS.PeerCertificates[0].NotAfter
+		}
+	}
+
+	// A failed fetch only says the certificate didn't verify; the
+	// certificate itself says why
+	info.TLS = probeTLS(ctx, domain, "443")
+	if err != nil && info.TLS.Error == "" {
+		if p := info.TLS.problems(time.Now()); len(p) > 0 {
+			info.Error = strings.Join(p, ", ")
+		}
+	}
+
+	info.families = probeFamilies(ctx, "nip05", domain, "443")
+	info.IPv4 = info.families.IPv4 == familyOK
+	info.IPv6 = info.families.IPv6 == familyOK
+
+	return info
+}
+
+// describeHostError shortens the error chains net/http produces into
+// something that fits on a check line.
+func describeHostError(err error) string {
+	var certErr *x509.CertificateInvalidError
+	var hostErr x509.HostnameError
+	var authErr x509.UnknownAuthorityError
+	switch {
+	case errors.As(err, &certErr):
+		return "TLS certificate invalid: " + certErr.Error()
+	case errors.As(err, &hostErr):
+		return "TLS certificate doesn't match the domain"
+	case errors.As(err, &authErr):
+		return "TLS certificate from an unknown authority"
+	case errors.Is(err, context.DeadlineExceeded):
+		return "timed out"
+	}
+	return err.Error()
+}
+
+// assessNIP05Host turns probe results into a check status and detail.
+func assessNIP05Host(info nip05HostInfo, now time.Time) (string, string) {
+	if !info.Reachable {
+		return "fail", fmt.Sprintf("%s: %s", info.Domain, info.Error)
+	}
+
+	ipStatus := func(ok bool) string {
+		if ok {
+			return "✓"
+		}
+		return "✗"
+	}
+	detail := fmt.Sprintf("%s — %dms, IPv4 %s, IPv6 %s", info.Domain, info.LatencyMs, ipStatus(info.IPv4), ipStatus(info.IPv6))
+	if !info.CertExpiry.IsZero() {
+		detail += ", TLS valid until " + info.CertExpiry.Format("2006-01-02")
+	}
+
+	var problems []string
+	if !info.CertExpiry.IsZero() {
+		if days := int(info.CertExpiry.Sub(now).Hours() / 24); days < 14 {
+			problems = append(problems, fmt.Sprintf("TLS cert expires in %d day(s)", days))
+		}
+	}
+	if info.Redirects > 0 {
+		problems = append(problems, fmt.Sprintf("%d redirect(s), which NIP-05 forbids", info.Redirects))
+	}
+	if info.LatencyMs > 2000 {
+		problems = append(problems, "slow response, web clients may time out")
+	}
+	if !info.IPv4 && info.IPv6 {
+		problems = append(problems, "IPv6 only")
+	}
+
+	if len(problems) > 0 {
+		return "warn", detail + " (" + strings.Join(problems, "; ") + ")"
+	}
+	return "pass", detail
+}
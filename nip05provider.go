@@ -0,0 +1,140 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+
+	"fiatjaf.com/nostr"
+)
+
+// NIP05Provider registers a NIP-05 name for a new identity's pubkey.
+type NIP05Provider interface {
+	Name() string
+	Register(ctx context.Context, kr nostr.Keyer, name string) (*NIP05Registration, error)
+}
+
+// NIP05Registration is the identifier a provider registered.
+type NIP05Registration struct {
+	Provider string `json:"provider"`
+	NIP05    string `json:"nip05"`
+	Verified bool   `json:"verified"` // resolves to the new pubkey
+}
+
+// nip05Providers are the built-in providers, by --nip05-provider name. Any
+// https:// URL also works as a provider speaking the same API.
+var nip05Providers = map[string]NIP05Provider{
+	"nostrcheck": nip98NIP05Provider{name: "nostrcheck", apiURL: "https://nostrcheck.me/api/v2/register", domain: "nostrcheck.me"},
+}
+
+// nip05ProviderNames lists the --nip05-provider values, for help and error
+// messages.
+func nip05ProviderNames() []string {
+	var names []string
+	for name := range nip05Providers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// lookupNIP05Provider resolves a --nip05-provider value: a built-in name,
+// or the registration URL of a self-hosted server.
+func lookupNIP05Provider(name string) (NIP05Provider, error) {
+	if p, ok := nip05Providers[name]; ok {
+		return p, nil
+	}
+	if u, err := url.Parse(name); err == nil && u.Scheme == "https" && u.Host != "" {
+		return nip98NIP05Provider{name: u.Host, apiURL: name, domain: u.Hostname()}, nil
+	}
+	return nil, fmt.Errorf("unknown NIP-05 provider %q (choose from %s, or give an https:// registration URL)", name, strings.Join(nip05ProviderNames(), ", "))
+}
+
+// nip98NIP05Provider registers names with a POST of {"username", "pubkey",
+// "domain"} authenticated by a NIP-98 event signed with the new key, which
+// proves the caller owns the pubkey. The server answers 2xx on success,
+// optionally with {"nip05": ...}, and 409 if the name is taken.
+type nip98NIP05Provider struct {
+	name   string
+	apiURL string
+	domain string
+}
+
+func (p nip98NIP05Provider) Name() string { return p.name }
+
+func (p nip98NIP05Provider) Register(ctx context.Context, kr nostr.Keyer, name string) (*NIP05Registration, error) {
+	pk, err := kr.GetPublicKey(ctx)
+	if err != nil {
+		return nil, err
+	}
+	base := nip05LocalPart(name)
+	if base == "" {
+		base = "nihao" + randomHex(3)
+	}
+
+	// Names are first come, first served: retry once with a suffix
+	var lastErr error
+	for _, username := range []string{base, base + randomHex(2)} {
+		body, _ := json.Marshal(map[string]string{"username": username, "pubkey": pk.Hex(), "domain": p.domain})
+		req, err := http.NewRequestWithContext(ctx, "POST", p.apiURL, bytes.NewReader(body))
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Set("Content-Type", "application/json")
+		auth, err := nip98Header(ctx, kr, "POST", p.apiURL, body)
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Set("Authorization", auth)
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			return nil, fmt.Errorf("%s registration failed: %w", p.name, err)
+		}
+		var answer struct {
+			NIP05 string `json:"nip05"`
+		}
+		json.NewDecoder(resp.Body).Decode(&answer)
+		resp.Body.Close()
+
+		switch {
+		case resp.StatusCode >= 200 && resp.StatusCode < 300:
+			nip05 := answer.NIP05
+			if nip05 == "" {
+				nip05 = username + "@" + p.domain
+			}
+			return &NIP05Registration{Provider: p.name, NIP05: nip05}, nil
+		case resp.StatusCode == http.StatusConflict:
+			lastErr = fmt.Errorf("%s registration failed: %s is taken", p.name, username)
+		default:
+			return nil, fmt.Errorf("%s registration failed: HTTP %d", p.name, resp.StatusCode)
+		}
+	}
+	return nil, lastErr
+}
+
+// providerNIP05 registers a name with the provider and checks that it
+// resolves to the new pubkey before it goes into the profile.
+func providerNIP05(ctx context.Context, providerName string, kr nostr.Keyer, name string) (*NIP05Registration, error) {
+	provider, err := lookupNIP05Provider(providerName)
+	if err != nil {
+		return nil, err
+	}
+	reg, err := provider.Register(ctx, kr, name)
+	if err != nil {
+		return nil, err
+	}
+	pk, err := kr.GetPublicKey(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if !verifyNIP05(ctx, reg.NIP05, pk) {
+		return reg, fmt.Errorf("%s doesn't resolve to the new pubkey", reg.NIP05)
+	}
+	reg.Verified = true
+	return reg, nil
+}
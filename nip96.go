@@ -0,0 +1,135 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+
+	"fiatjaf.com/nostr"
+)
+
+// nip96ServerInfo is the result of probing a NIP-96 file server.
+type nip96ServerInfo struct {
+	URL       string `json:"url"`
+	Alive     bool   `json:"alive"`
+	Uploads   bool   `json:"accepts_uploads"`
+	APIURL    string `json:"api_url,omitempty"`
+	Delegated string `json:"delegated_to_url,omitempty"`
+	Error     string `json:"error,omitempty"`
+}
+
+// nip96Servers extracts server URLs from a kind 10096 file server list.
+func nip96Servers(evt *nostr.Event) []string {
+	var servers []string
+	for _, tag := range evt.Tags {
+		if len(tag) >= 2 && tag[0] == "server" {
+			servers = append(servers, strings.TrimRight(tag[1], "/"))
+		}
+	}
+	return servers
+}
+
+// probeNIP96 fetches a server's /.well-known/nostr/nip96.json. A server
+// accepts uploads if it advertises an api_url, or delegates to another
+// server that does.
+func probeNIP96(ctx context.Context, server string) nip96ServerInfo {
+	info := nip96ServerInfo{URL: server}
+
+	req, err := http.NewRequestWithContext(ctx, "GET", server+"/.well-known/nostr/nip96.json", nil)
+	if err != nil {
+		info.Error = "invalid URL"
+		return info
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		info.Error = "unreachable"
+		return info
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != 200 {
+		info.Error = fmt.Sprintf("HTTP %d", resp.StatusCode)
+		return info
+	}
+
+	var doc struct {
+		APIURL         string `json:"api_url"`
+		DelegatedToURL string `json:"delegated_to_url"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&doc); err != nil {
+		info.Error = "invalid nip96.json"
+		return info
+	}
+	info.Alive = true
+	info.APIURL = doc.APIURL
+	info.Delegated = doc.DelegatedToURL
+
+	switch {
+	case doc.APIURL != "":
+		info.Uploads = true
+	case doc.DelegatedToURL != "":
+		delegated := probeNIP96(ctx, strings.TrimRight(doc.DelegatedToURL, "/"))
+		info.Uploads = delegated.Uploads
+		if !delegated.Uploads {
+			info.Error = "delegated server doesn't accept uploads"
+		}
+	default:
+		info.Error = "no api_url"
+	}
+	return info
+}
+
+// checkNIP96Servers probes every server in a kind 10096 list and records a
+// "nip96_servers" check.
+func checkNIP96Servers(ctx context.Context, result *CheckResult, evt *nostr.Event) {
+	servers := nip96Servers(evt)
+	if len(servers) == 0 {
+		result.addCheck("nip96_servers", "warn", "kind 10096 found but no server tags")
+		return
+	}
+
+	infos := make([]nip96ServerInfo, len(servers))
+	var wg sync.WaitGroup
+	for i, s := range servers {
+		wg.Add(1)
+		go func(i int, s string) {
+			defer wg.Done()
+			infos[i] = probeNIP96(ctx, s)
+		}(i, s)
+	}
+	wg.Wait()
+
+	status, detail := assessNIP96Servers(infos)
+	result.addCheck("nip96_servers", status, detail)
+}
+
+// assessNIP96Servers summarizes probe results into a check status and detail.
+func assessNIP96Servers(infos []nip96ServerInfo) (string, string) {
+	working := 0
+	var parts []string
+	for _, info := range infos {
+		host := info.URL
+		if u, err := url.Parse(info.URL); err == nil && u.Host != "" {
+			host = u.Host
+		}
+		if info.Uploads {
+			working++
+			parts = append(parts, host+" ✓")
+		} else {
+			parts = append(parts, fmt.Sprintf("%s ✗ (%s)", host, info.Error))
+		}
+	}
+
+	detail := fmt.Sprintf("%d/%d server(s) accepting uploads: %s", working, len(infos), strings.Join(parts, ", "))
+	switch {
+	case working == len(infos):
+		return "pass", detail
+	case working > 0:
+		return "warn", detail
+	default:
+		return "fail", detail
+	}
+}
@@ -0,0 +1,186 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+
+	"fiatjaf.com/nostr"
+)
+
+// nip98Event builds the kind 27235 event that authorizes one HTTP request:
+// the exact URL, the method, and the body's hash if there is one.
+func nip98Event(method, reqURL string, body []byte, now nostr.Timestamp) nostr.Event {
+	evt := nostr.Event{
+		Kind:      27235,
+		CreatedAt: now,
+		Tags:      nostr.Tags{{"u", reqURL}, {"method", method}},
+	}
+	if len(body) > 0 {
+		sum := sha256.Sum256(body)
+		evt.Tags = append(evt.Tags, nostr.Tag{"payload", hex.EncodeToString(sum[:])})
+	}
+	return evt
+}
+
+// nip98Header signs a nip98Event into an Authorization header.
+func nip98Header(ctx context.Context, signer nostr.Signer, method, reqURL string, body []byte) (string, error) {
+	evt := nip98Event(method, reqURL, body, nostr.Now())
+	if err := signer.SignEvent(ctx, &evt); err != nil {
+		return "", fmt.Errorf("signing NIP-98 authorization: %w", err)
+	}
+	data, _ := json.Marshal(evt)
+	return "Nostr " + base64.StdEncoding.EncodeToString(data), nil
+}
+
+// httpAuth tracks NIP-98 authentication for the HTTP requests one check
+// makes. Some endpoints (paid NIP-05 providers, NIP-96 servers, relay
+// management APIs) answer 401 to anonymous requests; with a signer we
+// retry those once, signed, and without one we remember that the check
+// saw a 401 it couldn't get past. It's the HTTP side of relayAuth.
+type httpAuth struct {
+	signer nostr.Signer
+
+	mu     sync.Mutex
+	status map[string]string // host → "authenticated", "auth-required", "auth-failed"
+}
+
+type httpAuthKey struct{}
+
+// withHTTPAuth makes requests sent with ctx answer 401s through auth.
+func withHTTPAuth(ctx context.Context, auth *httpAuth) context.Context {
+	return context.WithValue(ctx, httpAuthKey{}, auth)
+}
+
+// httpAuthFrom returns the tracker requests with ctx report to, or nil.
+func httpAuthFrom(ctx context.Context) *httpAuth {
+	auth, _ := ctx.Value(httpAuthKey{}).(*httpAuth)
+	return auth
+}
+
+// record notes how a host's 401 was resolved. A success doesn't overwrite
+// an earlier failure on the same host.
+func (a *httpAuth) record(host, status string) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.status == nil {
+		a.status = make(map[string]string)
+	}
+	if a.status[host] == "" || a.status[host] == "authenticated" {
+		a.status[host] = status
+	}
+}
+
+// nip98Transport retries requests that get a 401 with a NIP-98
+// Authorization header, when the request's context carries an httpAuth
+// with a signer. Relay WebSocket handshakes are left to NIP-42.
+type nip98Transport struct {
+	base http.RoundTripper
+}
+
+func (t *nip98Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	resp, err := t.base.RoundTrip(req)
+	if err != nil || resp.StatusCode != http.StatusUnauthorized {
+		return resp, err
+	}
+	auth := httpAuthFrom(req.Context())
+	if auth == nil || req.Header.Get("Authorization") != "" || strings.EqualFold(req.Header.Get("Upgrade"), "websocket") {
+		return resp, nil
+	}
+	host := strings.ToLower(req.URL.Host)
+	if auth.signer == nil || (req.Body != nil && req.GetBody == nil) {
+		auth.record(host, "auth-required")
+		return resp, nil
+	}
+
+	var body []byte
+	if req.GetBody != nil {
+		rc, err := req.GetBody()
+		if err != nil {
+			return resp, nil
+		}
+		body, err = io.ReadAll(rc)
+		rc.Close()
+		if err != nil {
+			return resp, nil
+		}
+	}
+	header, err := nip98Header(req.Context(), auth.signer, req.Method, req.URL.String(), body)
+	if err != nil {
+		auth.record(host, "auth-failed")
+		return resp, nil
+	}
+	retry := req.Clone(req.Context())
+	if req.GetBody != nil {
+		retry.Body, _ = req.GetBody()
+	}
+	retry.Header.Set("Authorization", header)
+	signed, err := t.base.RoundTrip(retry)
+	if err != nil {
+		return resp, nil
+	}
+	io.Copy(io.Discard, resp.Body)
+	resp.Body.Close()
+	if signed.StatusCode == http.StatusUnauthorized || signed.StatusCode == http.StatusForbidden {
+		auth.record(host, "auth-failed")
+	} else {
+		auth.record(host, "authenticated")
+	}
+	return signed, nil
+}
+
+// installNIP98Auth puts the NIP-98 retry in front of the default
+// transport. It goes last, so the signed retry is paced and proxied too.
+func installNIP98Auth() {
+	http.DefaultTransport = &nip98Transport{base: http.DefaultTransport}
+}
+
+// checkHTTPAuth adds an "http_auth" check when any endpoint the check
+// fetched answered 401, so a login wall doesn't read as a broken service.
+func checkHTTPAuth(result *CheckResult, auth *httpAuth) {
+	auth.mu.Lock()
+	defer auth.mu.Unlock()
+	if len(auth.status) == 0 {
+		return
+	}
+	result.HTTPAuth = make(map[string]string)
+	var authed, required, failed []string
+	for host, status := range auth.status {
+		result.HTTPAuth[host] = status
+		switch status {
+		case "authenticated":
+			authed = append(authed, host)
+		case "auth-required":
+			required = append(required, host)
+		case "auth-failed":
+			failed = append(failed, host)
+		}
+	}
+	sort.Strings(authed)
+	sort.Strings(required)
+	sort.Strings(failed)
+
+	var parts []string
+	if len(authed) > 0 {
+		parts = append(parts, "NIP-98 authenticated to "+strings.Join(authed, ", "))
+	}
+	if len(required) > 0 {
+		parts = append(parts, "HTTP auth required by "+strings.Join(required, ", ")+" — pass --sec to sign NIP-98 requests")
+	}
+	if len(failed) > 0 {
+		parts = append(parts, "NIP-98 auth refused by "+strings.Join(failed, ", "))
+	}
+	if len(required) == 0 && len(failed) == 0 {
+		result.addCheck("http_auth", "pass", strings.Join(parts, "; "))
+		return
+	}
+	result.addCheck("http_auth", "warn", strings.Join(parts, "; ")+" (checks against those hosts may be incomplete)")
+}
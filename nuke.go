@@ -0,0 +1,474 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"runtime"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"fiatjaf.com/nostr"
+	"fiatjaf.com/nostr/nip19"
+)
+
+// nukeMax caps how many events nuke discovers on each relay.
+const nukeMax = 20000
+
+// nukeDeleteBatch is how many events one deletion request (kind 5) names,
+// which keeps each request under common relay size limits.
+const nukeDeleteBatch = 200
+
+// nukeSettle is how long nuke gives relays to act on the deletions and
+// vanish requests before checking what they still serve.
+var nukeSettle = 5 * time.Second
+
+// nukeBlanked are the lists nuke overwrites with empty ones rather than
+// deleting, so clients that cached them see an empty identity instead of
+// a stale one.
+var nukeBlanked = map[nostr.Kind]bool{0: true, 3: true, 10002: true}
+
+type nukeOpts struct {
+	keys       keySource
+	relays     []string
+	reason     string // content of the vanish requests
+	confirm    string // --confirm <npub>, instead of the prompts
+	understand bool   // --i-understand
+	dryRun     bool
+	jsonOutput bool
+	quiet      bool
+}
+
+// NukeResult is the JSON output of `nihao nuke`.
+type NukeResult struct {
+	Npub       string      `json:"npub"`
+	Discovered int         `json:"discovered"` // events found across all relays
+	Deletions  int         `json:"deletions"`  // deletion requests (kind 5) at least one relay accepted
+	Blanked    []int       `json:"blanked"`    // kinds overwritten with empty events
+	Relays     []NukeRelay `json:"relays"`
+	Remaining  int         `json:"remaining"` // discovered events still served afterwards
+	DryRun     bool        `json:"dry_run,omitempty"`
+}
+
+// NukeRelay is one relay's part in the teardown.
+type NukeRelay struct {
+	URL       string `json:"url"`
+	Found     int    `json:"found"`
+	Vanish    bool   `json:"vanish"` // accepted the NIP-62 request to vanish
+	Error     string `json:"error,omitempty"`
+	Remaining int    `json:"remaining"`
+}
+
+func parseNukeFlags(args []string) nukeOpts {
+	var opts nukeOpts
+	for i := 0; i < len(args); i++ {
+		if next, ok := opts.keys.keyFlag(args, i); ok {
+			i = next
+			continue
+		}
+		a := args[i]
+		switch {
+		case a == "--relays" && i+1 < len(args):
+			i++
+			opts.relays = strings.Split(args[i], ",")
+		case a == "--reason" && i+1 < len(args):
+			i++
+			opts.reason = args[i]
+		case a == "--confirm" && i+1 < len(args):
+			i++
+			opts.confirm = args[i]
+		case a == "--i-understand":
+			opts.understand = true
+		case a == "--dry-run":
+			opts.dryRun = true
+		case a == "--json":
+			opts.jsonOutput = true
+		case a == "--quiet" || a == "-q":
+			opts.quiet = true
+		default:
+			fatal("unknown flag: %s (see nihao help)", a)
+		}
+	}
+	return opts
+}
+
+// discoverOwnEvents pages through everything pk published on each relay.
+// It returns the events, deduplicated, and how many each relay holds.
+func discoverOwnEvents(ctx context.Context, relays []checkRelay, pk nostr.PubKey) ([]nostr.Event, map[string]int) {
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	byID := make(map[nostr.ID]nostr.Event)
+	found := make(map[string]int)
+	for _, cr := range relays {
+		wg.Add(1)
+		go func(cr checkRelay) {
+			defer wg.Done()
+			events, _ := pageEvents(ctx, cr, nostr.Filter{Authors: []nostr.PubKey{pk}}, nukeMax)
+			mu.Lock()
+			defer mu.Unlock()
+			found[cr.url] = len(events)
+			for _, evt := range events {
+				byID[evt.ID] = evt
+			}
+		}(cr)
+	}
+	wg.Wait()
+
+	events := make([]nostr.Event, 0, len(byID))
+	for _, evt := range byID {
+		events = append(events, evt)
+	}
+	sort.Slice(events, func(i, j int) bool { return events[i].CreatedAt > events[j].CreatedAt })
+	return events, found
+}
+
+// nukeDeletions builds the unsigned deletion requests (NIP-09) for events:
+// an "e" tag for each, an "a" tag for replaceable and addressable ones, and
+// a "k" tag per kind, at most nukeDeleteBatch events per request. Blanked
+// lists, deletions, and vanish requests are left out.
+func nukeDeletions(events []nostr.Event) []nostr.Event {
+	var deletions []nostr.Event
+	var tags nostr.Tags
+	kinds := make(map[nostr.Kind]bool)
+	count := 0
+	flush := func() {
+		if count == 0 {
+			return
+		}
+		sorted := make([]int, 0, len(kinds))
+		for k := range kinds {
+			sorted = append(sorted, int(k))
+		}
+		sort.Ints(sorted)
+		for _, k := range sorted {
+			tags = append(tags, nostr.Tag{"k", fmt.Sprint(k)})
+		}
+		deletions = append(deletions, nostr.Event{CreatedAt: nostr.Now(), Kind: 5, Tags: tags})
+		tags, kinds, count = nil, make(map[nostr.Kind]bool), 0
+	}
+	seenAddr := make(map[string]bool)
+	for _, evt := range events {
+		if nukeBlanked[evt.Kind] || evt.Kind == 5 || evt.Kind == 62 || evt.Kind.IsEphemeral() {
+			continue
+		}
+		tags = append(tags, nostr.Tag{"e", evt.ID.Hex()})
+		if evt.Kind.IsReplaceable() || evt.Kind.IsAddressable() {
+			addr := fmt.Sprintf("%d:%s:%s", evt.Kind, evt.PubKey.Hex(), evt.Tags.GetD())
+			if !seenAddr[addr] {
+				seenAddr[addr] = true
+				tags = append(tags, nostr.Tag{"a", addr})
+			}
+		}
+		kinds[evt.Kind] = true
+		if count++; count == nukeDeleteBatch {
+			flush()
+		}
+	}
+	flush()
+	return deletions
+}
+
+// nukeBlanks are the empty profile, follow list, and relay list published
+// over the old ones.
+func nukeBlanks() []nostr.Event {
+	return []nostr.Event{
+		{CreatedAt: nostr.Now(), Kind: 0, Content: "{}", Tags: nostr.Tags{}},
+		{CreatedAt: nostr.Now(), Kind: 3, Tags: nostr.Tags{}},
+		{CreatedAt: nostr.Now(), Kind: 10002, Tags: nostr.Tags{}},
+	}
+}
+
+// vanishRequest is the NIP-62 request to vanish for one relay. Each relay
+// gets its own, naming only itself, so it doesn't learn the others.
+func vanishRequest(url, reason string) nostr.Event {
+	return nostr.Event{CreatedAt: nostr.Now(), Kind: 62, Tags: nostr.Tags{{"relay", url}}, Content: reason}
+}
+
+// confirmNuke asks on the terminal, twice, whether to go ahead: first for
+// the word NUKE, then for the npub being torn down.
+func confirmNuke(npub string) error {
+	ttyPath := "/dev/tty"
+	if runtime.GOOS == "windows" {
+		ttyPath = "CONIN$"
+	}
+	tty, err := os.Open(ttyPath)
+	if err != nil {
+		return fmt.Errorf("no terminal to confirm on; pass --confirm %s", npub)
+	}
+	defer tty.Close()
+	reader := bufio.NewReader(tty)
+	ask := func(prompt, want string) error {
+		fmt.Fprint(os.Stderr, prompt)
+		line, _ := reader.ReadString('\n')
+		if strings.TrimSpace(line) != want {
+			return fmt.Errorf("not confirmed; nothing was published")
+		}
+		return nil
+	}
+	if err := ask("💣 This can't be undone. Type NUKE to continue: ", "NUKE"); err != nil {
+		return err
+	}
+	return ask("💣 Type the npub to tear down: ", npub)
+}
+
+// runNuke tears an identity down: it blanks the profile and lists, asks
+// relays to delete everything else it published, sends every relay a
+// request to vanish, and checks what they still serve.
+func runNuke(args []string) {
+	opts := parseNukeFlags(args)
+	logln := func(a ...any) {
+		if !opts.jsonOutput && !opts.quiet {
+			fmt.Println(a...)
+		}
+	}
+
+	if !opts.keys.isSet() {
+		fatal("usage: nihao nuke --i-understand (--sec|--stdin|--sec-cmd|--bunker ...) [--relays <r1,r2,...>] [--reason <text>] [--dry-run]")
+	}
+	if !opts.understand && !opts.dryRun {
+		fatal("nuke deletes your whole identity and can't be undone; pass --i-understand (or --dry-run to see what it would do)")
+	}
+	kr, _, err := opts.keys.signer(context.Background())
+	if err != nil {
+		fatal("%s", err)
+	}
+	pk := signerPubkey(context.Background(), kr)
+	result := NukeResult{Npub: nip19.EncodeNpub(pk), Blanked: []int{}, Relays: []NukeRelay{}, DryRun: opts.dryRun}
+	if opts.confirm != "" && opts.confirm != result.Npub {
+		fatal("--confirm %s doesn't match the key's npub %s", opts.confirm, result.Npub)
+	}
+	logln(fmt.Sprintf("nihao nuke 💣 %s", result.Npub))
+	logln()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
+	defer cancel()
+
+	// Every relay the identity might be on: the ones given (or the
+	// defaults), plus all of its relay list and DM relays
+	readRelays := opts.relays
+	if len(readRelays) == 0 {
+		readRelays = defaultRelays
+	}
+	pool := NewRelayPool(readRelays, opts.quiet || opts.jsonOutput)
+	defer pool.Close()
+	if len(pool.CheckRelays()) == 0 {
+		fatal("could not connect to any relay")
+	}
+	for _, kind := range []int{10002, 10050} {
+		if _, evt := fetchKindFrom(ctx, pool.CheckRelays(), pk, kind); evt != nil {
+			for _, tag := range evt.Tags {
+				if len(tag) >= 2 && (tag[0] == "r" || tag[0] == "relay") {
+					if url := normalizeRelayURL(tag[1]); url != "" {
+						pool.Add(url)
+					}
+				}
+			}
+		}
+	}
+	checkRelays := pool.CheckRelays()
+	enableAuth(checkRelays, kr)
+
+	logln(fmt.Sprintf("🔍 Finding your events on %d relays...", len(checkRelays)))
+	events, found := discoverOwnEvents(ctx, checkRelays, pk)
+	result.Discovered = len(events)
+	byKind := make(map[int]int)
+	tokens := 0
+	for _, evt := range events {
+		byKind[int(evt.Kind)]++
+		if evt.Kind == 7375 {
+			tokens++
+		}
+	}
+	for _, url := range pool.URLs() {
+		nr := NukeRelay{URL: url, Found: found[url]}
+		if _, ok := found[url]; !ok {
+			nr.Error = "unreachable"
+		}
+		result.Relays = append(result.Relays, nr)
+	}
+	kinds := make([]int, 0, len(byKind))
+	for k := range byKind {
+		kinds = append(kinds, k)
+	}
+	sort.Ints(kinds)
+	for _, k := range kinds {
+		logln(fmt.Sprintf("   %5d × %s (kind %d)", byKind[k], kindName(k), k))
+	}
+	for _, nr := range result.Relays {
+		if nr.Error != "" {
+			logln(fmt.Sprintf("   ⚠️  %s: %s", nr.URL, nr.Error))
+		}
+	}
+	logln(fmt.Sprintf("   %d events on %d relays", len(events), len(found)))
+	if tokens > 0 {
+		logln(fmt.Sprintf("⚠️  %d of them are wallet tokens (kind 7375): the ecash in them is lost unless you spend or send it first", tokens))
+	}
+	logln()
+
+	deletions := nukeDeletions(events)
+	if opts.dryRun {
+		logln(fmt.Sprintf("Dry run: would blank your profile, follow list, and relay list, publish %d deletion requests, and ask %d relays to vanish.", len(deletions), len(pool.URLs())))
+		logln("Run again with --i-understand to do it.")
+		if opts.jsonOutput {
+			out, _ := json.MarshalIndent(result, "", "  ")
+			fmt.Println(string(out))
+		}
+		return
+	}
+	if opts.confirm == "" {
+		if err := confirmNuke(result.Npub); err != nil {
+			fatal("%s", err)
+		}
+		logln()
+	}
+
+	for _, evt := range nukeBlanks() {
+		logln(fmt.Sprintf("🧹 Blanking your %s (kind %d)...", kindName(int(evt.Kind)), evt.Kind))
+		signWith(kr, &evt)
+		if nukePublish(ctx, pool, evt, logln) > 0 {
+			result.Blanked = append(result.Blanked, int(evt.Kind))
+		}
+		logln()
+	}
+
+	for i, evt := range deletions {
+		logln(fmt.Sprintf("🗑️  Deletion request %d/%d (kind 5, %d events)...", i+1, len(deletions), len(slices.Collect(evt.Tags.FindAll("e")))))
+		signWith(kr, &evt)
+		if nukePublish(ctx, pool, evt, logln) > 0 {
+			result.Deletions++
+		}
+		logln()
+	}
+
+	logln("👋 Requesting to vanish (NIP-62)...")
+	for i := range result.Relays {
+		nr := &result.Relays[i]
+		evt := vanishRequest(nr.URL, opts.reason)
+		signWith(kr, &evt)
+		relay, err := pool.relay(nr.URL)
+		if err == nil {
+			pubCtx, pubCancel := context.WithTimeout(ctx, 8*time.Second)
+			err = relay.Publish(pubCtx, evt)
+			pubCancel()
+		}
+		if nr.Vanish = err == nil; nr.Vanish {
+			logln(fmt.Sprintf("   ✓ %s", nr.URL))
+		} else {
+			nr.Error = err.Error()
+			logln(fmt.Sprintf("   ✗ %s (%s)", nr.URL, err))
+		}
+	}
+	logln()
+
+	// What the relays still serve of what was there before
+	logln("🔍 Checking what's left...")
+	time.Sleep(nukeSettle)
+	old := make(map[nostr.ID]bool, len(events))
+	for _, evt := range events {
+		old[evt.ID] = true
+	}
+	remaining := nukeRemaining(ctx, pool.URLs(), kr, old)
+	for i := range result.Relays {
+		nr := &result.Relays[i]
+		n, ok := remaining[nr.URL]
+		switch {
+		case !ok:
+			logln(fmt.Sprintf("   ? %s (couldn't reconnect to check)", nr.URL))
+		case n == 0:
+			logln(fmt.Sprintf("   ✓ %s holds none of your events", nr.URL))
+		default:
+			logln(fmt.Sprintf("   ⚠️  %s still holds %d of your events", nr.URL, n))
+		}
+		nr.Remaining = n
+		result.Remaining += n
+	}
+
+	if opts.jsonOutput {
+		out, _ := json.MarshalIndent(result, "", "  ")
+		fmt.Println(string(out))
+	} else {
+		logln()
+		if result.Remaining == 0 {
+			logln(fmt.Sprintf("✅ %s is gone from every relay that answered", result.Npub))
+		} else {
+			logln(fmt.Sprintf("⚠️  %d events are still out there; relays may take a while, or ignore deletion requests", result.Remaining))
+		}
+		logln("   Copies on relays nihao doesn't know about, and in clients' caches, may remain.")
+	}
+	if result.Remaining > 0 {
+		os.Exit(1)
+	}
+}
+
+// nukePublish sends evt to every relay the teardown found and returns how
+// many took it. Nothing is held back by relay purpose, as setup's
+// PublishRouted does: paid, inbox, and search relays hold the user's
+// events too.
+func nukePublish(ctx context.Context, pool *RelayPool, evt nostr.Event, logln func(...any)) int {
+	urls := pool.URLs()
+	errs := make([]error, len(urls))
+	var wg sync.WaitGroup
+	for i, url := range urls {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			relay, err := pool.relay(url)
+			if err == nil {
+				pubCtx, pubCancel := context.WithTimeout(ctx, 8*time.Second)
+				err = relay.Publish(pubCtx, evt)
+				pubCancel()
+			}
+			errs[i] = err
+		}()
+	}
+	wg.Wait()
+	accepted := 0
+	for i, url := range urls {
+		if errs[i] == nil {
+			accepted++
+			logln(fmt.Sprintf("   ✓ %s", url))
+		} else {
+			logln(fmt.Sprintf("   ✗ %s (%s)", url, errs[i]))
+		}
+	}
+	return accepted
+}
+
+// nukeRemaining reconnects to each relay and counts how many of the old
+// events it still serves. Relays it can't reach are missing from the map.
+func nukeRemaining(ctx context.Context, urls []string, kr nostr.Signer, old map[nostr.ID]bool) map[string]int {
+	pk := signerPubkey(ctx, kr)
+	relays := connectCheckRelays(ctx, urls)
+	enableAuth(relays, kr)
+	defer func() {
+		for _, cr := range relays {
+			cr.relay.Close()
+		}
+	}()
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	remaining := make(map[string]int)
+	for _, cr := range relays {
+		wg.Add(1)
+		go func(cr checkRelay) {
+			defer wg.Done()
+			events, _ := pageEvents(ctx, cr, nostr.Filter{Authors: []nostr.PubKey{pk}}, nukeMax)
+			n := 0
+			for _, evt := range events {
+				if old[evt.ID] {
+					n++
+				}
+			}
+			mu.Lock()
+			remaining[cr.url] = n
+			mu.Unlock()
+		}(cr)
+	}
+	wg.Wait()
+	return remaining
+}
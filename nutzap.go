@@ -0,0 +1,130 @@
+package main
+
+import (
+	"encoding/hex"
+	"fmt"
+	"slices"
+	"strings"
+
+	"fiatjaf.com/nostr"
+	"github.com/btcsuite/btcd/btcec/v2"
+)
+
+// checkP2PKPubkey validates the NIP-61 "pubkey" tag: senders lock nutzaps
+// to it, so if it isn't a real secp256k1 point nobody can ever spend them.
+// It returns "" if the key is fine, otherwise the problem and whether it's
+// fatal for nutzaps.
+func checkP2PKPubkey(hexKey string) (problem string, fatal bool) {
+	if hexKey == "" {
+		return "no pubkey tag: senders have nothing to lock nutzaps to", true
+	}
+	raw, err := hex.DecodeString(hexKey)
+	if err != nil {
+		return "pubkey isn't hex", true
+	}
+	switch len(raw) {
+	case 33:
+		if _, err := btcec.ParsePubKey(raw); err != nil {
+			return "pubkey isn't a valid secp256k1 point", true
+		}
+		return "", false
+	case 32:
+		// x-only keys work once a sender adds the 02 prefix, but not every
+		// wallet does that
+		if _, err := btcec.ParsePubKey(append([]byte{0x02}, raw...)); err != nil {
+			return "pubkey isn't a valid secp256k1 point", true
+		}
+		return "pubkey is x-only, not 02-prefixed compressed", false
+	default:
+		return fmt.Sprintf("pubkey is %d bytes, want 33 (compressed)", len(raw)), true
+	}
+}
+
+// nutzapMintUnits maps each "mint" tag URL to the units it lists. A mint
+// without units is assumed to take sats.
+func nutzapMintUnits(evt *nostr.Event) map[string][]string {
+	units := make(map[string][]string)
+	for _, tag := range evt.Tags {
+		if len(tag) >= 2 && tag[0] == "mint" {
+			units[strings.TrimRight(tag[1], "/")] = tag[2:]
+		}
+	}
+	return units
+}
+
+// assessNutzapInfo validates a kind 10019 end to end: the P2PK key, the
+// mints (reachable, NUT-11, sat keyset), and the relays senders publish
+// kind 9321 to. mints and relays are the probe results for the event's
+// mint and relay tags.
+func assessNutzapInfo(evt *nostr.Event, mints []MintInfo, relays []RelayScore) (string, string) {
+	var fatals, warns []string
+
+	var pubkey string
+	for _, tag := range evt.Tags {
+		if len(tag) >= 2 && tag[0] == "pubkey" {
+			pubkey = tag[1]
+		}
+	}
+	if problem, isFatal := checkP2PKPubkey(pubkey); isFatal {
+		fatals = append(fatals, problem)
+	} else if problem != "" {
+		warns = append(warns, problem)
+	}
+
+	units := nutzapMintUnits(evt)
+	usable := 0
+	for _, m := range mints {
+		var issues []string
+		if !m.Reachable {
+			issues = append(issues, "unreachable")
+		} else {
+			if !m.SupportsP2PK {
+				issues = append(issues, "no NUT-11")
+			}
+			if !m.HasSatKeyset {
+				issues = append(issues, "no sat keyset")
+			}
+		}
+		if u := units[strings.TrimRight(m.URL, "/")]; len(u) > 0 && !slices.Contains(u, "sat") {
+			issues = append(issues, "listed without sat unit")
+		}
+		if len(issues) == 0 {
+			usable++
+		} else {
+			warns = append(warns, fmt.Sprintf("%s: %s", m.URL, strings.Join(issues, ", ")))
+		}
+	}
+	switch {
+	case len(mints) == 0:
+		fatals = append(fatals, "no mint tags: senders don't know where to mint")
+	case usable == 0:
+		fatals = append(fatals, "no listed mint can issue P2PK-locked sats")
+	}
+
+	accepting := 0
+	for _, rs := range relays {
+		switch {
+		case !rs.Reachable:
+			warns = append(warns, rs.URL+": unreachable")
+		case rs.PaymentRequired || (rs.Info != nil && rs.Info.Limitation != nil && rs.Info.Limitation.RestrictedWrites):
+			warns = append(warns, rs.URL+": restricts writes, senders' kind 9321 may be rejected")
+		default:
+			accepting++
+		}
+	}
+	if len(relays) == 0 {
+		warns = append(warns, "no relay tags: senders fall back to your read relays")
+	} else if accepting == 0 {
+		fatals = append(fatals, "no listed relay will accept nutzaps (kind 9321)")
+	}
+
+	summary := fmt.Sprintf("kind 10019: %d/%d mint(s) usable, %d/%d relay(s) open", usable, len(mints), accepting, len(relays))
+	switch {
+	case len(fatals) > 0:
+		return "fail", summary + " — nutzaps sent to you would be lost: " + strings.Join(append(fatals, warns...), "; ")
+	case len(warns) > 0:
+		return "warn", summary + " — " + strings.Join(warns, "; ")
+	default:
+		return "pass", summary
+	}
+}
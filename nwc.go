@@ -0,0 +1,157 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"fiatjaf.com/nostr"
+)
+
+// nwcAppDataTag is the "d" tag of the kind 30078 (NIP-78 app data) event
+// holding the user's encrypted NWC connection.
+const nwcAppDataTag = "nihao/nwc"
+
+// nwcConnection is a parsed NIP-47 Nostr Wallet Connect URI.
+type nwcConnection struct {
+	WalletPubkey nostr.PubKey
+	Relays       []string
+	Secret       nostr.SecretKey
+	LUD16        string
+}
+
+// NWCSetupResult describes the stored connection in setup output. The
+// secret is never included.
+type NWCSetupResult struct {
+	WalletPubkey string   `json:"wallet_pubkey"`
+	Relays       []string `json:"relays"`
+	Methods      []string `json:"methods,omitempty"`
+	LocalPath    string   `json:"local_path,omitempty"`
+}
+
+// parseNWCURI parses nostr+walletconnect://<wallet-pubkey>?relay=...&secret=...
+func parseNWCURI(uri string) (nwcConnection, error) {
+	var conn nwcConnection
+	u, err := url.Parse(strings.TrimSpace(uri))
+	if err != nil {
+		return conn, fmt.Errorf("invalid NWC URI: %w", err)
+	}
+	if u.Scheme != "nostr+walletconnect" {
+		return conn, fmt.Errorf("invalid NWC URI: scheme must be nostr+walletconnect://")
+	}
+	host := u.Host
+	if host == "" {
+		host = u.Opaque // nostr+walletconnect:<pubkey>?...
+	}
+	if conn.WalletPubkey, err = nostr.PubKeyFromHex(host); err != nil {
+		return conn, fmt.Errorf("invalid NWC URI: wallet pubkey must be 64 hex characters")
+	}
+	q := u.Query()
+	for _, r := range q["relay"] {
+		if r = normalizeRelayURL(r); r != "" {
+			conn.Relays = append(conn.Relays, r)
+		}
+	}
+	if len(conn.Relays) == 0 {
+		return conn, fmt.Errorf("invalid NWC URI: no relay parameter")
+	}
+	if conn.Secret, err = nostr.SecretKeyFromHex(q.Get("secret")); err != nil {
+		return conn, fmt.Errorf("invalid NWC URI: secret must be 64 hex characters")
+	}
+	conn.LUD16 = q.Get("lud16")
+	return conn, nil
+}
+
+// validateNWC checks that the wallet service is live: its NIP-47 info
+// event (kind 13194) must be on one of the connection's relays. Returns the
+// methods the wallet supports.
+func validateNWC(ctx context.Context, conn nwcConnection) ([]string, error) {
+	pool := NewRelayPool(conn.Relays, true)
+	defer pool.Close()
+	relays := pool.CheckRelays()
+	if len(relays) == 0 {
+		return nil, fmt.Errorf("could not connect to %s", strings.Join(conn.Relays, ", "))
+	}
+	_, info := fetchKindFrom(ctx, relays, conn.WalletPubkey, 13194)
+	if info == nil {
+		return nil, fmt.Errorf("no wallet service info (kind 13194) from %s on %s", conn.WalletPubkey.Hex(), strings.Join(conn.Relays, ", "))
+	}
+	return strings.Fields(info.Content), nil
+}
+
+// nwcLocalPath is where a user's encrypted NWC connection is kept between
+// runs, or "" if there's no usable config directory.
+func nwcLocalPath(pk nostr.PubKey) string {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(dir, "nihao", "nwc", pk.Hex())
+}
+
+// storeNWC encrypts the URI to the user's own key (NIP-44), writes it to
+// path (0600), and returns the kind 30078 event to publish. Both copies
+// hold only ciphertext.
+func storeNWC(ctx context.Context, kr nostr.Keyer, uri, path string) (nostr.Event, error) {
+	pk, err := kr.GetPublicKey(ctx)
+	if err != nil {
+		return nostr.Event{}, err
+	}
+	ciphertext, err := kr.Encrypt(ctx, uri, pk)
+	if err != nil {
+		return nostr.Event{}, fmt.Errorf("failed to encrypt NWC connection: %w", err)
+	}
+	if path != "" {
+		if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+			return nostr.Event{}, err
+		}
+		if err := writeNsecFile(path, ciphertext); err != nil {
+			return nostr.Event{}, err
+		}
+	}
+	evt := nostr.Event{
+		CreatedAt: nostr.Now(),
+		Kind:      30078,
+		Tags:      nostr.Tags{{"d", nwcAppDataTag}},
+		Content:   ciphertext,
+	}
+	if err := kr.SignEvent(ctx, &evt); err != nil {
+		return nostr.Event{}, fmt.Errorf("failed to sign NWC app data: %w", err)
+	}
+	return evt, nil
+}
+
+// loadNWC returns the user's stored NWC URI: from the local copy at path if
+// there is one, else from their kind 30078 on relays.
+func loadNWC(ctx context.Context, kr nostr.Keyer, path string, relays []checkRelay) (string, error) {
+	pk, err := kr.GetPublicKey(ctx)
+	if err != nil {
+		return "", err
+	}
+	var ciphertext string
+	if data, err := os.ReadFile(path); path != "" && err == nil {
+		ciphertext = strings.TrimSpace(string(data))
+	} else {
+		ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+		defer cancel()
+		_, evt := fetchLatestFrom(ctx, relays, nostr.Filter{
+			Authors: []nostr.PubKey{pk},
+			Kinds:   []nostr.Kind{30078},
+			Tags:    nostr.TagMap{"d": []string{nwcAppDataTag}},
+			Limit:   1,
+		})
+		if evt == nil {
+			return "", fmt.Errorf("no NWC connection stored: run nihao setup --nwc <uri>")
+		}
+		ciphertext = evt.Content
+	}
+	uri, err := kr.Decrypt(ctx, ciphertext, pk)
+	if err != nil {
+		return "", fmt.Errorf("failed to decrypt NWC connection: %w", err)
+	}
+	return uri, nil
+}
@@ -0,0 +1,318 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"fiatjaf.com/nostr"
+	"fiatjaf.com/nostr/nip19"
+	"github.com/BurntSushi/toml"
+)
+
+// OrgConfig is an organization policy loaded from a TOML file. Setup applies
+// it to every new identity; `nihao check --org` validates members against it.
+//
+//	name          = "Acme"
+//	relays        = ["wss://relay.acme.com", "wss://nos.lol"]
+//	dm_relays     = ["wss://dm.acme.com"]
+//	follows       = ["npub1...", "npub1..."]
+//	nip05_domain  = "acme.com"
+//	mints         = ["https://mint.acme.com"]
+//	allowed_mints = ["https://mint.acme.com", "https://mint.minibits.cash/Bitcoin"]
+//	members       = ["npub1...", "alice@acme.com"]
+type OrgConfig struct {
+	Name         string   `toml:"name" json:"name"`
+	Relays       []string `toml:"relays" json:"relays,omitempty"`
+	DMRelays     []string `toml:"dm_relays" json:"dm_relays,omitempty"`
+	Follows      []string `toml:"follows" json:"follows,omitempty"`
+	NIP05Domain  string   `toml:"nip05_domain" json:"nip05_domain,omitempty"`
+	Mints        []string `toml:"mints" json:"mints,omitempty"`
+	AllowedMints []string `toml:"allowed_mints" json:"allowed_mints,omitempty"` // defaults to mints
+	Members      []string `toml:"members" json:"members,omitempty"`
+
+	follows []nostr.PubKey
+}
+
+// loadOrgConfig reads and validates an org config. Unknown keys are an error
+// so a typo doesn't silently weaken the policy.
+func loadOrgConfig(path string) (*OrgConfig, error) {
+	var cfg OrgConfig
+	md, err := toml.DecodeFile(path, &cfg)
+	if err != nil {
+		return nil, err
+	}
+	if undecoded := md.Undecoded(); len(undecoded) > 0 {
+		return nil, fmt.Errorf("unknown key %q", undecoded[0].String())
+	}
+
+	for i, r := range cfg.Relays {
+		if cfg.Relays[i] = normalizeRelayURL(r); cfg.Relays[i] == "" {
+			return nil, fmt.Errorf("invalid relay URL %q", r)
+		}
+	}
+	for i, r := range cfg.DMRelays {
+		if cfg.DMRelays[i] = normalizeRelayURL(r); cfg.DMRelays[i] == "" {
+			return nil, fmt.Errorf("invalid DM relay URL %q", r)
+		}
+	}
+	for _, f := range cfg.Follows {
+		pk, err := parsePubkey(f)
+		if err != nil {
+			return nil, fmt.Errorf("invalid follow %q: %w", f, err)
+		}
+		cfg.follows = append(cfg.follows, pk)
+	}
+	cfg.NIP05Domain = strings.ToLower(strings.TrimSpace(cfg.NIP05Domain))
+	if strings.Contains(cfg.NIP05Domain, "@") {
+		return nil, fmt.Errorf("nip05_domain should be a bare domain, got %q", cfg.NIP05Domain)
+	}
+	if len(cfg.AllowedMints) == 0 {
+		cfg.AllowedMints = cfg.Mints
+	}
+	return &cfg, nil
+}
+
+// apply fills in setup options from the org policy. Explicit flags win.
+func (c *OrgConfig) apply(opts setupOpts) setupOpts {
+	if opts.relays == nil && len(c.Relays) > 0 {
+		opts.relays = c.Relays
+	}
+	if opts.dmRelays == nil && len(c.DMRelays) > 0 {
+		opts.dmRelays = c.DMRelays
+	}
+	if opts.mints == nil && len(c.Mints) > 0 {
+		opts.mints = c.Mints
+	}
+	opts.follows = c.follows
+	opts.nip05Domain = c.NIP05Domain
+	return opts
+}
+
+// orgNIP05 builds the NIP-05 identifier for name on the org domain,
+// e.g. "Jane Doe" → "jane.doe@acme.com".
+func orgNIP05(name, domain string) string {
+	local := nip05LocalPart(name)
+	if local == "" {
+		local = "_"
+	}
+	return local + "@" + domain
+}
+
+// nip05LocalPart reduces a name to the NIP-05 local-part alphabet,
+// e.g. "Jane Doe" → "jane.doe". It may return "".
+func nip05LocalPart(name string) string {
+	local := strings.Join(strings.Fields(strings.ToLower(name)), ".")
+	return strings.Map(func(r rune) rune {
+		if (r >= 'a' && r <= 'z') || (r >= '0' && r <= '9') || r == '.' || r == '-' || r == '_' {
+			return r
+		}
+		return -1
+	}, local)
+}
+
+// OrgMemberResult is one member's outcome in `nihao check --org`.
+type OrgMemberResult struct {
+	Member     string      `json:"member"`
+	Compliant  bool        `json:"compliant"`
+	Violations []string    `json:"violations"`
+	Check      CheckResult `json:"check"`
+}
+
+// OrgCheckResult is the JSON output of `nihao check --org`.
+type OrgCheckResult struct {
+	Org       string            `json:"org"`
+	Compliant int               `json:"compliant"`
+	Total     int               `json:"total"`
+	Members   []OrgMemberResult `json:"members"`
+}
+
+// orgViolations lists the ways a checked identity falls short of the org
+// policy. It only looks at events already fetched by checkIdentity.
+func orgViolations(c *OrgConfig, r CheckResult) []string {
+	violations := []string{}
+
+	tagValues := func(kind int, name string) map[string]bool {
+		values := make(map[string]bool)
+		if evt := r.events[kind]; evt != nil {
+			for _, tag := range evt.Tags {
+				if len(tag) >= 2 && tag[0] == name {
+					values[strings.TrimRight(tag[1], "/")] = true
+				}
+			}
+		}
+		return values
+	}
+
+	relays := relaySet(tagValues(10002, "r"))
+	for _, want := range c.Relays {
+		if !relays[want] {
+			violations = append(violations, "relay list missing "+want)
+		}
+	}
+
+	dmRelays := relaySet(tagValues(10050, "relay"))
+	for _, want := range c.DMRelays {
+		if !dmRelays[want] {
+			violations = append(violations, "DM relay list missing "+want)
+		}
+	}
+
+	follows := tagValues(3, "p")
+	for _, pk := range c.follows {
+		if !follows[pk.Hex()] && pk.Hex() != r.Pubkey {
+			violations = append(violations, "not following "+nip19.EncodeNpub(pk))
+		}
+	}
+
+	if c.NIP05Domain != "" {
+		var meta ProfileMetadata
+		if evt := r.events[0]; evt != nil {
+			json.Unmarshal([]byte(evt.Content), &meta)
+		}
+		nip05 := strings.ToLower(meta.NIP05)
+		switch {
+		case nip05 == "":
+			violations = append(violations, "no NIP-05 (expected @"+c.NIP05Domain+")")
+		case !strings.HasSuffix(nip05, "@"+c.NIP05Domain) && nip05 != c.NIP05Domain:
+			violations = append(violations, fmt.Sprintf("NIP-05 %s is not on %s", meta.NIP05, c.NIP05Domain))
+		case r.status("nip05") != "pass":
+			violations = append(violations, fmt.Sprintf("NIP-05 %s doesn't resolve", meta.NIP05))
+		}
+	}
+
+	if len(c.AllowedMints) > 0 {
+		allowed := make(map[string]bool)
+		for _, m := range c.AllowedMints {
+			allowed[strings.TrimRight(m, "/")] = true
+		}
+		for m := range tagValues(10019, "mint") {
+			if !allowed[m] {
+				violations = append(violations, "mint not allowed by policy: "+m)
+			}
+		}
+	}
+
+	return violations
+}
+
+// relaySet canonicalizes a set of relay URLs taken from event tags.
+func relaySet(urls map[string]bool) map[string]bool {
+	set := make(map[string]bool, len(urls))
+	for u := range urls {
+		if n := normalizeRelayURL(u); n != "" {
+			set[n] = true
+		}
+	}
+	return set
+}
+
+// status returns the status of the named check, or "" if it wasn't run.
+func (r CheckResult) status(name string) string {
+	for _, c := range r.Checks {
+		if c.Name == name {
+			return c.Status
+		}
+	}
+	return ""
+}
+
+// runOrgCheck checks every org member and validates them against the policy.
+// With leaderboard set it prints the aggregate report instead of each
+// member. Exits 1 if any member is out of policy.
+func runOrgCheck(path string, jsonOutput bool, quiet bool, leaderboard bool, relays []string) {
+	cfg, err := loadOrgConfig(path)
+	if err != nil {
+		fatal("invalid org config %s: %s", path, err)
+	}
+	if len(cfg.Members) == 0 {
+		fatal("org config %s has no members", path)
+	}
+
+	name := cfg.Name
+	if name == "" {
+		name = path
+	}
+	verbose := !jsonOutput && !quiet
+	if verbose {
+		fmt.Printf("nihao org 🏢 %s — %d members\n\n", name, len(cfg.Members))
+	}
+
+	// Members are expected to publish to the org relays, so look there first
+	if len(relays) == 0 {
+		relays = cfg.Relays
+	}
+	connectCtx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
+	checkRelays := connectCheckRelays(connectCtx, relays)
+	cancel()
+	if len(checkRelays) == 0 {
+		fatal("could not connect to any relay")
+	}
+	defer func() {
+		for _, cr := range checkRelays {
+			cr.relay.Close()
+		}
+	}()
+
+	result := OrgCheckResult{Org: cfg.Name, Total: len(cfg.Members)}
+	for _, member := range cfg.Members {
+		mr := OrgMemberResult{Member: member}
+		pk, err := resolveTarget(member, true)
+		if err != nil {
+			mr.Violations = []string{fmt.Sprintf("could not resolve: %s", err)}
+		} else {
+			ctx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
+			mr.Check = checkIdentityCached(ctx, checkRelays, relays, pk)
+			cancel()
+			mr.Violations = orgViolations(cfg, mr.Check)
+		}
+		mr.Compliant = len(mr.Violations) == 0
+		if mr.Compliant {
+			result.Compliant++
+		}
+		result.Members = append(result.Members, mr)
+
+		if verbose && !leaderboard {
+			printOrgMember(mr)
+		}
+	}
+
+	if leaderboard {
+		var checks []CheckResult
+		for _, mr := range result.Members {
+			checks = append(checks, mr.Check)
+		}
+		if jsonOutput {
+			out, _ := json.MarshalIndent(buildLeaderboard(checks), "", "  ")
+			fmt.Println(string(out))
+		} else if !quiet {
+			printLeaderboard(buildLeaderboard(checks))
+		}
+	} else if jsonOutput {
+		out, _ := json.MarshalIndent(result, "", "  ")
+		fmt.Println(string(out))
+	} else if !quiet {
+		fmt.Printf("\n  🏢 %d/%d members within policy\n", result.Compliant, result.Total)
+	}
+	if result.Compliant < result.Total {
+		os.Exit(1)
+	}
+}
+
+func printOrgMember(mr OrgMemberResult) {
+	icon := "✓"
+	if !mr.Compliant {
+		icon = "✗"
+	}
+	if mr.Check.Npub != "" {
+		fmt.Printf("  %s %s — score %d/%d\n", icon, mr.Member, mr.Check.Score, mr.Check.MaxScore)
+	} else {
+		fmt.Printf("  %s %s\n", icon, mr.Member)
+	}
+	for _, v := range mr.Violations {
+		fmt.Printf("      - %s\n", v)
+	}
+}
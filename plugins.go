@@ -0,0 +1,138 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+	"time"
+)
+
+// pluginTimeout bounds each check plugin's run.
+const pluginTimeout = 10 * time.Second
+
+// PluginInput is what a check plugin receives as JSON on stdin: the
+// identity, every event check fetched (with its relay), and the built-in
+// checks' results.
+type PluginInput struct {
+	Npub   string         `json:"npub"`
+	Pubkey string         `json:"pubkey"`
+	Events []SourcedEvent `json:"events"`
+	Checks []CheckItem    `json:"checks"`
+}
+
+// defaultPluginDir is where check plugins live unless --plugins says
+// otherwise, or "" if there's no usable config directory.
+func defaultPluginDir() string {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(dir, "nihao", "plugins")
+}
+
+// findPlugins lists the executables in dir, sorted by name. Hidden files
+// are skipped so editors' swap files don't run. A missing dir has none.
+func findPlugins(dir string) []string {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil
+	}
+	var plugins []string
+	for _, e := range entries {
+		if e.IsDir() || strings.HasPrefix(e.Name(), ".") {
+			continue
+		}
+		info, err := e.Info()
+		if err != nil || !info.Mode().IsRegular() {
+			continue
+		}
+		if runtime.GOOS != "windows" && info.Mode().Perm()&0111 == 0 {
+			continue
+		}
+		plugins = append(plugins, filepath.Join(dir, e.Name()))
+	}
+	return plugins
+}
+
+// pluginInput builds the stdin document for plugins from a check result.
+func pluginInput(r CheckResult) PluginInput {
+	in := PluginInput{Npub: r.Npub, Pubkey: r.Pubkey, Events: []SourcedEvent{}, Checks: r.Checks}
+	kinds := make([]int, 0, len(r.events))
+	for kind, evt := range r.events {
+		if evt != nil {
+			kinds = append(kinds, kind)
+		}
+	}
+	sort.Ints(kinds)
+	for _, kind := range kinds {
+		in.Events = append(in.Events, SourcedEvent{Kind: kind, Relay: r.sources[kind], Event: r.events[kind]})
+	}
+	return in
+}
+
+// runPlugin executes one plugin with input on stdin and returns the checks
+// it printed on stdout: a JSON array of {"name", "status", "detail"}. A
+// plugin that fails, times out, or prints garbage yields a single warn
+// item saying so, rather than aborting the whole check.
+func runPlugin(ctx context.Context, path string, input []byte) []CheckItem {
+	name := strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+	broken := func(format string, a ...any) []CheckItem {
+		return []CheckItem{{Name: "plugin_" + name, Status: "warn", Detail: fmt.Sprintf(format, a...)}}
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, pluginTimeout)
+	defer cancel()
+	cmd := exec.CommandContext(ctx, path)
+	cmd.Stdin = bytes.NewReader(input)
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		if ctx.Err() != nil {
+			return broken("plugin timed out after %s", pluginTimeout)
+		}
+		msg := strings.TrimSpace(stderr.String())
+		if msg == "" {
+			msg = err.Error()
+		}
+		return broken("plugin failed: %s", msg)
+	}
+
+	var items []CheckItem
+	if err := json.Unmarshal(stdout.Bytes(), &items); err != nil {
+		return broken("plugin output isn't a JSON array of checks: %s", err)
+	}
+	for i := range items {
+		if items[i].Name == "" {
+			items[i].Name = name
+		}
+		switch items[i].Status {
+		case "pass", "warn", "fail":
+		default:
+			items[i].Detail = fmt.Sprintf("invalid status %q from plugin %s: %s", items[i].Status, name, items[i].Detail)
+			items[i].Status = "warn"
+		}
+	}
+	return items
+}
+
+// runCheckPlugins runs every plugin in dir against the result and appends
+// their checks. Plugin checks aren't scored: the score stays comparable
+// between machines with different plugins installed.
+func runCheckPlugins(ctx context.Context, result *CheckResult, dir string) {
+	plugins := findPlugins(dir)
+	if len(plugins) == 0 {
+		return
+	}
+	input, _ := json.Marshal(pluginInput(*result))
+	for _, path := range plugins {
+		result.Checks = append(result.Checks, runPlugin(ctx, path, input)...)
+	}
+}
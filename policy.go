@@ -0,0 +1,246 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"fiatjaf.com/nostr"
+	"gopkg.in/yaml.v3"
+)
+
+// Policy is a set of pass/fail rules evaluated against a check result,
+// loaded from YAML. Every rule is optional; unset rules aren't evaluated.
+//
+//	name: Acme identity standard
+//	min_score: 7
+//	nip05_root: true
+//	relay_count: {min: 3, max: 6}
+//	dm_relay_count: {min: 1}
+//	follow_count: {min: 10}
+//	paid_relays: false
+//	require:
+//	  nip05: pass
+//	  picture: warn
+type Policy struct {
+	Name         string            `yaml:"name"`
+	MinScore     *int              `yaml:"min_score"`
+	NIP05Root    *bool             `yaml:"nip05_root"`
+	RelayCount   *CountRange       `yaml:"relay_count"`
+	DMRelayCount *CountRange       `yaml:"dm_relay_count"`
+	FollowCount  *CountRange       `yaml:"follow_count"`
+	PaidRelays   *bool             `yaml:"paid_relays"` // false forbids them
+	Require      map[string]string `yaml:"require"`     // check → worst acceptable status
+}
+
+// CountRange bounds a count. Either end may be left open.
+type CountRange struct {
+	Min *int `yaml:"min"`
+	Max *int `yaml:"max"`
+}
+
+// PolicyResult is the policy-compliance section of a check.
+type PolicyResult struct {
+	Name      string       `json:"name,omitempty"`
+	Compliant bool         `json:"compliant"`
+	Rules     []PolicyRule `json:"rules"`
+}
+
+// PolicyRule is one evaluated rule.
+type PolicyRule struct {
+	Rule   string `json:"rule"`
+	Pass   bool   `json:"pass"`
+	Detail string `json:"detail"`
+}
+
+// statusRank orders check statuses from worst to best.
+var statusRank = map[string]int{"fail": 0, "warn": 1, "pass": 2}
+
+// loadPolicy reads and validates a policy file. Unknown keys are an error
+// so a typo doesn't silently weaken the policy.
+func loadPolicy(path string) (*Policy, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	var p Policy
+	dec := yaml.NewDecoder(f)
+	dec.KnownFields(true)
+	if err := dec.Decode(&p); err != nil {
+		return nil, err
+	}
+
+	for check, status := range p.Require {
+		if status != "pass" && status != "warn" {
+			return nil, fmt.Errorf("require.%s: status must be pass or warn, got %q", check, status)
+		}
+	}
+	for name, r := range map[string]*CountRange{"relay_count": p.RelayCount, "dm_relay_count": p.DMRelayCount, "follow_count": p.FollowCount} {
+		if r == nil {
+			continue
+		}
+		if r.Min == nil && r.Max == nil {
+			return nil, fmt.Errorf("%s: needs min or max", name)
+		}
+		if r.Min != nil && r.Max != nil && *r.Min > *r.Max {
+			return nil, fmt.Errorf("%s: min %d is above max %d", name, *r.Min, *r.Max)
+		}
+	}
+	return &p, nil
+}
+
+func (r CountRange) String() string {
+	switch {
+	case r.Min != nil && r.Max != nil:
+		return fmt.Sprintf("between %d and %d", *r.Min, *r.Max)
+	case r.Min != nil:
+		return fmt.Sprintf("at least %d", *r.Min)
+	default:
+		return fmt.Sprintf("at most %d", *r.Max)
+	}
+}
+
+func (r CountRange) contains(n int) bool {
+	return (r.Min == nil || n >= *r.Min) && (r.Max == nil || n <= *r.Max)
+}
+
+// countRelays counts the distinct relays a relay list event names.
+func countRelays(evt *nostr.Event) int {
+	if evt == nil {
+		return 0
+	}
+	seen := make(map[string]bool)
+	for _, u := range relayTags(evt) {
+		if n := normalizeRelayURL(u); n != "" {
+			seen[n] = true
+		}
+	}
+	return len(seen)
+}
+
+// evaluatePolicy applies every set rule to a check result. Like
+// orgViolations, it only looks at what checkIdentity already fetched.
+func evaluatePolicy(p *Policy, r CheckResult) PolicyResult {
+	result := PolicyResult{Name: p.Name, Compliant: true}
+	add := func(rule string, pass bool, detail string) {
+		result.Rules = append(result.Rules, PolicyRule{Rule: rule, Pass: pass, Detail: detail})
+		result.Compliant = result.Compliant && pass
+	}
+
+	if p.MinScore != nil {
+		add(fmt.Sprintf("score at least %d", *p.MinScore), r.Score >= *p.MinScore, fmt.Sprintf("score %d/%d", r.Score, r.MaxScore))
+	}
+
+	if p.NIP05Root != nil && *p.NIP05Root {
+		var meta ProfileMetadata
+		if evt := r.events[0]; evt != nil {
+			json.Unmarshal([]byte(evt.Content), &meta)
+		}
+		switch {
+		case meta.NIP05 == "":
+			add("nip05 must be root", false, "no NIP-05")
+		case !isRootNIP05(meta.NIP05):
+			add("nip05 must be root", false, meta.NIP05+" is not _@domain")
+		case r.status("nip05") != "pass":
+			add("nip05 must be root", false, meta.NIP05+" doesn't resolve")
+		default:
+			add("nip05 must be root", true, meta.NIP05)
+		}
+	}
+
+	for _, c := range []struct {
+		name  string
+		rng   *CountRange
+		count int
+	}{
+		{"relay count", p.RelayCount, countRelays(r.events[10002])},
+		{"DM relay count", p.DMRelayCount, countRelays(r.events[10050])},
+		{"follow count", p.FollowCount, len(tagValues(r.events[3], "p"))},
+	} {
+		if c.rng != nil {
+			add(fmt.Sprintf("%s %s", c.name, c.rng), c.rng.contains(c.count), fmt.Sprintf("%d", c.count))
+		}
+	}
+
+	if p.PaidRelays != nil && !*p.PaidRelays {
+		if r.status("paid_relays") != "" {
+			add("no paid relays", false, r.detail("paid_relays"))
+		} else {
+			add("no paid relays", true, "none in relay list")
+		}
+	}
+
+	checks := make([]string, 0, len(p.Require))
+	for check := range p.Require {
+		checks = append(checks, check)
+	}
+	sort.Strings(checks)
+	for _, check := range checks {
+		want := p.Require[check]
+		rule := check + " must pass"
+		if want == "warn" {
+			rule = check + " must be warn or better"
+		}
+		got := r.status(check)
+		if got == "" {
+			add(rule, false, "not checked")
+			continue
+		}
+		add(rule, statusRank[got] >= statusRank[want], got)
+	}
+	return result
+}
+
+// tagValues returns the distinct values of an event's tags with the given
+// name, or none if evt is nil.
+func tagValues(evt *nostr.Event, name string) map[string]bool {
+	values := make(map[string]bool)
+	if evt == nil {
+		return values
+	}
+	for _, tag := range evt.Tags {
+		if len(tag) >= 2 && tag[0] == name {
+			values[tag[1]] = true
+		}
+	}
+	return values
+}
+
+// detail returns the detail of the named check, or "" if it wasn't run.
+func (r CheckResult) detail(name string) string {
+	for _, c := range r.Checks {
+		if c.Name == name {
+			return c.Detail
+		}
+	}
+	return ""
+}
+
+func printPolicyResult(p PolicyResult) {
+	name := p.Name
+	if name == "" {
+		name = "policy"
+	}
+	failed := 0
+	for _, rule := range p.Rules {
+		if !rule.Pass {
+			failed++
+		}
+	}
+	fmt.Println()
+	if p.Compliant {
+		fmt.Printf("  📋 %s: compliant (%d rule(s))\n", name, len(p.Rules))
+	} else {
+		fmt.Printf("  📋 %s: %d of %d rule(s) violated\n", name, failed, len(p.Rules))
+	}
+	for _, rule := range p.Rules {
+		icon := "✅"
+		if !rule.Pass {
+			icon = "❌"
+		}
+		fmt.Printf("  %s %s: %s\n", icon, rule.Rule, strings.TrimSpace(rule.Detail))
+	}
+}
@@ -0,0 +1,315 @@
+package main
+
+import (
+	"archive/zip"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"fiatjaf.com/nostr"
+)
+
+// defaultBlossomServers is where setup uploads imported profile images
+// unless --blossom-server says otherwise.
+var defaultBlossomServers = []string{
+	"https://blossom.primal.net",
+	"https://nostr.download",
+}
+
+// importedProfile is what setup --import found in an archive or mapping
+// file. Images are kept in memory until the new key can sign the uploads.
+type importedProfile struct {
+	source  string // "twitter" or "json"
+	name    string
+	about   string
+	website string
+	picture importedImage
+	banner  importedImage
+}
+
+// importedImage is a profile image from an import: its bytes, or only the
+// URL it can be downloaded from.
+type importedImage struct {
+	data        []byte
+	contentType string
+	url         string
+}
+
+func (img importedImage) isSet() bool { return len(img.data) > 0 || img.url != "" }
+
+// ProfileImport reports what setup --import prefilled.
+type ProfileImport struct {
+	Source string   `json:"source"` // "twitter" or "json"
+	Fields []string `json:"fields"` // profile fields taken from the import
+	Blobs  []string `json:"blobs,omitempty"`
+}
+
+// loadProfileImport reads a Twitter/X archive (.zip) or a JSON mapping of
+// profile fields.
+func loadProfileImport(file string) (*importedProfile, error) {
+	if strings.EqualFold(filepath.Ext(file), ".zip") {
+		zr, err := zip.OpenReader(file)
+		if err != nil {
+			return nil, err
+		}
+		defer zr.Close()
+		return parseTwitterArchive(&zr.Reader)
+	}
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	return parseProfileMapping(data, filepath.Dir(file))
+}
+
+// parseTwitterArchive reads the profile from a Twitter/X data export:
+// data/account.js (name), data/profile.js (bio, website, image URLs), and
+// the images themselves from data/profile_media.
+func parseTwitterArchive(zr *zip.Reader) (*importedProfile, error) {
+	var account []struct {
+		Account struct {
+			AccountID   string `json:"accountId"`
+			Username    string `json:"username"`
+			DisplayName string `json:"accountDisplayName"`
+		} `json:"account"`
+	}
+	var profile []struct {
+		Profile struct {
+			Description struct {
+				Bio     string `json:"bio"`
+				Website string `json:"website"`
+			} `json:"description"`
+			AvatarMediaURL string `json:"avatarMediaUrl"`
+			HeaderMediaURL string `json:"headerMediaUrl"`
+		} `json:"profile"`
+	}
+	if err := readArchiveJS(zr, "account.js", &account); err != nil {
+		return nil, err
+	}
+	if err := readArchiveJS(zr, "profile.js", &profile); err != nil {
+		return nil, err
+	}
+	if len(account) == 0 {
+		return nil, fmt.Errorf("not a Twitter archive: account.js is empty")
+	}
+
+	p := &importedProfile{source: "twitter", name: account[0].Account.DisplayName}
+	if p.name == "" {
+		p.name = account[0].Account.Username
+	}
+	if len(profile) > 0 {
+		pr := profile[0].Profile
+		p.about = pr.Description.Bio
+		p.website = pr.Description.Website
+		p.picture = archiveMedia(zr, account[0].Account.AccountID, pr.AvatarMediaURL)
+		p.banner = archiveMedia(zr, account[0].Account.AccountID, pr.HeaderMediaURL)
+	}
+	return p, nil
+}
+
+// readArchiveJS decodes one of an archive's data files, which are JSON
+// behind a `window.YTD.<name>.part0 = ` assignment.
+func readArchiveJS(zr *zip.Reader, name string, v any) error {
+	for _, f := range zr.File {
+		if path.Base(f.Name) != name {
+			continue
+		}
+		rc, err := f.Open()
+		if err != nil {
+			return err
+		}
+		data, err := io.ReadAll(io.LimitReader(rc, maxMirrorSize))
+		rc.Close()
+		if err != nil {
+			return err
+		}
+		if strings.HasPrefix(strings.TrimSpace(string(data)), "window.") {
+			data = data[strings.IndexByte(string(data), '=')+1:]
+		}
+		if err := json.Unmarshal(data, v); err != nil {
+			return fmt.Errorf("invalid %s in archive: %w", name, err)
+		}
+		return nil
+	}
+	return fmt.Errorf("not a Twitter archive: no %s", name)
+}
+
+// archiveMedia finds a profile image in the archive, which stores it as
+// profile_media/<account id>-<file name from the URL>. If it isn't there,
+// the URL is kept so it can be downloaded instead.
+func archiveMedia(zr *zip.Reader, accountID, mediaURL string) importedImage {
+	if mediaURL == "" {
+		return importedImage{}
+	}
+	base := path.Base(mediaURL)
+	for _, f := range zr.File {
+		if path.Base(path.Dir(f.Name)) != "profile_media" || !strings.HasPrefix(path.Base(f.Name), accountID+"-"+base) {
+			continue
+		}
+		if f.UncompressedSize64 > maxMirrorSize {
+			break
+		}
+		rc, err := f.Open()
+		if err != nil {
+			break
+		}
+		data, err := io.ReadAll(rc)
+		rc.Close()
+		if err != nil {
+			break
+		}
+		return importedImage{data: data, contentType: http.DetectContentType(data), url: mediaURL}
+	}
+	return importedImage{url: mediaURL}
+}
+
+// parseProfileMapping reads a generic JSON mapping of profile fields, for
+// archives nihao doesn't know. Images are URLs or paths relative to the
+// mapping file.
+func parseProfileMapping(data []byte, dir string) (*importedProfile, error) {
+	var m struct {
+		Name    string `json:"name"`
+		About   string `json:"about"`
+		Bio     string `json:"bio"`
+		Picture string `json:"picture"`
+		Avatar  string `json:"avatar"`
+		Banner  string `json:"banner"`
+		Website string `json:"website"`
+	}
+	if err := json.Unmarshal(data, &m); err != nil {
+		return nil, fmt.Errorf("invalid profile mapping: %w", err)
+	}
+	p := &importedProfile{source: "json", name: m.Name, about: m.About, website: m.Website}
+	if p.about == "" {
+		p.about = m.Bio
+	}
+	if m.Picture == "" {
+		m.Picture = m.Avatar
+	}
+	var err error
+	if p.picture, err = mappedImage(m.Picture, dir); err != nil {
+		return nil, err
+	}
+	if p.banner, err = mappedImage(m.Banner, dir); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// mappedImage loads an image named in a mapping file: a URL as is, a local
+// file into memory.
+func mappedImage(ref, dir string) (importedImage, error) {
+	if ref == "" || strings.HasPrefix(ref, "https://") || strings.HasPrefix(ref, "http://") {
+		return importedImage{url: ref}, nil
+	}
+	if !filepath.IsAbs(ref) {
+		ref = filepath.Join(dir, ref)
+	}
+	data, err := os.ReadFile(ref)
+	if err != nil {
+		return importedImage{}, err
+	}
+	if len(data) > maxMirrorSize {
+		return importedImage{}, fmt.Errorf("%s is larger than %s", ref, formatSize(maxMirrorSize))
+	}
+	return importedImage{data: data, contentType: http.DetectContentType(data)}, nil
+}
+
+// applyTo fills the setup fields the user didn't give as flags. Images
+// need the new key to upload, so they're handled by importProfile.
+func (p *importedProfile) applyTo(opts setupOpts) setupOpts {
+	if opts.name == "" {
+		opts.name = p.name
+	}
+	if opts.about == "" {
+		opts.about = p.about
+	}
+	opts.profileImport = p
+	return opts
+}
+
+// uploadImportedImage puts an imported image on the Blossom servers and
+// returns its URL there. Images only known by URL are downloaded first;
+// if that fails the original URL is used.
+func uploadImportedImage(ctx context.Context, kr nostr.Keyer, servers []string, img importedImage) (string, bool, error) {
+	data, contentType := img.data, img.contentType
+	if len(data) == 0 {
+		var err error
+		if data, contentType, err = downloadImage(ctx, img.url); err != nil {
+			return img.url, false, nil
+		}
+	}
+	var failures []string
+	_, urls := mirrorBlob(ctx, kr, servers, data, contentType, func(server, msg string) {
+		if !strings.HasPrefix(msg, "✓") {
+			failures = append(failures, server+" "+strings.TrimPrefix(msg, "✗ "))
+		}
+	})
+	if len(urls) == 0 {
+		if img.url != "" {
+			return img.url, false, nil
+		}
+		return "", false, fmt.Errorf("upload failed: %s", strings.Join(failures, ", "))
+	}
+	return urls[0], true, nil
+}
+
+// importProfile fills the profile from an import where flags left it
+// empty, uploading imported images to Blossom with the new key.
+func importProfile(p *importedProfile, opts setupOpts, kr nostr.Keyer, profile *ProfileMetadata, logln func(a ...any)) *ProfileImport {
+	result := &ProfileImport{Source: p.source, Fields: []string{}}
+	if p.name != "" && opts.name == p.name {
+		result.Fields = append(result.Fields, "name")
+	}
+	if p.about != "" && opts.about == p.about {
+		result.Fields = append(result.Fields, "about")
+	}
+	if p.website != "" {
+		profile.Website = p.website
+		result.Fields = append(result.Fields, "website")
+	}
+
+	servers := opts.blossom
+	if len(servers) == 0 {
+		servers = defaultBlossomServers
+	}
+	images := []struct {
+		field string
+		img   importedImage
+		dst   *string
+	}{
+		{"picture", p.picture, &profile.Picture},
+		{"banner", p.banner, &profile.Banner},
+	}
+	for _, im := range images {
+		if !im.img.isSet() || *im.dst != "" {
+			continue
+		}
+		logln(fmt.Sprintf("🖼️  Uploading imported %s to Blossom...", im.field))
+		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		u, uploaded, err := uploadImportedImage(ctx, kr, servers, im.img)
+		cancel()
+		switch {
+		case err != nil:
+			logln(fmt.Sprintf("⚠️  %s: %s; leaving it unset", im.field, err))
+		case uploaded:
+			*im.dst = u
+			result.Fields = append(result.Fields, im.field)
+			result.Blobs = append(result.Blobs, u)
+			logln(fmt.Sprintf("   ✓ %s", u))
+		default:
+			*im.dst = u
+			result.Fields = append(result.Fields, im.field)
+			logln(fmt.Sprintf("⚠️  couldn't re-host %s; linking %s", im.field, u))
+		}
+		logln()
+	}
+	return result
+}
@@ -0,0 +1,228 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"fiatjaf.com/nostr"
+	"fiatjaf.com/nostr/nip19"
+)
+
+// popularRelays are widely used public relays that strangers' clients are
+// likely to query. If a profile isn't on most of them, it looks blank to
+// anyone who doesn't already know the user's relays.
+var popularRelays = []string{
+	"wss://relay.damus.io",
+	"wss://nos.lol",
+	"wss://relay.primal.net",
+	"wss://purplepag.es",
+	"wss://relay.nostr.band",
+	"wss://relay.snort.social",
+	"wss://nostr.mom",
+	"wss://relay.nostr.bg",
+	"wss://nostr.oxtr.dev",
+	"wss://offchain.pub",
+	"wss://relay.nostr.net",
+	"wss://nostr-pub.wellorder.net",
+	"wss://nostr.bitcoiner.social",
+	"wss://relay.nos.social",
+	"wss://relay.0xchat.com",
+	"wss://nostr21.com",
+	"wss://relay.mostr.pub",
+	"wss://relay.noswhere.com",
+	"wss://nostr.fmt.wiz.biz",
+	"wss://relay.nostrplebs.com",
+}
+
+// PropagationCoverage records which relays hold an event kind.
+type PropagationCoverage struct {
+	Kind  int      `json:"kind"`
+	Found []string `json:"found"`
+}
+
+// fetchCoverage asks each relay on its own whether it has the latest event
+// of kind for pk. Unlike fetchKindFrom, it keeps every relay's answer.
+func fetchCoverage(ctx context.Context, relays []checkRelay, pk nostr.PubKey, kind int) []string {
+	return relaysHolding(ctx, relays, nostr.Filter{
+		Authors: []nostr.PubKey{pk},
+		Kinds:   []nostr.Kind{nostr.Kind(kind)},
+		Limit:   1,
+	})
+}
+
+// checkPropagation connects to relayURLs and adds a "propagation" check
+// reporting how many of them hold the profile (kind 0) and relay list
+// (kind 10002).
+func checkPropagation(result *CheckResult, pk nostr.PubKey, relayURLs []string) {
+	ctx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
+	defer cancel()
+
+	relays := connectCheckRelays(ctx, relayURLs)
+	defer func() {
+		for _, cr := range relays {
+			cr.relay.Close()
+		}
+	}()
+	if len(relays) == 0 {
+		result.addCheck("propagation", "warn", fmt.Sprintf("none of %d relays reachable", len(relayURLs)))
+		return
+	}
+
+	profile := fetchCoverage(ctx, relays, pk, 0)
+	relayList := fetchCoverage(ctx, relays, pk, 10002)
+	status, detail := assessPropagation(len(profile), len(relayList), len(relays), len(relayURLs))
+	result.addCheck("propagation", status, detail)
+	result.Propagation = []PropagationCoverage{
+		{Kind: 0, Found: profile},
+		{Kind: 10002, Found: relayList},
+	}
+}
+
+// assessPropagation rates coverage by the weaker of the two kinds, counting
+// only relays that could be reached.
+func assessPropagation(profile, relayList, reachable, total int) (string, string) {
+	detail := fmt.Sprintf("profile found on %d/%d, relay list on %d/%d", profile, reachable, relayList, reachable)
+	if unreachable := total - reachable; unreachable > 0 {
+		detail += fmt.Sprintf(" (%d unreachable)", unreachable)
+	}
+
+	weakest := min(profile, relayList)
+	switch {
+	case weakest*4 >= reachable*3:
+		return "pass", detail
+	case weakest*4 >= reachable:
+		return "warn", detail + " — run nihao propagate to broadcast more widely"
+	default:
+		return "fail", detail + " — strangers' clients will likely show a blank profile"
+	}
+}
+
+// propagateKinds are the identity events `nihao propagate` rebroadcasts.
+var propagateKinds = []struct {
+	kind  int
+	label string
+}{
+	{0, "profile"},
+	{10002, "relay list"},
+	{10050, "DM relays"},
+}
+
+// PropagateResult is the JSON output of `nihao propagate`.
+type PropagateResult struct {
+	Npub        string            `json:"npub"`
+	SkippedPaid []string          `json:"skipped_paid,omitempty"`
+	Events      []PropagatedEvent `json:"events"`
+}
+
+// PropagatedEvent reports where one rebroadcast event was accepted.
+type PropagatedEvent struct {
+	Kind     int             `json:"kind"`
+	ID       string          `json:"id"`
+	Accepted int             `json:"accepted"`
+	Results  []PublishResult `json:"results"`
+}
+
+// paidRelays returns the relays whose NIP-11 document says they require
+// payment. They'd reject events from a user who hasn't paid admission.
+func paidRelays(urls []string) map[string]bool {
+	paid := make(map[string]bool)
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	for _, u := range urls {
+		wg.Add(1)
+		go func(u string) {
+			defer wg.Done()
+			info, _, err := fetchNIP11(u)
+			if err == nil && info.Limitation != nil && info.Limitation.PaymentRequired {
+				mu.Lock()
+				paid[u] = true
+				mu.Unlock()
+			}
+		}(u)
+	}
+	wg.Wait()
+	return paid
+}
+
+// runPropagate rebroadcasts the latest kind 0/10002/10050 of target to a
+// wide relay set. The events are already signed, so no key is needed.
+func runPropagate(target string, jsonOutput bool, quiet bool, relays []string, to []string) {
+	if target == "" {
+		fatal("usage: nihao propagate <npub|nip05>")
+	}
+	verbose := !jsonOutput && !quiet
+	logln := func(a ...any) {
+		if verbose {
+			fmt.Println(a...)
+		}
+	}
+
+	pk, err := resolveTarget(target, !verbose)
+	if err != nil {
+		fatal("%s", err)
+	}
+	result := PropagateResult{Npub: nip19.EncodeNpub(pk), Events: []PropagatedEvent{}}
+	logln(fmt.Sprintf("nihao propagate 📣 %s", result.Npub))
+	logln()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
+	defer cancel()
+	checkRelays := connectCheckRelays(ctx, relays)
+	if len(checkRelays) == 0 {
+		fatal("could not connect to any relay")
+	}
+	var events []*nostr.Event
+	for _, k := range propagateKinds {
+		if _, evt := fetchKindFrom(ctx, checkRelays, pk, k.kind); evt != nil {
+			events = append(events, evt)
+			logln(fmt.Sprintf("📥 Found %s (kind %d)", k.label, k.kind))
+		}
+	}
+	for _, cr := range checkRelays {
+		cr.relay.Close()
+	}
+	if len(events) == 0 {
+		fatal("no profile, relay list, or DM relay list found to propagate")
+	}
+
+	if len(to) == 0 {
+		to = popularRelays
+	}
+	paid := paidRelays(to)
+	var targets []string
+	for _, u := range to {
+		if paid[u] {
+			result.SkippedPaid = append(result.SkippedPaid, u)
+		} else {
+			targets = append(targets, u)
+		}
+	}
+	if len(result.SkippedPaid) > 0 {
+		logln(fmt.Sprintf("⊘ Skipping %d paid relay(s): %s", len(result.SkippedPaid), strings.Join(result.SkippedPaid, ", ")))
+	}
+	logln()
+
+	pool := NewRelayPool(targets, !verbose)
+	defer pool.Close()
+	for _, evt := range events {
+		logln(fmt.Sprintf("📣 Broadcasting kind %d to %d relays...", evt.Kind, len(targets)))
+		pe := PropagatedEvent{Kind: int(evt.Kind), ID: evt.ID.Hex(), Results: pool.Publish(*evt)}
+		for _, r := range pe.Results {
+			if r.OK {
+				pe.Accepted++
+			}
+		}
+		result.Events = append(result.Events, pe)
+		logln(fmt.Sprintf("   → accepted by %d/%d", pe.Accepted, len(pe.Results)))
+		logln()
+	}
+
+	if jsonOutput {
+		out, _ := json.MarshalIndent(result, "", "  ")
+		fmt.Println(string(out))
+	}
+}
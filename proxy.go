@@ -0,0 +1,148 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"encoding/base64"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"time"
+
+	"golang.org/x/net/http/httpproxy"
+	xproxy "golang.org/x/net/proxy"
+)
+
+// outboundProxy picks the proxy for a request URL, or nil to go direct.
+// It reads HTTPS_PROXY, HTTP_PROXY, ALL_PROXY, and NO_PROXY unless
+// --proxy replaces it. Loopback hosts are never proxied, so a local dev
+// relay keeps working. Everything nihao dials goes through it: the HTTP
+// client, the relay WebSocket dialer, and raw TLS probes.
+var outboundProxy = envProxy()
+
+// envProxy is the proxy the environment asks for. ALL_PROXY (e.g. a
+// socks5:// Tor proxy) fills in when the per-scheme variables are unset.
+func envProxy() func(*url.URL) (*url.URL, error) {
+	cfg := httpproxy.FromEnvironment()
+	all := os.Getenv("ALL_PROXY")
+	if all == "" {
+		all = os.Getenv("all_proxy")
+	}
+	if cfg.HTTPSProxy == "" {
+		cfg.HTTPSProxy = all
+	}
+	if cfg.HTTPProxy == "" {
+		cfg.HTTPProxy = all
+	}
+	return cfg.ProxyFunc()
+}
+
+// setProxy applies --proxy: all traffic except NO_PROXY hosts goes through
+// proxyURL (http://, https://, socks5://, or socks5h://).
+func setProxy(flag, proxyURL string) {
+	u, err := url.Parse(proxyURL)
+	if err != nil || u.Host == "" {
+		fatal("invalid %s %q (use e.g. http://proxy:3128 or socks5://127.0.0.1:9050)", flag, proxyURL)
+	}
+	switch u.Scheme {
+	case "http", "https", "socks5", "socks5h":
+	default:
+		fatal("invalid %s %q: unsupported scheme %q (http, https, socks5, socks5h)", flag, proxyURL, u.Scheme)
+	}
+	noProxy := os.Getenv("NO_PROXY")
+	if noProxy == "" {
+		noProxy = os.Getenv("no_proxy")
+	}
+	cfg := httpproxy.Config{HTTPProxy: proxyURL, HTTPSProxy: proxyURL, NoProxy: noProxy}
+	outboundProxy = cfg.ProxyFunc()
+}
+
+// installProxy makes the default transport, which the nostr library's
+// relay dialer uses too, ask outboundProxy. It reads the variable on every
+// request, so --proxy can be parsed after this runs.
+func installProxy() {
+	if t, ok := http.DefaultTransport.(*http.Transport); ok {
+		t.Proxy = func(req *http.Request) (*url.URL, error) {
+			return outboundProxy(req.URL)
+		}
+	}
+}
+
+// proxyFor returns the proxy a TLS connection to addr (host:port) would
+// use, or nil.
+func proxyFor(addr string) *url.URL {
+	p, err := outboundProxy(&url.URL{Scheme: "https", Host: addr})
+	if err != nil {
+		return nil
+	}
+	return p
+}
+
+// dialOutbound opens a TCP connection to addr, through the proxy if one
+// applies: a CONNECT tunnel for http(s):// proxies, SOCKS5 otherwise.
+func dialOutbound(ctx context.Context, addr string, timeout time.Duration) (net.Conn, error) {
+	dialer := &net.Dialer{Timeout: timeout}
+	proxy := proxyFor(addr)
+	if proxy == nil {
+		return dialer.DialContext(ctx, "tcp", addr)
+	}
+	switch proxy.Scheme {
+	case "socks5", "socks5h":
+		var auth *xproxy.Auth
+		if proxy.User != nil {
+			password, _ := proxy.User.Password()
+			auth = &xproxy.Auth{User: proxy.User.Username(), Password: password}
+		}
+		socks, err := xproxy.SOCKS5("tcp", proxy.Host, auth, dialer)
+		if err != nil {
+			return nil, err
+		}
+		return socks.(xproxy.ContextDialer).DialContext(ctx, "tcp", addr)
+	default:
+		return dialConnectTunnel(ctx, dialer, proxy, addr, timeout)
+	}
+}
+
+// dialConnectTunnel asks an HTTP proxy to CONNECT to addr.
+func dialConnectTunnel(ctx context.Context, dialer *net.Dialer, proxy *url.URL, addr string, timeout time.Duration) (net.Conn, error) {
+	port := proxy.Port()
+	if port == "" {
+		port = map[string]string{"http": "80", "https": "443"}[proxy.Scheme]
+	}
+	conn, err := dialer.DialContext(ctx, "tcp", net.JoinHostPort(proxy.Hostname(), port))
+	if err != nil {
+		return nil, fmt.Errorf("proxy %s: %w", proxy.Host, err)
+	}
+	if proxy.Scheme == "https" {
+		conn = tls.Client(conn, &tls.Config{ServerName: proxy.Hostname()})
+	}
+
+	conn.SetDeadline(time.Now().Add(timeout))
+	req := &http.Request{Method: "CONNECT", URL: &url.URL{Opaque: addr}, Host: addr, Header: make(http.Header)}
+	if proxy.User != nil {
+		password, _ := proxy.User.Password()
+		creds := base64.StdEncoding.EncodeToString([]byte(proxy.User.Username() + ":" + password))
+		req.Header.Set("Proxy-Authorization", "Basic "+creds)
+	}
+	if err := req.Write(conn); err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("proxy %s: %w", proxy.Host, err)
+	}
+	// The server says nothing until the client does, so the reader can't
+	// swallow tunneled bytes
+	resp, err := http.ReadResponse(bufio.NewReader(conn), req)
+	if err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("proxy %s: %w", proxy.Host, err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		conn.Close()
+		return nil, fmt.Errorf("proxy %s refused CONNECT to %s: %s", proxy.Host, addr, resp.Status)
+	}
+	conn.SetDeadline(time.Time{})
+	return conn, nil
+}
@@ -0,0 +1,172 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Relays and NIP-05/LNURL hosts defend against abuse by throttling or
+// banning IPs that hit them too fast, and batch setup, relay discovery, and
+// a deep check can easily look like that. Every request nihao makes is
+// paced per host (HTTP) and per relay (WebSocket connections and REQs).
+// Set by --rate-limit or $NIHAO_RATE_LIMIT.
+var (
+	httpLimiter  = newRateLimiter(5)  // requests per second per HTTP host
+	relayLimiter = newRateLimiter(10) // connections + REQs per second per relay
+)
+
+// rateLimiter is a token bucket per key: up to burst requests go out at
+// once, then one per 1/rate seconds. A zero rate disables it.
+type rateLimiter struct {
+	mu      sync.Mutex
+	rate    float64
+	burst   int
+	next    map[string]time.Time // when each key's bucket is next empty
+	total   int
+	delayed int
+	waited  time.Duration
+}
+
+func newRateLimiter(rate float64) *rateLimiter {
+	l := &rateLimiter{next: make(map[string]time.Time)}
+	l.setRate(rate)
+	return l
+}
+
+// setRate changes the budget; the burst is two seconds' worth.
+func (l *rateLimiter) setRate(rate float64) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.rate = rate
+	l.burst = max(1, int(2*rate))
+}
+
+// wait blocks until key has budget for one more request, or ctx is done.
+func (l *rateLimiter) wait(ctx context.Context, key string) error {
+	l.mu.Lock()
+	l.total++
+	if l.rate <= 0 {
+		l.mu.Unlock()
+		return nil
+	}
+	interval := time.Duration(float64(time.Second) / l.rate)
+	now := time.Now()
+	next := l.next[key]
+	if next.Before(now) {
+		next = now
+	}
+	start := next.Add(-time.Duration(l.burst-1) * interval)
+	l.next[key] = next.Add(interval)
+	delay := start.Sub(now)
+	if delay > 0 {
+		l.delayed++
+		l.waited += delay
+	}
+	l.mu.Unlock()
+
+	if delay <= 0 {
+		return nil
+	}
+	t := time.NewTimer(delay)
+	defer t.Stop()
+	select {
+	case <-t.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// limiterKey is the host a URL's requests are budgeted under.
+func limiterKey(rawURL string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil || u.Host == "" {
+		return rawURL
+	}
+	return strings.ToLower(u.Host)
+}
+
+// rateLimitedTransport paces requests through the limiters. WebSocket
+// handshakes are HTTP requests too, so relay connections made through
+// the default client count against the relay's budget here.
+type rateLimitedTransport struct {
+	base  http.RoundTripper
+	http  *rateLimiter
+	relay *rateLimiter
+}
+
+func (t *rateLimitedTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	l := t.http
+	if strings.EqualFold(req.Header.Get("Upgrade"), "websocket") {
+		l = t.relay
+	}
+	if err := l.wait(req.Context(), strings.ToLower(req.URL.Host)); err != nil {
+		return nil, err
+	}
+	return t.base.RoundTrip(req)
+}
+
+// installRateLimiter routes every client that uses the default transport,
+// including the nostr library's relay dialer, through the limiters.
+func installRateLimiter() {
+	http.DefaultTransport = &rateLimitedTransport{base: http.DefaultTransport, http: httpLimiter, relay: relayLimiter}
+}
+
+// parseRateLimit reads a budget like "http=5,relay=10" (requests per
+// second per host and per relay), or "0"/"off" to disable both.
+func parseRateLimit(flag, s string) (httpRate, relayRate float64) {
+	httpRate, relayRate = httpLimiter.rate, relayLimiter.rate
+	if s == "0" || s == "off" {
+		return 0, 0
+	}
+	for _, part := range strings.Split(s, ",") {
+		name, value, ok := strings.Cut(strings.TrimSpace(part), "=")
+		rate, err := strconv.ParseFloat(value, 64)
+		if !ok || err != nil || rate < 0 {
+			fatal("invalid %s %q (use e.g. http=5,relay=10, or 0 to disable)", flag, s)
+		}
+		switch name {
+		case "http":
+			httpRate = rate
+		case "relay":
+			relayRate = rate
+		default:
+			fatal("invalid %s %q: unknown budget %q (http or relay)", flag, s, name)
+		}
+	}
+	return httpRate, relayRate
+}
+
+// setRateLimit applies a --rate-limit or $NIHAO_RATE_LIMIT value.
+func setRateLimit(flag, s string) {
+	httpRate, relayRate := parseRateLimit(flag, s)
+	httpLimiter.setRate(httpRate)
+	relayLimiter.setRate(relayRate)
+}
+
+// describe summarizes one limiter, e.g. "5/s per HTTP host (12 requests,
+// 2 delayed 0.4s)".
+func (l *rateLimiter) describe(per string) string {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.rate <= 0 {
+		return fmt.Sprintf("unlimited per %s (%d requests)", per, l.total)
+	}
+	s := fmt.Sprintf("%s/s per %s (%d requests", strconv.FormatFloat(l.rate, 'f', -1, 64), per, l.total)
+	if l.delayed > 0 {
+		s += fmt.Sprintf(", %d delayed %.1fs", l.delayed, l.waited.Seconds())
+	}
+	return s + ")"
+}
+
+// printRequestBudget shows the budget and how much it slowed this run.
+func printRequestBudget(w io.Writer) {
+	fmt.Fprintf(w, "\n  ⏱️  Request budget: %s, %s (--rate-limit)\n", httpLimiter.describe("HTTP host"), relayLimiter.describe("relay"))
+}
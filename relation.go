@@ -0,0 +1,206 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"fiatjaf.com/nostr"
+	"fiatjaf.com/nostr/nip19"
+)
+
+// RelationResult is the JSON output of `nihao check --relation`: whether
+// two identities can actually find and message each other.
+type RelationResult struct {
+	A            string      `json:"a"`
+	B            string      `json:"b"`
+	AFollowsB    bool        `json:"a_follows_b"`
+	BFollowsA    bool        `json:"b_follows_a"`
+	SharedRelays []string    `json:"shared_relays"`
+	ASeesB       []string    `json:"a_sees_b"` // A's read relays that B writes to
+	BSeesA       []string    `json:"b_sees_a"`
+	Checks       []CheckItem `json:"checks"`
+}
+
+// relationSide is what one identity has published that matters for the
+// relationship: follows (kind 3), relays (kind 10002), DM relays (10050).
+type relationSide struct {
+	pk       nostr.PubKey
+	follows  *nostr.Event
+	relays   *nostr.Event
+	dmRelays *nostr.Event
+}
+
+// readRelays returns the relays a kind 10002 list marks for reading
+// (bare entries count as read+write).
+func readRelays(evt *nostr.Event) []string {
+	var urls []string
+	for _, tag := range evt.Tags {
+		if len(tag) >= 2 && tag[0] == "r" && (len(tag) < 3 || tag[2] == "read") {
+			urls = append(urls, tag[1])
+		}
+	}
+	return urls
+}
+
+// intersectRelays returns the relays in both lists, compared normalized,
+// in the order of a.
+func intersectRelays(a, b []string) []string {
+	in := make(map[string]bool)
+	for _, u := range b {
+		in[normalizeRelayURL(u)] = true
+	}
+	shared := []string{}
+	for _, u := range a {
+		if n := normalizeRelayURL(u); n != "" && in[n] && !slices.Contains(shared, n) {
+			shared = append(shared, n)
+		}
+	}
+	return shared
+}
+
+func follows(list *nostr.Event, pk nostr.PubKey) bool {
+	if list == nil {
+		return false
+	}
+	for _, tag := range list.Tags {
+		if len(tag) >= 2 && tag[0] == "p" && tag[1] == pk.Hex() {
+			return true
+		}
+	}
+	return false
+}
+
+// assessRelation compares two identities' published lists.
+func assessRelation(a, b relationSide) RelationResult {
+	r := RelationResult{
+		A:         nip19.EncodeNpub(a.pk),
+		B:         nip19.EncodeNpub(b.pk),
+		AFollowsB: follows(a.follows, b.pk),
+		BFollowsA: follows(b.follows, a.pk),
+	}
+	add := func(name, status, detail string) {
+		r.Checks = append(r.Checks, CheckItem{Name: name, Status: status, Detail: detail})
+	}
+
+	switch {
+	case r.AFollowsB && r.BFollowsA:
+		add("follows", "pass", "mutual follow")
+	case r.AFollowsB:
+		add("follows", "warn", "A follows B, B doesn't follow A")
+	case r.BFollowsA:
+		add("follows", "warn", "B follows A, A doesn't follow B")
+	default:
+		add("follows", "warn", "neither follows the other")
+	}
+
+	if a.relays == nil || b.relays == nil {
+		missing := "A"
+		if a.relays != nil {
+			missing = "B"
+		} else if b.relays == nil {
+			missing = "A and B"
+		}
+		add("shared_relays", "warn", missing+" publish no relay list (kind 10002)")
+	} else {
+		r.SharedRelays = intersectRelays(relayTags(a.relays), relayTags(b.relays))
+		if len(r.SharedRelays) > 0 {
+			add("shared_relays", "pass", strings.Join(r.SharedRelays, ", "))
+		} else {
+			add("shared_relays", "warn", "no relay in common")
+		}
+
+		// Can each side's client find the other's notes on the relays it reads?
+		r.ASeesB = intersectRelays(readRelays(a.relays), writeRelays(b.relays))
+		r.BSeesA = intersectRelays(readRelays(b.relays), writeRelays(a.relays))
+		for _, dir := range []struct {
+			name, reader, writer string
+			via                  []string
+		}{
+			{"a_sees_b", "A", "B", r.ASeesB},
+			{"b_sees_a", "B", "A", r.BSeesA},
+		} {
+			if len(dir.via) > 0 {
+				add(dir.name, "pass", fmt.Sprintf("%s reads %s's notes via %s", dir.reader, dir.writer, strings.Join(dir.via, ", ")))
+			} else {
+				add(dir.name, "fail", fmt.Sprintf("%s reads none of the relays %s writes to — only outbox-aware clients will show %s's notes", dir.reader, dir.writer, dir.writer))
+			}
+		}
+	}
+
+	// NIP-17 DMs go to the recipient's kind 10050 relays
+	for _, dir := range []struct {
+		name, from, to string
+		side           relationSide
+	}{
+		{"dm_a_to_b", "A", "B", b},
+		{"dm_b_to_a", "B", "A", a},
+	} {
+		var urls []string
+		if dir.side.dmRelays != nil {
+			urls = relayTags(dir.side.dmRelays)
+		}
+		if len(urls) > 0 {
+			add(dir.name, "pass", fmt.Sprintf("%s can DM %s via %s", dir.from, dir.to, strings.Join(urls, ", ")))
+		} else {
+			add(dir.name, "warn", fmt.Sprintf("%s has no DM relay list (kind 10050): NIP-17 DMs from %s have nowhere to go", dir.to, dir.from))
+		}
+	}
+	return r
+}
+
+// runRelation fetches both identities' lists and reports how they relate.
+func runRelation(left, right string, jsonOutput bool, quiet bool, relays []string) {
+	var sides [2]relationSide
+	for i, target := range []string{left, right} {
+		pk, err := resolveTarget(target, jsonOutput || quiet)
+		if err != nil {
+			fatal("%s: %s", target, err)
+		}
+		sides[i].pk = pk
+	}
+
+	if !jsonOutput && !quiet {
+		fmt.Printf("nihao relation 🤝 %s ↔ %s\n\n", left, right)
+	}
+
+	connectCtx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
+	checkRelays := connectCheckRelays(connectCtx, relays)
+	cancel()
+	if len(checkRelays) == 0 {
+		fatal("could not connect to any relay")
+	}
+	defer func() {
+		for _, cr := range checkRelays {
+			cr.relay.Close()
+		}
+	}()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
+	defer cancel()
+	for i := range sides {
+		_, sides[i].follows = fetchKindFrom(ctx, checkRelays, sides[i].pk, 3)
+		_, sides[i].relays = fetchKindFrom(ctx, checkRelays, sides[i].pk, 10002)
+		_, sides[i].dmRelays = fetchKindFrom(ctx, checkRelays, sides[i].pk, 10050)
+	}
+
+	result := assessRelation(sides[0], sides[1])
+	if jsonOutput {
+		out, _ := json.MarshalIndent(result, "", "  ")
+		fmt.Println(string(out))
+		return
+	}
+	if quiet {
+		return
+	}
+	fmt.Printf("  A: %s\n", result.A)
+	fmt.Printf("  B: %s\n", result.B)
+	fmt.Println()
+	icon := map[string]string{"pass": "✅", "warn": "⚠️ ", "fail": "❌"}
+	for _, c := range result.Checks {
+		fmt.Printf("  %s %s: %s\n", icon[c.Status], c.Name, c.Detail)
+	}
+}
@@ -3,56 +3,85 @@ package main
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
+	"net"
 	"net/http"
+	"net/url"
 	"sort"
+	"strconv"
 	"strings"
 	"sync"
 	"time"
 
 	"fiatjaf.com/nostr"
+	"github.com/coder/websocket"
+	"golang.org/x/net/idna"
 )
 
 // NIP-11 relay information document
 type RelayInfo struct {
-	Name          string   `json:"name"`
-	Description   string   `json:"description"`
-	Pubkey        string   `json:"pubkey"`
-	Contact       string   `json:"contact"`
-	SupportedNIPs []int    `json:"supported_nips"`
-	Software      string   `json:"software"`
-	Version       string   `json:"version"`
+	Name          string           `json:"name"`
+	Description   string           `json:"description"`
+	Pubkey        string           `json:"pubkey"`
+	Contact       string           `json:"contact"`
+	SupportedNIPs []int            `json:"supported_nips"`
+	Software      string           `json:"software"`
+	Version       string           `json:"version"`
 	Limitation    *RelayLimitation `json:"limitation,omitempty"`
-	PaymentRequired bool   `json:"payments_url,omitempty"`
+	PaymentsURL   string           `json:"payments_url,omitempty"`
+	Fees          *RelayFees       `json:"fees,omitempty"`
 }
 
 type RelayLimitation struct {
-	MaxMessageLength int  `json:"max_message_length"`
-	MaxSubscriptions int  `json:"max_subscriptions"`
-	MaxFilters       int  `json:"max_filters"`
-	MaxEventTags     int  `json:"max_event_tags"`
-	MaxContentLength int  `json:"max_content_length"`
-	AuthRequired     bool `json:"auth_required"`
-	PaymentRequired  bool `json:"payment_required"`
+	MaxMessageLength int   `json:"max_message_length"`
+	MaxSubscriptions int   `json:"max_subscriptions"`
+	MaxFilters       int   `json:"max_filters"`
+	MaxEventTags     int   `json:"max_event_tags"`
+	MaxContentLength int   `json:"max_content_length"`
+	MaxLimit         int   `json:"max_limit"`
+	MinPowDifficulty int   `json:"min_pow_difficulty"`
+	CreatedAtLower   int64 `json:"created_at_lower_limit"` // seconds before now
+	CreatedAtUpper   int64 `json:"created_at_upper_limit"` // seconds after now
+	AuthRequired     bool  `json:"auth_required"`
+	PaymentRequired  bool  `json:"payment_required"`
+	RestrictedWrites bool  `json:"restricted_writes"`
 }
 
 // RelayScore holds quality metrics for a single relay
 type RelayScore struct {
-	URL          string      `json:"url"`
-	Reachable    bool        `json:"reachable"`
-	LatencyMs    int64       `json:"latency_ms"`
-	Info         *RelayInfo  `json:"info,omitempty"`
-	HasNIP11     bool        `json:"has_nip11"`
-	SupportsRead bool        `json:"supports_read"`
-	SupportsWrite bool       `json:"supports_write"`
-	AuthRequired bool        `json:"auth_required"`
-	PaymentRequired bool     `json:"payment_required"`
-	Score        float64     `json:"score"`       // 0.0 - 1.0
-	Purpose      string      `json:"purpose"`     // "general", "outbox", "inbox", "specialized"
-	Issues       []string    `json:"issues,omitempty"`
+	URL             string     `json:"url"`
+	Reachable       bool       `json:"reachable"`
+	LatencyMs       int64      `json:"latency_ms"` // p50 of the latency samples, used in scoring
+	LatencyP95Ms    int64      `json:"latency_p95_ms,omitempty"`
+	LatencySamples  int        `json:"latency_samples,omitempty"` // REQ round trips timed over one connection
+	PingMs          int64      `json:"ping_ms,omitempty"`         // WebSocket PING/PONG round trip
+	Hung            bool       `json:"hung,omitempty"`            // accepts connections but doesn't answer a ping
+	TLS             *TLSInfo   `json:"tls,omitempty"`             // wss:// only
+	Info            *RelayInfo `json:"info,omitempty"`
+	HasNIP11        bool       `json:"has_nip11"`
+	SupportsRead    bool       `json:"supports_read"`
+	SupportsWrite   bool       `json:"supports_write"`          // took an event from a throwaway key
+	WriteProbe      string     `json:"write_probe,omitempty"`   // "ephemeral", or "expiring" where ephemeral kinds are refused
+	WriteRefusal    string     `json:"write_refusal,omitempty"` // the relay's answer when it refused
+	AuthRequired    bool       `json:"auth_required"`
+	PaymentRequired bool       `json:"payment_required"`
+	Score           float64    `json:"score"`   // 0.0 - 1.0
+	Purpose         string     `json:"purpose"` // "general", "outbox", "inbox", "specialized"
+	Issues          []string   `json:"issues,omitempty"`
+	DistanceKm      float64    `json:"distance_km,omitempty"` // from --near, via NIP-66 geohash
+	Network         string     `json:"network,omitempty"`     // hosting network prefix, for diversity
 }
 
+// latencySamples is how many round trips are timed per relay. A single
+// sample is easily skewed by one slow reply, so scoring uses the median.
+const latencySamples = 5
+
+// relayScoreTimeout caps how long scoring one relay takes in all, however
+// many of its probes hang.
+var relayScoreTimeout = 10 * time.Second
+
 // ──────────────────────────────────────────────────────────────
 // Relay classification config
 //
@@ -84,10 +113,10 @@ var urlPatterns = []struct {
 	pattern string
 	purpose string
 }{
-	{"/inbox", "inbox"},     // e.g. pyramid.fiatjaf.com/inbox
-	{"nwc.", "nwc"},         // NWC endpoints, not general relays
-	{"pyramid.", "paid"},    // pyramid relays require membership
-	{"premium.", "paid"},    // premium tier relays
+	{"/inbox", "inbox"},  // e.g. pyramid.fiatjaf.com/inbox
+	{"nwc.", "nwc"},      // NWC endpoints, not general relays
+	{"pyramid.", "paid"}, // pyramid relays require membership
+	{"premium.", "paid"}, // premium tier relays
 }
 
 // wellConnectedNpubs are hex pubkeys of well-known, well-connected users.
@@ -137,11 +166,16 @@ func classifyRelay(relayURL string) string {
 
 // fetchNIP11 fetches the NIP-11 relay information document
 func fetchNIP11(relayURL string) (*RelayInfo, time.Duration, error) {
+	return fetchNIP11Context(context.Background(), relayURL)
+}
+
+// fetchNIP11Context is fetchNIP11, giving up when ctx is done.
+func fetchNIP11Context(ctx context.Context, relayURL string) (*RelayInfo, time.Duration, error) {
 	// Convert wss:// to https:// for NIP-11
 	httpURL := strings.Replace(relayURL, "wss://", "https://", 1)
 	httpURL = strings.Replace(httpURL, "ws://", "http://", 1)
 
-	req, err := http.NewRequest("GET", httpURL, nil)
+	req, err := http.NewRequestWithContext(ctx, "GET", httpURL, nil)
 	if err != nil {
 		return nil, 0, err
 	}
@@ -173,65 +207,247 @@ func fetchNIP11(relayURL string) (*RelayInfo, time.Duration, error) {
 	return &info, latency, nil
 }
 
-// testRelayReadWrite does a quick connect + read test
-func testRelayReadWrite(relayURL string) (canConnect bool, latency time.Duration, err error) {
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+// connectRelay opens a long-lived relay connection, giving up after timeout.
+//
+// The nostr library ties the websocket's lifetime to the context passed to
+// RelayConnect: when that context is cancelled or its deadline passes, the
+// connection is closed. So we can't dial with a timeout context. Instead we
+// dial with a background context (the library caps the dial itself at 7s)
+// and just stop waiting after timeout; a late connection is closed.
+func connectRelay(url string, timeout time.Duration) (*nostr.Relay, error) {
+	type dialResult struct {
+		relay *nostr.Relay
+		err   error
+	}
+	ch := make(chan dialResult, 1)
+	go func() {
+		relay, err := nostr.RelayConnect(context.Background(), url, nostr.RelayOptions{})
+		ch <- dialResult{relay, err}
+	}()
+
+	select {
+	case r := <-ch:
+		return r.relay, r.err
+	case <-time.After(timeout):
+		go func() {
+			if r := <-ch; r.err == nil {
+				r.relay.Close()
+			}
+		}()
+		return nil, fmt.Errorf("connection to %s timed out", url)
+	}
+}
+
+// pingTimeout is how long a relay gets to answer a WebSocket ping before
+// it's considered hung.
+var pingTimeout = 3 * time.Second
+
+// errNoPong means the relay accepted the connection but never answered a
+// WebSocket ping.
+var errNoPong = errors.New("connected, but no pong")
+
+// pingRelay connects and times a protocol-level WebSocket PING/PONG. A
+// relay can complete the handshake and still be stuck, so a connection
+// alone doesn't prove it's alive.
+func pingRelay(relayURL string) (time.Duration, error) {
+	p := probeRelayConn(context.Background(), relayURL, 0)
+	return p.ping, p.err
+}
+
+// connProbe is what one WebSocket connection to a relay showed.
+type connProbe struct {
+	ping    time.Duration
+	samples []time.Duration // REQ round trips
+	err     error           // errNoPong if it connected but never answered the ping
+}
+
+// probeRelayConn opens one WebSocket connection, times a PING/PONG on it,
+// then times up to n round trips over it: a REQ with limit 0, which any
+// relay answers right away with EOSE (or CLOSED). Sampling stops at the
+// first REQ that goes unanswered.
+func probeRelayConn(ctx context.Context, relayURL string, n int) connProbe {
+	var p connProbe
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second+pingTimeout+time.Duration(n)*5*time.Second)
 	defer cancel()
 
+	dialCtx, cancelDial := context.WithTimeout(ctx, 5*time.Second)
+	conn, _, err := websocket.Dial(dialCtx, relayURL, nil)
+	cancelDial()
+	if err != nil {
+		p.err = err
+		return p
+	}
+	defer conn.CloseNow()
+
+	// Pongs are only processed while reading. Replies that end a REQ are
+	// passed on; anything else (e.g. an AUTH challenge) is dropped
+	conn.SetReadLimit(-1)
+	ends := make(chan string, 4)
+	go func() {
+		for {
+			_, data, err := conn.Read(ctx)
+			if err != nil {
+				return
+			}
+			var msg []json.RawMessage
+			var label, subID string
+			if json.Unmarshal(data, &msg) != nil || len(msg) < 2 || json.Unmarshal(msg[0], &label) != nil || json.Unmarshal(msg[1], &subID) != nil {
+				continue
+			}
+			if label == "EOSE" || label == "CLOSED" {
+				select {
+				case ends <- subID:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}
+	}()
+
+	pingCtx, cancelPing := context.WithTimeout(ctx, pingTimeout)
 	start := time.Now()
-	relay, err := nostr.RelayConnect(ctx, relayURL, nostr.RelayOptions{})
-	latency = time.Since(start)
+	err = conn.Ping(pingCtx)
+	cancelPing()
 	if err != nil {
-		return false, latency, err
+		p.err = errNoPong
+		return p
+	}
+	p.ping = time.Since(start)
+
+	for i := 0; i < n; i++ {
+		subID := fmt.Sprintf("nihao-latency-%d", i)
+		req := fmt.Sprintf(`["REQ",%q,{"kinds":[0],"limit":0}]`, subID)
+		reqCtx, cancelReq := context.WithTimeout(ctx, 5*time.Second)
+		start := time.Now()
+		answered := conn.Write(reqCtx, websocket.MessageText, []byte(req)) == nil && awaitEnd(reqCtx, ends, subID)
+		cancelReq()
+		if !answered {
+			if len(p.samples) == 0 {
+				p.err = fmt.Errorf("no response to REQ")
+			}
+			break
+		}
+		p.samples = append(p.samples, time.Since(start))
+		conn.Write(ctx, websocket.MessageText, []byte(fmt.Sprintf(`["CLOSE",%q]`, subID)))
 	}
-	defer relay.Close()
+	return p
+}
 
-	return true, latency, nil
+// awaitEnd waits for the EOSE or CLOSED of subscription subID.
+func awaitEnd(ctx context.Context, ends <-chan string, subID string) bool {
+	for {
+		select {
+		case id := <-ends:
+			if id == subID {
+				return true
+			}
+		case <-ctx.Done():
+			return false
+		}
+	}
 }
 
-// ScoreRelay evaluates a single relay's quality
-func ScoreRelay(relayURL string) RelayScore {
+// ScoreRelay evaluates a single relay's quality. NIP-11, the certificate,
+// and the connection are probed at once, then the write probe runs, all
+// within relayScoreTimeout and ctx.
+func ScoreRelay(ctx context.Context, relayURL string) RelayScore {
+	ctx, cancel := context.WithTimeout(ctx, relayScoreTimeout)
+	defer cancel()
+
 	rs := RelayScore{
 		URL:     relayURL,
-		Purpose: "general",
+		Purpose: classifyRelay(relayURL),
 	}
 
-	// Classify relay purpose
-	rs.Purpose = classifyRelay(relayURL)
+	var info *RelayInfo
+	var tlsInfo *TLSInfo
+	var conn connProbe
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		info, _, _ = fetchNIP11Context(ctx, relayURL)
+	}()
+	// Check the certificate on its own, so a bad one is reported as such
+	if host, port, ok := tlsHostPort(relayURL); ok {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			tlsCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+			defer cancel()
+			tlsInfo = probeTLS(tlsCtx, host, port)
+		}()
+	}
+	// Test WebSocket liveness, then time several round trips for a stable
+	// latency. A relay that connects but never pongs is hung, not reachable
+	go func() {
+		defer wg.Done()
+		conn = probeRelayConn(ctx, relayURL, latencySamples)
+	}()
+	wg.Wait()
 
-	// Fetch NIP-11
-	info, nip11Latency, err := fetchNIP11(relayURL)
-	if err == nil && info != nil {
+	if info != nil {
 		rs.HasNIP11 = true
 		rs.Info = info
-		rs.LatencyMs = nip11Latency.Milliseconds()
 		if info.Limitation != nil {
 			rs.AuthRequired = info.Limitation.AuthRequired
 			rs.PaymentRequired = info.Limitation.PaymentRequired
 		}
 	}
-
-	// Test WebSocket connectivity
-	canConnect, wsLatency, err := testRelayReadWrite(relayURL)
-	rs.Reachable = canConnect
-	if canConnect {
-		// Use WS latency if we didn't get NIP-11 latency
-		if rs.LatencyMs == 0 {
-			rs.LatencyMs = wsLatency.Milliseconds()
-		}
+	rs.TLS = tlsInfo
+	rs.Hung = errors.Is(conn.err, errNoPong)
+	rs.PingMs = conn.ping.Milliseconds()
+	rs.Reachable = len(conn.samples) > 0
+	if rs.Reachable {
+		rs.Network = relayNetwork(relayURL)
+		rs.LatencyMs = medianLatency(conn.samples).Milliseconds()
+		rs.LatencyP95Ms = latencyPercentile(conn.samples, 95).Milliseconds()
+		rs.LatencySamples = len(conn.samples)
 		rs.SupportsRead = true
-		rs.SupportsWrite = true // assume until proven otherwise
+		rs.SupportsWrite, rs.WriteProbe, rs.WriteRefusal = probeWrite(ctx, relayURL)
 	}
 
 	// Calculate score (0.0 - 1.0)
-	rs.Score = calculateRelayScore(rs)
+	rs.Score = calculateRelayScore(&rs)
 
 	return rs
 }
 
-func calculateRelayScore(rs RelayScore) float64 {
+// medianLatency returns the median of samples (the lower middle for an
+// even count, so one slow outlier never wins).
+func medianLatency(samples []time.Duration) time.Duration {
+	if len(samples) == 0 {
+		return 0
+	}
+	sorted := append([]time.Duration(nil), samples...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+	return sorted[(len(sorted)-1)/2]
+}
+
+// latencyPercentile returns the p-th percentile of samples by nearest
+// rank, e.g. the slowest of up to 20 samples for p95.
+func latencyPercentile(samples []time.Duration, p int) time.Duration {
+	if len(samples) == 0 {
+		return 0
+	}
+	sorted := append([]time.Duration(nil), samples...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+	rank := (p*len(sorted) + 99) / 100
+	if rank < 1 {
+		rank = 1
+	}
+	return sorted[rank-1]
+}
+
+// calculateRelayScore scores rs from 0 to 1 and records what cost it points
+// in rs.Issues.
+func calculateRelayScore(rs *RelayScore) float64 {
 	if !rs.Reachable {
-		rs.Issues = append(rs.Issues, "unreachable")
+		if rs.Hung {
+			rs.Issues = append(rs.Issues, "hung (no pong)")
+		} else {
+			rs.Issues = append(rs.Issues, "unreachable")
+		}
 		return 0.0
 	}
 
@@ -277,102 +493,228 @@ func calculateRelayScore(rs RelayScore) float64 {
 	return score
 }
 
-// ScoreRelays evaluates multiple relays in parallel
-func ScoreRelays(urls []string) []RelayScore {
+// ScoreRelays evaluates multiple relays in parallel, giving up on those
+// still being probed when ctx is done. Scores younger than relayCacheTTL
+// are reused from the on-disk cache; only stale or unknown relays are
+// probed, and only scores that weren't cut short are cached.
+func ScoreRelays(ctx context.Context, urls []string) []RelayScore {
 	scores := make([]RelayScore, len(urls))
 	var wg sync.WaitGroup
 
+	cachePath := ""
+	if relayCacheTTL > 0 {
+		cachePath = relayCachePath()
+	}
+	cache := loadRelayScoreCache(cachePath)
+	now := time.Now()
+
+	probed := make([]bool, len(urls))
 	for i, url := range urls {
+		if c, ok := cache[url]; ok && c.fresh(now, relayCacheTTL) {
+			scores[i] = c.Score
+			continue
+		}
+		probed[i] = true
 		wg.Add(1)
 		go func(i int, url string) {
 			defer wg.Done()
-			scores[i] = ScoreRelay(url)
+			scores[i] = ScoreRelay(ctx, url)
+			if ctx.Err() != nil {
+				probed[i] = false // cut short, so not worth caching
+			}
 		}(i, url)
 	}
 
 	wg.Wait()
+
+	if cachePath != "" {
+		for i, rs := range scores {
+			if probed[i] {
+				cache[rs.URL] = cachedRelayScore{Score: rs, ScoredAt: now}
+			}
+		}
+		saveRelayScoreCache(cachePath, cache, now, relayCacheTTL)
+	}
 	return scores
 }
 
+// maxDiscoveryCandidates caps how many relays discovery scores. Sampling a
+// few hundred follows can turn up far more relays than are worth probing,
+// so only the most widely used ones are kept.
+const maxDiscoveryCandidates = 40
+
 // DiscoverRelays fetches relay lists (kind 10002) from well-known npubs
 // and returns a deduplicated, scored list of relays
 func DiscoverRelays(seedRelays []string) []RelayScore {
+	var authors []nostr.PubKey
+	for _, hex := range wellConnectedNpubs {
+		if pk, err := nostr.PubKeyFromHex(hex); err == nil {
+			authors = append(authors, pk)
+		}
+	}
+	return DiscoverRelaysFrom(seedRelays, authors)
+}
+
+// DiscoverRelaysFrom is DiscoverRelays sampling the given authors' relay
+// lists instead, e.g. the npubs a user follows.
+func DiscoverRelaysFrom(seedRelays []string, authors []nostr.PubKey) []RelayScore {
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
 
-	relaySet := make(map[string]int) // url -> count of npubs using it
+	relays := connectCheckRelays(ctx, seedRelays)
+	lists := fetchLatestByAuthor(ctx, relays, authors, 10002)
+	for _, cr := range relays {
+		cr.relay.Close()
+	}
 
-	var mu sync.Mutex
-	var wg sync.WaitGroup
+	urls := topRelaysByUsage(countRelayUsage(lists), maxDiscoveryCandidates)
 
-	for _, hexKey := range wellConnectedNpubs {
-		wg.Add(1)
-		go func(hex string) {
-			defer wg.Done()
-			pk, err := nostr.PubKeyFromHex(hex)
-			if err != nil {
-				return
-			}
-			filter := nostr.Filter{
-				Authors: []nostr.PubKey{pk},
-				Kinds:   []nostr.Kind{10002},
-				Limit:   1,
-			}
+	// Score all discovered relays in parallel
+	scores := ScoreRelays(context.Background(), urls)
 
-			for _, seedURL := range seedRelays {
-				relayCtx, relayCancel := context.WithTimeout(ctx, 5*time.Second)
-				relay, err := nostr.RelayConnect(relayCtx, seedURL, nostr.RelayOptions{})
-				if err != nil {
-					relayCancel()
-					continue
-				}
+	// Sort by score descending
+	sort.Slice(scores, func(i, j int) bool {
+		return scores[i].Score > scores[j].Score
+	})
 
-				for evt := range relay.QueryEvents(filter) {
-					for _, tag := range evt.Tags {
-						if len(tag) >= 2 && tag[0] == "r" {
-							url := normalizeRelayURL(tag[1])
-							if url != "" {
-								mu.Lock()
-								relaySet[url]++
-								mu.Unlock()
-							}
-						}
+	return scores
+}
+
+// fetchLatestByAuthor queries every relay for kind from all authors at
+// once (in chunks, since relays cap filter sizes) and keeps the newest
+// event per author.
+func fetchLatestByAuthor(ctx context.Context, relays []checkRelay, authors []nostr.PubKey, kind int) []*nostr.Event {
+	const chunkSize = 200
+
+	latest := make(map[nostr.PubKey]*nostr.Event)
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	for start := 0; start < len(authors); start += chunkSize {
+		filter := nostr.Filter{
+			Authors: authors[start:min(start+chunkSize, len(authors))],
+			Kinds:   []nostr.Kind{nostr.Kind(kind)},
+		}
+		for _, cr := range relays {
+			wg.Add(1)
+			go func(cr checkRelay) {
+				defer wg.Done()
+				for evt := range cr.queryEvents(filter) {
+					mu.Lock()
+					if cur := latest[evt.PubKey]; cur == nil || evt.CreatedAt > cur.CreatedAt {
+						latest[evt.PubKey] = &evt
 					}
+					mu.Unlock()
 				}
-				relay.Close()
-				relayCancel()
-				break // got it from one seed, move on
-			}
-		}(hexKey)
+			}(cr)
+		}
 	}
 
-	wg.Wait()
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-ctx.Done():
+	}
 
-	// Collect unique URLs
-	var urls []string
-	for url := range relaySet {
-		urls = append(urls, url)
+	mu.Lock()
+	defer mu.Unlock()
+	events := make([]*nostr.Event, 0, len(latest))
+	for _, evt := range latest {
+		events = append(events, evt)
 	}
+	return events
+}
 
-	// Score all discovered relays in parallel
-	scores := ScoreRelays(urls)
+// countRelayUsage counts how many relay lists include each relay.
+func countRelayUsage(lists []*nostr.Event) map[string]int {
+	usage := make(map[string]int)
+	for _, evt := range lists {
+		seen := make(map[string]bool)
+		for _, tag := range evt.Tags {
+			if len(tag) >= 2 && tag[0] == "r" {
+				if url := normalizeRelayURL(tag[1]); url != "" && !seen[url] {
+					seen[url] = true
+					usage[url]++
+				}
+			}
+		}
+	}
+	return usage
+}
 
-	// Sort by score descending
-	sort.Slice(scores, func(i, j int) bool {
-		return scores[i].Score > scores[j].Score
+// topRelaysByUsage returns up to n relays, most widely used first.
+func topRelaysByUsage(usage map[string]int, n int) []string {
+	urls := make([]string, 0, len(usage))
+	for url := range usage {
+		urls = append(urls, url)
+	}
+	sort.Slice(urls, func(i, j int) bool {
+		if usage[urls[i]] != usage[urls[j]] {
+			return usage[urls[i]] > usage[urls[j]]
+		}
+		return urls[i] < urls[j]
 	})
+	if len(urls) > n {
+		urls = urls[:n]
+	}
+	return urls
+}
 
-	return scores
+// followedPubkeys returns the p tags of pk's latest follow list (kind 3).
+func followedPubkeys(ctx context.Context, relays []checkRelay, pk nostr.PubKey) []nostr.PubKey {
+	_, evt := fetchKindFrom(ctx, relays, pk, 3)
+	if evt == nil {
+		return nil
+	}
+	var follows []nostr.PubKey
+	for _, tag := range evt.Tags {
+		if len(tag) >= 2 && tag[0] == "p" {
+			if followed, err := nostr.PubKeyFromHex(tag[1]); err == nil {
+				follows = append(follows, followed)
+			}
+		}
+	}
+	return follows
+}
+
+// discoveryFollows returns the npubs pk already follows on the default
+// relays plus extra (e.g. an org follow pack), without duplicates.
+func discoveryFollows(pk nostr.PubKey, extra []nostr.PubKey) []nostr.PubKey {
+	ctx, cancel := context.WithTimeout(context.Background(), 8*time.Second)
+	defer cancel()
+	relays := connectCheckRelays(ctx, defaultRelays)
+	existing := followedPubkeys(ctx, relays, pk)
+	for _, cr := range relays {
+		cr.relay.Close()
+	}
+
+	seen := make(map[nostr.PubKey]bool)
+	var follows []nostr.PubKey
+	for _, f := range append(existing, extra...) {
+		if f != pk && !seen[f] {
+			seen[f] = true
+			follows = append(follows, f)
+		}
+	}
+	return follows
 }
 
-// SelectRelays picks an optimal relay set from scored candidates
+// SelectRelays picks an optimal relay set from scored candidates. Relays
+// that share an operator (NIP-11 pubkey), domain, or hosting network with
+// one already picked are passed over, as are more than half of the set on
+// the same software, unless there aren't enough other candidates.
 func SelectRelays(candidates []RelayScore, maxCount int) []string {
 	if maxCount <= 0 {
 		maxCount = 5
 	}
 
 	var selected []string
+	var overlapping []RelayScore
 	hasOutbox := false
+	diversity := newRelayDiversity(maxCount)
 
 	for _, rs := range candidates {
 		if len(selected) >= maxCount {
@@ -394,15 +736,32 @@ func SelectRelays(candidates []RelayScore, maxCount int) []string {
 		if rs.Purpose == "outbox" {
 			if !hasOutbox {
 				selected = append(selected, rs.URL)
+				diversity.add(rs)
 				hasOutbox = true
 			}
 			continue
 		}
 
-		// General relays — pick by score
+		// General relays — pick by score, unless the write probe was refused
+		if rs.WriteRefusal != "" {
+			continue
+		}
 		if rs.Score >= 0.5 {
+			if !diversity.allows(rs) {
+				overlapping = append(overlapping, rs)
+				continue
+			}
 			selected = append(selected, rs.URL)
+			diversity.add(rs)
+		}
+	}
+
+	// Not enough independent relays: fall back to the best overlapping ones
+	for _, rs := range overlapping {
+		if len(selected) >= maxCount {
+			break
 		}
+		selected = append(selected, rs.URL)
 	}
 
 	// If no outbox relay found, add purplepag.es as fallback
@@ -482,8 +841,38 @@ func MarkedRelayURLs(relays []MarkedRelay) []string {
 	return urls
 }
 
+// WriteRelayURLs returns the relays marked write or not marked (both),
+// the ones others should look for the identity's events on.
+func WriteRelayURLs(relays []MarkedRelay) []string {
+	var urls []string
+	for _, r := range relays {
+		if r.Marker != RelayMarkerRead {
+			urls = append(urls, r.URL)
+		}
+	}
+	return urls
+}
+
 // DiscoverDMRelays looks for kind 10050 events from well-connected npubs
 func DiscoverDMRelays(seedRelays []string) []string {
+	relaySet := sampleDMRelayLists(seedRelays)
+
+	// Return relays used by 2+ npubs, or fall back to defaults
+	var discovered []string
+	for url, count := range relaySet {
+		if count >= 2 {
+			discovered = append(discovered, url)
+		}
+	}
+	if len(discovered) == 0 {
+		return DefaultDMRelays
+	}
+	return discovered
+}
+
+// sampleDMRelayLists fetches the kind 10050 lists of well-connected npubs
+// and counts how many of them use each relay.
+func sampleDMRelayLists(seedRelays []string) map[string]int {
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
 
@@ -530,25 +919,67 @@ func DiscoverDMRelays(seedRelays []string) []string {
 		}(hexKey)
 	}
 	wg.Wait()
+	return relaySet
+}
 
-	// Return relays used by 2+ npubs, or fall back to defaults
-	var discovered []string
-	for url, count := range relaySet {
-		if count >= 2 {
-			discovered = append(discovered, url)
+// normalizeRelayURL canonicalizes a relay URL so equivalent spellings
+// compare equal: lowercase scheme and host, punycode for international
+// domains, no default port (:443 for wss, :80 for ws), and no trailing
+// slash. Returns "" for anything that isn't a valid ws:// or wss:// URL.
+func normalizeRelayURL(raw string) string {
+	u, err := url.Parse(strings.TrimSpace(raw))
+	if err != nil || u.Opaque != "" || u.User != nil {
+		return ""
+	}
+	scheme := strings.ToLower(u.Scheme)
+	if scheme != "ws" && scheme != "wss" {
+		return ""
+	}
+
+	host := strings.TrimSuffix(strings.ToLower(u.Hostname()), ".")
+	if host == "" {
+		return ""
+	}
+	if net.ParseIP(host) == nil {
+		if host, err = idna.Lookup.ToASCII(host); err != nil {
+			return ""
 		}
+	} else if strings.Contains(host, ":") {
+		host = "[" + host + "]" // IPv6 literal
 	}
-	if len(discovered) == 0 {
-		return DefaultDMRelays
+
+	port := u.Port()
+	if (scheme == "wss" && port == "443") || (scheme == "ws" && port == "80") {
+		port = ""
 	}
-	return discovered
+	if port != "" {
+		if n, err := strconv.Atoi(port); err != nil || n < 1 || n > 65535 {
+			return ""
+		}
+		host += ":" + port
+	}
+
+	normalized := scheme + "://" + host + strings.TrimRight(u.EscapedPath(), "/")
+	if u.RawQuery != "" {
+		normalized += "?" + u.RawQuery
+	}
+	return normalized
 }
 
-func normalizeRelayURL(url string) string {
-	url = strings.TrimSpace(url)
-	url = strings.TrimRight(url, "/")
-	if !strings.HasPrefix(url, "wss://") && !strings.HasPrefix(url, "ws://") {
-		return ""
+// dedupeRelayURLs canonicalizes urls and drops effective duplicates, keeping
+// the first occurrence's position. It fails on the first invalid URL.
+func dedupeRelayURLs(urls []string) ([]string, error) {
+	seen := make(map[string]bool)
+	var out []string
+	for _, raw := range urls {
+		u := normalizeRelayURL(raw)
+		if u == "" {
+			return nil, fmt.Errorf("invalid relay URL %q", raw)
+		}
+		if !seen[u] {
+			seen[u] = true
+			out = append(out, u)
+		}
 	}
-	return url
+	return out, nil
 }
@@ -0,0 +1,158 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"iter"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"fiatjaf.com/nostr"
+)
+
+// relayAuth tracks NIP-42 authentication for one check connection. Relays
+// that gate reads answer a REQ with CLOSED "auth-required:"; with a signer
+// we authenticate once and retry, without one we remember that results from
+// this relay may be incomplete.
+type relayAuth struct {
+	signer nostr.Signer
+
+	mu     sync.Mutex
+	status string // "", "authenticated", "auth-required", "auth-failed"
+	reason string
+}
+
+// authenticate performs NIP-42 AUTH on relay, at most once per connection.
+// It reports whether the relay now considers us authenticated.
+func (a *relayAuth) authenticate(relay *nostr.Relay) bool {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	switch {
+	case a.status == "authenticated":
+		return true
+	case a.status == "auth-failed":
+		return false
+	case a.signer == nil:
+		a.status = "auth-required"
+		return false
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := relay.Auth(ctx, a.signer.SignEvent); err != nil {
+		a.status = "auth-failed"
+		a.reason = err.Error()
+		return false
+	}
+	a.status = "authenticated"
+	return true
+}
+
+// enableAuth lets relays authenticate with signer when they ask for it.
+func enableAuth(relays []checkRelay, signer nostr.Signer) {
+	for _, cr := range relays {
+		cr.auth.signer = signer
+	}
+}
+
+// queryEvents is like nostr.Relay.QueryEvents, but retries once after
+// authenticating when the relay closes the subscription with "auth-required:".
+func (cr checkRelay) queryEvents(filter nostr.Filter) iter.Seq[nostr.Event] {
+	return func(yield func(nostr.Event) bool) {
+		for attempt := 0; attempt < 2; attempt++ {
+			closed, stop := cr.subscribeOnce(filter, yield)
+			if stop || !strings.HasPrefix(closed, "auth-required:") {
+				return
+			}
+			if !cr.auth.authenticate(cr.relay) {
+				return
+			}
+		}
+	}
+}
+
+// subscribeOnce streams stored events to yield until EOSE. It returns the
+// CLOSED reason if the relay refused the subscription, and whether yield
+// asked to stop.
+func (cr checkRelay) subscribeOnce(filter nostr.Filter, yield func(nostr.Event) bool) (closed string, stop bool) {
+	ctx, cancel := context.WithCancel(cr.relay.Context())
+	defer cancel()
+
+	if err := relayLimiter.wait(ctx, limiterKey(cr.url)); err != nil {
+		return "", false
+	}
+	if cr.timings != nil {
+		start := time.Now()
+		defer func() { cr.timings.record("relay", cr.url, time.Since(start), closed != "") }()
+	}
+	sub, err := cr.relay.Subscribe(ctx, filter, nostr.SubscriptionOptions{Label: "nihao"})
+	if err != nil {
+		return "", false
+	}
+	for {
+		select {
+		case evt := <-sub.Events:
+			if !yield(evt) {
+				return "", true
+			}
+		case <-sub.EndOfStoredEvents:
+			return "", false
+		case reason := <-sub.ClosedReason:
+			return reason, false
+		case <-ctx.Done():
+			return "", false
+		}
+	}
+}
+
+// checkRelayAuth adds a "relay_auth" check when any relay asked for NIP-42
+// authentication, so auth-gated relays don't silently look empty.
+func checkRelayAuth(result *CheckResult, relays []checkRelay) {
+	var authed, required, failed []string
+	for _, cr := range relays {
+		cr.auth.mu.Lock()
+		status, reason := cr.auth.status, cr.auth.reason
+		cr.auth.mu.Unlock()
+		if status == "" {
+			continue
+		}
+		if result.RelayAuth == nil {
+			result.RelayAuth = make(map[string]string)
+		}
+		result.RelayAuth[cr.url] = status
+		switch status {
+		case "authenticated":
+			authed = append(authed, cr.url)
+		case "auth-required":
+			required = append(required, cr.url)
+		case "auth-failed":
+			failed = append(failed, fmt.Sprintf("%s (%s)", cr.url, reason))
+		}
+	}
+	sort.Strings(authed)
+	sort.Strings(required)
+	sort.Strings(failed)
+
+	var parts []string
+	if len(authed) > 0 {
+		parts = append(parts, "authenticated to "+strings.Join(authed, ", "))
+	}
+	if len(required) > 0 {
+		parts = append(parts, "auth required by "+strings.Join(required, ", ")+" — pass --sec to read from them")
+	}
+	if len(failed) > 0 {
+		parts = append(parts, "auth failed on "+strings.Join(failed, ", "))
+	}
+
+	switch {
+	case len(parts) == 0:
+		return
+	case len(required) == 0 && len(failed) == 0:
+		result.addCheck("relay_auth", "pass", strings.Join(parts, "; "))
+	default:
+		result.addCheck("relay_auth", "warn", strings.Join(parts, "; ")+" (results from those relays may be incomplete)")
+	}
+}
@@ -0,0 +1,135 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+
+	"fiatjaf.com/nostr"
+	"fiatjaf.com/nostr/nip19"
+)
+
+// AdvertisedRelayDiff compares the relays a kind 10002 says the user
+// writes to with the relays that actually hold their events. Clients
+// following the outbox model only look where the list points, so an empty
+// write relay hides the user, and a relay that has their events but isn't
+// listed is never asked.
+type AdvertisedRelayDiff struct {
+	Empty        []string `json:"empty,omitempty"`        // advertised write relays holding none of their events
+	Unadvertised []string `json:"unadvertised,omitempty"` // relays holding their events that the list doesn't name
+	Remedy       string   `json:"remedy,omitempty"`       // command that rebroadcasts to the empty relays
+}
+
+// relaysHolding returns the relays that answer filter with at least one
+// event, asking each on its own.
+func relaysHolding(ctx context.Context, relays []checkRelay, filter nostr.Filter) []string {
+	var mu sync.Mutex
+	var found []string
+	var wg sync.WaitGroup
+	for _, cr := range relays {
+		wg.Add(1)
+		go func(cr checkRelay) {
+			defer wg.Done()
+			for range cr.queryEvents(filter) {
+				mu.Lock()
+				found = append(found, cr.url)
+				mu.Unlock()
+				return
+			}
+		}(cr)
+	}
+
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-ctx.Done():
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	return append([]string(nil), found...)
+}
+
+// diffAdvertisedRelays finds the reachable write relays that hold nothing
+// by the user, and the relays holding their events that relayEvt doesn't
+// list at all (as read or write).
+func diffAdvertisedRelays(relayEvt *nostr.Event, reachableWrite, holdingWrite, holdingChecked []string) AdvertisedRelayDiff {
+	has := make(map[string]bool)
+	for _, u := range holdingWrite {
+		has[normalizeRelayURL(u)] = true
+	}
+	listed := make(map[string]bool)
+	for _, tag := range relayEvt.Tags {
+		if len(tag) >= 2 && tag[0] == "r" {
+			listed[normalizeRelayURL(tag[1])] = true
+		}
+	}
+
+	var diff AdvertisedRelayDiff
+	for _, u := range reachableWrite {
+		if !has[normalizeRelayURL(u)] {
+			diff.Empty = append(diff.Empty, u)
+		}
+	}
+	for _, u := range holdingChecked {
+		if !listed[normalizeRelayURL(u)] {
+			diff.Unadvertised = append(diff.Unadvertised, u)
+		}
+	}
+	return diff
+}
+
+// checkAdvertisedRelays reports write relays in the kind 10002 that hold
+// none of the user's events, and relays that have their events but aren't
+// in the list. Unreachable relays are left to relay_quality. Not scored.
+func checkAdvertisedRelays(ctx context.Context, result *CheckResult, checkRelays []checkRelay, pk nostr.PubKey, relayEvt *nostr.Event, scores []RelayScore) {
+	reachable := make(map[string]bool)
+	for _, rs := range scores {
+		reachable[rs.URL] = rs.Reachable
+	}
+	var write []string
+	for _, u := range writeRelays(relayEvt) {
+		if reachable[u] {
+			write = append(write, u)
+		}
+	}
+
+	filter := nostr.Filter{Authors: []nostr.PubKey{pk}, Limit: 1}
+	writeConns := connectCheckRelays(ctx, write)
+	holdingWrite := relaysHolding(ctx, writeConns, filter)
+	for _, cr := range writeConns {
+		cr.relay.Close()
+	}
+	// A relay that refused the connection this time isn't called empty
+	var connected []string
+	for _, cr := range writeConns {
+		connected = append(connected, cr.url)
+	}
+
+	diff := diffAdvertisedRelays(relayEvt, connected, holdingWrite, relaysHolding(ctx, checkRelays, filter))
+	if len(diff.Empty) == 0 && len(diff.Unadvertised) == 0 {
+		if len(connected) > 0 {
+			result.addCheck("advertised_relays", "pass", fmt.Sprintf("all %d write relay(s) hold your events", len(connected)))
+		}
+		return
+	}
+	var parts []string
+	status := "pass"
+	if len(diff.Empty) > 0 {
+		status = "warn"
+		diff.Remedy = fmt.Sprintf("nihao propagate %s --to %s", nip19.EncodeNpub(pk), strings.Join(diff.Empty, ","))
+		parts = append(parts, fmt.Sprintf("%d advertised write relay(s) hold none of your events: %s — fix: %s",
+			len(diff.Empty), strings.Join(diff.Empty, ", "), diff.Remedy))
+	}
+	if len(diff.Unadvertised) > 0 {
+		parts = append(parts, fmt.Sprintf("your events are also on %s, which your relay list doesn't name",
+			strings.Join(diff.Unadvertised, ", ")))
+	}
+	result.AdvertisedRelays = &diff
+	result.addCheck("advertised_relays", status, strings.Join(parts, "; "))
+}
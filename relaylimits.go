@@ -0,0 +1,158 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"fiatjaf.com/nostr"
+	"fiatjaf.com/nostr/nip13"
+)
+
+// Typical client usage the limits are compared with: a client opens a
+// handful of subscriptions per relay (feed, notifications, DMs, profiles,
+// replies, ...) and asks for a few hundred events per feed page.
+const (
+	clientSubscriptions = 10
+	clientQueryLimit    = 100
+)
+
+// RelayLimitReport is one relay's NIP-11 limitation document and what it
+// means for how the identity uses that relay.
+type RelayLimitReport struct {
+	URL        string           `json:"url"`
+	Purpose    string           `json:"purpose"` // "read", "write", or "read+write"
+	Limitation *RelayLimitation `json:"limitation"`
+	Issues     []string         `json:"issues,omitempty"`
+}
+
+// relayPurposes maps the relays in a kind 10002 to their NIP-65 marker.
+func relayPurposes(relayEvt *nostr.Event) map[string]string {
+	purposes := make(map[string]string)
+	for _, tag := range relayEvt.Tags {
+		if len(tag) >= 2 && tag[0] == "r" {
+			if len(tag) >= 3 && (tag[2] == "read" || tag[2] == "write") {
+				purposes[tag[1]] = tag[2]
+			} else {
+				purposes[tag[1]] = "read+write"
+			}
+		}
+	}
+	return purposes
+}
+
+// assessRelayLimits cross-checks each relay's limitation with its purpose
+// and the identity's own events. Write relays have to take the identity's
+// events and serve them to strangers; read relays have to take everyone
+// else's mentions and replies. Event sizes are left to checkEventSizes.
+func assessRelayLimits(purposes map[string]string, scores []RelayScore, own []*nostr.Event, now time.Time) []RelayLimitReport {
+	var reports []RelayLimitReport
+	for _, rs := range scores {
+		if rs.Info == nil || rs.Info.Limitation == nil {
+			continue
+		}
+		l := rs.Info.Limitation
+		purpose := purposes[rs.URL]
+		if purpose == "" {
+			purpose = "read+write"
+		}
+		write := purpose != "read"
+		read := purpose != "write"
+		var issues []string
+
+		if write {
+			if l.MinPowDifficulty > 0 {
+				weakest := -1
+				for _, evt := range own {
+					if d := nip13.Difficulty(evt.ID); weakest < 0 || d < weakest {
+						weakest = d
+					}
+				}
+				if weakest >= 0 && weakest < l.MinPowDifficulty {
+					issues = append(issues, fmt.Sprintf("requires PoW %d, your events have %d: updates without mining are rejected", l.MinPowDifficulty, weakest))
+				}
+			}
+			if l.CreatedAtLower > 0 {
+				cutoff := now.Add(-time.Duration(l.CreatedAtLower) * time.Second)
+				for _, evt := range own {
+					if evt.CreatedAt.Time().Before(cutoff) {
+						issues = append(issues, fmt.Sprintf("rejects events older than %s, so your kind %d from %s can't be rebroadcast there",
+							formatAge(time.Duration(l.CreatedAtLower)*time.Second), evt.Kind, evt.CreatedAt.Time().UTC().Format("2006-01-02")))
+					}
+				}
+			}
+			if l.CreatedAtUpper > 0 {
+				for _, evt := range own {
+					if ahead := evt.CreatedAt.Time().Sub(now); ahead > time.Duration(l.CreatedAtUpper)*time.Second {
+						issues = append(issues, fmt.Sprintf("rejects events more than %s ahead; your kind %d is dated %s in the future (client clock?)",
+							formatAge(time.Duration(l.CreatedAtUpper)*time.Second), evt.Kind, formatAge(ahead)))
+					}
+				}
+			}
+			if l.AuthRequired {
+				issues = append(issues, "requires NIP-42 AUTH to read: clients without it can't fetch your notes from here")
+			}
+		}
+		if read {
+			if l.RestrictedWrites || l.PaymentRequired {
+				issues = append(issues, "only members can write: mentions and replies from others can't reach you here")
+			} else if l.MinPowDifficulty > 0 {
+				issues = append(issues, fmt.Sprintf("requires PoW %d: replies from clients that don't mine are rejected", l.MinPowDifficulty))
+			}
+		}
+		if l.MaxSubscriptions > 0 && l.MaxSubscriptions < clientSubscriptions {
+			issues = append(issues, fmt.Sprintf("allows %d subscriptions; clients open around %d, so some views stall", l.MaxSubscriptions, clientSubscriptions))
+		}
+		if l.MaxLimit > 0 && l.MaxLimit < clientQueryLimit {
+			issues = append(issues, fmt.Sprintf("returns at most %d events per query; feeds and follow lists page slowly", l.MaxLimit))
+		}
+		reports = append(reports, RelayLimitReport{URL: rs.URL, Purpose: purpose, Limitation: l, Issues: issues})
+	}
+	return reports
+}
+
+// formatAge shows a duration in the largest whole unit that fits.
+func formatAge(d time.Duration) string {
+	switch {
+	case d >= 24*time.Hour:
+		return fmt.Sprintf("%dd", int(d/(24*time.Hour)))
+	case d >= time.Hour:
+		return fmt.Sprintf("%dh", int(d/time.Hour))
+	case d >= time.Minute:
+		return fmt.Sprintf("%dm", int(d/time.Minute))
+	default:
+		return fmt.Sprintf("%ds", int(d/time.Second))
+	}
+}
+
+// checkRelayLimits adds a "relay_limits" check from the NIP-11 limits of
+// the relays in the identity's kind 10002. Only reported if any relay
+// publishes a limitation document.
+func checkRelayLimits(result *CheckResult, relayEvt *nostr.Event, scores []RelayScore, now time.Time) {
+	var own []*nostr.Event
+	for _, kind := range []int{0, 3, 10002} {
+		if evt := result.events[kind]; evt != nil {
+			own = append(own, evt)
+		}
+	}
+	reports := assessRelayLimits(relayPurposes(relayEvt), scores, own, now)
+	if len(reports) == 0 {
+		return
+	}
+	result.RelayLimits = reports
+	var problems []string
+	for _, r := range reports {
+		for _, issue := range r.Issues {
+			problems = append(problems, r.URL+" "+issue)
+		}
+	}
+	if len(problems) == 0 {
+		result.addCheck("relay_limits", "pass", fmt.Sprintf("limits of %d relay(s) fit how you use them", len(reports)))
+		return
+	}
+	detail := strings.Join(problems[:min(len(problems), 3)], "; ")
+	if len(problems) > 3 {
+		detail += fmt.Sprintf("; and %d more", len(problems)-3)
+	}
+	result.addCheck("relay_limits", "warn", detail)
+}
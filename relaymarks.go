@@ -0,0 +1,206 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"fiatjaf.com/nostr"
+	"fiatjaf.com/nostr/nip19"
+)
+
+type identityOpts struct {
+	keys       keySource
+	relays     []string
+	jsonOutput bool
+	quiet      bool
+	args       []string // positional arguments
+}
+
+// RelayMarkResult is the JSON output of `nihao relays mark`.
+type RelayMarkResult struct {
+	Npub      string `json:"npub"`
+	Relay     string `json:"relay"`
+	From      string `json:"from"` // "read", "write", or "both"
+	To        string `json:"to"`
+	Published bool   `json:"published"`
+}
+
+func parseIdentityFlags(args []string) identityOpts {
+	var opts identityOpts
+	for i := 0; i < len(args); i++ {
+		if next, ok := opts.keys.keyFlag(args, i); ok {
+			i = next
+			continue
+		}
+		a := args[i]
+		switch {
+		case a == "--relays" && i+1 < len(args):
+			i++
+			opts.relays = strings.Split(args[i], ",")
+		case a == "--json":
+			opts.jsonOutput = true
+		case a == "--quiet" || a == "-q":
+			opts.quiet = true
+		case strings.HasPrefix(a, "-"):
+			fatal("unknown flag: %s (see nihao help)", a)
+		default:
+			opts.args = append(opts.args, a)
+		}
+	}
+	return opts
+}
+
+// parseRelayMarker reads a marker argument: read, write, or both.
+func parseRelayMarker(s string) (RelayMarker, error) {
+	switch strings.ToLower(s) {
+	case "read":
+		return RelayMarkerRead, nil
+	case "write":
+		return RelayMarkerWrite, nil
+	case "both", "read+write", "rw":
+		return RelayMarkerBoth, nil
+	}
+	return "", fmt.Errorf("unknown marker %q (use read, write, or both)", s)
+}
+
+// markerName is how a marker is shown: "both" for a bare entry.
+func markerName(m RelayMarker) string {
+	if m == RelayMarkerBoth {
+		return "both"
+	}
+	return string(m)
+}
+
+// markRelay changes the NIP-65 marker of one entry in a kind 10002 list,
+// leaving every other tag as it is. The relay must already be listed, and
+// the list must keep at least one relay to read from and one to write to.
+func markRelay(tags nostr.Tags, relayURL string, marker RelayMarker) (nostr.Tags, RelayMarker, error) {
+	want := normalizeRelayURL(relayURL)
+	next := make(nostr.Tags, 0, len(tags))
+	found := false
+	var from RelayMarker
+	for _, tag := range tags {
+		tag = tag.Clone()
+		if len(tag) >= 2 && tag[0] == "r" && normalizeRelayURL(tag[1]) == want && !found {
+			found = true
+			if len(tag) >= 3 {
+				from = RelayMarker(tag[2])
+			}
+			tag = nostr.Tag{"r", tag[1]}
+			if marker != RelayMarkerBoth {
+				tag = append(tag, string(marker))
+			}
+		}
+		next = append(next, tag)
+	}
+	if !found {
+		return nil, "", fmt.Errorf("%s isn't in your relay list (kind 10002)", relayURL)
+	}
+
+	reads, writes := 0, 0
+	for _, tag := range next {
+		if len(tag) < 2 || tag[0] != "r" {
+			continue
+		}
+		if len(tag) < 3 || tag[2] == "read" {
+			reads++
+		}
+		if len(tag) < 3 || tag[2] == "write" {
+			writes++
+		}
+	}
+	switch {
+	case reads == 0:
+		return nil, from, fmt.Errorf("that would leave no read relay: others couldn't reach you")
+	case writes == 0:
+		return nil, from, fmt.Errorf("that would leave no write relay: others couldn't find your notes")
+	}
+	return next, from, nil
+}
+
+// runRelaysMark edits the read/write marker of one relay in the user's
+// kind 10002 and republishes it.
+func runRelaysMark(args []string) {
+	opts := parseIdentityFlags(args)
+	logln := func(a ...any) {
+		if !opts.jsonOutput && !opts.quiet {
+			fmt.Println(a...)
+		}
+	}
+
+	if len(opts.args) != 2 {
+		fatal("usage: nihao relays mark <relay-url> read|write|both (--sec|--stdin|--sec-cmd ...)")
+	}
+	marker, err := parseRelayMarker(opts.args[1])
+	if err != nil {
+		fatal("%s", err)
+	}
+	if !opts.keys.isSet() {
+		fatal("relays mark signs your updated relay list: pass --sec, --stdin, --sec-cmd, or --bunker")
+	}
+	kr, _, err := opts.keys.signer(context.Background())
+	if err != nil {
+		fatal("%s", err)
+	}
+	me := signerPubkey(context.Background(), kr)
+	npub := nip19.EncodeNpub(me)
+
+	readRelays := opts.relays
+	if len(readRelays) == 0 {
+		readRelays = defaultRelays
+	}
+	pool := NewRelayPool(readRelays, opts.quiet || opts.jsonOutput)
+	defer pool.Close()
+	if len(pool.CheckRelays()) == 0 {
+		fatal("could not connect to any relay")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	_, relayEvt := fetchKindFrom(ctx, pool.CheckRelays(), me, 10002)
+	cancel()
+	if relayEvt == nil {
+		fatal("no relay list (kind 10002) found for %s — run nihao setup first", npub)
+	}
+
+	tags, from, err := markRelay(relayEvt.Tags, opts.args[0], marker)
+	if err != nil {
+		fatal("%s", err)
+	}
+	result := RelayMarkResult{Npub: npub, Relay: opts.args[0], From: markerName(from), To: markerName(marker)}
+	logln(fmt.Sprintf("nihao relays 📡 mark %s %s → %s", result.Relay, result.From, result.To))
+	logln()
+
+	if from == marker {
+		logln("✅ Nothing to change")
+	} else {
+		evt := nostr.Event{
+			CreatedAt: nostr.Now(),
+			Kind:      10002,
+			Tags:      tags,
+			Content:   relayEvt.Content,
+		}
+		signWith(kr, &evt)
+
+		// Publish where the old and new lists say the user writes
+		pool.Add(mergeRelayURLs(mergeRelayURLs(readRelays, writeRelays(relayEvt)), writeRelays(&evt))...)
+		logln("📡 Publishing relay list (kind 10002)...")
+		for _, r := range pool.Publish(evt) {
+			if r.OK {
+				result.Published = true
+			}
+		}
+		logln()
+	}
+
+	if opts.jsonOutput {
+		out, _ := json.MarshalIndent(result, "", "  ")
+		fmt.Println(string(out))
+	}
+	if from != marker && !result.Published {
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,126 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"fiatjaf.com/nostr"
+	"fiatjaf.com/nostr/nip19"
+)
+
+// RelaysFrom reports what setup --relays-from took from another identity's
+// kind 10002: the relays that passed scoring, markers kept, and the ones
+// left behind with why.
+type RelaysFrom struct {
+	Npub    string         `json:"npub"`
+	Kept    []MarkedRelay  `json:"kept"`
+	Dropped []DroppedRelay `json:"dropped,omitempty"`
+}
+
+// DroppedRelay is a relay from the copied list that setup didn't use.
+type DroppedRelay struct {
+	URL    string `json:"url"`
+	Reason string `json:"reason"`
+}
+
+// loadRelaysFrom fetches target's relay list from relays (default: the
+// default relays) and scores each entry, so only relays a new account can
+// actually use are copied.
+func loadRelaysFrom(target string, relays []string, quiet bool) (*RelaysFrom, error) {
+	pk, err := resolveTarget(target, quiet)
+	if err != nil {
+		return nil, err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if len(relays) == 0 {
+		relays = defaultRelays
+	}
+	checkRelays := connectCheckRelays(ctx, relays)
+	if len(checkRelays) == 0 {
+		return nil, fmt.Errorf("could not connect to any relay")
+	}
+	_, relayEvt := fetchKindFrom(ctx, checkRelays, pk, 10002)
+	for _, cr := range checkRelays {
+		cr.relay.Close()
+	}
+	npub := nip19.EncodeNpub(pk)
+	if relayEvt == nil {
+		return nil, fmt.Errorf("%s has no relay list (kind 10002)", npub)
+	}
+
+	candidates, dropped := relayListCandidates(relayEvt)
+	scores := ScoreRelays(context.Background(), MarkedRelayURLs(candidates))
+	kept, unusable := vetRelays(candidates, scores)
+	from := &RelaysFrom{Npub: npub, Kept: kept, Dropped: append(dropped, unusable...)}
+	if len(kept) == 0 {
+		return nil, fmt.Errorf("none of the %d relays in %s's list are usable", len(candidates)+len(dropped), npub)
+	}
+	return from, nil
+}
+
+// relayListCandidates reads the relays of a kind 10002 with their markers.
+// Relays a new account can't use the same way (paid, search, NWC,
+// aggregators) are dropped by URL, as in discovery.
+func relayListCandidates(relayEvt *nostr.Event) ([]MarkedRelay, []DroppedRelay) {
+	var candidates []MarkedRelay
+	var dropped []DroppedRelay
+	seen := make(map[string]bool)
+	for _, tag := range relayEvt.Tags {
+		if len(tag) < 2 || tag[0] != "r" {
+			continue
+		}
+		url := normalizeRelayURL(tag[1])
+		if url == "" {
+			dropped = append(dropped, DroppedRelay{URL: tag[1], Reason: "invalid URL"})
+			continue
+		}
+		if seen[url] {
+			continue
+		}
+		seen[url] = true
+		if _, ok := ClassifyDiscoveredRelay(url); !ok {
+			dropped = append(dropped, DroppedRelay{URL: url, Reason: classifyRelay(url) + " relay"})
+			continue
+		}
+		mr := MarkedRelay{URL: url, Marker: RelayMarkerBoth}
+		if len(tag) >= 3 && (tag[2] == "read" || tag[2] == "write") {
+			mr.Marker = RelayMarker(tag[2])
+		}
+		candidates = append(candidates, mr)
+	}
+	return candidates, dropped
+}
+
+// vetRelays keeps the candidates whose score shows a working relay that
+// takes writes from a stranger for free. Read relays need that too: it's
+// how mentions and replies from others get in.
+func vetRelays(candidates []MarkedRelay, scores []RelayScore) ([]MarkedRelay, []DroppedRelay) {
+	byURL := make(map[string]RelayScore, len(scores))
+	for _, rs := range scores {
+		byURL[rs.URL] = rs
+	}
+	var kept []MarkedRelay
+	var dropped []DroppedRelay
+	for _, mr := range candidates {
+		rs, ok := byURL[mr.URL]
+		reason := ""
+		switch {
+		case ok && rs.Hung:
+			reason = "not responding"
+		case !ok || !rs.Reachable:
+			reason = "unreachable"
+		case rs.PaymentRequired:
+			reason = "paid"
+		case rs.WriteRefusal != "":
+			reason = "refuses writes: " + rs.WriteRefusal
+		}
+		if reason != "" {
+			dropped = append(dropped, DroppedRelay{URL: mr.URL, Reason: reason})
+			continue
+		}
+		kept = append(kept, mr)
+	}
+	return kept, dropped
+}
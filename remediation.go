@@ -0,0 +1,154 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Remediation is what to do about a check that failed or warned: the fix
+// in a sentence, the nihao command that does it (if there is one), the
+// event kinds involved, and roughly how much work it is.
+type Remediation struct {
+	Summary string `json:"summary"`
+	Command string `json:"command,omitempty"` // placeholders in <angle brackets>
+	Kinds   []int  `json:"kinds,omitempty"`   // event kinds the fix publishes or changes
+	Effort  string `json:"effort"`            // "quick" (one command), "moderate" (a few steps or choices), "involved" (outside nihao: hosting, providers, operators)
+}
+
+// keyFlag stands in for however the user passes their key.
+const keyFlag = "--sec-cmd <key-command>"
+
+// remediations maps check names to their fix. A "name:status" entry
+// overrides the plain one when a failure needs a different fix than a
+// warning. {npub} is replaced with the checked identity.
+var remediations = map[string]Remediation{
+	"profile": {Summary: "Fill in name, display_name, about, and picture in your client's profile editor", Kinds: []int{0}, Effort: "quick"},
+	"picture": {Summary: "Move the picture to your Blossom servers so it stays up and loads fast",
+		Command: "nihao media mirror " + keyFlag, Kinds: []int{0}, Effort: "quick"},
+	"picture:fail": {Summary: "Set a profile picture that loads: upload one to your Blossom servers and put its URL in your profile", Kinds: []int{0}, Effort: "moderate"},
+	"banner": {Summary: "Move the banner to your Blossom servers so it stays up and loads fast",
+		Command: "nihao media mirror " + keyFlag, Kinds: []int{0}, Effort: "quick"},
+	"banner:fail": {Summary: "Set a banner that loads: upload one to your Blossom servers and put its URL in your profile", Kinds: []int{0}, Effort: "moderate"},
+	"nip05": {Summary: "Serve /.well-known/nostr.json on your domain with your pubkey, or register a name with a NIP-05 provider, then set nip05 in your profile",
+		Command: "nihao check --domain <your-domain>", Kinds: []int{0}, Effort: "involved"},
+	"nip05_hosting": {Summary: "Fix the NIP-05 host: valid TLS, no redirects, CORS header, answering over IPv4 and IPv6",
+		Command: "nihao check --domain <your-domain>", Effort: "involved"},
+	"lud16": {Summary: "Set lud16 in your profile to a lightning address that resolves, e.g. <npub>@npub.cash", Kinds: []int{0}, Effort: "moderate"},
+	"relay_list": {Summary: "Publish a kind 10002 relay list with a few reliable read and write relays from your client",
+		Kinds: []int{10002}, Effort: "quick"},
+	"relay_quality": {Summary: "Replace unreachable or slow relays in your relay list", Kinds: []int{10002}, Effort: "moderate"},
+	"relay_markers": {Summary: "Mark relays read, write, or both so clients know where to find and send your events",
+		Command: "nihao relays mark <relay-url> read|write|both " + keyFlag, Kinds: []int{10002}, Effort: "quick"},
+	"relay_tls":    {Summary: "Ask the relay operators to fix their certificates, or replace those relays", Kinds: []int{10002}, Effort: "involved"},
+	"relay_uptime": {Summary: "Replace relays that are often down in your relay list", Kinds: []int{10002}, Effort: "moderate"},
+	"relay_features": {Summary: "Add a relay that supports the features you use (DMs, search, nutzaps) to the matching list",
+		Kinds: []int{10002}, Effort: "moderate"},
+	"search_relays": {Summary: "Replace search relays that no longer answer NIP-50 searches in your search relay list",
+		Kinds: []int{10007}, Effort: "moderate"},
+	"paid_relays": {Summary: "Add at least one free relay so people without a subscription can read you", Kinds: []int{10002}, Effort: "moderate"},
+	"advertised_relays": {Summary: "Rebroadcast your events to the relays you advertise but that don't have them",
+		Command: "nihao propagate {npub}", Kinds: []int{10002}, Effort: "quick"},
+	"propagation": {Summary: "Rebroadcast your profile and relay lists so strangers' clients can find them",
+		Command: "nihao propagate {npub}", Kinds: []int{0}, Effort: "quick"},
+	"relay_auth": {Summary: "Check with your key so auth-gated relays return your events",
+		Command: "nihao check " + keyFlag, Effort: "quick"},
+	"http_auth": {Summary: "Check with your key so hosts that require NIP-98 answer",
+		Command: "nihao check " + keyFlag, Effort: "quick"},
+	"address_families": {Summary: "Ask the operators to fix the broken IPv4/IPv6 address, or move to a service that works over both",
+		Effort: "involved"},
+	"dm_relays": {Summary: "Publish NIP-17 DM relays (kind 10050) so people can message you",
+		Command: "nihao dm-relays discover --publish " + keyFlag, Kinds: []int{10050}, Effort: "quick"},
+	"dm_loopback": {Summary: "Replace DM relays that lose or withhold gift wraps",
+		Command: "nihao dm-relays discover --publish " + keyFlag, Kinds: []int{10050}, Effort: "quick"},
+	"legacy_dms": {Summary: "Stop sending NIP-04 DMs: publish DM relays and switch to a client that sends NIP-17",
+		Command: "nihao dm-relays discover --publish " + keyFlag, Kinds: []int{4, 10050}, Effort: "moderate"},
+	"event_tags": {Summary: "Re-save the listed events from a client that writes them correctly, or remove and re-add the broken entries",
+		Effort: "moderate"},
+	"event_size": {Summary: "Trim the profile (shorter about, no embedded images) or unfollow inactive accounts until it fits, or replace the write relays with tighter limits",
+		Kinds: []int{0, 3}, Effort: "moderate"},
+	"relay_limits": {Summary: "Replace relays whose limits don't fit their role: member-only inboxes, PoW-gated outboxes, tight subscription caps",
+		Kinds: []int{10002}, Effort: "moderate"},
+	"follow_list":     {Summary: "Follow some accounts from your client so your feed isn't empty", Kinds: []int{3}, Effort: "quick"},
+	"interests":       {Summary: "Publish a kind 10015 interest list from a client that supports it", Kinds: []int{10015}, Effort: "quick"},
+	"emoji_list":      {Summary: "Publish a kind 10030 emoji list from a client that supports it", Kinds: []int{10030}, Effort: "quick"},
+	"blossom_servers": {Summary: "Replace dead or upload-refusing servers in your Blossom server list", Kinds: []int{10063}, Effort: "moderate"},
+	"nip96_servers":   {Summary: "Replace dead NIP-96 servers in your file server list", Kinds: []int{10096}, Effort: "moderate"},
+	"nip60_wallet":    {Summary: "Set up a NIP-60 Cashu wallet from a client that supports it", Kinds: []int{17375}, Effort: "moderate"},
+	"wallet_mints": {Summary: "Move funds off unreachable mints (nihao wallet send, then receive at a working mint) and drop them from the wallet",
+		Kinds: []int{17375}, Effort: "moderate"},
+	"nutzap_info": {Summary: "Publish nutzap info (kind 10019) with your mints and P2PK key so people can nutzap you",
+		Kinds: []int{10019}, Effort: "quick"},
+	"nwc": {Summary: "Connect a wallet service over NWC and store the connection",
+		Command: "nihao setup --nwc <nostr+walletconnect://...>", Effort: "moderate"},
+	"key_notice": {Summary: "Move to a new key, carrying over your profile, lists, and follows",
+		Command: "nihao migrate --from-sec <old-key> --to-sec <new-key>", Effort: "involved"},
+}
+
+// remediationFor returns the fix for a check in the given state, or nil if
+// it passed or there's nothing to suggest.
+func remediationFor(name, status, npub string) *Remediation {
+	if status == "pass" {
+		return nil
+	}
+	r, ok := remediations[name+":"+status]
+	if !ok {
+		if r, ok = remediations[name]; !ok {
+			return nil
+		}
+	}
+	r.Command = strings.ReplaceAll(r.Command, "{npub}", npub)
+	return &r
+}
+
+// attachRemediations fills in Remediation for every check that failed or
+// warned. Checks that already have one keep it, so it's safe to run again
+// after more checks were added.
+func (r *CheckResult) attachRemediations() {
+	for i, c := range r.Checks {
+		if c.Remediation == nil {
+			r.Checks[i].Remediation = remediationFor(c.Name, c.Status, r.Npub)
+		}
+	}
+	// The relay diff already knows exactly which relays need the events
+	if r.AdvertisedRelays != nil && r.AdvertisedRelays.Remedy != "" {
+		for i, c := range r.Checks {
+			if c.Name == "advertised_relays" && c.Remediation != nil {
+				r.Checks[i].Remediation.Command = r.AdvertisedRelays.Remedy
+			}
+		}
+	}
+}
+
+// printRemediations lists the fixes for --explain as a to-do list, worst
+// first.
+func printRemediations(r CheckResult) {
+	var todo []CheckItem
+	for _, status := range []string{"fail", "warn"} {
+		for _, c := range r.Checks {
+			if c.Status == status && c.Remediation != nil {
+				todo = append(todo, c)
+			}
+		}
+	}
+	fmt.Println()
+	if len(todo) == 0 {
+		fmt.Println("  📝 Nothing to fix")
+		return
+	}
+	fmt.Println("  📝 To do:")
+	for i, c := range todo {
+		rem := c.Remediation
+		meta := rem.Effort
+		if len(rem.Kinds) > 0 {
+			kinds := make([]string, len(rem.Kinds))
+			for j, k := range rem.Kinds {
+				kinds[j] = fmt.Sprint(k)
+			}
+			meta = fmt.Sprintf("kind %s, %s", strings.Join(kinds, "/"), rem.Effort)
+		}
+		fmt.Printf("    %d. %s (%s): %s\n", i+1, c.Name, meta, rem.Summary)
+		if rem.Command != "" {
+			fmt.Printf("       $ %s\n", rem.Command)
+		}
+	}
+}
@@ -0,0 +1,231 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"fiatjaf.com/nostr"
+	"fiatjaf.com/nostr/nip19"
+)
+
+// reportLimit caps how many reports and lists are fetched. Mass-reporting
+// shows up well below it.
+const reportLimit = 200
+
+// reportRecent is the window recent reports are listed for: relays and
+// clients that act on reports mostly weigh fresh ones.
+const reportRecent = 30 * 24 * time.Hour
+
+// ReportExposure summarizes how others flag an identity: NIP-56 reports
+// (kind 1984), public mute lists (kind 10000) and public block lists
+// (kind 30000 sets named like one).
+type ReportExposure struct {
+	Reports     int            `json:"reports"`
+	Reporters   int            `json:"reporters"`
+	ByType      map[string]int `json:"by_type,omitempty"` // report type → count
+	PublicMutes int            `json:"public_mutes"`
+	Blocklists  []string       `json:"blocklists,omitempty"` // titles of block lists naming the target
+	Recent      []RecentReport `json:"recent,omitempty"`     // last 30 days, grouped by type, newest first
+}
+
+// RecentReport is one report filed in the last reportRecent.
+type RecentReport struct {
+	Type      string `json:"type"` // "spam", "impersonation", "illegal", ..., or "other"
+	Reporter  string `json:"reporter"`
+	CreatedAt int64  `json:"created_at"`
+	Note      string `json:"note,omitempty"`   // event ID, when a note was reported rather than the profile
+	Reason    string `json:"reason,omitempty"` // the reporter's own words, if any
+}
+
+// blocklistWords mark a kind 30000 follow set as a block list rather than
+// an ordinary list of people.
+var blocklistWords = []string{"mute", "block", "spam", "scam", "bot"}
+
+// reportType is a report's NIP-56 type: on the p tag for a report about
+// the profile, on the e tag for one about a note.
+func reportType(r nostr.Event, pTag nostr.Tag) string {
+	if len(pTag) >= 3 && pTag[2] != "" {
+		return pTag[2]
+	}
+	if e := r.Tags.Find("e"); e != nil && len(e) >= 3 && e[2] != "" {
+		return e[2]
+	}
+	return "other"
+}
+
+func tagsPubkey(evt nostr.Event, pk nostr.PubKey) nostr.Tag {
+	for _, tag := range evt.Tags {
+		if len(tag) >= 2 && tag[0] == "p" && tag[1] == pk.Hex() {
+			return tag
+		}
+	}
+	return nil
+}
+
+// isBlocklist reports whether a kind 30000 set's "d" or "title" names it as
+// a block list. Returns the name to show.
+func isBlocklist(evt nostr.Event) (string, bool) {
+	name := ""
+	if tag := evt.Tags.Find("title"); tag != nil {
+		name = tag[1]
+	}
+	if tag := evt.Tags.Find("d"); tag != nil && name == "" {
+		name = tag[1]
+	}
+	lower := strings.ToLower(name)
+	for _, w := range blocklistWords {
+		if strings.Contains(lower, w) {
+			return name, true
+		}
+	}
+	return name, false
+}
+
+// assessReportExposure counts reports, mutes and block lists naming pk.
+// Only public entries are visible: encrypted mutes stay private. It's
+// informational, so the worst status is "warn".
+func assessReportExposure(pk nostr.PubKey, reports, mutes, sets []nostr.Event) (string, string, ReportExposure) {
+	exp := ReportExposure{ByType: make(map[string]int)}
+	reporters := make(map[nostr.PubKey]bool)
+	for _, r := range reports {
+		tag := tagsPubkey(r, pk)
+		if tag == nil || r.PubKey == pk {
+			continue
+		}
+		exp.Reports++
+		reporters[r.PubKey] = true
+		exp.ByType[reportType(r, tag)]++
+	}
+	exp.Reporters = len(reporters)
+
+	muters := make(map[nostr.PubKey]bool)
+	for _, m := range mutes {
+		if m.PubKey != pk && tagsPubkey(m, pk) != nil {
+			muters[m.PubKey] = true
+		}
+	}
+	exp.PublicMutes = len(muters)
+
+	for _, s := range sets {
+		if tagsPubkey(s, pk) == nil {
+			continue
+		}
+		if name, ok := isBlocklist(s); ok {
+			exp.Blocklists = append(exp.Blocklists, name)
+		}
+	}
+	sort.Strings(exp.Blocklists)
+
+	if exp.Reports == 0 && exp.PublicMutes == 0 && len(exp.Blocklists) == 0 {
+		exp.ByType = nil
+		return "pass", "no reports, public mutes, or block lists found", exp
+	}
+
+	var parts []string
+	if exp.Reports > 0 {
+		reasons := make([]string, 0, len(exp.ByType))
+		for reason, n := range exp.ByType {
+			reasons = append(reasons, fmt.Sprintf("%s %d", reason, n))
+		}
+		sort.Strings(reasons)
+		parts = append(parts, fmt.Sprintf("%d report(s) from %d reporter(s) (%s)", exp.Reports, exp.Reporters, strings.Join(reasons, ", ")))
+	}
+	if exp.PublicMutes > 0 {
+		parts = append(parts, fmt.Sprintf("publicly muted by %d", exp.PublicMutes))
+	}
+	if len(exp.Blocklists) > 0 {
+		parts = append(parts, fmt.Sprintf("on %d block list(s): %s", len(exp.Blocklists), strings.Join(exp.Blocklists, ", ")))
+	}
+	return "warn", strings.Join(parts, "; "), exp
+}
+
+// recentReports lists the reports against pk filed since now minus
+// reportRecent, grouped by type (most reported first), newest first within
+// a type, along with a summary like "3 in the last 30 days: spam 2,
+// impersonation 1".
+func recentReports(pk nostr.PubKey, reports []nostr.Event, now time.Time) ([]RecentReport, string) {
+	since := now.Add(-reportRecent).Unix()
+	var recent []RecentReport
+	byType := make(map[string]int)
+	for _, r := range reports {
+		tag := tagsPubkey(r, pk)
+		if tag == nil || r.PubKey == pk || int64(r.CreatedAt) < since {
+			continue
+		}
+		rr := RecentReport{Type: reportType(r, tag), Reporter: nip19.EncodeNpub(r.PubKey), CreatedAt: int64(r.CreatedAt), Reason: strings.TrimSpace(r.Content)}
+		if e := r.Tags.Find("e"); e != nil {
+			rr.Note = e[1]
+		}
+		byType[rr.Type]++
+		recent = append(recent, rr)
+	}
+	if len(recent) == 0 {
+		return nil, ""
+	}
+	sort.Slice(recent, func(i, j int) bool {
+		a, b := recent[i], recent[j]
+		if byType[a.Type] != byType[b.Type] {
+			return byType[a.Type] > byType[b.Type]
+		}
+		if a.Type != b.Type {
+			return a.Type < b.Type
+		}
+		return a.CreatedAt > b.CreatedAt
+	})
+
+	var types []string
+	last := ""
+	for _, rr := range recent {
+		if rr.Type != last {
+			types = append(types, fmt.Sprintf("%s %d", rr.Type, byType[rr.Type]))
+			last = rr.Type
+		}
+	}
+	return recent, fmt.Sprintf("%d in the last %d days: %s", len(recent), int(reportRecent.Hours()/24), strings.Join(types, ", "))
+}
+
+// checkReportExposure fetches reports and lists naming pk and adds a
+// "reports" check.
+func checkReportExposure(ctx context.Context, result *CheckResult, relays []checkRelay, pk nostr.PubKey) {
+	naming := nostr.TagMap{"p": []string{pk.Hex()}}
+	reports := fetchAllFrom(ctx, relays, nostr.Filter{Kinds: []nostr.Kind{1984}, Tags: naming, Limit: reportLimit})
+	mutes := fetchAllFrom(ctx, relays, nostr.Filter{Kinds: []nostr.Kind{10000}, Tags: naming, Limit: reportLimit})
+	sets := fetchAllFrom(ctx, relays, nostr.Filter{Kinds: []nostr.Kind{30000}, Tags: naming, Limit: reportLimit})
+	status, detail, exp := assessReportExposure(pk, reports, mutes, sets)
+	recent, summary := recentReports(pk, reports, time.Now())
+	if summary != "" {
+		exp.Recent = recent
+		detail += " — " + summary + "; some relays and clients hide reported accounts"
+	}
+	result.Reports = &exp
+	result.addCheck("reports", status, detail)
+}
+
+// printRecentReports lists recent reports under their type, so it's clear
+// who flagged the identity for what.
+func printRecentReports(recent []RecentReport) {
+	fmt.Println()
+	fmt.Printf("  Reports in the last %d days:\n", int(reportRecent.Hours()/24))
+	last := ""
+	for _, rr := range recent {
+		if rr.Type != last {
+			fmt.Printf("    %s:\n", rr.Type)
+			last = rr.Type
+		}
+		line := fmt.Sprintf("      %s  %s…", time.Unix(rr.CreatedAt, 0).UTC().Format("2006-01-02"), rr.Reporter[:16])
+		if rr.Note != "" {
+			line += "  (note " + rr.Note[:min(len(rr.Note), 8)] + ")"
+		}
+		if rr.Reason != "" {
+			reason := rr.Reason
+			if r := []rune(reason); len(r) > 80 {
+				reason = string(r[:77]) + "..."
+			}
+			line += fmt.Sprintf("  %q", reason)
+		}
+		fmt.Println(line)
+	}
+}
@@ -0,0 +1,255 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"time"
+)
+
+// rpcRequest is a JSON-RPC 2.0 request or notification (no ID).
+type rpcRequest struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id,omitempty"`
+	Method  string          `json:"method"`
+	Params  json.RawMessage `json:"params,omitempty"`
+}
+
+type rpcResponse struct {
+	JSONRPC string
+	ID      json.RawMessage
+	Result  any
+	Error   *rpcError
+}
+
+// MarshalJSON writes exactly one of "result" and "error", as JSON-RPC
+// requires; omitempty would drop an empty result like ping's {}.
+func (r rpcResponse) MarshalJSON() ([]byte, error) {
+	if r.Error != nil {
+		return json.Marshal(struct {
+			JSONRPC string          `json:"jsonrpc"`
+			ID      json.RawMessage `json:"id"`
+			Error   *rpcError       `json:"error"`
+		}{r.JSONRPC, r.ID, r.Error})
+	}
+	return json.Marshal(struct {
+		JSONRPC string          `json:"jsonrpc"`
+		ID      json.RawMessage `json:"id"`
+		Result  any             `json:"result"`
+	}{r.JSONRPC, r.ID, r.Result})
+}
+
+type rpcError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+// JSON-RPC 2.0 error codes
+const (
+	rpcParseError     = -32700
+	rpcInvalidRequest = -32600
+	rpcMethodNotFound = -32601
+	rpcInvalidParams  = -32602
+	rpcServerError    = -32000 // the method ran and failed
+)
+
+// rpcCallTimeout bounds one `nihao rpc` call.
+const rpcCallTimeout = 30 * time.Second
+
+// rpcMintCacheTTL is how long a mint validation is reused between calls.
+const rpcMintCacheTTL = 5 * time.Minute
+
+// serveJSONRPC reads newline-delimited JSON-RPC requests from in and
+// writes each response to out as one line, until in is closed. With
+// concurrent set, requests run in parallel and responses are written as
+// they finish, so callers match them up by ID.
+func serveJSONRPC(ctx context.Context, in io.Reader, out io.Writer, handle func(context.Context, rpcRequest) *rpcResponse, concurrent bool) error {
+	scanner := bufio.NewScanner(in)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	enc := json.NewEncoder(out)
+	var mu sync.Mutex
+	var writeErr error
+	write := func(resp *rpcResponse) {
+		if resp == nil {
+			return
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		if err := enc.Encode(resp); err != nil && writeErr == nil {
+			writeErr = err
+		}
+	}
+
+	var wg sync.WaitGroup
+	for scanner.Scan() {
+		line := bytes.TrimSpace(scanner.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+		var req rpcRequest
+		if err := json.Unmarshal(line, &req); err != nil {
+			write(&rpcResponse{JSONRPC: "2.0", ID: json.RawMessage("null"), Error: &rpcError{Code: rpcParseError, Message: "parse error"}})
+			continue
+		}
+		if req.JSONRPC != "2.0" || req.Method == "" {
+			resp := &rpcResponse{JSONRPC: "2.0", ID: req.ID, Error: &rpcError{Code: rpcInvalidRequest, Message: "invalid request"}}
+			if len(resp.ID) == 0 {
+				resp.ID = json.RawMessage("null")
+			}
+			write(resp)
+			continue
+		}
+		if !concurrent {
+			write(handle(ctx, req))
+			continue
+		}
+		wg.Add(1)
+		go func(req rpcRequest) {
+			defer wg.Done()
+			write(handle(ctx, req))
+		}(req)
+	}
+	wg.Wait()
+
+	if err := scanner.Err(); err != nil && !errors.Is(err, io.EOF) {
+		return err
+	}
+	return writeErr
+}
+
+type cachedMint struct {
+	info MintInfo
+	at   time.Time
+}
+
+// rpcSession is the state `nihao rpc` keeps warm between calls: one relay
+// pool whose connections every call reuses, and recent mint validations.
+type rpcSession struct {
+	pool  *RelayPool
+	mu    sync.Mutex
+	mints map[string]cachedMint
+}
+
+func newRPCSession() *rpcSession {
+	return &rpcSession{pool: NewRelayPool(nil, true), mints: make(map[string]cachedMint)}
+}
+
+// relaysFor returns live connections to urls (the defaults if empty),
+// connecting to any the session hasn't seen yet and redialing dropped ones.
+func (s *rpcSession) relaysFor(urls []string) []checkRelay {
+	if len(urls) == 0 {
+		urls = defaultRelays
+	}
+	s.pool.Add(urls...)
+	var relays []checkRelay
+	for _, url := range urls {
+		if relay, err := s.pool.relay(url); err == nil {
+			relays = append(relays, checkRelay{url: url, relay: relay, auth: &relayAuth{}})
+		}
+	}
+	return relays
+}
+
+// validateMint is validateMint with a short-lived cache.
+func (s *rpcSession) validateMint(ctx context.Context, url string) MintInfo {
+	s.mu.Lock()
+	cached, ok := s.mints[url]
+	s.mu.Unlock()
+	if ok && time.Since(cached.at) < rpcMintCacheTTL {
+		return cached.info
+	}
+	info := validateMint(ctx, url)
+	s.mu.Lock()
+	s.mints[url] = cachedMint{info: info, at: time.Now()}
+	s.mu.Unlock()
+	return info
+}
+
+// call runs one method and returns its result, or an RPC error.
+func (s *rpcSession) call(ctx context.Context, method string, params json.RawMessage) (any, *rpcError) {
+	var p struct {
+		Target     string   `json:"target"`
+		Relays     []string `json:"relays"`
+		ExtraKinds []int    `json:"extraKinds"`
+		URL        string   `json:"url"`
+	}
+	if len(params) > 0 {
+		if err := json.Unmarshal(params, &p); err != nil {
+			return nil, &rpcError{Code: rpcInvalidParams, Message: "params must be an object"}
+		}
+	}
+
+	switch method {
+	case "ping":
+		return map[string]any{}, nil
+	case "version":
+		return map[string]string{"version": version}, nil
+	case "check":
+		if p.Target == "" {
+			return nil, &rpcError{Code: rpcInvalidParams, Message: "target is required"}
+		}
+		pk, err := resolveTarget(p.Target, true)
+		if err != nil {
+			return nil, &rpcError{Code: rpcServerError, Message: err.Error()}
+		}
+		relays := s.relaysFor(p.Relays)
+		if len(relays) == 0 {
+			return nil, &rpcError{Code: rpcServerError, Message: "could not connect to any relay"}
+		}
+		if len(p.ExtraKinds) == 0 {
+			return checkIdentityCached(ctx, relays, p.Relays, pk), nil
+		}
+		checkCtx, cancel := holdBack(ctx, extraKindsWeight)
+		result := checkIdentityCached(checkCtx, relays, p.Relays, pk)
+		cancel()
+		checkExtraKinds(ctx, &result, relays, pk, p.ExtraKinds)
+		return result, nil
+	case "scoreRelays":
+		if len(p.Relays) == 0 {
+			return nil, &rpcError{Code: rpcInvalidParams, Message: "relays is required"}
+		}
+		return ScoreRelays(ctx, p.Relays), nil
+	case "validateMint":
+		if p.URL == "" {
+			return nil, &rpcError{Code: rpcInvalidParams, Message: "url is required"}
+		}
+		return s.validateMint(ctx, p.URL), nil
+	}
+	return nil, &rpcError{Code: rpcMethodNotFound, Message: fmt.Sprintf("method not found: %s", method)}
+}
+
+func (s *rpcSession) handle(ctx context.Context, req rpcRequest) *rpcResponse {
+	ctx, cancel := context.WithTimeout(ctx, rpcCallTimeout)
+	defer cancel()
+	result, rpcErr := s.call(ctx, req.Method, req.Params)
+	if len(req.ID) == 0 {
+		return nil // notification: run it, don't answer
+	}
+	return &rpcResponse{JSONRPC: "2.0", ID: req.ID, Result: result, Error: rpcErr}
+}
+
+// runRPC serves nihao's checks as newline-delimited JSON-RPC over stdio
+// for GUI wrappers. Relay connections and caches stay warm between calls,
+// so only the first call pays for connecting.
+func runRPC(args []string) {
+	for i := 0; i < len(args); i++ {
+		switch a := args[i]; {
+		case a == "--cache-ttl" && i+1 < len(args):
+			i++
+			checkCacheTTL = parseCacheTTL("--cache-ttl", args[i])
+		default:
+			fatal("unknown flag: %s (see nihao help)", a)
+		}
+	}
+	s := newRPCSession()
+	defer s.pool.Close()
+	if err := serveJSONRPC(context.Background(), os.Stdin, os.Stdout, s.handle, true); err != nil {
+		fatal("%s", err)
+	}
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+)
+
+// schemaVersion is the layout of the JSON that setup, check, and backup
+// print, reported as "schema_version". Adding a field doesn't change it;
+// renaming, removing, or changing the meaning of one does. A bump comes
+// with an entry in schemaDowngrades so --output-version can still produce
+// the old layout for at least one release.
+const schemaVersion = 1
+
+// schemaDowngrades[v] rewrites a version v+1 document into version v, in
+// place. A document is downgraded one version at a time.
+var schemaDowngrades = map[int]func(doc map[string]any){}
+
+// outputVersion is the layout asked for with --output-version; 0 means
+// the current one.
+var outputVersion int
+
+// oldestSchemaVersion is the oldest layout that can still be produced.
+func oldestSchemaVersion() int {
+	v := schemaVersion
+	for schemaDowngrades[v-1] != nil {
+		v--
+	}
+	return v
+}
+
+// parseOutputVersion reads an --output-version value.
+func parseOutputVersion(flag, s string) int {
+	v, err := strconv.Atoi(s)
+	oldest := oldestSchemaVersion()
+	if err != nil || v < oldest || v > schemaVersion {
+		if oldest == schemaVersion {
+			fatal("invalid %s %q: only schema version %d is supported", flag, s, schemaVersion)
+		}
+		fatal("invalid %s %q: supported schema versions are %d to %d", flag, s, oldest, schemaVersion)
+	}
+	return v
+}
+
+// marshalVersioned is json.MarshalIndent for setup, check, and backup
+// results, in the layout --output-version asked for.
+func marshalVersioned(v any) ([]byte, error) {
+	if outputVersion == 0 {
+		return marshalSchema(v, schemaVersion)
+	}
+	return marshalSchema(v, outputVersion)
+}
+
+// marshalSchema marshals v in schema version target.
+func marshalSchema(v any, target int) ([]byte, error) {
+	if target == schemaVersion {
+		return json.MarshalIndent(v, "", "  ")
+	}
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	var doc map[string]any
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return nil, err
+	}
+	for from := schemaVersion - 1; from >= target; from-- {
+		downgrade := schemaDowngrades[from]
+		if downgrade == nil {
+			return nil, fmt.Errorf("no layout for schema version %d", target)
+		}
+		downgrade(doc)
+	}
+	doc["schema_version"] = target
+	return json.MarshalIndent(doc, "", "  ")
+}
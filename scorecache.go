@@ -0,0 +1,93 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// relayCacheTTL is how long a relay's score is reused before the relay is
+// probed again. Set by --relay-cache-ttl or $NIHAO_RELAY_CACHE_TTL; zero
+// disables the cache.
+var relayCacheTTL = time.Hour
+
+// cachedRelayScore is a RelayScore with the time it was measured.
+type cachedRelayScore struct {
+	Score    RelayScore `json:"score"`
+	ScoredAt time.Time  `json:"scored_at"`
+}
+
+// fresh reports whether the entry can be reused. Unreachable relays are
+// always re-probed so one bad moment doesn't stick for the whole TTL.
+func (c cachedRelayScore) fresh(now time.Time, ttl time.Duration) bool {
+	return c.Score.Reachable && now.Sub(c.ScoredAt) < ttl
+}
+
+// relayCachePath is where relay scores persist between runs, or "" if
+// there's no usable cache directory.
+func relayCachePath() string {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(dir, "nihao", "relay-scores.json")
+}
+
+// loadRelayScoreCache reads the cache file. A missing or corrupt file is
+// just an empty cache.
+func loadRelayScoreCache(path string) map[string]cachedRelayScore {
+	cache := make(map[string]cachedRelayScore)
+	if path == "" {
+		return cache
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return cache
+	}
+	json.Unmarshal(data, &cache)
+	return cache
+}
+
+// saveRelayScoreCache writes the cache, dropping entries that are already
+// stale. The file is replaced atomically so concurrent runs can't corrupt it.
+func saveRelayScoreCache(path string, cache map[string]cachedRelayScore, now time.Time, ttl time.Duration) error {
+	for url, c := range cache {
+		if !c.fresh(now, ttl) {
+			delete(cache, url)
+		}
+	}
+	data, err := json.Marshal(cache)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".relay-scores-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// parseCacheTTL parses a cache TTL such as "30m" or "0" given for flag
+// (e.g. --relay-cache-ttl).
+func parseCacheTTL(flag, s string) time.Duration {
+	if s == "0" {
+		return 0
+	}
+	d, err := time.ParseDuration(s)
+	if err != nil || d < 0 {
+		fatal("invalid %s %q (use e.g. 30m, 6h, or 0 to disable)", flag, s)
+	}
+	return d
+}
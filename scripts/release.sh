@@ -0,0 +1,56 @@
+#!/bin/sh
+# Builds the assets of a nihao release and signs their checksums, the way
+# `nihao update` expects to find them:
+#
+#   nihao_<os>_<arch>[.exe]    one binary per platform (see releaseAssetName)
+#   checksums.txt              sha256sum lines for every binary
+#   checksums.txt.nostr.json   a kind 1063 event signed by releaseSigner,
+#                              with the SHA-256 of checksums.txt in its x tag
+#                              and the release tag in its version tag
+#
+# Usage: scripts/release.sh v1.2.3
+#
+# Run it from a clean checkout of the tag, so Go stamps the version into
+# the binaries. Signing uses nak (https://github.com/fiatjaf/nak) with the
+# release key in NOSTR_SECRET_KEY, or a bunker URL in NOSTR_SECRET_KEY for
+# a remote signer. Upload everything in dist/<version> to the GitHub
+# release for the tag.
+set -eu
+
+version=${1:?usage: scripts/release.sh <vX.Y.Z>}
+case $version in
+v[0-9]*.[0-9]*.[0-9]*) ;;
+*) echo "version must look like v1.2.3, got $version" >&2; exit 1 ;;
+esac
+if [ "$(git describe --tags --exact-match 2>/dev/null)" != "$version" ] || [ -n "$(git status --porcelain)" ]; then
+	echo "check out $version with a clean tree first" >&2
+	exit 1
+fi
+command -v nak >/dev/null || { echo "nak is needed to sign the checksums" >&2; exit 1; }
+: "${NOSTR_SECRET_KEY:?set NOSTR_SECRET_KEY to the release key (or a bunker URL)}"
+
+sha256() {
+	if command -v sha256sum >/dev/null; then sha256sum "$@"; else shasum -a 256 "$@"; fi
+}
+
+out=dist/$version
+rm -rf "$out"
+mkdir -p "$out"
+for target in linux/amd64 linux/arm64 darwin/amd64 darwin/arm64 windows/amd64 windows/arm64; do
+	goos=${target%/*}
+	goarch=${target#*/}
+	name=nihao_${goos}_${goarch}
+	[ "$goos" = windows ] && name=$name.exe
+	echo "building $name"
+	CGO_ENABLED=0 GOOS=$goos GOARCH=$goarch go build -trimpath -ldflags "-s -w" -o "$out/$name" .
+done
+
+(cd "$out" && sha256 nihao_* >checksums.txt)
+sum=$(sha256 "$out/checksums.txt" | cut -d' ' -f1)
+nak event --kind 1063 \
+	--content "nihao $version checksums" \
+	--tag m=text/plain \
+	--tag "x=$sum" \
+	--tag "version=$version" \
+	>"$out/checksums.txt.nostr.json"
+echo "signed $out/checksums.txt; upload $out/* to the $version release"
@@ -0,0 +1,162 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"fiatjaf.com/nostr"
+)
+
+// searchProbeQuery is what search relays are asked for: a word common
+// enough that any working NIP-50 index has a note containing it.
+const searchProbeQuery = "nostr"
+
+// searchProbeTimeout bounds the test search on each relay.
+const searchProbeTimeout = 5 * time.Second
+
+// SearchRelayStatus is whether one search relay still searches: it
+// advertises NIP-50 and a trivial search returns notes.
+type SearchRelayStatus struct {
+	URL     string `json:"url"`
+	NIP50   string `json:"nip50"`   // "yes", "no", or "unknown" (no NIP-11)
+	Results int    `json:"results"` // notes the test search returned
+	Error   string `json:"error,omitempty"`
+}
+
+// working reports whether the relay answered the test search with notes
+// and doesn't deny NIP-50 in its NIP-11.
+func (s SearchRelayStatus) working() bool {
+	return s.Error == "" && s.Results > 0 && s.NIP50 != "no"
+}
+
+// searchRelayURLs collects the relays the identity relies on for search:
+// its kind 10007 list, and any relay in its other lists that is known to
+// be a search relay.
+func searchRelayURLs(events map[int]*nostr.Event) []string {
+	var urls []string
+	add := func(raw string, onlySearch bool) {
+		url := normalizeRelayURL(raw)
+		if url == "" || slices.Contains(urls, url) || (onlySearch && classifyRelay(url) != "search") {
+			return
+		}
+		urls = append(urls, url)
+	}
+	if evt := events[10007]; evt != nil {
+		for _, raw := range relayTags(evt) {
+			add(raw, false)
+		}
+	}
+	for _, kind := range []int{10002, 10050, 10019} {
+		if evt := events[kind]; evt != nil {
+			for _, raw := range relayTags(evt) {
+				add(raw, true)
+			}
+		}
+	}
+	return urls
+}
+
+// probeSearch sends a NIP-50 search REQ and counts the notes returned
+// before EOSE.
+func probeSearch(ctx context.Context, cr checkRelay) (int, error) {
+	ctx, cancel := context.WithTimeout(ctx, searchProbeTimeout)
+	defer cancel()
+	if err := relayLimiter.wait(ctx, limiterKey(cr.url)); err != nil {
+		return 0, err
+	}
+	sub, err := cr.relay.Subscribe(ctx, nostr.Filter{Kinds: []nostr.Kind{1}, Search: searchProbeQuery, Limit: 3}, nostr.SubscriptionOptions{Label: "search"})
+	if err != nil {
+		return 0, err
+	}
+	n := 0
+	for {
+		select {
+		case <-sub.Events:
+			n++
+		case <-sub.EndOfStoredEvents:
+			return n, nil
+		case reason := <-sub.ClosedReason:
+			return n, fmt.Errorf("refused the search: %s", reason)
+		case <-ctx.Done():
+			return n, fmt.Errorf("no answer to the search")
+		}
+	}
+}
+
+// checkSearchRelays probes every search relay for NIP-50 in its NIP-11
+// (infos, already fetched) and with a test search, and adds a
+// "search_relays" check. Relays that silently stopped searching look fine
+// in the feature matrix, so this is what catches them.
+func checkSearchRelays(ctx context.Context, result *CheckResult, infos map[string]*RelayInfo) {
+	urls := searchRelayURLs(result.events)
+	if len(urls) == 0 {
+		return
+	}
+
+	relays := connectCheckRelays(ctx, urls)
+	defer func() {
+		for _, cr := range relays {
+			cr.relay.Close()
+		}
+	}()
+	byURL := make(map[string]checkRelay)
+	for _, cr := range relays {
+		byURL[cr.url] = cr
+	}
+
+	statuses := make([]SearchRelayStatus, len(urls))
+	var wg sync.WaitGroup
+	for i, url := range urls {
+		statuses[i] = SearchRelayStatus{URL: url, NIP50: featureSupport(infos[url], relayFeature{nips: [][]int{{50}}})}
+		cr, ok := byURL[url]
+		if !ok {
+			statuses[i].Error = "unreachable"
+			continue
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			n, err := probeSearch(ctx, cr)
+			statuses[i].Results = n
+			if err != nil {
+				statuses[i].Error = err.Error()
+			}
+		}()
+	}
+	wg.Wait()
+
+	result.SearchRelays = statuses
+	status, detail := assessSearchRelays(statuses)
+	result.addCheck("search_relays", status, detail)
+}
+
+// assessSearchRelays passes when every search relay works, warns when
+// some are stale, and fails when none are left.
+func assessSearchRelays(statuses []SearchRelayStatus) (string, string) {
+	var stale []string
+	for _, s := range statuses {
+		switch {
+		case s.working():
+			continue
+		case s.Error != "":
+			stale = append(stale, fmt.Sprintf("%s (%s)", s.URL, s.Error))
+		case s.NIP50 == "no":
+			stale = append(stale, fmt.Sprintf("%s (no NIP-50 in its NIP-11)", s.URL))
+		default:
+			stale = append(stale, fmt.Sprintf("%s (search returned nothing)", s.URL))
+		}
+	}
+	working := len(statuses) - len(stale)
+	detail := fmt.Sprintf("%d/%d search relay(s) answer NIP-50 searches", working, len(statuses))
+	switch {
+	case len(stale) == 0:
+		return "pass", detail
+	case working == 0:
+		return "fail", detail + " — stale: " + strings.Join(stale, ", ")
+	}
+	return "warn", detail + " — stale: " + strings.Join(stale, ", ")
+}
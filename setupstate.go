@@ -0,0 +1,278 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"fiatjaf.com/nostr"
+	"fiatjaf.com/nostr/nip19"
+)
+
+// setupState is the progress of a setup run, saved after every step so an
+// interrupted run can be finished with --resume. Events are kept as signed:
+// republishing one with the same ID is a no-op on relays that already have
+// it, so the first note is never posted twice.
+//
+// It's only written when the key can be read back (--nsec-file, --nsec-cmd,
+// or a key source), since a resumed run must sign as the same identity.
+type setupState struct {
+	Pubkey    string                `json:"pubkey"`
+	StartedAt time.Time             `json:"started_at"`
+	NsecFile  string                `json:"nsec_file,omitempty"` // the key is read back from here
+	Relays    []string              `json:"relays,omitempty"`    // publishing targets, chosen once
+	Marked    []MarkedRelay         `json:"marked_relays,omitempty"`
+	Steps     map[string]*setupStep `json:"steps"`
+
+	// Results of steps with side effects beyond an event, so they aren't
+	// repeated. A provider login is kept here (0600) until setup finishes.
+	Lightning *LightningAccount  `json:"lightning,omitempty"`
+	NIP05     *NIP05Registration `json:"nip05,omitempty"`
+	Wallet    *WalletSetupResult `json:"wallet,omitempty"`
+
+	path string
+}
+
+// setupStep is one published event of a setup run.
+type setupStep struct {
+	Event  *nostr.Event      `json:"event"`
+	Done   bool              `json:"done"`             // at least one relay took it
+	Relays map[string]string `json:"relays,omitempty"` // relay URL → "ok", or why it didn't take the event
+}
+
+// failed lists the relays that didn't take the step's event, in order.
+func (s *setupStep) failed() []string {
+	var urls []string
+	for url, status := range s.Relays {
+		if status != "ok" {
+			urls = append(urls, url)
+		}
+	}
+	slices.Sort(urls)
+	return urls
+}
+
+// setupStateDir is where setup state files live, or "" if there's no
+// usable config directory.
+func setupStateDir() string {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(dir, "nihao", "setup")
+}
+
+func setupStatePath(pk nostr.PubKey) string {
+	dir := setupStateDir()
+	if dir == "" {
+		return ""
+	}
+	return filepath.Join(dir, pk.Hex()+".json")
+}
+
+// newSetupState starts tracking a setup run for pk. It returns nil (and
+// every method is then a no-op) if there's nowhere to save it.
+func newSetupState(pk nostr.PubKey, nsecFile string) *setupState {
+	path := setupStatePath(pk)
+	if path == "" {
+		return nil
+	}
+	if nsecFile != "" {
+		if abs, err := filepath.Abs(nsecFile); err == nil {
+			nsecFile = abs
+		}
+	}
+	st := &setupState{Pubkey: pk.Hex(), StartedAt: time.Now().UTC(), NsecFile: nsecFile, Steps: make(map[string]*setupStep), path: path}
+	st.save()
+	return st
+}
+
+func loadSetupState(path string) (*setupState, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var st setupState
+	if err := json.Unmarshal(data, &st); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	if st.Steps == nil {
+		st.Steps = make(map[string]*setupStep)
+	}
+	st.path = path
+	return &st, nil
+}
+
+// pendingSetups lists the state files of interrupted setup runs.
+func pendingSetups() []string {
+	dir := setupStateDir()
+	if dir == "" {
+		return nil
+	}
+	paths, _ := filepath.Glob(filepath.Join(dir, "*.json"))
+	return paths
+}
+
+// resumeSetupState finds the interrupted run to continue and its key: the
+// one for the given key source, or else the only one pending, with the key
+// read back from its --nsec-file.
+func resumeSetupState(keys keySource) (*setupState, nostr.SecretKey, string) {
+	if keys.isSet() {
+		sk, source, err := keys.load()
+		if err != nil {
+			fatal("%s", err)
+		}
+		st, err := loadSetupState(setupStatePath(sk.Public()))
+		if err != nil {
+			fatal("no interrupted setup for %s to resume", nip19.EncodeNpub(sk.Public()))
+		}
+		return st, sk, source
+	}
+
+	pending := pendingSetups()
+	switch len(pending) {
+	case 0:
+		fatal("no interrupted setup to resume")
+	case 1:
+	default:
+		fatal("%d interrupted setups; pass the key of the one to resume (--sec-cmd, --stdin, ...)", len(pending))
+	}
+	st, err := loadSetupState(pending[0])
+	if err != nil {
+		fatal("%s", err)
+	}
+	if st.NsecFile == "" {
+		fatal("the interrupted setup stored its key with --nsec-cmd; pass it back with --sec-cmd to resume")
+	}
+	data, err := os.ReadFile(st.NsecFile)
+	if err != nil {
+		fatal("could not read the key back: %s", err)
+	}
+	keys.sec = strings.TrimSpace(string(data))
+	sk, _, err := keys.load()
+	if err != nil {
+		fatal("%s", err)
+	}
+	if sk.Public().Hex() != st.Pubkey {
+		fatal("%s no longer holds the key of the interrupted setup", st.NsecFile)
+	}
+	return st, sk, "secret key from " + st.NsecFile
+}
+
+// save writes the state (0600, replacing the file atomically).
+func (st *setupState) save() {
+	if st == nil {
+		return
+	}
+	if err := os.MkdirAll(filepath.Dir(st.path), 0700); err != nil {
+		return
+	}
+	data, err := json.MarshalIndent(st, "", "  ")
+	if err != nil {
+		return
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(st.path), ".setup-*")
+	if err != nil {
+		return
+	}
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return
+	}
+	if tmp.Close() == nil {
+		os.Rename(tmp.Name(), st.path)
+	}
+}
+
+// saved returns the event a step signed in an earlier run, or nil.
+func (st *setupState) saved(step string) *nostr.Event {
+	if st == nil || st.Steps[step] == nil {
+		return nil
+	}
+	return st.Steps[step].Event
+}
+
+// done reports whether a step was published in an earlier run to every
+// relay that would take it, so there's nothing left to retry.
+func (st *setupState) done(step string) bool {
+	return st != nil && st.Steps[step] != nil && st.Steps[step].Done && len(st.Steps[step].failed()) == 0
+}
+
+// publish publishes a step's event: the one from an earlier run if there
+// is one, else evt, which is saved first so a crash mid-publish resumes
+// with the same event. Each relay's answer is recorded, and relays that
+// already took the event aren't sent it again: a step published before
+// is only retried on the relays that refused it or couldn't be reached.
+func (st *setupState) publish(pool *RelayPool, step string, evt nostr.Event, logln func(...any)) {
+	if st.done(step) {
+		logln("   ↩️  already published")
+		return
+	}
+	if prev := st.saved(step); prev != nil {
+		evt = *prev
+	} else if st != nil {
+		st.Steps[step] = &setupStep{Event: &evt}
+		st.save()
+	}
+	if st == nil {
+		pool.PublishRouted(evt)
+		return
+	}
+
+	s := st.Steps[step]
+	urls := pool.URLs()
+	if s.Done {
+		urls = s.failed()
+		logln(fmt.Sprintf("   ↩️  published before; retrying %d relay(s) that didn't take it", len(urls)))
+	} else {
+		urls = slices.DeleteFunc(urls, func(url string) bool { return s.Relays[url] == "ok" })
+	}
+	if s.Relays == nil {
+		s.Relays = make(map[string]string)
+	}
+	for _, r := range pool.publish(evt, urls, true) {
+		switch {
+		case r.Skipped:
+		case r.OK:
+			s.Relays[r.URL] = "ok"
+			s.Done = true
+		default:
+			s.Relays[r.URL] = r.Reason
+		}
+	}
+	st.save()
+}
+
+// unpublished lists the steps no relay has taken yet, in order.
+func (st *setupState) unpublished() []string {
+	if st == nil {
+		return nil
+	}
+	var steps []string
+	for step, s := range st.Steps {
+		if !s.Done {
+			steps = append(steps, step)
+		}
+	}
+	slices.Sort(steps)
+	return steps
+}
+
+// finish removes the state once setup has completed, unless a step never
+// reached any relay: then the state is kept for --resume to retry it, and
+// those steps are returned.
+func (st *setupState) finish() []string {
+	if st == nil {
+		return nil
+	}
+	if steps := st.unpublished(); len(steps) > 0 {
+		return steps
+	}
+	os.Remove(st.path)
+	return nil
+}
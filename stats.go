@@ -0,0 +1,275 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"fiatjaf.com/nostr"
+	"fiatjaf.com/nostr/nip19"
+)
+
+// statsKinds are the kinds stats counts unless --kinds says otherwise: the
+// identity's lists and the content people notice going missing.
+var statsKinds = []int{0, 1, 3, 6, 7, 10002, 10050, 10019, 30023}
+
+// Relays without NIP-45 COUNT are paged through statsPageSize events at a
+// time, up to statsPagedMax per kind.
+const (
+	statsPageSize = 500
+	statsPagedMax = 5000
+)
+
+// RelayStats is how many of the identity's events one relay stores.
+type RelayStats struct {
+	URL    string      `json:"url"`
+	Method string      `json:"method,omitempty"` // "count" (NIP-45) or "paged"
+	Counts map[int]int `json:"counts"`           // kind → events stored
+	Capped []int       `json:"capped,omitempty"` // kinds that hit statsPagedMax, so at least that many
+	Error  string      `json:"error,omitempty"`
+}
+
+// StatsResult is the output of `nihao stats`.
+type StatsResult struct {
+	Npub   string       `json:"npub"`
+	Kinds  []int        `json:"kinds"`
+	Relays []RelayStats `json:"relays"`
+	Gaps   []string     `json:"gaps,omitempty"` // relays holding noticeably less than the others
+}
+
+// countKind counts pk's events of one kind on a relay: with a NIP-45
+// COUNT if useCount, else by paging back through them with until.
+func countKind(ctx context.Context, cr checkRelay, pk nostr.PubKey, kind int, useCount bool) (n int, method string, capped bool) {
+	filter := nostr.Filter{Authors: []nostr.PubKey{pk}, Kinds: []nostr.Kind{nostr.Kind(kind)}}
+	if useCount && relayLimiter.wait(ctx, limiterKey(cr.url)) == nil {
+		countCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+		count, _, err := cr.relay.Count(countCtx, filter, nostr.SubscriptionOptions{Label: "stats"})
+		cancel()
+		if err == nil {
+			return int(count), "count", false
+		}
+	}
+
+	events, capped := pageEvents(ctx, cr, filter, statsPagedMax)
+	return len(events), "paged", capped
+}
+
+// pageEvents fetches every event matching filter from one relay, newest
+// first, statsPageSize at a time with until, stopping after max. It
+// reports whether it stopped there rather than at the end.
+func pageEvents(ctx context.Context, cr checkRelay, filter nostr.Filter, max int) ([]nostr.Event, bool) {
+	var events []nostr.Event
+	seen := make(map[nostr.ID]bool)
+	for len(events) < max && ctx.Err() == nil {
+		filter.Limit = statsPageSize
+		got, fresh := 0, 0
+		var oldest nostr.Timestamp
+		for evt := range cr.queryEvents(filter) {
+			got++
+			if !seen[evt.ID] {
+				seen[evt.ID] = true
+				events = append(events, evt)
+				fresh++
+			}
+			if oldest == 0 || evt.CreatedAt < oldest {
+				oldest = evt.CreatedAt
+			}
+		}
+		// until is inclusive, so a page can repeat the previous one's
+		// oldest second; one with nothing new is the end
+		if got < statsPageSize || fresh == 0 {
+			return events, false
+		}
+		filter.Until = oldest
+	}
+	return events, len(events) >= max
+}
+
+// relayStats counts each kind on one relay. COUNT is tried first and not
+// again once the relay turns it down.
+func relayStats(ctx context.Context, cr checkRelay, pk nostr.PubKey, kinds []int) RelayStats {
+	rs := RelayStats{URL: cr.url, Counts: make(map[int]int)}
+	useCount := true
+	for _, kind := range kinds {
+		n, method, capped := countKind(ctx, cr, pk, kind, useCount)
+		rs.Counts[kind] = n
+		if capped {
+			rs.Capped = append(rs.Capped, kind)
+		}
+		if method == "paged" {
+			useCount = false
+		}
+		if rs.Method == "" || method == "paged" {
+			rs.Method = method
+		}
+	}
+	return rs
+}
+
+// retentionGaps compares relays kind by kind. A relay missing a
+// replaceable list the others have, or holding under half of what the
+// best-stocked relay has of a regular kind, is dropping content.
+func retentionGaps(stats []RelayStats, kinds []int) []string {
+	var gaps []string
+	for _, kind := range kinds {
+		best := 0
+		for _, rs := range stats {
+			if rs.Error == "" && rs.Counts[kind] > best {
+				best = rs.Counts[kind]
+			}
+		}
+		if best == 0 {
+			continue
+		}
+		label := kindName(kind)
+		for _, rs := range stats {
+			if rs.Error != "" {
+				continue
+			}
+			n := rs.Counts[kind]
+			switch {
+			case n == 0 && isReplaceableKind(kind):
+				gaps = append(gaps, fmt.Sprintf("%s doesn't have your %s (kind %d)", rs.URL, label, kind))
+			case n == 0 && best >= 2:
+				gaps = append(gaps, fmt.Sprintf("%s has none of your %d %s events (kind %d)", rs.URL, best, label, kind))
+			case !isReplaceableKind(kind) && best >= 10 && n*2 < best:
+				gaps = append(gaps, fmt.Sprintf("%s has %d of your %d %s events (kind %d, %d%%)", rs.URL, n, best, label, kind, n*100/best))
+			}
+		}
+	}
+	return gaps
+}
+
+// isReplaceableKind reports whether a relay keeps only the latest event
+// of the kind per author (NIP-01), so one stored is all there is.
+func isReplaceableKind(kind int) bool {
+	return kind == 0 || kind == 3 || (kind >= 10000 && kind < 20000)
+}
+
+func runStats(args []string) {
+	var relays []string
+	var target string
+	kinds := statsKinds
+	jsonOutput, quiet := false, false
+	for i := 0; i < len(args); i++ {
+		switch a := args[i]; {
+		case a == "--relays" && i+1 < len(args):
+			i++
+			relays = strings.Split(args[i], ",")
+		case a == "--kinds" && i+1 < len(args):
+			i++
+			var err error
+			if kinds, err = parseKinds(args[i]); err != nil {
+				fatal("--kinds: %s", err)
+			}
+		case a == "--json":
+			jsonOutput = true
+		case a == "--quiet" || a == "-q":
+			quiet = true
+		case strings.HasPrefix(a, "-"):
+			fatal("unknown flag: %s (see nihao help)", a)
+		default:
+			target = a
+		}
+	}
+	if target == "" {
+		fatal("usage: nihao stats <npub|nip05> [--relays <r1,r2,...>] [--kinds <k1,k2,...>] [--json]")
+	}
+	pk, err := resolveTarget(target, quiet || jsonOutput)
+	if err != nil {
+		fatal("%s", err)
+	}
+	npub := nip19.EncodeNpub(pk)
+	logf := func(format string, a ...any) {
+		if !quiet && !jsonOutput {
+			fmt.Printf(format+"\n", a...)
+		}
+	}
+	logf("nihao stats 📊 %s\n", npub)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Minute)
+	defer cancel()
+
+	// The identity's own relays, from its kind 10002
+	if len(relays) == 0 {
+		lookup := connectCheckRelays(ctx, defaultRelays)
+		_, relayEvt := fetchKindFrom(ctx, lookup, pk, 10002)
+		for _, cr := range lookup {
+			cr.relay.Close()
+		}
+		if relayEvt == nil {
+			fatal("%s has no relay list (kind 10002); pass --relays", npub)
+		}
+		for url := range relayPurposes(relayEvt) {
+			relays = append(relays, url)
+		}
+		sort.Strings(relays)
+	}
+
+	result := StatsResult{Npub: npub, Kinds: kinds}
+	connected := connectCheckRelays(ctx, relays)
+	defer func() {
+		for _, cr := range connected {
+			cr.relay.Close()
+		}
+	}()
+	byURL := make(map[string]checkRelay)
+	for _, cr := range connected {
+		byURL[cr.url] = cr
+	}
+	result.Relays = make([]RelayStats, len(relays))
+	var wg sync.WaitGroup
+	for i, url := range relays {
+		cr, ok := byURL[url]
+		if !ok {
+			result.Relays[i] = RelayStats{URL: url, Counts: map[int]int{}, Error: "unreachable"}
+			continue
+		}
+		wg.Add(1)
+		go func(i int, cr checkRelay) {
+			defer wg.Done()
+			result.Relays[i] = relayStats(ctx, cr, pk, kinds)
+		}(i, cr)
+	}
+	wg.Wait()
+	result.Gaps = retentionGaps(result.Relays, kinds)
+
+	if jsonOutput {
+		out, _ := json.MarshalIndent(result, "", "  ")
+		fmt.Println(string(out))
+		return
+	}
+	for _, rs := range result.Relays {
+		if rs.Error != "" {
+			logf("❌ %s: %s", rs.URL, rs.Error)
+			continue
+		}
+		method := "NIP-45 COUNT"
+		if rs.Method == "paged" {
+			method = "paged"
+		}
+		logf("📡 %s (%s)", rs.URL, method)
+		var parts []string
+		for _, kind := range kinds {
+			n := fmt.Sprint(rs.Counts[kind])
+			if slices.Contains(rs.Capped, kind) {
+				n += "+"
+			}
+			parts = append(parts, fmt.Sprintf("%s %s", kindName(kind), n))
+		}
+		logf("   %s", strings.Join(parts, " · "))
+	}
+	if len(result.Gaps) > 0 {
+		logf("")
+		for _, gap := range result.Gaps {
+			logf("⚠️  %s", gap)
+		}
+	} else {
+		logf("\n✅ Your relays hold the same content")
+	}
+}
@@ -0,0 +1,220 @@
+package main
+
+import (
+	"fmt"
+	"net/url"
+	"sort"
+	"strings"
+
+	"fiatjaf.com/nostr"
+)
+
+// TagProblem is a malformed tag in one of the identity's replaceable
+// events. Most code reading these lists skips a tag it can't use, which
+// keeps check from crashing but hides the client that wrote it.
+type TagProblem struct {
+	Kind    int      `json:"kind"`
+	Tag     []string `json:"tag"`
+	Problem string   `json:"problem"`
+}
+
+// tagRules validates the tags of each list kind. A rule returns the
+// problems in one tag, or nil if it's fine or not one it knows.
+var tagRules = map[int]func(tag nostr.Tag) []string{
+	3:     followTagProblems,
+	10002: relayListTagProblems,
+	10050: relayTagProblems,
+	10007: relayTagProblems,
+	10019: nutzapTagProblems,
+	10063: serverTagProblems,
+	10096: serverTagProblems,
+	10015: interestTagProblems,
+	10030: emojiTagProblems,
+}
+
+func followTagProblems(tag nostr.Tag) []string {
+	if tag[0] != "p" {
+		return nil
+	}
+	if len(tag) < 2 {
+		return []string{"p tag without a pubkey"}
+	}
+	var problems []string
+	if _, err := nostr.PubKeyFromHex(tag[1]); err != nil {
+		problems = append(problems, fmt.Sprintf("%q isn't a 64-character hex pubkey", tag[1]))
+	}
+	if len(tag) >= 3 && tag[2] != "" && normalizeRelayURL(tag[2]) == "" {
+		problems = append(problems, fmt.Sprintf("relay hint %q isn't a ws:// or wss:// URL", tag[2]))
+	}
+	return problems
+}
+
+func relayListTagProblems(tag nostr.Tag) []string {
+	switch tag[0] {
+	case "relay":
+		return []string{`NIP-65 relay lists use "r" tags; clients ignore "relay"`}
+	case "r":
+	default:
+		return nil
+	}
+	if len(tag) < 2 {
+		return []string{"r tag without a URL"}
+	}
+	var problems []string
+	if normalizeRelayURL(tag[1]) == "" {
+		problems = append(problems, fmt.Sprintf("%q isn't a ws:// or wss:// URL", tag[1]))
+	}
+	if len(tag) >= 3 && tag[2] != "" && tag[2] != "read" && tag[2] != "write" {
+		problems = append(problems, fmt.Sprintf("marker %q isn't read or write", tag[2]))
+	}
+	return problems
+}
+
+// relayTagProblems is for the lists that use "relay" tags: DM relays and
+// search relays.
+func relayTagProblems(tag nostr.Tag) []string {
+	switch tag[0] {
+	case "r":
+		return []string{`this list uses "relay" tags; clients ignore "r"`}
+	case "relay":
+	default:
+		return nil
+	}
+	if len(tag) < 2 {
+		return []string{"relay tag without a URL"}
+	}
+	if normalizeRelayURL(tag[1]) == "" {
+		return []string{fmt.Sprintf("%q isn't a ws:// or wss:// URL", tag[1])}
+	}
+	return nil
+}
+
+func nutzapTagProblems(tag nostr.Tag) []string {
+	switch tag[0] {
+	case "relay":
+		return relayTagProblems(tag)
+	case "mint":
+		if len(tag) < 2 {
+			return []string{"mint tag without a URL"}
+		}
+		var problems []string
+		if !isHTTPURL(tag[1]) {
+			problems = append(problems, fmt.Sprintf("%q isn't an http(s) URL", tag[1]))
+		}
+		if len(tag) < 3 {
+			problems = append(problems, "no units; senders have to guess the mint takes sats")
+		}
+		for _, unit := range tag[2:] {
+			if unit == "" || unit != strings.ToLower(unit) {
+				problems = append(problems, fmt.Sprintf("unit %q isn't a lowercase unit like sat", unit))
+			}
+		}
+		return problems
+	case "pubkey":
+		if len(tag) < 2 || tag[1] == "" {
+			return []string{"pubkey tag without a key"}
+		}
+	}
+	return nil
+}
+
+// serverTagProblems is for Blossom (10063) and NIP-96 (10096) server lists.
+func serverTagProblems(tag nostr.Tag) []string {
+	if tag[0] != "server" {
+		return nil
+	}
+	if len(tag) < 2 {
+		return []string{"server tag without a URL"}
+	}
+	if !isHTTPURL(tag[1]) {
+		return []string{fmt.Sprintf("%q isn't an http(s) URL", tag[1])}
+	}
+	return nil
+}
+
+func interestTagProblems(tag nostr.Tag) []string {
+	if tag[0] != "t" {
+		return nil
+	}
+	if len(tag) < 2 || strings.TrimSpace(tag[1]) == "" {
+		return []string{"empty hashtag"}
+	}
+	if strings.HasPrefix(tag[1], "#") {
+		return []string{fmt.Sprintf("hashtag %q includes the #", tag[1])}
+	}
+	return nil
+}
+
+func emojiTagProblems(tag nostr.Tag) []string {
+	if tag[0] != "emoji" {
+		return nil
+	}
+	if len(tag) < 3 {
+		return []string{"emoji tag needs a shortcode and an image URL"}
+	}
+	var problems []string
+	if tag[1] == "" || strings.Trim(tag[1], "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789_") != "" {
+		problems = append(problems, fmt.Sprintf("shortcode %q has characters other than letters, digits, and _", tag[1]))
+	}
+	if !isHTTPURL(tag[2]) {
+		problems = append(problems, fmt.Sprintf("%q isn't an http(s) URL", tag[2]))
+	}
+	return problems
+}
+
+func isHTTPURL(raw string) bool {
+	u, err := url.Parse(raw)
+	return err == nil && (u.Scheme == "https" || u.Scheme == "http") && u.Host != ""
+}
+
+// lintEventTags returns the malformed tags in evt, for the kinds it knows.
+func lintEventTags(evt *nostr.Event) []TagProblem {
+	rule := tagRules[int(evt.Kind)]
+	if rule == nil {
+		return nil
+	}
+	var problems []TagProblem
+	for _, tag := range evt.Tags {
+		if len(tag) == 0 {
+			problems = append(problems, TagProblem{Kind: int(evt.Kind), Tag: []string{}, Problem: "empty tag"})
+			continue
+		}
+		for _, p := range rule(tag) {
+			problems = append(problems, TagProblem{Kind: int(evt.Kind), Tag: tag, Problem: p})
+		}
+	}
+	return problems
+}
+
+// checkEventTags validates the tags of every list event check fetched and
+// adds an "event_tags" check. Run it once the events are recorded.
+func checkEventTags(result *CheckResult) {
+	var kinds []int
+	for kind, evt := range result.events {
+		if evt != nil && tagRules[kind] != nil {
+			kinds = append(kinds, kind)
+		}
+	}
+	if len(kinds) == 0 {
+		return
+	}
+	sort.Ints(kinds)
+	var problems []TagProblem
+	for _, kind := range kinds {
+		problems = append(problems, lintEventTags(result.events[kind])...)
+	}
+	result.TagProblems = problems
+	if len(problems) == 0 {
+		result.addCheck("event_tags", "pass", fmt.Sprintf("tags well-formed in %d list event(s)", len(kinds)))
+		return
+	}
+	var shown []string
+	for _, p := range problems[:min(len(problems), 3)] {
+		shown = append(shown, fmt.Sprintf("kind %d %s", p.Kind, p.Problem))
+	}
+	detail := fmt.Sprintf("%d malformed tag(s): %s", len(problems), strings.Join(shown, "; "))
+	if len(problems) > 3 {
+		detail += fmt.Sprintf("; and %d more", len(problems)-3)
+	}
+	result.addCheck("event_tags", "warn", detail)
+}
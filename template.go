@@ -0,0 +1,112 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"fiatjaf.com/nostr"
+	"fiatjaf.com/nostr/nip19"
+)
+
+// setupTemplate is the structure setup --template copies from another
+// identity: its relay lists, interests, and public mutes. Never its
+// profile, which is who they are, not how they're set up.
+type setupTemplate struct {
+	npub      string
+	relays    []MarkedRelay // kind 10002, markers kept
+	dmRelays  []string      // kind 10050
+	interests nostr.Tags    // kind 10015
+	mutes     nostr.Tags    // kind 10000, public items only
+}
+
+// SetupTemplate reports what setup --template copied: counts of the
+// relays, interests, and mutes taken from the template's lists.
+type SetupTemplate struct {
+	Npub      string `json:"npub"`
+	Relays    int    `json:"relays"`
+	DMRelays  int    `json:"dm_relays"`
+	Interests int    `json:"interests"`
+	Mutes     int    `json:"mutes"`
+}
+
+// loadSetupTemplate fetches the lists setup --template copies, from relays
+// (default: the default relays).
+func loadSetupTemplate(target string, relays []string, quiet bool) (*setupTemplate, error) {
+	pk, err := resolveTarget(target, quiet)
+	if err != nil {
+		return nil, err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if len(relays) == 0 {
+		relays = defaultRelays
+	}
+	checkRelays := connectCheckRelays(ctx, relays)
+	if len(checkRelays) == 0 {
+		return nil, fmt.Errorf("could not connect to any relay")
+	}
+	defer func() {
+		for _, cr := range checkRelays {
+			cr.relay.Close()
+		}
+	}()
+
+	events := make(map[int]*nostr.Event)
+	for _, kind := range []int{10002, 10050, 10015, 10000} {
+		_, events[kind] = fetchKindFrom(ctx, checkRelays, pk, kind)
+	}
+	tpl := templateFromEvents(pk, events[10002], events[10050], events[10015], events[10000])
+	if len(tpl.relays) == 0 && len(tpl.dmRelays) == 0 && len(tpl.interests) == 0 && len(tpl.mutes) == 0 {
+		return nil, fmt.Errorf("%s has no relay list, interests, or mute list to copy", tpl.npub)
+	}
+	return tpl, nil
+}
+
+// templateFromEvents extracts what a template identity's lists have to
+// copy. Relays a new account can't use the same way (paid, search, NWC,
+// aggregators) are left out, as in discovery. The mute list's encrypted
+// private items stay behind: they're only readable by their owner.
+func templateFromEvents(pk nostr.PubKey, relayEvt, dmEvt, interestsEvt, muteEvt *nostr.Event) *setupTemplate {
+	tpl := &setupTemplate{npub: nip19.EncodeNpub(pk)}
+	if relayEvt != nil {
+		for _, tag := range relayEvt.Tags {
+			if len(tag) < 2 || tag[0] != "r" || normalizeRelayURL(tag[1]) == "" {
+				continue
+			}
+			if _, ok := ClassifyDiscoveredRelay(tag[1]); !ok {
+				continue
+			}
+			mr := MarkedRelay{URL: tag[1], Marker: RelayMarkerBoth}
+			if len(tag) >= 3 && (tag[2] == "read" || tag[2] == "write") {
+				mr.Marker = RelayMarker(tag[2])
+			}
+			tpl.relays = append(tpl.relays, mr)
+		}
+	}
+	if dmEvt != nil {
+		for _, tag := range dmEvt.Tags {
+			if len(tag) >= 2 && tag[0] == "relay" && normalizeRelayURL(tag[1]) != "" {
+				tpl.dmRelays = append(tpl.dmRelays, tag[1])
+			}
+		}
+	}
+	for _, item := range []struct {
+		evt  *nostr.Event
+		dst  *nostr.Tags
+		keep map[string]bool
+	}{
+		{interestsEvt, &tpl.interests, map[string]bool{"t": true, "a": true}},
+		{muteEvt, &tpl.mutes, map[string]bool{"p": true, "t": true, "word": true, "e": true}},
+	} {
+		if item.evt == nil {
+			continue
+		}
+		for _, tag := range item.evt.Tags {
+			if len(tag) >= 2 && item.keep[tag[0]] {
+				*item.dst = append(*item.dst, tag.Clone())
+			}
+		}
+	}
+	return tpl
+}
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// tier is one rung of the identity ladder. Each tier needs the checks of
+// the ones below it plus its own, so reaching it always means passing a
+// few specific, fixable things rather than crossing a score threshold.
+type tier struct {
+	name     string
+	emoji    string
+	blurb    string
+	requires []string // scored checks that must pass
+}
+
+// tiers climb from a bare key to an identity that works everywhere. The
+// top tier requires every scored check.
+var tiers = []tier{
+	{"hatchling", "🥚", "a key, not yet an identity", nil},
+	{"fledgling", "🐣", "findable, with a profile and a feed", []string{"profile", "relay_list", "follow_list"}},
+	{"established", "🐦", "recognizable: verified name and a face", []string{"nip05", "picture"}},
+	{"sovereign", "🦅", "payable and complete everywhere", []string{"lud16", "nip60_wallet", "banner"}},
+}
+
+// TierProgress is where an identity stands on the tier ladder and what
+// gets it to the next rung.
+type TierProgress struct {
+	Name    string   `json:"name"`
+	Level   int      `json:"level"` // 1 = hatchling
+	Of      int      `json:"of"`
+	Next    string   `json:"next,omitempty"`
+	Unlocks []string `json:"unlocks,omitempty"` // checks to pass for the next tier
+}
+
+// assessTier finds the highest tier whose checks (and those of every tier
+// below) all pass.
+func assessTier(r CheckResult) TierProgress {
+	passed := make(map[string]bool)
+	for _, c := range r.Checks {
+		if c.Status == "pass" {
+			passed[c.Name] = true
+		}
+	}
+	level := 0
+	for level+1 < len(tiers) && allPassed(passed, tiers[level+1].requires) {
+		level++
+	}
+	p := TierProgress{Name: tiers[level].name, Level: level + 1, Of: len(tiers)}
+	if level+1 < len(tiers) {
+		next := tiers[level+1]
+		p.Next = next.name
+		for _, name := range next.requires {
+			if !passed[name] {
+				p.Unlocks = append(p.Unlocks, name)
+			}
+		}
+	}
+	return p
+}
+
+func allPassed(passed map[string]bool, names []string) bool {
+	for _, name := range names {
+		if !passed[name] {
+			return false
+		}
+	}
+	return true
+}
+
+// printTier shows the tier and what unlocks the next one, in place of a
+// bare "good" or "needs work".
+func printTier(p TierProgress) {
+	t := tiers[p.Level-1]
+	fmt.Printf("  %s Tier %d/%d: %s — %s\n", t.emoji, p.Level, p.Of, t.name, t.blurb)
+	if p.Next == "" {
+		fmt.Println("  🎉 Top tier: a perfect identity!")
+		return
+	}
+	next := tiers[p.Level]
+	fmt.Printf("  %s Next: %s — pass %s\n", next.emoji, next.name, strings.Join(p.Unlocks, ", "))
+}
@@ -0,0 +1,158 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// probeLimit is how many of the slowest targets a result keeps.
+const probeLimit = 10
+
+// ProbeTiming is the time one check run spent talking to one HTTP host or
+// relay, so a slow run can be pinned on a dead mint or a sluggish NIP-05
+// host instead of guessed at.
+type ProbeTiming struct {
+	Type      string `json:"type"`   // "http" or "relay"
+	Target    string `json:"target"` // host, or relay URL
+	Requests  int    `json:"requests"`
+	TotalMs   int64  `json:"total_ms"`
+	SlowestMs int64  `json:"slowest_ms"`
+	Errors    int    `json:"errors,omitempty"`
+}
+
+// probeTimings collects ProbeTimings for one check run. HTTP requests find
+// it through their context, relay queries through their checkRelay.
+type probeTimings struct {
+	mu       sync.Mutex
+	byTarget map[string]*ProbeTiming
+}
+
+func (t *probeTimings) record(typ, target string, d time.Duration, failed bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.byTarget == nil {
+		t.byTarget = make(map[string]*ProbeTiming)
+	}
+	p := t.byTarget[typ+" "+target]
+	if p == nil {
+		p = &ProbeTiming{Type: typ, Target: target}
+		t.byTarget[typ+" "+target] = p
+	}
+	p.Requests++
+	p.TotalMs += d.Milliseconds()
+	p.SlowestMs = max(p.SlowestMs, d.Milliseconds())
+	if failed {
+		p.Errors++
+	}
+}
+
+// slowest returns up to n targets, most total time first.
+func (t *probeTimings) slowest(n int) []ProbeTiming {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	var all []ProbeTiming
+	for _, p := range t.byTarget {
+		all = append(all, *p)
+	}
+	sort.Slice(all, func(i, j int) bool {
+		if all[i].TotalMs != all[j].TotalMs {
+			return all[i].TotalMs > all[j].TotalMs
+		}
+		return all[i].Target < all[j].Target
+	})
+	return all[:min(len(all), n)]
+}
+
+type probeTimingsKey struct{}
+
+// withProbeTimings makes HTTP requests sent with ctx report to t.
+func withProbeTimings(ctx context.Context, t *probeTimings) context.Context {
+	return context.WithValue(ctx, probeTimingsKey{}, t)
+}
+
+func probeTimingsFrom(ctx context.Context) *probeTimings {
+	t, _ := ctx.Value(probeTimingsKey{}).(*probeTimings)
+	return t
+}
+
+// timingTransport times requests whose context carries probeTimings. It
+// sits under the rate limiter, so waiting for budget isn't blamed on the
+// host.
+type timingTransport struct {
+	base http.RoundTripper
+}
+
+func (t *timingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	timings := probeTimingsFrom(req.Context())
+	if timings == nil || strings.EqualFold(req.Header.Get("Upgrade"), "websocket") {
+		return t.base.RoundTrip(req)
+	}
+	start := time.Now()
+	resp, err := t.base.RoundTrip(req)
+	timings.record("http", strings.ToLower(req.URL.Host), time.Since(start), err != nil || resp.StatusCode >= 500)
+	return resp, err
+}
+
+// installProbeTiming puts the timer in front of the default transport.
+// Call before installRateLimiter.
+func installProbeTiming() {
+	http.DefaultTransport = &timingTransport{base: http.DefaultTransport}
+}
+
+// timeChecks gives relays a timer for their queries. It copies the slice:
+// callers such as follows and org share one set of connections across
+// identities checked in parallel.
+func timeChecks(relays []checkRelay, t *probeTimings) []checkRelay {
+	timed := make([]checkRelay, len(relays))
+	for i, cr := range relays {
+		cr.timings = t
+		timed[i] = cr
+	}
+	return timed
+}
+
+// formatMs shows a duration in milliseconds as e.g. "850ms" or "2.3s".
+func formatMs(ms int64) string {
+	if ms < 1000 {
+		return fmt.Sprintf("%dms", ms)
+	}
+	return fmt.Sprintf("%.1fs", float64(ms)/1000)
+}
+
+// slowCheckMs is how long a check has to take before its line says so.
+const slowCheckMs = 1000
+
+// printBottlenecks shows where a run's time went: the total and the
+// slowest few hosts and relays, and any phases cut off for time.
+func printBottlenecks(r CheckResult) {
+	if r.DurationMs == 0 {
+		return
+	}
+	var slow []string
+	for _, p := range r.Probes[:min(len(r.Probes), 3)] {
+		if p.TotalMs < 500 {
+			break
+		}
+		s := fmt.Sprintf("%s %s", p.Target, formatMs(p.TotalMs))
+		if p.Requests > 1 {
+			s += fmt.Sprintf(" over %d requests", p.Requests)
+		}
+		if p.Errors > 0 {
+			s += fmt.Sprintf(", %d failed", p.Errors)
+		}
+		slow = append(slow, s)
+	}
+	line := fmt.Sprintf("\n  🐢 Took %s", formatMs(r.DurationMs))
+	if len(slow) > 0 {
+		line += "; slowest: " + strings.Join(slow, "; ")
+	}
+	fmt.Println(line)
+	if len(r.CutOff) > 0 {
+		fmt.Printf("  ⌛ Cut off at their share of the time budget: %s (those checks may be incomplete)\n", strings.Join(r.CutOff, ", "))
+	}
+}
@@ -0,0 +1,174 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// tlsExpiryWarnDays is how close to expiry a certificate gets a warning.
+// Mobile clients tend to drop a relay silently once its cert lapses.
+const tlsExpiryWarnDays = 14
+
+// TLSInfo is what a TLS handshake revealed about a host's certificate,
+// checked separately from the connection itself so a bad certificate is
+// reported as such rather than as an unreachable host.
+type TLSInfo struct {
+	Host             string    `json:"host"`
+	Expiry           time.Time `json:"expiry,omitempty"`
+	Issuer           string    `json:"issuer,omitempty"`
+	HostnameMismatch bool      `json:"hostname_mismatch,omitempty"`
+	IncompleteChain  bool      `json:"incomplete_chain,omitempty"` // intermediate certificate not sent
+	Untrusted        bool      `json:"untrusted,omitempty"`        // chain doesn't verify for another reason
+	Error            string    `json:"error,omitempty"`            // handshake failed
+}
+
+// tlsRoots is the trust store certificates are verified against; nil
+// means the system roots. Tests swap in their own.
+var tlsRoots *x509.CertPool
+
+// probeTLS completes a TLS handshake with host:port without verifying,
+// then checks the certificate by hand: expiry, whether it names host, and
+// whether the chain the server sent verifies on its own. Servers that
+// omit their intermediate work in browsers that fetch it, but not in most
+// nostr clients.
+func probeTLS(ctx context.Context, host, port string) *TLSInfo {
+	info := &TLSInfo{Host: host}
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+	raw, err := dialOutbound(ctx, net.JoinHostPort(host, port), 5*time.Second)
+	if err != nil {
+		info.Error = describeHostError(err)
+		return info
+	}
+	conn := tls.Client(raw, &tls.Config{ServerName: host, InsecureSkipVerify: true})
+	defer conn.Close()
+	if err := conn.HandshakeContext(ctx); err != nil {
+		info.Error = describeHostError(err)
+		return info
+	}
+
+	certs := conn.ConnectionState().PeerCertificates
+	if len(certs) == 0 {
+		info.Error = "no certificate"
+		return info
+	}
+	leaf := certs[0]
+	info.Expiry = leaf.NotAfter
+	info.Issuer = leaf.Issuer.CommonName
+	info.HostnameMismatch = leaf.VerifyHostname(host) != nil
+
+	intermediates := x509.NewCertPool()
+	for _, c := range certs[1:] {
+		intermediates.AddCert(c)
+	}
+	_, err = leaf.Verify(x509.VerifyOptions{Roots: tlsRoots, Intermediates: intermediates, CurrentTime: time.Now()})
+	var authErr x509.UnknownAuthorityError
+	switch {
+	case err == nil:
+	case errors.As(err, &authErr) && missingIntermediate(certs):
+		info.IncompleteChain = true
+	default:
+		var invalid x509.CertificateInvalidError
+		if !errors.As(err, &invalid) || invalid.Reason != x509.Expired {
+			info.Untrusted = true
+		}
+	}
+	return info
+}
+
+// missingIntermediate reports whether the last certificate the server sent
+// was issued by a certificate it didn't send, and says where to get it
+// (AIA): the sign of a server configured without its intermediate.
+func missingIntermediate(certs []*x509.Certificate) bool {
+	last := certs[len(certs)-1]
+	if bytes.Equal(last.RawIssuer, last.RawSubject) {
+		return false // self-signed
+	}
+	return len(last.IssuingCertificateURL) > 0
+}
+
+// problems lists what's wrong with the certificate as of now, for check
+// details. Expiry within tlsExpiryWarnDays counts as a problem.
+func (t *TLSInfo) problems(now time.Time) []string {
+	if t == nil {
+		return nil
+	}
+	if t.Error != "" {
+		return []string{t.Error}
+	}
+	var problems []string
+	if !t.Expiry.IsZero() {
+		switch days := int(t.Expiry.Sub(now).Hours() / 24); {
+		case !t.Expiry.After(now):
+			problems = append(problems, "TLS cert expired "+t.Expiry.Format("2006-01-02"))
+		case days < tlsExpiryWarnDays:
+			problems = append(problems, fmt.Sprintf("TLS cert expires in %d day(s)", days))
+		}
+	}
+	if t.HostnameMismatch {
+		problems = append(problems, "TLS cert doesn't match "+t.Host)
+	}
+	if t.IncompleteChain {
+		problems = append(problems, "incomplete TLS chain (intermediate certificate missing)")
+	}
+	if t.Untrusted {
+		problems = append(problems, "TLS cert not trusted")
+	}
+	return problems
+}
+
+// tlsHostPort returns the host and port to check TLS for, for wss:// and
+// https:// URLs only.
+func tlsHostPort(rawURL string) (host, port string, ok bool) {
+	u, err := url.Parse(rawURL)
+	if err != nil || (u.Scheme != "wss" && u.Scheme != "https") || u.Hostname() == "" {
+		return "", "", false
+	}
+	port = u.Port()
+	if port == "" {
+		port = "443"
+	}
+	return u.Hostname(), port, true
+}
+
+// checkRelayTLS reports certificate problems across the relays that were
+// scored. It's not scored itself: an unreachable relay already costs
+// relay_quality, this says why, or warns before it happens.
+func checkRelayTLS(result *CheckResult, scores []RelayScore, now time.Time) {
+	var problems []string
+	var checked int
+	var earliest *TLSInfo
+	var earliestURL string
+	for _, rs := range scores {
+		if rs.TLS == nil {
+			continue
+		}
+		checked++
+		if p := rs.TLS.problems(now); len(p) > 0 {
+			problems = append(problems, fmt.Sprintf("%s: %s", rs.URL, strings.Join(p, ", ")))
+		}
+		if !rs.TLS.Expiry.IsZero() && (earliest == nil || rs.TLS.Expiry.Before(earliest.Expiry)) {
+			earliest, earliestURL = rs.TLS, rs.URL
+		}
+	}
+	switch {
+	case checked == 0:
+		return
+	case len(problems) > 0:
+		result.addCheck("relay_tls", "warn", strings.Join(problems, "; "))
+	default:
+		detail := fmt.Sprintf("%d relay cert(s) valid", checked)
+		if earliest != nil {
+			detail += fmt.Sprintf(", next expiry %s (%s)", earliest.Expiry.Format("2006-01-02"), earliestURL)
+		}
+		result.addCheck("relay_tls", "pass", detail)
+	}
+}
@@ -0,0 +1,333 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"time"
+
+	"fiatjaf.com/nostr"
+)
+
+// releaseAPI is where nihao update looks for the latest release.
+var releaseAPI = "https://api.github.com/repos/dergigi/nihao/releases/latest"
+
+// releaseSigner is the maintainer's pubkey (dergigi). Every release ships
+// checksums.txt.nostr.json, a NIP-94 file metadata event (kind 1063) this
+// key signed over checksums.txt, so a compromised release page alone
+// can't push a binary. scripts/release.sh builds the nihao_<os>_<arch>
+// binaries, writes checksums.txt, and signs it with this key; a release
+// published without those assets is refused.
+var releaseSigner = "6e468422dfb74a5738702a8823b9b28168abab8655faacb6853cd0ee15deee93"
+
+// maxReleaseAssetSize caps downloads during an update.
+const maxReleaseAssetSize = 100 << 20
+
+// UpdateResult is the JSON output of `nihao update`.
+type UpdateResult struct {
+	Current   string `json:"current"`
+	Latest    string `json:"latest"`
+	Available bool   `json:"update_available"`
+	Asset     string `json:"asset,omitempty"`
+	SHA256    string `json:"sha256,omitempty"`
+	Installed string `json:"installed,omitempty"` // path of the replaced binary
+}
+
+type githubRelease struct {
+	TagName string `json:"tag_name"`
+	Assets  []struct {
+		Name string `json:"name"`
+		URL  string `json:"browser_download_url"`
+	} `json:"assets"`
+}
+
+func (r githubRelease) assetURL(name string) string {
+	for _, a := range r.Assets {
+		if a.Name == name {
+			return a.URL
+		}
+	}
+	return ""
+}
+
+// releaseAssetName is the binary built for this platform.
+func releaseAssetName() string {
+	name := fmt.Sprintf("nihao_%s_%s", runtime.GOOS, runtime.GOARCH)
+	if runtime.GOOS == "windows" {
+		name += ".exe"
+	}
+	return name
+}
+
+// compareVersions compares two vMAJOR.MINOR.PATCH versions numerically.
+// ok is false if either isn't a release version (e.g. a dev build).
+func compareVersions(a, b string) (cmp int, ok bool) {
+	parse := func(v string) ([3]int, bool) {
+		var n [3]int
+		v, _, _ = strings.Cut(strings.TrimPrefix(v, "v"), "-")
+		parts := strings.Split(v, ".")
+		if len(parts) != 3 {
+			return n, false
+		}
+		for i, p := range parts {
+			x, err := strconv.Atoi(p)
+			if err != nil {
+				return n, false
+			}
+			n[i] = x
+		}
+		return n, true
+	}
+	va, okA := parse(a)
+	vb, okB := parse(b)
+	if !okA || !okB {
+		return 0, false
+	}
+	for i := range va {
+		if va[i] != vb[i] {
+			if va[i] < vb[i] {
+				return -1, true
+			}
+			return 1, true
+		}
+	}
+	return 0, true
+}
+
+func fetchURL(ctx context.Context, url string, limit int64) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != 200 {
+		return nil, fmt.Errorf("%s: HTTP %d", url, resp.StatusCode)
+	}
+	data, err := io.ReadAll(io.LimitReader(resp.Body, limit+1))
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(data)) > limit {
+		return nil, fmt.Errorf("%s: larger than %s", url, formatSize(limit))
+	}
+	return data, nil
+}
+
+func fetchLatestRelease(ctx context.Context) (githubRelease, error) {
+	var rel githubRelease
+	data, err := fetchURL(ctx, releaseAPI, 1<<20)
+	if err != nil {
+		return rel, err
+	}
+	if err := json.Unmarshal(data, &rel); err != nil {
+		return rel, fmt.Errorf("invalid release info: %w", err)
+	}
+	if rel.TagName == "" {
+		return rel, fmt.Errorf("release has no tag")
+	}
+	return rel, nil
+}
+
+// verifyChecksumsEvent checks that sigJSON is a kind 1063 event, validly
+// signed by signer, whose x tag is the SHA-256 of checksums and whose
+// version tag is the release's tag. Without the version, an older signed
+// checksums file and its binaries could be served under a newer tag.
+func verifyChecksumsEvent(checksums, sigJSON []byte, signer, version string) error {
+	var evt nostr.Event
+	if err := json.Unmarshal(sigJSON, &evt); err != nil {
+		return fmt.Errorf("invalid checksum signature event: %w", err)
+	}
+	if evt.Kind != 1063 {
+		return fmt.Errorf("checksum signature is kind %d, not 1063", evt.Kind)
+	}
+	if evt.PubKey.Hex() != signer {
+		return fmt.Errorf("checksums signed by %s, not the release key", evt.PubKey.Hex())
+	}
+	if !evt.CheckID() || !evt.VerifySignature() {
+		return fmt.Errorf("checksum signature doesn't verify")
+	}
+	sum := sha256.Sum256(checksums)
+	if tag := evt.Tags.Find("x"); tag == nil || tag[1] != hex.EncodeToString(sum[:]) {
+		return fmt.Errorf("signed hash doesn't match checksums.txt")
+	}
+	if tag := evt.Tags.Find("version"); tag == nil || tag[1] != version {
+		return fmt.Errorf("checksums are signed for another release, not %s", version)
+	}
+	return nil
+}
+
+// checksumFor finds name in a sha256sum-style checksums file.
+func checksumFor(checksums []byte, name string) (string, bool) {
+	scanner := bufio.NewScanner(bytes.NewReader(checksums))
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 2 && strings.TrimPrefix(fields[1], "*") == name {
+			return strings.ToLower(fields[0]), true
+		}
+	}
+	return "", false
+}
+
+// downloadVerifiedAsset downloads the named release asset after verifying
+// the signed checksums, and checks it against them.
+func downloadVerifiedAsset(ctx context.Context, rel githubRelease, name string) ([]byte, string, error) {
+	checksumsURL, sigURL, assetURL := rel.assetURL("checksums.txt"), rel.assetURL("checksums.txt.nostr.json"), rel.assetURL(name)
+	switch {
+	case assetURL == "":
+		return nil, "", fmt.Errorf("release %s has no build for %s/%s", rel.TagName, runtime.GOOS, runtime.GOARCH)
+	case checksumsURL == "" || sigURL == "":
+		return nil, "", fmt.Errorf("release %s has no signed checksums; refusing to install it", rel.TagName)
+	}
+	checksums, err := fetchURL(ctx, checksumsURL, 1<<20)
+	if err != nil {
+		return nil, "", err
+	}
+	sig, err := fetchURL(ctx, sigURL, 1<<20)
+	if err != nil {
+		return nil, "", err
+	}
+	if err := verifyChecksumsEvent(checksums, sig, releaseSigner, rel.TagName); err != nil {
+		return nil, "", err
+	}
+	want, ok := checksumFor(checksums, name)
+	if !ok {
+		return nil, "", fmt.Errorf("checksums.txt has no entry for %s", name)
+	}
+	data, err := fetchURL(ctx, assetURL, maxReleaseAssetSize)
+	if err != nil {
+		return nil, "", err
+	}
+	sum := sha256.Sum256(data)
+	if got := hex.EncodeToString(sum[:]); got != want {
+		return nil, "", fmt.Errorf("%s checksum mismatch: got %s, want %s", name, got, want)
+	}
+	return data, want, nil
+}
+
+// replaceBinary swaps the binary at path for data. The new file is written
+// next to it and renamed over it, so an interrupted update leaves the old
+// binary in place. Windows can't overwrite a running binary, so there the
+// old one is moved aside first.
+func replaceBinary(path string, data []byte) error {
+	info, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".nihao-update-*")
+	if err != nil {
+		return fmt.Errorf("can't write next to %s: %w", path, err)
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(tmp.Name(), info.Mode().Perm()|0o111); err != nil {
+		return err
+	}
+	if runtime.GOOS == "windows" {
+		old := path + ".old"
+		os.Remove(old)
+		if err := os.Rename(path, old); err != nil {
+			return err
+		}
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// runUpdate checks for a newer release and, unless --check-only, installs
+// it over the running binary once its signed checksum verifies.
+func runUpdate(args []string) {
+	checkOnly, force, jsonOutput, quiet := false, false, false, false
+	for _, a := range args {
+		switch a {
+		case "--check-only":
+			checkOnly = true
+		case "--force":
+			force = true
+		case "--json":
+			jsonOutput = true
+		case "--quiet", "-q":
+			quiet = true
+		default:
+			fatal("unknown flag: %s (see nihao help)", a)
+		}
+	}
+	logln := func(a ...any) {
+		if !jsonOutput && !quiet {
+			fmt.Println(a...)
+		}
+	}
+	printJSON := func(r UpdateResult) {
+		if jsonOutput {
+			out, _ := json.MarshalIndent(r, "", "  ")
+			fmt.Println(string(out))
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
+	defer cancel()
+	rel, err := fetchLatestRelease(ctx)
+	if err != nil {
+		fatal("couldn't check for updates: %s", err)
+	}
+	result := UpdateResult{Current: version, Latest: rel.TagName}
+	cmp, comparable := compareVersions(version, rel.TagName)
+	result.Available = comparable && cmp < 0
+	logln(fmt.Sprintf("nihao update 🔄 %s → latest %s", version, rel.TagName))
+
+	switch {
+	case !comparable && !force:
+		logln(fmt.Sprintf("   This is a development build; run with --force to replace it with %s", rel.TagName))
+		printJSON(result)
+		return
+	case !result.Available && !force:
+		logln("✅ Already up to date")
+		printJSON(result)
+		return
+	case checkOnly:
+		logln(fmt.Sprintf("⬆️  %s is available (run nihao update to install it)", rel.TagName))
+		printJSON(result)
+		return
+	}
+
+	result.Asset = releaseAssetName()
+	logln(fmt.Sprintf("📥 Downloading %s and verifying its signed checksum...", result.Asset))
+	data, sum, err := downloadVerifiedAsset(ctx, rel, result.Asset)
+	if err != nil {
+		fatal("%s", err)
+	}
+	result.SHA256 = sum
+
+	exe, err := os.Executable()
+	if err == nil {
+		exe, err = filepath.EvalSymlinks(exe)
+	}
+	if err != nil {
+		fatal("can't locate the running binary: %s", err)
+	}
+	if err := replaceBinary(exe, data); err != nil {
+		fatal("couldn't replace %s: %s", exe, err)
+	}
+	result.Installed = exe
+	logln(fmt.Sprintf("✅ Installed %s at %s", rel.TagName, exe))
+	printJSON(result)
+}
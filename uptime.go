@@ -0,0 +1,185 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"fiatjaf.com/nostr"
+)
+
+// uptimeWindow is how far back NIP-66 monitor history is read.
+const uptimeWindow = 30 * 24 * time.Hour
+
+// uptimeWarnPercent is the uptime below which a relay is called unreliable.
+const uptimeWarnPercent = 95.0
+
+// minUptimeChecks is how many expected monitor checks a relay needs before
+// an uptime percentage means anything.
+const minUptimeChecks = 24
+
+// RelayUptime is a relay's uptime as seen by NIP-66 monitors: each kind
+// 30166 a monitor publishes records that it found the relay online then,
+// so checks it should have made but didn't publish count as downtime.
+type RelayUptime struct {
+	URL      string  `json:"url"`
+	Percent  float64 `json:"percent"`
+	Online   int     `json:"online"`   // monitor checks that found it online
+	Expected int     `json:"expected"` // checks the monitors' frequencies imply
+	Monitors int     `json:"monitors"`
+}
+
+// fetchRelayUptime reads the last uptimeWindow of kind 30166 events for
+// urls from the NIP-66 relays, plus the monitors' announcements (kind
+// 10166) for how often they check.
+func fetchRelayUptime(ctx context.Context, urls []string, now time.Time) []RelayUptime {
+	if len(urls) == 0 {
+		return nil
+	}
+	relays := connectCheckRelays(ctx, nip66Relays)
+	defer func() {
+		for _, cr := range relays {
+			cr.relay.Close()
+		}
+	}()
+
+	// Monitors aren't consistent about trailing slashes in "d" tags
+	var dTags []string
+	for _, u := range urls {
+		dTags = append(dTags, u, u+"/")
+	}
+	observations := fetchAllFrom(ctx, relays, nostr.Filter{
+		Kinds: []nostr.Kind{30166},
+		Tags:  nostr.TagMap{"d": dTags},
+		Since: nostr.Timestamp(now.Add(-uptimeWindow).Unix()),
+	})
+
+	monitorSet := make(map[nostr.PubKey]bool)
+	for _, evt := range observations {
+		monitorSet[evt.PubKey] = true
+	}
+	if len(monitorSet) == 0 {
+		return nil
+	}
+	var monitors []nostr.PubKey
+	for pk := range monitorSet {
+		monitors = append(monitors, pk)
+	}
+	announcements := fetchAllFrom(ctx, relays, nostr.Filter{Kinds: []nostr.Kind{10166}, Authors: monitors})
+	return computeRelayUptime(urls, observations, monitorFrequencies(announcements), now)
+}
+
+// monitorFrequencies reads each monitor's check interval from the
+// "frequency" tag (seconds) of its latest announcement.
+func monitorFrequencies(announcements []nostr.Event) map[nostr.PubKey]time.Duration {
+	latest := make(map[nostr.PubKey]nostr.Timestamp)
+	freqs := make(map[nostr.PubKey]time.Duration)
+	for _, evt := range announcements {
+		if evt.CreatedAt < latest[evt.PubKey] {
+			continue
+		}
+		tag := evt.Tags.Find("frequency")
+		if tag == nil {
+			continue
+		}
+		secs, err := strconv.Atoi(tag[1])
+		if err != nil || secs <= 0 {
+			continue
+		}
+		latest[evt.PubKey] = evt.CreatedAt
+		freqs[evt.PubKey] = time.Duration(secs) * time.Second
+	}
+	return freqs
+}
+
+// computeRelayUptime turns monitor observations into an uptime per relay.
+// For each monitor and relay, the window starts at the monitor's first
+// observation of the relay (so a relay it only just started watching
+// doesn't count as down), and observations are counted once per check
+// interval. Monitors whose frequency is
+// unknown, or whose history the relays didn't keep (never more than one
+// observation of a relay), are skipped. Relays without enough expected
+// checks are left out.
+func computeRelayUptime(urls []string, observations []nostr.Event, freqs map[nostr.PubKey]time.Duration, now time.Time) []RelayUptime {
+	type key struct {
+		relay   string
+		monitor nostr.PubKey
+	}
+	buckets := make(map[key]map[int64]bool)
+	firstSeen := make(map[key]time.Time)
+	hasHistory := make(map[nostr.PubKey]bool)
+	windowStart := now.Add(-uptimeWindow)
+	for _, evt := range observations {
+		freq, ok := freqs[evt.PubKey]
+		at := evt.CreatedAt.Time()
+		if !ok || at.Before(windowStart) || at.After(now) {
+			continue
+		}
+		relayURL := normalizeRelayURL(evt.Tags.GetD())
+		if relayURL == "" {
+			continue
+		}
+		k := key{relayURL, evt.PubKey}
+		if buckets[k] == nil {
+			buckets[k] = make(map[int64]bool)
+		}
+		buckets[k][int64(at.Sub(windowStart)/freq)] = true
+		if len(buckets[k]) > 1 {
+			hasHistory[evt.PubKey] = true
+		}
+		if first, ok := firstSeen[k]; !ok || at.Before(first) {
+			firstSeen[k] = at
+		}
+	}
+
+	var uptimes []RelayUptime
+	for _, u := range urls {
+		relayURL := normalizeRelayURL(u)
+		up := RelayUptime{URL: u}
+		for monitor, freq := range freqs {
+			k := key{relayURL, monitor}
+			seen := buckets[k]
+			if len(seen) == 0 || !hasHistory[monitor] {
+				continue
+			}
+			up.Monitors++
+			up.Online += len(seen)
+			up.Expected += max(int(now.Sub(firstSeen[k])/freq)+1, len(seen))
+		}
+		if up.Expected < minUptimeChecks {
+			continue
+		}
+		up.Percent = float64(up.Online) * 100 / float64(up.Expected)
+		uptimes = append(uptimes, up)
+	}
+	sort.Slice(uptimes, func(i, j int) bool { return uptimes[i].Percent < uptimes[j].Percent })
+	return uptimes
+}
+
+// checkRelayUptime reports how reliable the user's relays have been over
+// the last 30 days according to NIP-66 monitors, since being reachable
+// right now says little about tomorrow. Only reported if monitors have
+// history for at least one relay, and not scored.
+func checkRelayUptime(ctx context.Context, result *CheckResult, urls []string) {
+	uptimes := fetchRelayUptime(ctx, urls, time.Now())
+	if len(uptimes) == 0 {
+		return
+	}
+	result.RelayUptime = uptimes
+
+	var unreliable []string
+	for _, up := range uptimes {
+		if up.Percent < uptimeWarnPercent {
+			unreliable = append(unreliable, fmt.Sprintf("%s %.1f%%", up.URL, up.Percent))
+		}
+	}
+	if len(unreliable) > 0 {
+		result.addCheck("relay_uptime", "warn", fmt.Sprintf("below %.0f%% uptime over 30 days: %s", uptimeWarnPercent, strings.Join(unreliable, ", ")))
+		return
+	}
+	result.addCheck("relay_uptime", "pass", fmt.Sprintf("%d/%d relay(s) with NIP-66 history, lowest %.1f%% over 30 days (%s)",
+		len(uptimes), len(urls), uptimes[0].Percent, uptimes[0].URL))
+}
@@ -0,0 +1,272 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"strings"
+	"time"
+
+	"fiatjaf.com/nostr"
+	"fiatjaf.com/nostr/nip19"
+)
+
+// knownKinds labels the event kinds verify is likely to be shown: what
+// setup and backup write, and the common kinds around them.
+var knownKinds = map[int]string{
+	0:     "profile",
+	1:     "short note",
+	3:     "follow list",
+	4:     "encrypted DM (NIP-04)",
+	5:     "deletion request",
+	6:     "repost",
+	7:     "reaction",
+	13:    "seal",
+	14:    "chat message",
+	16:    "generic repost",
+	1059:  "gift wrap",
+	1063:  "file metadata",
+	1984:  "report",
+	9734:  "zap request",
+	9735:  "zap receipt",
+	9321:  "nutzap",
+	10000: "mute list",
+	10001: "pinned notes",
+	10002: "relay list",
+	10003: "bookmarks",
+	10007: "search relays",
+	10015: "interests",
+	10019: "nutzap info",
+	10030: "emoji list",
+	10050: "DM relay list",
+	10063: "Blossom server list",
+	10096: "NIP-96 server list",
+	17375: "wallet",
+	22242: "relay auth",
+	24133: "Nostr Connect",
+	24242: "Blossom auth",
+	27235: "HTTP auth",
+	30000: "follow set",
+	30002: "relay set",
+	30023: "long-form article",
+	30030: "emoji set",
+	30078: "app data",
+	37375: "wallet (old)",
+}
+
+// kindName describes a kind: its label if known, else its NIP-01 class.
+func kindName(kind int) string {
+	if label, ok := knownKinds[kind]; ok {
+		return label
+	}
+	switch {
+	case kind >= 10000 && kind < 20000:
+		return "replaceable event"
+	case kind >= 20000 && kind < 30000:
+		return "ephemeral event"
+	case kind >= 30000 && kind < 40000:
+		return "addressable event"
+	}
+	return "regular event"
+}
+
+// VerifiedEvent is verify's verdict on one event.
+type VerifiedEvent struct {
+	ID          string       `json:"id"`
+	Kind        int          `json:"kind"`
+	KindLabel   string       `json:"kind_label"`
+	Author      string       `json:"author"` // npub
+	CreatedAt   string       `json:"created_at"`
+	IDValid     bool         `json:"id_valid"`
+	SigValid    bool         `json:"sig_valid"`
+	StoredOn    []string     `json:"stored_on,omitempty"`
+	MissingFrom []string     `json:"missing_from,omitempty"`
+	Event       *nostr.Event `json:"event"`
+}
+
+// VerifyResult is the output of `nihao verify`.
+type VerifyResult struct {
+	Events []VerifiedEvent `json:"events"`
+	Valid  bool            `json:"valid"` // every event's ID and signature check out
+}
+
+// parseEventsJSON reads events from a single event object, an array of
+// events, or a nihao backup.
+func parseEventsJSON(data []byte) ([]nostr.Event, error) {
+	data = bytes.TrimSpace(data)
+	if len(data) == 0 {
+		return nil, fmt.Errorf("no input")
+	}
+	if data[0] == '[' {
+		var events []nostr.Event
+		if err := json.Unmarshal(data, &events); err != nil {
+			return nil, fmt.Errorf("invalid event array: %w", err)
+		}
+		return events, nil
+	}
+	var backup struct {
+		Events []BackupEvent `json:"events"`
+	}
+	if json.Unmarshal(data, &backup) == nil && len(backup.Events) > 0 {
+		var events []nostr.Event
+		for _, be := range backup.Events {
+			if be.Event != nil {
+				events = append(events, *be.Event)
+			}
+		}
+		return events, nil
+	}
+	var evt nostr.Event
+	if err := json.Unmarshal(data, &evt); err != nil {
+		return nil, fmt.Errorf("invalid event: %w", err)
+	}
+	return []nostr.Event{evt}, nil
+}
+
+// verifyEvent recomputes an event's ID and checks its signature.
+func verifyEvent(evt nostr.Event) VerifiedEvent {
+	return VerifiedEvent{
+		ID:        evt.ID.Hex(),
+		Kind:      int(evt.Kind),
+		KindLabel: kindName(int(evt.Kind)),
+		Author:    nip19.EncodeNpub(evt.PubKey),
+		CreatedAt: evt.CreatedAt.Time().UTC().Format(time.RFC3339),
+		IDValid:   evt.CheckID(),
+		SigValid:  evt.VerifySignature(),
+		Event:     &evt,
+	}
+}
+
+func runVerify(args []string) {
+	var relays []string
+	var jsonOutput, quiet bool
+	var input string
+	for i := 0; i < len(args); i++ {
+		switch a := args[i]; {
+		case a == "--relays" && i+1 < len(args):
+			i++
+			relays = strings.Split(args[i], ",")
+		case a == "--json":
+			jsonOutput = true
+		case a == "--quiet" || a == "-q":
+			quiet = true
+		case a == "-":
+			input = a
+		case strings.HasPrefix(a, "-"):
+			fatal("unknown flag: %s (see nihao help)", a)
+		default:
+			if input != "" {
+				fatal("usage: nihao verify [<event.json>|-] [--relays <r1,r2,...>] [--json]")
+			}
+			input = a
+		}
+	}
+
+	var data []byte
+	var err error
+	if input == "" || input == "-" {
+		input = "stdin"
+		data, err = io.ReadAll(os.Stdin)
+	} else {
+		data, err = os.ReadFile(input)
+	}
+	if err != nil {
+		fatal("%s", err)
+	}
+	events, err := parseEventsJSON(data)
+	if err != nil {
+		fatal("%s: %s", input, err)
+	}
+
+	result := VerifyResult{Events: []VerifiedEvent{}, Valid: true}
+	for _, evt := range events {
+		v := verifyEvent(evt)
+		result.Events = append(result.Events, v)
+		result.Valid = result.Valid && v.IDValid && v.SigValid
+	}
+
+	if len(relays) > 0 {
+		ctx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
+		checkRelays := connectCheckRelays(ctx, relays)
+		for i := range result.Events {
+			v := &result.Events[i]
+			if !v.IDValid {
+				continue
+			}
+			v.StoredOn = relaysHolding(ctx, checkRelays, nostr.Filter{IDs: []nostr.ID{v.Event.ID}})
+			for _, url := range relays {
+				if !slices.Contains(v.StoredOn, url) {
+					v.MissingFrom = append(v.MissingFrom, url)
+				}
+			}
+		}
+		for _, cr := range checkRelays {
+			cr.relay.Close()
+		}
+		cancel()
+	}
+
+	if jsonOutput {
+		out, _ := json.MarshalIndent(result, "", "  ")
+		fmt.Println(string(out))
+	} else if !quiet {
+		fmt.Printf("nihao verify 🔏 %s\n\n", input)
+		for _, v := range result.Events {
+			printVerifiedEvent(v, len(relays) > 0)
+		}
+	}
+	if !result.Valid {
+		os.Exit(1)
+	}
+}
+
+// printVerifiedEvent shows one event for a human: the verdict, then the
+// kind, author, tags, and content.
+func printVerifiedEvent(v VerifiedEvent, relaysChecked bool) {
+	mark := func(ok bool) string {
+		if ok {
+			return "✓"
+		}
+		return "✗"
+	}
+	icon := "✅"
+	if !v.IDValid || !v.SigValid {
+		icon = "❌"
+	}
+	fmt.Printf("%s %s (kind %d)\n", icon, v.KindLabel, v.Kind)
+	fmt.Printf("   id:        %s %s\n", v.ID, mark(v.IDValid))
+	if !v.IDValid {
+		fmt.Printf("              computed %s\n", v.Event.GetID().Hex())
+	}
+	fmt.Printf("   signature: %s\n", mark(v.SigValid))
+	fmt.Printf("   author:    %s\n", v.Author)
+	fmt.Printf("   created:   %s\n", v.CreatedAt)
+	if len(v.Event.Tags) > 0 {
+		fmt.Printf("   tags (%d):\n", len(v.Event.Tags))
+		for _, tag := range v.Event.Tags {
+			t, _ := json.Marshal(tag)
+			fmt.Printf("     %s\n", t)
+		}
+	}
+	if v.Event.Content != "" {
+		fmt.Println("   content:")
+		for _, line := range strings.Split(v.Event.Content, "\n") {
+			fmt.Printf("     %s\n", line)
+		}
+	}
+	if relaysChecked && v.IDValid {
+		total := len(v.StoredOn) + len(v.MissingFrom)
+		fmt.Printf("   relays:    stored on %d/%d\n", len(v.StoredOn), total)
+		for _, url := range v.StoredOn {
+			fmt.Printf("     ✓ %s\n", url)
+		}
+		for _, url := range v.MissingFrom {
+			fmt.Printf("     ✗ %s\n", url)
+		}
+	}
+	fmt.Println()
+}
@@ -7,7 +7,6 @@ import (
 	"fmt"
 
 	"fiatjaf.com/nostr"
-	"fiatjaf.com/nostr/keyer"
 	"github.com/btcsuite/btcd/btcec/v2"
 )
 
@@ -20,8 +19,7 @@ type WalletSetupResult struct {
 // setupWallet creates a NIP-60 wallet and publishes kind 17375 + kind 10019.
 // Returns the wallet setup result or an error.
 // The quiet parameter suppresses non-error output to avoid polluting --json.
-func setupWallet(ctx context.Context, sk nostr.SecretKey, relays []string, mintInfos []MintInfo, quiet bool, pool ...*RelayPool) (*WalletSetupResult, error) {
-	kr := keyer.NewPlainKeySigner(sk)
+func setupWallet(ctx context.Context, kr nostr.Keyer, relays []string, mintInfos []MintInfo, quiet bool, pool ...*RelayPool) (*WalletSetupResult, error) {
 
 	// Step 1: Generate a separate P2PK private key for the wallet
 	var walletSkBytes [32]byte
@@ -72,7 +70,7 @@ func setupWallet(ctx context.Context, sk nostr.SecretKey, relays []string, mintI
 		fmt.Println("💰 Publishing wallet (kind 17375)...")
 	}
 	if len(pool) > 0 && pool[0] != nil {
-		pool[0].Publish(walletEvt)
+		pool[0].PublishRouted(walletEvt)
 	} else {
 		publishToRelays(walletEvt, relays, quiet)
 	}
@@ -110,7 +108,7 @@ func setupWallet(ctx context.Context, sk nostr.SecretKey, relays []string, mintI
 		fmt.Println("⚡ Publishing nutzap info (kind 10019)...")
 	}
 	if len(pool) > 0 && pool[0] != nil {
-		pool[0].Publish(nutzapEvt)
+		pool[0].PublishRouted(nutzapEvt)
 	} else {
 		publishToRelays(nutzapEvt, relays, quiet)
 	}
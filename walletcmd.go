@@ -0,0 +1,340 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"fiatjaf.com/nostr"
+	"fiatjaf.com/nostr/nip19"
+	"fiatjaf.com/nostr/nip60"
+)
+
+type walletOpts struct {
+	keys       keySource
+	relays     []string
+	mint       string // send: spend from this mint only
+	output     string // export: file to write
+	decrypt    string // export: file to read back
+	jsonOutput bool
+	quiet      bool
+	args       []string // positional arguments
+}
+
+// WalletReceiveResult is the JSON output of `nihao wallet receive`.
+type WalletReceiveResult struct {
+	Npub      string `json:"npub"`
+	Mint      string `json:"mint"`
+	Amount    uint64 `json:"amount"`  // sats received
+	Balance   uint64 `json:"balance"` // sats in the wallet afterwards
+	Published int    `json:"published"`
+}
+
+// WalletSendResult is the JSON output of `nihao wallet send`.
+type WalletSendResult struct {
+	Npub    string `json:"npub"`
+	Mint    string `json:"mint"`
+	Amount  uint64 `json:"amount"`
+	Token   string `json:"token"`
+	Balance uint64 `json:"balance"` // sats left in the wallet
+}
+
+func parseWalletFlags(args []string) walletOpts {
+	var opts walletOpts
+	for i := 0; i < len(args); i++ {
+		if next, ok := opts.keys.keyFlag(args, i); ok {
+			i = next
+			continue
+		}
+		a := args[i]
+		switch {
+		case a == "--relays" && i+1 < len(args):
+			i++
+			opts.relays = strings.Split(args[i], ",")
+		case a == "--mint" && i+1 < len(args):
+			i++
+			opts.mint = args[i]
+		case (a == "--output" || a == "-o") && i+1 < len(args):
+			i++
+			opts.output = args[i]
+		case a == "--decrypt" && i+1 < len(args):
+			i++
+			opts.decrypt = args[i]
+		case a == "--json":
+			opts.jsonOutput = true
+		case a == "--quiet" || a == "-q":
+			opts.quiet = true
+		case strings.HasPrefix(a, "-"):
+			fatal("unknown flag: %s (see nihao help)", a)
+		default:
+			opts.args = append(opts.args, a)
+		}
+	}
+	return opts
+}
+
+// walletHasMint reports whether mint is one of the wallet's mints, ignoring
+// trailing slashes and other URL spelling differences.
+func walletHasMint(mints []string, mint string) bool {
+	want, err := nostr.NormalizeHTTPURL(mint)
+	if err != nil {
+		return false
+	}
+	return slices.ContainsFunc(mints, func(m string) bool {
+		have, err := nostr.NormalizeHTTPURL(m)
+		return err == nil && have == want
+	})
+}
+
+// openWallet loads the user's NIP-60 wallet (kind 17375 and its 7375
+// tokens) from their relays. Wallet updates are published through the
+// returned pool, which also includes the user's write relays.
+func openWallet(ctx context.Context, kr nostr.Keyer, opts walletOpts) (*nip60.Wallet, *RelayPool) {
+	pk := signerPubkey(ctx, kr)
+	readRelays := opts.relays
+	if len(readRelays) == 0 {
+		readRelays = defaultRelays
+	}
+	pool := NewRelayPool(readRelays, opts.quiet || opts.jsonOutput)
+	if len(pool.CheckRelays()) == 0 {
+		fatal("could not connect to any relay")
+	}
+	if _, relayEvt := fetchKindFrom(ctx, pool.CheckRelays(), pk, 10002); relayEvt != nil {
+		pool.Add(mergeRelayURLs(readRelays, writeRelays(relayEvt))...)
+	}
+
+	// The subscription ends once the wallet is loaded: nip60 handles live
+	// events under the wallet lock and then the token lock, the reverse of a
+	// send, so our own echoed updates would deadlock it
+	loadCtx, stopLoading := context.WithCancel(ctx)
+	defer stopLoading()
+	loader := nostr.NewPool(nostr.PoolOptions{AuthRequiredHandler: kr.SignEvent})
+	w := nip60.LoadWallet(loadCtx, kr, loader, pool.URLs(), nip60.WalletOptions{})
+	select {
+	case <-w.Stable:
+	case <-ctx.Done():
+		fatal("timed out loading wallet from %s", strings.Join(pool.URLs(), ", "))
+	}
+	if w.PrivateKey == nil {
+		fatal("no NIP-60 wallet (kind 17375) found for %s — run nihao setup first", nip19.EncodeNpub(pk))
+	}
+	return w, pool
+}
+
+// publishWalletUpdate sends a wallet event (a token, a history entry, or
+// the deletion of a spent token) to every relay in the wallet's pool and
+// returns how many took it. It isn't routed by relay purpose like setup:
+// a paid relay the user writes to is just where proofs should be kept.
+func publishWalletUpdate(pool *RelayPool, evt nostr.Event) int {
+	accepted := 0
+	for _, r := range pool.Publish(evt) {
+		if r.OK {
+			accepted++
+		}
+	}
+	return accepted
+}
+
+// runWalletReceive redeems a cashu token into the user's NIP-60 wallet:
+// the proofs are swapped at the issuing mint for fresh ones only the
+// wallet knows, then stored as a kind 7375 token with a 7376 history entry.
+func runWalletReceive(args []string) {
+	opts := parseWalletFlags(args)
+	logln := func(a ...any) {
+		if !opts.jsonOutput && !opts.quiet {
+			fmt.Println(a...)
+		}
+	}
+
+	if len(opts.args) != 1 {
+		fatal("usage: nihao wallet receive <cashu-token> (--sec|--stdin|--sec-cmd ...)")
+	}
+	if !opts.keys.isSet() {
+		fatal("wallet receive decrypts and updates your wallet: pass --sec, --stdin, --sec-cmd, or --bunker")
+	}
+	token := strings.TrimPrefix(strings.TrimSpace(opts.args[0]), "cashu:")
+	if !strings.HasPrefix(token, "cashuA") && !strings.HasPrefix(token, "cashuB") {
+		fatal("not a cashu token (expected cashuA... or cashuB...)")
+	}
+	proofs, mint, err := nip60.GetProofsAndMint(token)
+	if err != nil {
+		fatal("invalid cashu token: %s", err)
+	}
+	if len(proofs) == 0 {
+		fatal("cashu token has no proofs")
+	}
+	kr, _, err := opts.keys.signer(context.Background())
+	if err != nil {
+		fatal("%s", err)
+	}
+	npub := nip19.EncodeNpub(signerPubkey(context.Background(), kr))
+	amount := proofs.Amount()
+	logln(fmt.Sprintf("nihao wallet 💰 receive %d sats from %s", amount, mint))
+	logln()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Minute)
+	defer cancel()
+
+	logln("🔍 Loading wallet...")
+	w, pool := openWallet(ctx, kr, opts)
+	defer pool.Close()
+	logln(fmt.Sprintf("   %d sats across %d mint(s)", w.Balance(), len(w.Mints)))
+	logln()
+	if !walletHasMint(w.Mints, mint) {
+		logln(fmt.Sprintf("⚠️  %s isn't one of your wallet's mints; the sats stay there", mint))
+		logln()
+	}
+
+	result := WalletReceiveResult{Npub: npub, Mint: mint, Amount: amount}
+	var unsaved *nip60.Token
+	w.PublishUpdate = func(evt nostr.Event, deleted, received, change *nip60.Token, isHistory bool) {
+		if isHistory {
+			logln("📜 Publishing history entry (kind 7376)...")
+		} else {
+			logln("💰 Publishing token (kind 7375)...")
+		}
+		accepted := publishWalletUpdate(pool, evt)
+		if received != nil {
+			result.Published = accepted
+			if accepted == 0 {
+				unsaved = received
+			}
+		}
+		logln()
+	}
+
+	logln(fmt.Sprintf("🔄 Swapping proofs at %s...", mint))
+	if err := w.Receive(ctx, proofs, mint, nip60.ReceiveOptions{IntoMint: []string{mint}}); err != nil {
+		fatal("receive failed: %s", err)
+	}
+	logln()
+
+	// The old proofs are spent now, so if no relay stored the new ones
+	// they must not be lost with this process
+	if unsaved != nil {
+		fmt.Fprintln(os.Stderr, "error: no relay accepted the new token; it is NOT in your wallet. Save this token and receive it again:")
+		fmt.Fprintln(os.Stderr, nip60.MakeTokenString(unsaved.Proofs, unsaved.Mint))
+		os.Exit(1)
+	}
+
+	result.Balance = w.Balance()
+	if opts.jsonOutput {
+		out, _ := json.MarshalIndent(result, "", "  ")
+		fmt.Println(string(out))
+		return
+	}
+	logln(fmt.Sprintf("✅ Received %d sats — balance: %d sats", amount, result.Balance))
+}
+
+// runWalletSend takes amount sats out of the user's NIP-60 wallet as a
+// cashu token. The stored proofs are swapped at their mint into the sent
+// amount plus change; the change is stored as a new kind 7375 that replaces
+// the spent ones (deleted with kind 5), and a kind 7376 records the spend.
+func runWalletSend(args []string) {
+	opts := parseWalletFlags(args)
+	logln := func(a ...any) {
+		if !opts.jsonOutput && !opts.quiet {
+			fmt.Println(a...)
+		}
+	}
+
+	if len(opts.args) != 1 {
+		fatal("usage: nihao wallet send <amount> [--mint <url>] (--sec|--stdin|--sec-cmd ...)")
+	}
+	amount, err := strconv.ParseUint(opts.args[0], 10, 64)
+	if err != nil || amount == 0 {
+		fatal("amount must be a positive number of sats")
+	}
+	if !opts.keys.isSet() {
+		fatal("wallet send decrypts and updates your wallet: pass --sec, --stdin, --sec-cmd, or --bunker")
+	}
+	kr, _, err := opts.keys.signer(context.Background())
+	if err != nil {
+		fatal("%s", err)
+	}
+	logln(fmt.Sprintf("nihao wallet 💸 send %d sats", amount))
+	logln()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Minute)
+	defer cancel()
+
+	logln("🔍 Loading wallet...")
+	w, pool := openWallet(ctx, kr, opts)
+	defer pool.Close()
+	logln(fmt.Sprintf("   %d sats across %d mint(s)", w.Balance(), len(w.Mints)))
+	logln()
+
+	result, unsaved, err := walletSend(ctx, w, pool, amount, opts.mint, logln)
+	if err != nil {
+		fatal("%s", err)
+	}
+	result.Npub = nip19.EncodeNpub(signerPubkey(ctx, kr))
+
+	// Same as receive: the change only exists in this process if no relay
+	// stored it
+	if unsaved != nil {
+		fmt.Fprintln(os.Stderr, "error: no relay accepted your change; it is NOT in your wallet. Save this token and receive it again:")
+		fmt.Fprintln(os.Stderr, nip60.MakeTokenString(unsaved.Proofs, unsaved.Mint))
+	}
+
+	switch {
+	case opts.jsonOutput:
+		out, _ := json.MarshalIndent(result, "", "  ")
+		fmt.Println(string(out))
+	case opts.quiet:
+		fmt.Println(result.Token)
+	default:
+		fmt.Printf("✅ Sent %d sats from %s — balance: %d sats\n", result.Amount, result.Mint, result.Balance)
+		fmt.Println()
+		fmt.Println(result.Token)
+	}
+	if unsaved != nil {
+		os.Exit(1)
+	}
+}
+
+// walletSend swaps amount sats out of w (from mint only, if set) and
+// publishes the change, the deletion of the spent tokens, and the history
+// entry to every relay in pool. The returned token is the change when no
+// relay stored it, so the caller can hand it to the user.
+func walletSend(ctx context.Context, w *nip60.Wallet, pool *RelayPool, amount uint64, mint string, logln func(...any)) (WalletSendResult, *nip60.Token, error) {
+	if balance := w.Balance(); balance < amount {
+		return WalletSendResult{}, nil, fmt.Errorf("insufficient balance: have %d sats, need %d", balance, amount)
+	}
+
+	var unsaved *nip60.Token
+	w.PublishUpdate = func(evt nostr.Event, deleted, received, change *nip60.Token, isHistory bool) {
+		switch {
+		case isHistory:
+			logln("📜 Publishing history entry (kind 7376)...")
+		case deleted != nil:
+			logln("🗑️  Deleting spent token (kind 5)...")
+		default:
+			logln("💰 Publishing change (kind 7375)...")
+		}
+		accepted := publishWalletUpdate(pool, evt)
+		if change != nil && accepted == 0 {
+			unsaved = change
+		}
+		logln()
+	}
+
+	logln("🔄 Swapping proofs at the mint...")
+	proofs, mint, err := w.SendInternal(ctx, amount, nip60.SendOptions{SpecificSourceMint: mint})
+	if err != nil {
+		return WalletSendResult{}, nil, fmt.Errorf("send failed: %w", err)
+	}
+	logln()
+
+	return WalletSendResult{
+		Mint:    mint,
+		Amount:  proofs.Amount(),
+		Token:   nip60.MakeTokenString(proofs, mint),
+		Balance: w.Balance(),
+	}, unsaved, nil
+}
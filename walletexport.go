@@ -0,0 +1,260 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"os"
+	"time"
+
+	"fiatjaf.com/nostr"
+	"fiatjaf.com/nostr/nip19"
+	"fiatjaf.com/nostr/nip60"
+	"golang.org/x/crypto/chacha20poly1305"
+	"golang.org/x/crypto/scrypt"
+)
+
+// walletExportLogN is the scrypt cost of export files, as in NIP-49's
+// ncryptsec (2^16 rounds).
+const walletExportLogN = 16
+
+// WalletExport is what `nihao wallet export` saves: every unspent token,
+// decrypted and re-encoded as a cashu token any wallet can redeem, and the
+// wallet's P2PK key for proofs locked to it (nutzaps).
+type WalletExport struct {
+	Npub       string          `json:"npub"`
+	ExportedAt string          `json:"exported_at"`
+	PrivKey    string          `json:"privkey,omitempty"` // the wallet's P2PK key, not the nostr key
+	Mints      []string        `json:"mints"`
+	Balance    uint64          `json:"balance"` // sats across all tokens
+	Tokens     []ExportedToken `json:"tokens"`
+}
+
+// ExportedToken is one kind 7375 token's proofs.
+type ExportedToken struct {
+	Event  string `json:"event,omitempty"` // the kind 7375 it came from
+	Mint   string `json:"mint"`
+	Amount uint64 `json:"amount"`
+	Token  string `json:"token"` // cashuB..., for nihao wallet receive
+}
+
+// WalletExportResult is the JSON output of `nihao wallet export`.
+type WalletExportResult struct {
+	Npub    string `json:"npub"`
+	File    string `json:"file"`
+	Balance uint64 `json:"balance"`
+	Tokens  int    `json:"tokens"`
+}
+
+// walletExportFile is the file on disk: the export as JSON, encrypted with
+// XChaCha20-Poly1305 under a key derived from a passphrase with scrypt.
+type walletExportFile struct {
+	Type       string `json:"type"` // "nihao-wallet-export"
+	Version    int    `json:"version"`
+	LogN       int    `json:"log_n"`
+	Salt       string `json:"salt"`  // base64
+	Nonce      string `json:"nonce"` // base64
+	Ciphertext string `json:"ciphertext"`
+}
+
+// walletExportKey derives the file key from the passphrase.
+func walletExportKey(passphrase string, salt []byte, logN int) ([]byte, error) {
+	if logN < 10 || logN > 22 {
+		return nil, fmt.Errorf("unsupported scrypt cost 2^%d", logN)
+	}
+	return scrypt.Key([]byte(passphrase), salt, 1<<logN, 8, 1, chacha20poly1305.KeySize)
+}
+
+// sealWalletExport encrypts an export with a passphrase.
+func sealWalletExport(exp WalletExport, passphrase string) ([]byte, error) {
+	if passphrase == "" {
+		return nil, fmt.Errorf("empty passphrase")
+	}
+	plaintext, err := json.Marshal(exp)
+	if err != nil {
+		return nil, err
+	}
+	salt := make([]byte, 16)
+	nonce := make([]byte, chacha20poly1305.NonceSizeX)
+	if _, err := rand.Read(salt); err != nil {
+		return nil, err
+	}
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	key, err := walletExportKey(passphrase, salt, walletExportLogN)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := chacha20poly1305.NewX(key)
+	if err != nil {
+		return nil, err
+	}
+	return json.MarshalIndent(walletExportFile{
+		Type:       "nihao-wallet-export",
+		Version:    1,
+		LogN:       walletExportLogN,
+		Salt:       base64.StdEncoding.EncodeToString(salt),
+		Nonce:      base64.StdEncoding.EncodeToString(nonce),
+		Ciphertext: base64.StdEncoding.EncodeToString(aead.Seal(nil, nonce, plaintext, nil)),
+	}, "", "  ")
+}
+
+// openWalletExport decrypts an export file.
+func openWalletExport(data []byte, passphrase string) (WalletExport, error) {
+	var exp WalletExport
+	var f walletExportFile
+	if err := json.Unmarshal(data, &f); err != nil || f.Type != "nihao-wallet-export" {
+		return exp, fmt.Errorf("not a nihao wallet export")
+	}
+	if f.Version != 1 {
+		return exp, fmt.Errorf("unsupported wallet export version %d", f.Version)
+	}
+	salt, err1 := base64.StdEncoding.DecodeString(f.Salt)
+	nonce, err2 := base64.StdEncoding.DecodeString(f.Nonce)
+	ciphertext, err3 := base64.StdEncoding.DecodeString(f.Ciphertext)
+	if err1 != nil || err2 != nil || err3 != nil || len(nonce) != chacha20poly1305.NonceSizeX {
+		return exp, fmt.Errorf("corrupt wallet export")
+	}
+	key, err := walletExportKey(passphrase, salt, f.LogN)
+	if err != nil {
+		return exp, err
+	}
+	aead, err := chacha20poly1305.NewX(key)
+	if err != nil {
+		return exp, err
+	}
+	plaintext, err := aead.Open(nil, nonce, ciphertext, nil)
+	if err != nil {
+		return exp, fmt.Errorf("decryption failed (wrong passphrase?)")
+	}
+	if err := json.Unmarshal(plaintext, &exp); err != nil {
+		return exp, fmt.Errorf("corrupt wallet export: %w", err)
+	}
+	return exp, nil
+}
+
+// walletExport collects a loaded wallet's unspent tokens.
+func walletExport(w *nip60.Wallet, pk nostr.PubKey, now time.Time) WalletExport {
+	exp := WalletExport{
+		Npub:       nip19.EncodeNpub(pk),
+		ExportedAt: now.UTC().Format(time.RFC3339),
+		Mints:      w.Mints,
+		Tokens:     []ExportedToken{},
+	}
+	if w.PrivateKey != nil {
+		exp.PrivKey = nostr.HexEncodeToString(w.PrivateKey.Serialize())
+	}
+	for _, t := range w.Tokens {
+		if len(t.Proofs) == 0 {
+			continue
+		}
+		amount := t.Proofs.Amount()
+		et := ExportedToken{Mint: t.Mint, Amount: amount, Token: nip60.MakeTokenString(t.Proofs, t.Mint)}
+		if _, err := nostr.IDFromHex(t.ID()); err == nil {
+			et.Event = t.ID()
+		}
+		exp.Tokens = append(exp.Tokens, et)
+		exp.Balance += amount
+	}
+	return exp
+}
+
+// runWalletExport saves the NIP-60 wallet's proofs and key to a local file
+// encrypted with a passphrase, so the sats survive relays dropping the
+// token events. With --decrypt it reads such a file back.
+func runWalletExport(args []string) {
+	opts := parseWalletFlags(args)
+	logln := func(a ...any) {
+		if !opts.jsonOutput && !opts.quiet {
+			fmt.Println(a...)
+		}
+	}
+	if opts.decrypt != "" {
+		runWalletExportDecrypt(opts)
+		return
+	}
+	if len(opts.args) > 0 || !opts.keys.isSet() {
+		fatal("usage: nihao wallet export [--output <file>] (--sec|--stdin|--sec-cmd ...), or nihao wallet export --decrypt <file>")
+	}
+	kr, _, err := opts.keys.signer(context.Background())
+	if err != nil {
+		fatal("%s", err)
+	}
+	pk := signerPubkey(context.Background(), kr)
+	npub := nip19.EncodeNpub(pk)
+	output := opts.output
+	if output == "" {
+		output = fmt.Sprintf("nihao-wallet-%s-%s.json", npub[:16], time.Now().Format("2006-01-02"))
+	}
+	logln(fmt.Sprintf("nihao wallet 🗄️  export %s", npub))
+	logln()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Minute)
+	defer cancel()
+
+	logln("🔍 Loading wallet...")
+	w, pool := openWallet(ctx, kr, opts)
+	pool.Close()
+	exp := walletExport(w, pk, time.Now())
+	logln(fmt.Sprintf("   %d sats in %d tokens across %d mint(s)", exp.Balance, len(exp.Tokens), len(exp.Mints)))
+	logln()
+
+	passphrase, err := readPassphrase(opts.keys.passphraseFD, true)
+	if err != nil {
+		fatal("%s", err)
+	}
+	data, err := sealWalletExport(exp, passphrase)
+	if err != nil {
+		fatal("%s", err)
+	}
+	if err := writeNsecFile(output, string(data)); err != nil {
+		fatal("%s", err)
+	}
+
+	if opts.jsonOutput {
+		out, _ := json.MarshalIndent(WalletExportResult{Npub: npub, File: output, Balance: exp.Balance, Tokens: len(exp.Tokens)}, "", "  ")
+		fmt.Println(string(out))
+		return
+	}
+	logln(fmt.Sprintf("✅ Saved %d sats to %s (encrypted, mode 0600)", exp.Balance, output))
+	logln("   Keep it somewhere other than this machine. To recover, run nihao wallet export --decrypt " + output)
+	logln("   and redeem each token with nihao wallet receive. Spent proofs in an old export are worthless.")
+}
+
+// runWalletExportDecrypt prints the tokens in an export file.
+func runWalletExportDecrypt(opts walletOpts) {
+	data, err := os.ReadFile(opts.decrypt)
+	if err != nil {
+		fatal("%s", err)
+	}
+	passphrase, err := readPassphrase(opts.keys.passphraseFD, false)
+	if err != nil {
+		fatal("%s", err)
+	}
+	exp, err := openWalletExport(data, passphrase)
+	if err != nil {
+		fatal("%s: %s", opts.decrypt, err)
+	}
+	if opts.jsonOutput {
+		out, _ := json.MarshalIndent(exp, "", "  ")
+		fmt.Println(string(out))
+		return
+	}
+	if opts.quiet {
+		for _, t := range exp.Tokens {
+			fmt.Println(t.Token)
+		}
+		return
+	}
+	fmt.Printf("nihao wallet 🗄️  %s, exported %s\n\n", exp.Npub, exp.ExportedAt)
+	for _, t := range exp.Tokens {
+		fmt.Printf("💰 %d sats at %s\n   %s\n\n", t.Amount, t.Mint, t.Token)
+	}
+	if exp.PrivKey != "" {
+		fmt.Printf("🔑 Wallet P2PK key: %s\n\n", exp.PrivKey)
+	}
+	fmt.Printf("%d sats in %d tokens. Redeem each with nihao wallet receive <token>.\n", exp.Balance, len(exp.Tokens))
+}
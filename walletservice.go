@@ -0,0 +1,144 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"fiatjaf.com/nostr"
+)
+
+// WalletServiceInfo is what check found of a NIP-47 wallet service tied
+// to the identity: one it runs itself, or the one its stored NWC
+// connection points at.
+type WalletServiceInfo struct {
+	Advertised   bool     `json:"advertised"`        // the identity publishes kind 13194 itself
+	Stored       bool     `json:"stored_connection"` // an encrypted NWC connection in kind 30078
+	Verified     bool     `json:"verified"`          // the stored connection was decrypted and probed
+	WalletPubkey string   `json:"wallet_pubkey,omitempty"`
+	Relays       []string `json:"relays,omitempty"`
+	Reachable    bool     `json:"reachable"`
+	Methods      []string `json:"methods,omitempty"`
+	Error        string   `json:"error,omitempty"`
+}
+
+// PaymentsReadiness groups the ways an identity can be paid, by the
+// status of the check behind each.
+type PaymentsReadiness struct {
+	Lightning string `json:"lightning"` // lud16
+	Nutzaps   string `json:"nutzaps"`   // nutzap_info, else nip60_wallet
+	NWC       string `json:"nwc"`
+	Ready     bool   `json:"ready"` // can receive by lightning or nutzap
+}
+
+// checkWalletService looks for a NIP-47 wallet service: a kind 13194 info
+// event from the identity itself, else the NWC connection nihao setup
+// stores. That one is encrypted, so its wallet service is only probed when
+// check was given the identity's key.
+func checkWalletService(ctx context.Context, result *CheckResult, checkRelays []checkRelay, pk nostr.PubKey) {
+	info := &WalletServiceInfo{}
+	if src, evt := fetchKindFrom(ctx, checkRelays, pk, 13194); evt != nil {
+		info.Advertised = true
+		info.WalletPubkey = pk.Hex()
+		info.Relays = []string{src}
+		info.Reachable = true
+		info.Methods = strings.Fields(evt.Content)
+	} else if _, evt := fetchLatestFrom(ctx, checkRelays, nostr.Filter{
+		Authors: []nostr.PubKey{pk},
+		Kinds:   []nostr.Kind{30078},
+		Tags:    nostr.TagMap{"d": []string{nwcAppDataTag}},
+		Limit:   1,
+	}); evt != nil {
+		info.Stored = true
+		if cipher := identityCipher(checkRelays, pk); cipher != nil {
+			probeStoredNWC(ctx, info, cipher, pk, evt.Content)
+		}
+	}
+
+	status, detail := assessWalletService(*info)
+	result.addCheck("nwc", status, detail)
+	result.NWC = info
+}
+
+// identityCipher returns the key check was given, if it's pk's and can
+// decrypt.
+func identityCipher(checkRelays []checkRelay, pk nostr.PubKey) nostr.Cipher {
+	if len(checkRelays) == 0 || checkRelays[0].auth.signer == nil {
+		return nil
+	}
+	signer := checkRelays[0].auth.signer
+	cipher, ok := signer.(nostr.Cipher)
+	if !ok {
+		return nil
+	}
+	if own, err := signer.GetPublicKey(context.Background()); err != nil || own != pk {
+		return nil
+	}
+	return cipher
+}
+
+// probeStoredNWC decrypts a stored NWC connection and checks that its
+// wallet service answers on its relays.
+func probeStoredNWC(ctx context.Context, info *WalletServiceInfo, cipher nostr.Cipher, pk nostr.PubKey, ciphertext string) {
+	info.Verified = true
+	uri, err := cipher.Decrypt(ctx, ciphertext, pk)
+	if err != nil {
+		info.Error = "could not decrypt the stored connection"
+		return
+	}
+	conn, err := parseNWCURI(uri)
+	if err != nil {
+		info.Error = err.Error()
+		return
+	}
+	info.WalletPubkey = conn.WalletPubkey.Hex()
+	info.Relays = conn.Relays
+	methods, err := validateNWC(ctx, conn)
+	if err != nil {
+		info.Error = err.Error()
+		return
+	}
+	info.Reachable = true
+	info.Methods = methods
+}
+
+// assessWalletService rates what checkWalletService found. NWC isn't
+// needed to be paid, so its absence is only a warning.
+func assessWalletService(info WalletServiceInfo) (string, string) {
+	methods := ""
+	if len(info.Methods) > 0 {
+		methods = ": " + truncateList(info.Methods, 4)
+	}
+	switch {
+	case info.Advertised:
+		return "pass", fmt.Sprintf("runs a wallet service (kind 13194 on %s)%s", strings.Join(info.Relays, ", "), methods)
+	case info.Stored && info.Reachable:
+		return "pass", fmt.Sprintf("wallet service %s… live on %s%s", truncHex(info.WalletPubkey), strings.Join(info.Relays, ", "), methods)
+	case info.Stored && info.Verified:
+		return "warn", "stored NWC connection, but " + info.Error
+	case info.Stored:
+		return "pass", "encrypted NWC connection stored (give your key to check its wallet service)"
+	}
+	return "warn", "no NWC wallet service found"
+}
+
+// assessPayments groups the payment checks into one readiness summary.
+func assessPayments(r CheckResult) PaymentsReadiness {
+	p := PaymentsReadiness{Lightning: r.status("lud16"), Nutzaps: r.status("nutzap_info"), NWC: r.status("nwc")}
+	if p.Nutzaps == "" {
+		p.Nutzaps = r.status("nip60_wallet")
+	}
+	p.Ready = p.Lightning == "pass" || p.Nutzaps == "pass"
+	return p
+}
+
+func printPaymentsReadiness(p PaymentsReadiness) {
+	statusIcon := map[string]string{"pass": "✅", "warn": "⚠️", "fail": "❌", "": "—"}
+	verdict := "can't receive payments yet"
+	if p.Ready {
+		verdict = "ready to receive"
+	}
+	fmt.Println()
+	fmt.Printf("  💸 Payments: lightning %s · nutzaps %s · NWC %s — %s\n",
+		statusIcon[p.Lightning], statusIcon[p.Nutzaps], statusIcon[p.NWC], verdict)
+}
@@ -0,0 +1,201 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"fiatjaf.com/nostr"
+	"fiatjaf.com/nostr/nip19"
+	"fiatjaf.com/nostr/nip60"
+)
+
+// secCmdEnv names a command that prints the user's secret key, for
+// commands like whoami that work on "my" identity without key flags.
+const secCmdEnv = "NIHAO_SEC_CMD"
+
+// WhoamiResult is the JSON output of `nihao whoami`.
+type WhoamiResult struct {
+	Npub     string           `json:"npub"`
+	Pubkey   string           `json:"pubkey"`
+	Key      string           `json:"key"` // where the key came from
+	Profile  *ProfileMetadata `json:"profile,omitempty"`
+	Relays   []MarkedRelay    `json:"relays"`
+	DMRelays []string         `json:"dm_relays"`
+	Wallet   *WhoamiWallet    `json:"wallet,omitempty"`
+}
+
+// WhoamiWallet summarizes the NIP-60 wallet.
+type WhoamiWallet struct {
+	Mints   []string `json:"mints"`
+	Balance uint64   `json:"balance"` // sats
+	Nutzaps bool     `json:"nutzaps"` // kind 10019 published
+}
+
+// storedKeySource fills in the key from NIHAO_SEC_CMD when no key flag
+// was given. The command runs through the shell, like --sec-cmd.
+func storedKeySource(keys keySource) keySource {
+	if keys.isSet() {
+		return keys
+	}
+	if cmd := strings.TrimSpace(os.Getenv(secCmdEnv)); cmd != "" {
+		keys.secCmd.shell = cmd
+	}
+	return keys
+}
+
+// runWhoami shows the identity behind the configured key by fetching its
+// own events: profile, relay lists, and wallet.
+func runWhoami(args []string) {
+	opts := parseIdentityFlags(args)
+	logln := func(a ...any) {
+		if !opts.jsonOutput && !opts.quiet {
+			fmt.Println(a...)
+		}
+	}
+	if len(opts.args) > 0 {
+		fatal("usage: nihao whoami [--sec|--stdin|--sec-cmd ...] (or set %s)", secCmdEnv)
+	}
+
+	fromEnv := !opts.keys.isSet()
+	opts.keys = storedKeySource(opts.keys)
+	if !opts.keys.isSet() {
+		fatal("no key configured: pass --sec, --stdin, --sec-cmd, or --bunker, or set %s (e.g. %s='pass show nostr/nsec')", secCmdEnv, secCmdEnv)
+	}
+	signer, source, err := opts.keys.signer(context.Background())
+	if err != nil {
+		fatal("%s", err)
+	}
+	if fromEnv {
+		source = "secret key from $" + secCmdEnv
+	}
+	pk := signerPubkey(context.Background(), signer)
+	result := WhoamiResult{Npub: nip19.EncodeNpub(pk), Pubkey: pk.Hex(), Key: source, Relays: []MarkedRelay{}, DMRelays: []string{}}
+
+	readRelays := opts.relays
+	if len(readRelays) == 0 {
+		readRelays = defaultRelays
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
+	defer cancel()
+	checkRelays := connectCheckRelays(ctx, readRelays)
+	if len(checkRelays) == 0 {
+		fatal("could not connect to any relay")
+	}
+	defer func() {
+		for _, cr := range checkRelays {
+			cr.relay.Close()
+		}
+	}()
+
+	events := make(map[int]*nostr.Event)
+	for _, kind := range []int{0, 10002, 10050, 10019} {
+		_, events[kind] = fetchKindFrom(ctx, checkRelays, pk, kind)
+	}
+	if evt := events[0]; evt != nil {
+		var profile ProfileMetadata
+		if json.Unmarshal([]byte(evt.Content), &profile) == nil {
+			result.Profile = &profile
+		}
+	}
+	urls := readRelays
+	if evt := events[10002]; evt != nil {
+		for _, tag := range evt.Tags {
+			if len(tag) >= 2 && tag[0] == "r" {
+				mr := MarkedRelay{URL: tag[1]}
+				if len(tag) >= 3 {
+					mr.Marker = RelayMarker(tag[2])
+				}
+				result.Relays = append(result.Relays, mr)
+			}
+		}
+		urls = mergeRelayURLs(readRelays, writeRelays(evt))
+	}
+	if evt := events[10050]; evt != nil {
+		for _, tag := range evt.Tags {
+			if len(tag) >= 2 && tag[0] == "relay" {
+				result.DMRelays = append(result.DMRelays, tag[1])
+			}
+		}
+	}
+
+	// The wallet is encrypted to the key, so only its owner can summarize it
+	loader := nostr.NewPool(nostr.PoolOptions{AuthRequiredHandler: signer.SignEvent})
+	w := nip60.LoadWallet(ctx, signer, loader, urls, nip60.WalletOptions{})
+	select {
+	case <-w.Stable:
+	case <-ctx.Done():
+	}
+	if w.PrivateKey != nil {
+		result.Wallet = &WhoamiWallet{Mints: w.Mints, Balance: w.Balance(), Nutzaps: events[10019] != nil}
+	}
+	loader.Close("whoami done")
+
+	if opts.jsonOutput {
+		out, _ := json.MarshalIndent(result, "", "  ")
+		fmt.Println(string(out))
+		return
+	}
+	printWhoami(result, logln)
+}
+
+func printWhoami(r WhoamiResult, logln func(a ...any)) {
+	logln(fmt.Sprintf("nihao whoami 👤 %s", r.Npub))
+	logln(fmt.Sprintf("   Key:        %s", r.Key))
+	logln()
+
+	if p := r.Profile; p != nil {
+		name := p.Name
+		if p.DisplayName != "" && p.DisplayName != p.Name {
+			name = fmt.Sprintf("%s (%s)", p.DisplayName, p.Name)
+		}
+		logln(fmt.Sprintf("   Name:       %s", valueOr(name, "—")))
+		logln(fmt.Sprintf("   NIP-05:     %s", valueOr(p.NIP05, "—")))
+		logln(fmt.Sprintf("   Lightning:  %s", valueOr(p.LUD16, "—")))
+		if p.About != "" {
+			about := strings.Join(strings.Fields(p.About), " ")
+			if runes := []rune(about); len(runes) > 80 {
+				about = string(runes[:80]) + "…"
+			}
+			logln(fmt.Sprintf("   About:      %s", about))
+		}
+	} else {
+		logln("   Profile:    none found (kind 0) — run nihao setup")
+	}
+	logln()
+
+	if len(r.Relays) == 0 {
+		logln("   Relays:     none found (kind 10002)")
+	} else {
+		logln(fmt.Sprintf("   Relays:     %d", len(r.Relays)))
+		for _, mr := range r.Relays {
+			logln(fmt.Sprintf("     %s (%s)", mr.URL, markerName(mr.Marker)))
+		}
+	}
+	logln(fmt.Sprintf("   DM relays:  %s", valueOr(strings.Join(r.DMRelays, ", "), "none (kind 10050)")))
+	logln()
+
+	if w := r.Wallet; w != nil {
+		nutzaps := "nutzaps on"
+		if !w.Nutzaps {
+			nutzaps = "no nutzap info (kind 10019)"
+		}
+		logln(fmt.Sprintf("   Wallet:     %d sats across %d mint(s), %s", w.Balance, len(w.Mints), nutzaps))
+		for _, m := range w.Mints {
+			logln(fmt.Sprintf("     %s", m))
+		}
+	} else {
+		logln("   Wallet:     none (NIP-60)")
+	}
+}
+
+// valueOr returns s, or fallback if s is empty.
+func valueOr(s, fallback string) string {
+	if s == "" {
+		return fallback
+	}
+	return s
+}
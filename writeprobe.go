@@ -0,0 +1,84 @@
+package main
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"fiatjaf.com/nostr"
+)
+
+// writeProbeKind is the ephemeral kind (20000–29999) write probes use.
+// Relays relay ephemeral events to live subscribers and don't store them,
+// so probing leaves nothing behind.
+const writeProbeKind = 20999
+
+// ephemeralRefusals are OK-message prefixes that refuse the event rather
+// than the author: the relay might still take a regular event. Anything
+// else (restricted, auth-required, pow, rate-limited, ...) is about who's
+// writing and would refuse a regular event just the same.
+var ephemeralRefusals = []string{"blocked:", "invalid:", "error:", "unsupported:"}
+
+// probeWrite publishes an event from a throwaway key to see whether the
+// relay takes writes from a stranger. It tries an ephemeral event first and
+// falls back to a kind 1 that expires after testEventTTL (NIP-40) for
+// relays that refuse ephemeral kinds. via is "ephemeral" or "expiring".
+func probeWrite(ctx context.Context, relayURL string) (ok bool, via string, reason string) {
+	timeout := 5 * time.Second
+	if deadline, ok := ctx.Deadline(); ok {
+		timeout = min(timeout, time.Until(deadline))
+	}
+	relay, err := connectRelay(relayURL, timeout)
+	if err != nil {
+		return false, "", "unreachable"
+	}
+	defer relay.Close()
+
+	sk := nostr.Generate()
+	ctx, cancel := context.WithTimeout(ctx, 8*time.Second)
+	defer cancel()
+
+	probe := nostr.Event{
+		CreatedAt: nostr.Now(),
+		Kind:      writeProbeKind,
+		Content:   "nihao relay write probe",
+	}
+	probe.Sign(sk)
+	// "mute:" means the relay took it but no one was subscribed (NIP-01)
+	err = relay.Publish(ctx, probe)
+	if err == nil || strings.HasPrefix(err.Error(), "msg: mute:") {
+		return true, "ephemeral", ""
+	}
+	if !refusesEphemeral(err.Error()) {
+		return false, "", err.Error()
+	}
+
+	probe = nostr.Event{
+		CreatedAt: nostr.Now(),
+		Kind:      1,
+		Tags:      nostr.Tags{expirationTag(time.Now(), testEventTTL)},
+		Content:   "nihao relay write probe (expires in " + formatAge(testEventTTL) + ")",
+	}
+	probe.Sign(sk)
+	if err := relay.Publish(ctx, probe); err != nil {
+		return false, "", err.Error()
+	}
+	return true, "expiring", ""
+}
+
+// refusesEphemeral reports whether a publish error is the relay refusing
+// the event kind rather than the author. Refusals without a prefix count
+// too: some relays just say "ephemeral events not allowed". Timeouts and
+// dropped connections don't.
+func refusesEphemeral(err string) bool {
+	msg, ok := strings.CutPrefix(err, "msg: ")
+	if !ok {
+		return false
+	}
+	for _, prefix := range ephemeralRefusals {
+		if strings.HasPrefix(msg, prefix) {
+			return true
+		}
+	}
+	return !strings.Contains(msg, ":") || strings.Contains(msg, "ephemeral") || strings.Contains(msg, "kind")
+}
@@ -0,0 +1,190 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"fiatjaf.com/nostr"
+	"github.com/btcsuite/btcd/btcutil/bech32"
+)
+
+// zapReceiptLimit caps how many receipts are fetched each way. Enough to
+// tell "works" from "broken" without downloading a popular npub's history.
+const zapReceiptLimit = 50
+
+// ZapActivity summarizes the NIP-57 zap receipts (kind 9735) that mention
+// an identity as recipient ("p") or sender ("P").
+type ZapActivity struct {
+	Received        int      `json:"received"`
+	Sent            int      `json:"sent"`
+	InvalidReceived int      `json:"invalid_received"`
+	ReceivedSats    uint64   `json:"received_sats"`
+	Problems        []string `json:"problems,omitempty"`
+}
+
+// bolt11Invoice holds the parts of a BOLT-11 invoice a zap receipt is
+// checked against.
+type bolt11Invoice struct {
+	MSats           uint64 // 0 if the invoice doesn't fix an amount
+	DescriptionHash string // hex, "" if the invoice has no "h" field
+}
+
+// decodeBolt11 reads the amount and description hash of a BOLT-11
+// invoice. The signature isn't verified: the point is consistency with
+// the zap request, not proving who issued the invoice.
+func decodeBolt11(invoice string) (bolt11Invoice, error) {
+	var inv bolt11Invoice
+	hrp, data, err := bech32.DecodeNoLimit(strings.ToLower(strings.TrimSpace(invoice)))
+	if err != nil {
+		return inv, fmt.Errorf("invalid bolt11: %w", err)
+	}
+	if !strings.HasPrefix(hrp, "ln") {
+		return inv, fmt.Errorf("invalid bolt11: prefix %q", hrp)
+	}
+
+	// Amount: ln<currency><digits><multiplier>, e.g. lnbc2500u
+	amount := strings.TrimLeft(hrp[2:], "abcdefghijklmnopqrstuvwxyz")
+	if amount != "" {
+		multiplier := amount[len(amount)-1]
+		if multiplier >= '0' && multiplier <= '9' {
+			multiplier = 0
+		} else {
+			amount = amount[:len(amount)-1]
+		}
+		n, err := strconv.ParseUint(amount, 10, 64)
+		if err != nil {
+			return inv, fmt.Errorf("invalid bolt11 amount %q", hrp)
+		}
+		switch multiplier {
+		case 0:
+			inv.MSats = n * 100_000_000_000
+		case 'm':
+			inv.MSats = n * 100_000_000
+		case 'u':
+			inv.MSats = n * 100_000
+		case 'n':
+			inv.MSats = n * 100
+		case 'p':
+			inv.MSats = n / 10
+		default:
+			return inv, fmt.Errorf("invalid bolt11 multiplier %q", multiplier)
+		}
+	}
+
+	// 7 groups of timestamp, then tagged fields, then a 104-group signature
+	const timestampLen, signatureLen = 7, 104
+	if len(data) < timestampLen+signatureLen {
+		return inv, fmt.Errorf("invalid bolt11: too short")
+	}
+	fields := data[timestampLen : len(data)-signatureLen]
+	for i := 0; i+3 <= len(fields); {
+		tag := fields[i]
+		length := int(fields[i+1])<<5 | int(fields[i+2])
+		if i+3+length > len(fields) {
+			return inv, fmt.Errorf("invalid bolt11: truncated field")
+		}
+		if tag == 23 && length == 52 { // "h": sha256 of the description
+			hash, err := bech32.ConvertBits(fields[i+3:i+3+length], 5, 8, false)
+			if err == nil {
+				inv.DescriptionHash = hex.EncodeToString(hash)
+			}
+		}
+		i += 3 + length
+	}
+	return inv, nil
+}
+
+// verifyZapReceipt checks that a kind 9735 is internally consistent: it
+// embeds a signed kind 9734 zap request, its invoice commits to that
+// request (description hash), and the invoice amount matches the amount
+// the zapper asked for.
+func verifyZapReceipt(receipt nostr.Event) error {
+	desc := receipt.Tags.Find("description")
+	if desc == nil {
+		return fmt.Errorf("no description tag")
+	}
+	var request nostr.Event
+	if err := json.Unmarshal([]byte(desc[1]), &request); err != nil {
+		return fmt.Errorf("description isn't a zap request")
+	}
+	if request.Kind != 9734 {
+		return fmt.Errorf("description is kind %d, not a 9734 zap request", request.Kind)
+	}
+	if !request.VerifySignature() {
+		return fmt.Errorf("zap request has a bad signature")
+	}
+
+	bolt11 := receipt.Tags.Find("bolt11")
+	if bolt11 == nil {
+		return fmt.Errorf("no bolt11 tag")
+	}
+	inv, err := decodeBolt11(bolt11[1])
+	if err != nil {
+		return err
+	}
+	sum := sha256.Sum256([]byte(desc[1]))
+	if inv.DescriptionHash != hex.EncodeToString(sum[:]) {
+		return fmt.Errorf("bolt11 description hash doesn't match the zap request")
+	}
+	if tag := request.Tags.Find("amount"); tag != nil && inv.MSats != 0 {
+		if want, err := strconv.ParseUint(tag[1], 10, 64); err == nil && want != inv.MSats {
+			return fmt.Errorf("bolt11 is for %d msats, zap request asked for %d", inv.MSats, want)
+		}
+	}
+	return nil
+}
+
+// assessZapActivity verifies received receipts and rates the identity's
+// zap history. Sent receipts are only counted: their consistency is the
+// recipients' zapper's business.
+func assessZapActivity(received, sent []nostr.Event) (string, string, ZapActivity) {
+	zaps := ZapActivity{Received: len(received), Sent: len(sent)}
+	for _, r := range received {
+		if err := verifyZapReceipt(r); err != nil {
+			zaps.InvalidReceived++
+			if len(zaps.Problems) < 3 {
+				zaps.Problems = append(zaps.Problems, err.Error())
+			}
+			continue
+		}
+		if inv, err := decodeBolt11(r.Tags.Find("bolt11")[1]); err == nil {
+			zaps.ReceivedSats += inv.MSats / 1000
+		}
+	}
+
+	detail := fmt.Sprintf("%d received (%d sats), %d sent", zaps.Received, zaps.ReceivedSats, zaps.Sent)
+	switch {
+	case zaps.Received == 0 && zaps.Sent == 0:
+		return "warn", "no zap receipts (kind 9735) found — lightning setup unproven", zaps
+	case zaps.Received > 0 && zaps.InvalidReceived == zaps.Received:
+		return "fail", fmt.Sprintf("%s — every received receipt is inconsistent: %s", detail, strings.Join(zaps.Problems, "; ")), zaps
+	case zaps.InvalidReceived > 0:
+		return "warn", fmt.Sprintf("%s — %d inconsistent: %s", detail, zaps.InvalidReceived, strings.Join(zaps.Problems, "; ")), zaps
+	case zaps.Received == 0:
+		return "warn", detail + " — never received a zap", zaps
+	}
+	return "pass", detail, zaps
+}
+
+// checkZapActivity fetches receipts naming pk as recipient or sender and
+// adds a "zap_activity" check.
+func checkZapActivity(ctx context.Context, result *CheckResult, relays []checkRelay, pk nostr.PubKey) {
+	received := fetchAllFrom(ctx, relays, nostr.Filter{
+		Kinds: []nostr.Kind{9735},
+		Tags:  nostr.TagMap{"p": []string{pk.Hex()}},
+		Limit: zapReceiptLimit,
+	})
+	sent := fetchAllFrom(ctx, relays, nostr.Filter{
+		Kinds: []nostr.Kind{9735},
+		Tags:  nostr.TagMap{"P": []string{pk.Hex()}},
+		Limit: zapReceiptLimit,
+	})
+	status, detail, zaps := assessZapActivity(received, sent)
+	result.Zaps = &zaps
+	result.addCheck("zap_activity", status, detail)
+}